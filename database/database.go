@@ -20,18 +20,41 @@ func InitDB(dsn string) {
 		utils.ConsoleLog("❌ Erreur de connexion à la base de données : %v", err).Fatal()
 	}
 
-	// Migrations automatiques
+	// Les doublons de username doivent disparaître avant la création de l'index unique sur LOWER(username)
+	if err := dedupUsernames(); err != nil {
+		utils.ConsoleLog("❌ Erreur lors du dédoublonnage des usernames : %v", err).Fatal()
+	}
+
+	// Migrations automatiques (request_logs, partitionnée, est migrée par request_log_service.Migrate)
 	err = DB.AutoMigrate(
 		&models.Team{},
+		&models.TeamRole{},
 		&models.TeamMember{},
+		&models.TeamInvitation{},
+		&models.TeamOwnershipTransfer{},
+		&models.TeamSCIMToken{},
 		&models.User{},
+		&models.TeamDomain{},
+		&models.TeamJoinRequest{},
+		&models.TeamActivity{},
 		&models.UserProfile{},
+		&models.UsernameHistory{},
+		&models.UserStats{},
+		&models.TeamStats{},
+		&models.UserExport{},
+		&models.UserDeletionRequest{},
+		&models.UserPasswordReset{},
+		&models.UserEmailVerification{},
 		&models.UserCredit{},
 		&models.UserCreditHistory{},
 		&models.UserSubscription{},
 		&models.Subscription{},
 		&models.SubscriptionPerks{},
-		&models.RequestLog{},
+		&models.RequestLogArchive{},
+		&models.RequestLogRollup{},
+		&models.RequestLogUserRollup{},
+		&models.RequestLogRollupWatermark{},
+		&models.AuditLog{},
 	)
 	if err != nil {
 		utils.ConsoleLog("❌ Erreur lors des migrations : %v", err).Fatal()
@@ -39,3 +62,24 @@ func InitDB(dsn string) {
 
 	fmt.Println("🚀 Connexion à la base de données établie")
 }
+
+// dedupUsernames renomme les profils dont le username est déjà pris (à la casse et aux espaces près) : le compte le plus ancien
+// garde le username, les autres reçoivent un username généré à partir de leur ID (modifiable ensuite).
+// Les usernames restants sont passés en minuscules. Sans effet une fois l'index unique en place.
+func dedupUsernames() error {
+	if !DB.Migrator().HasTable(&models.UserProfile{}) {
+		return nil
+	}
+
+	return DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Exec(`WITH ranked AS (
+			SELECT p.id, ROW_NUMBER() OVER (PARTITION BY LOWER(TRIM(p.username)) ORDER BY u.created_on, p.id) AS rank
+			FROM user_profiles p LEFT JOIN users u ON u.id = p.customer_id
+		)
+		UPDATE user_profiles SET username = 'user-' || LEFT(REPLACE(user_profiles.customer_id::text, '-', ''), 25)
+		FROM ranked WHERE ranked.id = user_profiles.id AND ranked.rank > 1`).Error; err != nil {
+			return err
+		}
+		return tx.Exec(`UPDATE user_profiles SET username = LOWER(TRIM(username)) WHERE username <> LOWER(TRIM(username))`).Error
+	})
+}
@@ -20,8 +20,116 @@ const (
 	TeamMemberRoleOwner     TeamMemberRole = "owner"
 	TeamMemberRoleAdmin     TeamMemberRole = "admin"
 	TeamMemberRoleSpectator TeamMemberRole = "spectator"
+	// A custom role's permissions are defined by the TeamRole referenced in TeamMember.CustomRoleID
+	TeamMemberRoleCustom TeamMemberRole = "custom"
 )
 
+// IsValid reports whether r is one of the built-in roles (custom roles are assigned separately)
+func (r TeamMemberRole) IsValid() bool {
+	switch r {
+	case TeamMemberRoleOwner, TeamMemberRoleAdmin, TeamMemberRoleSpectator:
+		return true
+	}
+	return false
+}
+
+type TeamPermission string
+
+const (
+	TeamPermissionMembersInvite TeamPermission = "members:invite"
+	TeamPermissionMembersRemove TeamPermission = "members:remove"
+	TeamPermissionMembersUpdate TeamPermission = "members:update"
+	TeamPermissionProductsWrite TeamPermission = "products:write"
+	TeamPermissionBillingRead   TeamPermission = "billing:read"
+	TeamPermissionSettingsWrite TeamPermission = "settings:write"
+)
+
+var AllTeamPermissions = []TeamPermission{
+	TeamPermissionMembersInvite,
+	TeamPermissionMembersRemove,
+	TeamPermissionMembersUpdate,
+	TeamPermissionProductsWrite,
+	TeamPermissionBillingRead,
+	TeamPermissionSettingsWrite,
+}
+
+func (p TeamPermission) IsValid() bool {
+	for _, perm := range AllTeamPermissions {
+		if p == perm {
+			return true
+		}
+	}
+	return false
+}
+
+type TeamInvitationStatus string
+
+const (
+	TeamInvitationStatusPending  TeamInvitationStatus = "pending"
+	TeamInvitationStatusAccepted TeamInvitationStatus = "accepted"
+	TeamInvitationStatusDeclined TeamInvitationStatus = "declined"
+	TeamInvitationStatusRevoked  TeamInvitationStatus = "revoked"
+)
+
+type TeamDomainJoinPolicy string
+
+const (
+	TeamDomainJoinPolicyAuto    TeamDomainJoinPolicy = "auto"
+	TeamDomainJoinPolicyRequest TeamDomainJoinPolicy = "request"
+)
+
+func (p TeamDomainJoinPolicy) IsValid() bool {
+	return p == TeamDomainJoinPolicyAuto || p == TeamDomainJoinPolicyRequest
+}
+
+type TeamJoinRequestStatus string
+
+const (
+	TeamJoinRequestStatusPending  TeamJoinRequestStatus = "pending"
+	TeamJoinRequestStatusApproved TeamJoinRequestStatus = "approved"
+	TeamJoinRequestStatusRejected TeamJoinRequestStatus = "rejected"
+)
+
+type OwnershipTransferStatus string
+
+const (
+	OwnershipTransferStatusPending   OwnershipTransferStatus = "pending"
+	OwnershipTransferStatusAccepted  OwnershipTransferStatus = "accepted"
+	OwnershipTransferStatusDeclined  OwnershipTransferStatus = "declined"
+	OwnershipTransferStatusCancelled OwnershipTransferStatus = "cancelled"
+)
+
+type UserExportStatus string
+
+const (
+	UserExportStatusPending    UserExportStatus = "pending"
+	UserExportStatusProcessing UserExportStatus = "processing"
+	UserExportStatusReady      UserExportStatus = "ready"
+	UserExportStatusFailed     UserExportStatus = "failed"
+	UserExportStatusExpired    UserExportStatus = "expired"
+)
+
+type UserDeletionStatus string
+
+const (
+	UserDeletionStatusPending   UserDeletionStatus = "pending"
+	UserDeletionStatusCancelled UserDeletionStatus = "cancelled"
+	UserDeletionStatusCompleted UserDeletionStatus = "completed"
+)
+
+// Sort d'une Team company dont l'utilisateur supprimé est le seul owner
+type TeamResolutionAction string
+
+const (
+	TeamResolutionTransfer TeamResolutionAction = "transfer"
+	TeamResolutionArchive  TeamResolutionAction = "archive"
+)
+
+type TeamResolution struct {
+	Action     TeamResolutionAction `json:"action"`
+	ToMemberID *uuid.UUID           `json:"to_member_id,omitempty"`
+}
+
 type CreditOperationType string
 
 const (
@@ -37,40 +145,278 @@ const (
 )
 
 type Team struct {
-	ID           uuid.UUID `gorm:"type:uuid;default:gen_random_uuid();primaryKey"`
-	Type         TeamType  `gorm:"not null"`
-	Name         string    `gorm:"not null"`
-	IsAccessible bool      `gorm:"default:true"`
+	ID           uuid.UUID  `gorm:"type:uuid;default:gen_random_uuid();primaryKey"`
+	Type         TeamType   `gorm:"not null"`
+	Name         string     `gorm:"not null"`
+	IsAccessible bool       `gorm:"default:true"`
+	ArchivedAt   *time.Time `gorm:"index;default:null"`
+	ParentID     *uuid.UUID `gorm:"type:uuid;index;default:null"`
+	Parent       *Team      `gorm:"foreignKey:ParentID;constraint:OnUpdate:CASCADE;OnDelete:SET NULL;"`
+}
+
+func (t Team) IsArchived() bool {
+	return t.ArchivedAt != nil
 }
+
 type TeamMember struct {
-	ID           uint           `gorm:"primaryKey;autoIncrement"`
-	MemberID     uuid.UUID      `gorm:"index;not null"`
-	Member       User           `gorm:"foreignKey:MemberID;constraint:OnUpdate:CASCADE;OnDelete:SET NULL;"`
-	TeamID       uuid.UUID      `gorm:"index;not null"`
-	Team         Team           `gorm:"foreignKey:TeamID;constraint:OnUpdate:CASCADE;OnDelete:CASCADE;"`
-	Role         TeamMemberRole `gorm:"not null"`
-	IsActive     bool           `gorm:"default:true"`
-	IsAccessible bool           `gorm:"default:true"`
+	ID            uint           `gorm:"primaryKey;autoIncrement"`
+	MemberID      uuid.UUID      `gorm:"index;not null"`
+	Member        User           `gorm:"foreignKey:MemberID;constraint:OnUpdate:CASCADE;OnDelete:SET NULL;"`
+	TeamID        uuid.UUID      `gorm:"index;not null"`
+	Team          Team           `gorm:"foreignKey:TeamID;constraint:OnUpdate:CASCADE;OnDelete:CASCADE;"`
+	Role          TeamMemberRole `gorm:"not null"`
+	CustomRoleID  *uuid.UUID     `gorm:"index;default:null"`
+	CustomRole    *TeamRole      `gorm:"foreignKey:CustomRoleID;constraint:OnUpdate:CASCADE;OnDelete:SET NULL;"`
+	IsActive      bool           `gorm:"default:true"`
+	IsAccessible  bool           `gorm:"default:true"`
+	JoinedAt      time.Time      `gorm:"autoCreateTime;default:CURRENT_TIMESTAMP"`
+	RoleChangedAt *time.Time     `gorm:"default:null"`
+	SuspendedAt   *time.Time     `gorm:"default:null"`
+	LeftAt        *time.Time     `gorm:"index;default:null"`
+	ExternalID    string         `gorm:"index"`
+}
+
+type TeamRole struct {
+	ID          uuid.UUID        `gorm:"type:uuid;default:gen_random_uuid();primaryKey"`
+	TeamID      uuid.UUID        `gorm:"index;not null"`
+	Team        Team             `gorm:"foreignKey:TeamID;constraint:OnUpdate:CASCADE;OnDelete:CASCADE;"`
+	Name        string           `gorm:"not null"`
+	Permissions []TeamPermission `gorm:"type:jsonb;serializer:json;not null"`
+	CreatedOn   time.Time        `gorm:"autoCreateTime"`
+}
+
+type TeamInvitation struct {
+	ID          uuid.UUID            `gorm:"type:uuid;default:gen_random_uuid();primaryKey"`
+	TeamID      uuid.UUID            `gorm:"index;not null"`
+	Team        Team                 `gorm:"foreignKey:TeamID;constraint:OnUpdate:CASCADE;OnDelete:CASCADE;"`
+	Email       string               `gorm:"index;not null"`
+	Role        TeamMemberRole       `gorm:"not null"`
+	InvitedByID *uuid.UUID           `gorm:"index;default:null"`
+	InvitedBy   *User                `gorm:"foreignKey:InvitedByID;constraint:OnUpdate:CASCADE;OnDelete:SET NULL;"`
+	TokenHash   string               `gorm:"uniqueIndex;not null"`
+	Status      TeamInvitationStatus `gorm:"index;not null"`
+	ExpiresAt   time.Time            `gorm:"not null"`
+	CreatedOn   time.Time            `gorm:"autoCreateTime"`
+	RespondedAt *time.Time           `gorm:"default:null"`
+}
+
+type TeamOwnershipTransfer struct {
+	ID           uuid.UUID               `gorm:"type:uuid;default:gen_random_uuid();primaryKey"`
+	TeamID       uuid.UUID               `gorm:"index;not null"`
+	Team         Team                    `gorm:"foreignKey:TeamID;constraint:OnUpdate:CASCADE;OnDelete:CASCADE;"`
+	FromMemberID uuid.UUID               `gorm:"index;not null"`
+	ToMemberID   uuid.UUID               `gorm:"index;not null"`
+	Status       OwnershipTransferStatus `gorm:"index;not null"`
+	CreatedOn    time.Time               `gorm:"autoCreateTime"`
+	RespondedAt  *time.Time              `gorm:"default:null"`
+}
+
+// Un domaine ne peut être vérifié que par une seule Team (index unique partiel sur les domaines vérifiés)
+type TeamDomain struct {
+	ID                uuid.UUID            `gorm:"type:uuid;default:gen_random_uuid();primaryKey"`
+	TeamID            uuid.UUID            `gorm:"index;not null"`
+	Team              Team                 `gorm:"foreignKey:TeamID;constraint:OnUpdate:CASCADE;OnDelete:CASCADE;"`
+	Domain            string               `gorm:"index;not null;uniqueIndex:idx_team_domains_verified,where:verified_at IS NOT NULL"`
+	VerificationToken string               `gorm:"not null"`
+	JoinPolicy        TeamDomainJoinPolicy `gorm:"not null;default:request"`
+	DefaultRole       TeamMemberRole       `gorm:"not null;default:spectator"`
+	CreatedOn         time.Time            `gorm:"autoCreateTime"`
+	VerifiedAt        *time.Time           `gorm:"default:null"`
+}
+
+type TeamJoinRequest struct {
+	ID            uuid.UUID             `gorm:"type:uuid;default:gen_random_uuid();primaryKey"`
+	TeamID        uuid.UUID             `gorm:"index;not null"`
+	Team          Team                  `gorm:"foreignKey:TeamID;constraint:OnUpdate:CASCADE;OnDelete:CASCADE;"`
+	UserID        uuid.UUID             `gorm:"index;not null"`
+	User          User                  `gorm:"foreignKey:UserID;constraint:OnUpdate:CASCADE;OnDelete:CASCADE;"`
+	DomainID      *uuid.UUID            `gorm:"index;default:null"`
+	Domain        *TeamDomain           `gorm:"foreignKey:DomainID;constraint:OnUpdate:CASCADE;OnDelete:SET NULL;"`
+	Status        TeamJoinRequestStatus `gorm:"index;not null"`
+	CreatedOn     time.Time             `gorm:"autoCreateTime"`
+	RespondedAt   *time.Time            `gorm:"default:null"`
+	RespondedByID *uuid.UUID            `gorm:"default:null"`
+}
+
+// Jeton d'accès SCIM : seul le hash est stocké, le jeton n'est affiché qu'à la création
+type TeamSCIMToken struct {
+	ID         uuid.UUID  `gorm:"type:uuid;default:gen_random_uuid();primaryKey"`
+	TeamID     uuid.UUID  `gorm:"index;not null"`
+	Team       Team       `gorm:"foreignKey:TeamID;constraint:OnUpdate:CASCADE;OnDelete:CASCADE;"`
+	Name       string     `gorm:"not null"`
+	TokenHash  string     `gorm:"uniqueIndex;not null"`
+	CreatedOn  time.Time  `gorm:"autoCreateTime"`
+	LastUsedAt *time.Time `gorm:"default:null"`
+	RevokedAt  *time.Time `gorm:"default:null"`
+}
+
+type TeamActivityChange struct {
+	Before any `json:"before"`
+	After  any `json:"after"`
+}
+
+// Journal des événements d'une Team : qui (Actor) a fait quoi (Action) sur quoi (Target), avec le diff avant/après.
+// ActorID est nul pour les actions système (provisioning, tâches planifiées).
+type TeamActivity struct {
+	ID         uuid.UUID                     `gorm:"type:uuid;default:gen_random_uuid();primaryKey"`
+	TeamID     uuid.UUID                     `gorm:"index;not null"`
+	Team       Team                          `gorm:"foreignKey:TeamID;constraint:OnUpdate:CASCADE;OnDelete:CASCADE;"`
+	ActorID    *uuid.UUID                    `gorm:"index;default:null"`
+	Actor      *User                         `gorm:"foreignKey:ActorID;constraint:OnUpdate:CASCADE;OnDelete:SET NULL;"`
+	Action     string                        `gorm:"index;not null"`
+	TargetType string                        `gorm:"index"`
+	TargetID   string                        `gorm:"index"`
+	Changes    map[string]TeamActivityChange `gorm:"type:jsonb;serializer:json"`
+	CreatedOn  time.Time                     `gorm:"autoCreateTime;index"`
 }
 
 type User struct {
-	ID           uuid.UUID `gorm:"type:uuid;default:gen_random_uuid();primaryKey"`
-	Email        string    `gorm:"index;unique"`
-	Password     string    `gorm:"not null"`
-	CreatedOn    time.Time `gorm:"autoCreateTime"`
-	IsAppAdmin   bool      `gorm:"default:false"`
-	IsActive     bool      `gorm:"default:true"`
-	IsAccessible bool      `gorm:"default:true"`
+	ID           uuid.UUID  `gorm:"type:uuid;default:gen_random_uuid();primaryKey"`
+	Email        string     `gorm:"index;unique"`
+	Password     string     `gorm:"not null"`
+	CreatedOn    time.Time  `gorm:"autoCreateTime"`
+	IsAppAdmin   bool       `gorm:"default:false"`
+	IsActive     bool       `gorm:"default:true"`
+	IsAccessible bool       `gorm:"default:true"`
+	AnonymizedAt *time.Time `gorm:"default:null"`
+
+	// Date à laquelle l'utilisateur a prouvé posséder son email (nil tant que le lien n'a pas été ouvert)
+	EmailVerifiedAt *time.Time `gorm:"default:null"`
+
+	// Révocation des sessions (désactivation, réinitialisation du mot de passe…) : chaque token porte la
+	// version des sessions à son émission ("sv"), seuls les tokens de la version courante sont acceptés
+	SessionsRevokedAt     *time.Time `gorm:"default:null"`
+	SessionVersion        int        `gorm:"not null;default:0"`
+	PasswordResetRequired bool       `gorm:"default:false"`
+}
+
+// Vérification de l'email : le token est envoyé à l'adresse à vérifier, conservée ici en cas de changement entre-temps
+type UserEmailVerification struct {
+	ID         uuid.UUID  `gorm:"type:uuid;default:gen_random_uuid();primaryKey"`
+	CustomerID uuid.UUID  `gorm:"index;not null"`
+	Customer   User       `gorm:"foreignKey:CustomerID;constraint:OnUpdate:CASCADE;OnDelete:CASCADE;"`
+	Email      string     `gorm:"not null"`
+	TokenHash  string     `gorm:"uniqueIndex;not null"`
+	ExpiresAt  time.Time  `gorm:"not null"`
+	CreatedOn  time.Time  `gorm:"autoCreateTime"`
+	UsedAt     *time.Time `gorm:"default:null"`
 }
 
+// Demande de réinitialisation du mot de passe, imposée par un admin : le token est envoyé par email
+type UserPasswordReset struct {
+	ID            uuid.UUID  `gorm:"type:uuid;default:gen_random_uuid();primaryKey"`
+	CustomerID    uuid.UUID  `gorm:"index;not null"`
+	Customer      User       `gorm:"foreignKey:CustomerID;constraint:OnUpdate:CASCADE;OnDelete:CASCADE;"`
+	TokenHash     string     `gorm:"uniqueIndex;not null"`
+	RequestedByID *uuid.UUID `gorm:"index;default:null"`
+	RequestedBy   *User      `gorm:"foreignKey:RequestedByID;constraint:OnUpdate:CASCADE;OnDelete:SET NULL;"`
+	ExpiresAt     time.Time  `gorm:"not null"`
+	CreatedOn     time.Time  `gorm:"autoCreateTime"`
+	UsedAt        *time.Time `gorm:"default:null"`
+}
+
+// Visibilité d'un champ du profil public
+type ProfileVisibility string
+
+const (
+	ProfileVisibilityPublic        ProfileVisibility = "public"        // tout le monde, même sans compte
+	ProfileVisibilityAuthenticated ProfileVisibility = "authenticated" // utilisateurs connectés
+	ProfileVisibilityPrivate       ProfileVisibility = "private"       // l'utilisateur seul
+)
+
+func (v ProfileVisibility) IsValid() bool {
+	return v == ProfileVisibilityPublic || v == ProfileVisibilityAuthenticated || v == ProfileVisibilityPrivate
+}
+
+// Champs du profil dont la visibilité est réglable (le username est toujours public)
+var ProfileFields = []string{"display_name", "bio", "email", "member_since"}
+
 type UserProfile struct {
-	ID         uuid.UUID `gorm:"type:uuid;default:gen_random_uuid();primaryKey"`
+	ID                 uuid.UUID                    `gorm:"type:uuid;default:gen_random_uuid();primaryKey"`
+	CustomerID         uuid.UUID                    `gorm:"index;not null"`
+	Customer           User                         `gorm:"foreignKey:CustomerID;constraint:OnUpdate:CASCADE;OnDelete:CASCADE;"`
+	Username           string                       `gorm:"not null;uniqueIndex:idx_user_profiles_username_lower,expression:LOWER(username)"`
+	AvatarURL          string                       `gorm:"default:null"`
+	DisplayName        string                       `gorm:"default:null"`
+	Bio                string                       `gorm:"default:null"`
+	Visibility         map[string]ProfileVisibility `gorm:"type:jsonb;serializer:json"`
+	UsernameChangedAt  *time.Time                   `gorm:"default:null"`
+	PublicStatsDisplay bool                         `gorm:"default:true"`
+	IsAccessible       bool                         `gorm:"default:true"`
+}
+
+// FieldVisibility retourne la visibilité d'un champ ; par défaut seuls display_name et bio sont publics
+func (p UserProfile) FieldVisibility(field string) ProfileVisibility {
+	if v, ok := p.Visibility[field]; ok && v.IsValid() {
+		return v
+	}
+	if field == "display_name" || field == "bio" {
+		return ProfileVisibilityPublic
+	}
+	return ProfileVisibilityPrivate
+}
+
+// Anciens usernames, conservés pour rediriger les anciens liens et réserver le handle pendant un temps
+type UsernameHistory struct {
+	ID         uint      `gorm:"primaryKey;autoIncrement"`
 	CustomerID uuid.UUID `gorm:"index;not null"`
 	Customer   User      `gorm:"foreignKey:CustomerID;constraint:OnUpdate:CASCADE;OnDelete:CASCADE;"`
-	Username   string    `gorm:"not null"`
-	// AvatarURL          string   `gorm:"default:null"`
-	PublicStatsDisplay bool `gorm:"default:true"`
-	IsAccessible       bool `gorm:"default:true"`
+	Username   string    `gorm:"not null;index:idx_username_histories_username_lower,expression:LOWER(username)"`
+	ChangedAt  time.Time `gorm:"autoCreateTime;index"`
+}
+
+// Statistiques d'un utilisateur, recalculées périodiquement par le job "stats"
+type UserStats struct {
+	CustomerID     uuid.UUID  `gorm:"type:uuid;primaryKey"`
+	Customer       User       `gorm:"foreignKey:CustomerID;constraint:OnUpdate:CASCADE;OnDelete:CASCADE;"`
+	TeamsJoined    int        `gorm:"not null;default:0"`
+	TeamsOwned     int        `gorm:"not null;default:0"`
+	ActivityEvents int        `gorm:"not null;default:0"`
+	ActiveDays     int        `gorm:"not null;default:0"`
+	CurrentStreak  int        `gorm:"not null;default:0"`
+	LongestStreak  int        `gorm:"not null;default:0"`
+	LastActiveOn   *time.Time `gorm:"type:date;default:null"`
+	ComputedAt     time.Time  `gorm:"index"`
+}
+
+// Statistiques d'une Team, recalculées périodiquement par le job "stats"
+type TeamStats struct {
+	TeamID         uuid.UUID `gorm:"type:uuid;primaryKey"`
+	Team           Team      `gorm:"foreignKey:TeamID;constraint:OnUpdate:CASCADE;OnDelete:CASCADE;"`
+	Members        int       `gorm:"not null;default:0"`
+	ActiveMembers  int       `gorm:"not null;default:0"`
+	SubTeams       int       `gorm:"not null;default:0"`
+	ActivityEvents int       `gorm:"not null;default:0"`
+	ActiveDays     int       `gorm:"not null;default:0"`
+	ComputedAt     time.Time `gorm:"index"`
+}
+
+// Export des données d'un utilisateur (droit d'accès RGPD), généré en tâche de fond
+type UserExport struct {
+	ID          uuid.UUID        `gorm:"type:uuid;default:gen_random_uuid();primaryKey"`
+	CustomerID  uuid.UUID        `gorm:"index;not null"`
+	Customer    User             `gorm:"foreignKey:CustomerID;constraint:OnUpdate:CASCADE;OnDelete:CASCADE;"`
+	Status      UserExportStatus `gorm:"index;not null;default:'pending'"`
+	StorageKey  string           `gorm:"default:null"`
+	SizeBytes   int64            `gorm:"default:0"`
+	TokenHash   string           `gorm:"index;default:null"`
+	Error       string           `gorm:"default:null"`
+	RequestedAt time.Time        `gorm:"autoCreateTime"`
+	CompletedAt *time.Time       `gorm:"default:null"`
+	ExpiresAt   *time.Time       `gorm:"index;default:null"`
+}
+
+// Demande de suppression de compte : le compte est anonymisé à l'issue du délai de rétractation
+type UserDeletionRequest struct {
+	ID              uuid.UUID                 `gorm:"type:uuid;default:gen_random_uuid();primaryKey"`
+	CustomerID      uuid.UUID                 `gorm:"index;not null"`
+	Customer        User                      `gorm:"foreignKey:CustomerID;constraint:OnUpdate:CASCADE;OnDelete:CASCADE;"`
+	Status          UserDeletionStatus        `gorm:"index;not null;default:'pending'"`
+	TeamResolutions map[string]TeamResolution `gorm:"type:jsonb;serializer:json"`
+	RequestedAt     time.Time                 `gorm:"autoCreateTime"`
+	ScheduledFor    time.Time                 `gorm:"index;not null"`
+	CancelledAt     *time.Time                `gorm:"default:null"`
+	CompletedAt     *time.Time                `gorm:"default:null"`
 }
 
 type UserCredit struct {
@@ -128,15 +474,74 @@ type SubscriptionPerks struct {
 	IsAccessible              bool              `gorm:"default:true"`
 }
 
+// Table partitionnée par jour sur Timestamp (voir request_log_service.Migrate) : la clé de partition
+// doit faire partie de la clé primaire
+// Les index des colonnes filtrables se terminent par l'ID : la recherche des logs (filtre + tri et
+// curseur sur l'ID) parcourt l'index dans l'ordre, sans trier toutes les lignes correspondantes
 type RequestLog struct {
-	ID        uint       `gorm:"primaryKey;autoIncrement"`
-	UserID    *uuid.UUID `gorm:"index;default:null"`
-	Domain    string     `gorm:"index"`
-	Endpoint  string     `gorm:"index"`
-	Content   string     `gorm:"type:bytea"`
+	ID        uint       `gorm:"primaryKey;autoIncrement;index:idx_request_logs_user_id_id,priority:2;index:idx_request_logs_domain_id,priority:2;index:idx_request_logs_endpoint_id,priority:2;index:idx_request_logs_route_id,priority:2;index:idx_request_logs_status_id,priority:2;index:idx_request_logs_request_id_id,priority:2;index:idx_request_logs_client_ip_id,priority:2"`
+	UserID    *uuid.UUID `gorm:"index:idx_request_logs_user_id_id,priority:1;default:null"`
+	Domain    string     `gorm:"index:idx_request_logs_domain_id,priority:1"`
+	Endpoint  string     `gorm:"index:idx_request_logs_endpoint_id,priority:1;index:idx_request_logs_endpoint_prefix,expression:endpoint text_pattern_ops"`
+	Route     string     `gorm:"index:idx_request_logs_route_id,priority:1"` // modèle de route mux ("/users/{id}")
+	Query     string
+	Content   string            `gorm:"type:bytea"`
+	Headers   map[string]string `gorm:"type:jsonb;serializer:json"`
 	Method    string
-	Status    int
-	Timestamp time.Time `gorm:"autoCreateTime"`
+	Status    int       `gorm:"index:idx_request_logs_status_id,priority:1"`
+	Timestamp time.Time `gorm:"primaryKey;autoCreateTime;index"`
+
+	RequestID     string `gorm:"index:idx_request_logs_request_id_id,priority:1"`
+	ClientIP      string `gorm:"index:idx_request_logs_client_ip_id,priority:1"`
+	UserAgent     string
+	Duration      time.Duration // en nanosecondes
+	ResponseBytes int64
+}
+
+// Agrégat horaire des logs par route, maintenu par le job "analytics-rollup"
+type RequestLogRollup struct {
+	Hour             time.Time `gorm:"primaryKey"`
+	Route            string    `gorm:"primaryKey"`
+	Method           string    `gorm:"primaryKey"`
+	Requests         int64
+	Status2xx        int64
+	Status3xx        int64
+	Status4xx        int64
+	Status5xx        int64
+	Unauthorized     int64 // 401
+	Forbidden        int64 // 403
+	DurationSum      int64 // en nanosecondes
+	ResponseBytes    int64
+	LatencyHistogram []int64 `gorm:"type:jsonb;serializer:json"` // voir analytics_service.LatencyBuckets
+}
+
+// Agrégat horaire des logs par utilisateur, maintenu par le job "analytics-rollup"
+type RequestLogUserRollup struct {
+	Hour         time.Time `gorm:"primaryKey"`
+	UserID       uuid.UUID `gorm:"type:uuid;primaryKey"`
+	Requests     int64
+	Errors       int64 // 4xx et 5xx
+	AuthFailures int64 // 401 et 403
+}
+
+// Avancement du job "analytics-rollup" : dernière heure agrégée, y compris une heure sans aucun log.
+// Une seule ligne (ID 1).
+type RequestLogRollupWatermark struct {
+	ID        int       `gorm:"primaryKey;autoIncrement:false"`
+	Hour      time.Time `gorm:"not null"`
+	UpdatedAt time.Time `gorm:"autoUpdateTime"`
+}
+
+// Fichier d'archive (NDJSON compressé) de logs supprimés par la politique de rétention
+type RequestLogArchive struct {
+	ID         uuid.UUID `gorm:"type:uuid;default:gen_random_uuid();primaryKey"`
+	Partition  string    `gorm:"index"`
+	StorageKey string    `gorm:"uniqueIndex;not null"`
+	Rows       int64
+	SizeBytes  int64
+	FirstAt    time.Time // premier log archivé
+	LastAt     time.Time // dernier log archivé
+	CreatedAt  time.Time `gorm:"autoCreateTime;index"`
 }
 
 func (r *RequestLog) BeforeCreate(tx *gorm.DB) (err error) {
@@ -145,3 +550,23 @@ func (r *RequestLog) BeforeCreate(tx *gorm.DB) (err error) {
 	}
 	return
 }
+
+// Journal d'audit des actions d'administration, en ajout seul. Les entrées sont chaînées : Hash est calculé
+// sur le contenu de l'entrée et sur PrevHash, le hash de la précédente (voir audit_service). Pas de clé
+// étrangère vers User : l'audit doit survivre à la suppression des comptes.
+type AuditLog struct {
+	Sequence   uint64     `gorm:"primaryKey;autoIncrement:false"` // 1, 2, 3… sans trou
+	ActorID    *uuid.UUID `gorm:"type:uuid;index"`                // nul pour une action système
+	ActorEmail string
+	Action     string `gorm:"index;not null"`
+	TargetType string `gorm:"index"`
+	TargetID   string `gorm:"index"`
+	Before     any    `gorm:"type:jsonb;serializer:json"`
+	After      any    `gorm:"type:jsonb;serializer:json"`
+	Reason     string
+	ClientIP   string
+	RequestID  string    `gorm:"index"`
+	CreatedAt  time.Time `gorm:"index;not null"`
+	PrevHash   string    `gorm:"size:64;not null"`
+	Hash       string    `gorm:"size:64;uniqueIndex;not null"`
+}
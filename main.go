@@ -3,9 +3,18 @@ package main
 import (
 	"fmt"
 	"os"
+	"time"
 
 	"gox/database"
 	server "gox/routes"
+	analytics_service "gox/services/analytics"
+	audit_service "gox/services/audit"
+	"gox/services/jobs"
+	request_log_service "gox/services/requestlogs"
+	stats_service "gox/services/stats"
+	team_service "gox/services/teams"
+	user_deletion_service "gox/services/users/deletion"
+	user_export_service "gox/services/users/export"
 	"gox/utils"
 
 	"github.com/joho/godotenv"
@@ -51,6 +60,25 @@ func run() error {
 		dbHost, dbPort, dbUser, dbPassword, dbName,
 	)
 	database.InitDB(dsn)
+	if err := request_log_service.Migrate(); err != nil {
+		utils.ConsoleLog("❌ Erreur lors de la migration des logs de requêtes : %v", err).Fatal()
+	}
+	if err := audit_service.Migrate(); err != nil {
+		utils.ConsoleLog("❌ Erreur lors de la migration du journal d'audit : %v", err).Fatal()
+	}
+	if err := user_export_service.Migrate(); err != nil {
+		utils.ConsoleLog("❌ Erreur lors de la migration des exports : %v", err).Fatal()
+	}
+
+	// Tâches de fond
+	jobs.Every("teams-purge", time.Hour, team_service.PurgeArchived)
+	jobs.Every("stats", stats_service.Interval(), stats_service.ComputeAll)
+	jobs.Every("exports-purge", time.Hour, user_export_service.PurgeExpired)
+	jobs.Every("account-deletions", time.Hour, user_deletion_service.ProcessDue)
+	jobs.Every("analytics-rollup", analytics_service.Interval(), analytics_service.Rollup)
+	jobs.Every("request-logs-retention", request_log_service.RetentionInterval(), request_log_service.RunRetention)
+	jobs.Every("request-logs-legacy-copy", time.Hour, request_log_service.CopyLegacyLogs)
+
 	server.Start()
 	return nil
 }
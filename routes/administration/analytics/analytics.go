@@ -0,0 +1,140 @@
+package admin_analytics
+
+import (
+	"errors"
+	analytics_service "gox/services/analytics"
+	"gox/utils"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// parseRange lit since et until (RFC3339, dernières 24 heures par défaut) et bucket (hour|day ; day par
+// défaut au-delà de 7 jours). Les agrégats étant horaires, since est arrondi à l'heure.
+func parseRange(w http.ResponseWriter, r *http.Request) (analytics_service.Range, bool) {
+	params := r.URL.Query()
+
+	rng := analytics_service.Range{Until: time.Now()}
+	if until := params.Get("until"); until != "" {
+		parsed, err := time.Parse(time.RFC3339, until)
+		if err != nil {
+			utils.AbortRequest(w, "Invalid until (expected RFC3339)", http.StatusBadRequest)
+			return rng, false
+		}
+		rng.Until = parsed
+	}
+	rng.Since = rng.Until.Add(-24 * time.Hour)
+	if since := params.Get("since"); since != "" {
+		parsed, err := time.Parse(time.RFC3339, since)
+		if err != nil {
+			utils.AbortRequest(w, "Invalid since (expected RFC3339)", http.StatusBadRequest)
+			return rng, false
+		}
+		rng.Since = parsed
+	}
+	rng.Since = rng.Since.Truncate(time.Hour)
+
+	rng.Bucket = strings.ToLower(params.Get("bucket"))
+	if rng.Bucket == "" {
+		rng.Bucket = "hour"
+		if rng.Until.Sub(rng.Since) > 7*24*time.Hour {
+			rng.Bucket = "day"
+		}
+	}
+
+	if err := rng.Validate(); err != nil {
+		utils.AbortRequest(w, err.Error(), http.StatusBadRequest)
+		return rng, false
+	}
+	return rng, true
+}
+
+func respond(w http.ResponseWriter, data any, err error) {
+	if errors.Is(err, analytics_service.ErrInvalidRange) {
+		utils.AbortRequest(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if err != nil {
+		utils.AbortRequest(w, "Error computing analytics", http.StatusInternalServerError)
+		return
+	}
+	utils.RespondJSON(w, data)
+}
+
+// ~ /administrate/analytics ~
+func HandleGetOverview(w http.ResponseWriter, r *http.Request) {
+	rng, ok := parseRange(w, r)
+	if !ok {
+		return
+	}
+
+	overview, err := analytics_service.GetOverview(rng)
+	respond(w, overview, err)
+}
+
+// ~ /administrate/analytics/requests ~
+// Filtres : route (modèle de route, ex : /users/{id}), method
+func HandleGetRequests(w http.ResponseWriter, r *http.Request) {
+	rng, ok := parseRange(w, r)
+	if !ok {
+		return
+	}
+
+	points, err := analytics_service.Requests(rng, r.URL.Query().Get("route"), strings.ToUpper(r.URL.Query().Get("method")))
+	respond(w, points, err)
+}
+
+// ~ /administrate/analytics/errors ~
+func HandleGetErrorRates(w http.ResponseWriter, r *http.Request) {
+	rng, ok := parseRange(w, r)
+	if !ok {
+		return
+	}
+
+	points, err := analytics_service.ErrorRates(rng, r.URL.Query().Get("route"))
+	respond(w, points, err)
+}
+
+// ~ /administrate/analytics/latency ~
+func HandleGetLatency(w http.ResponseWriter, r *http.Request) {
+	rng, ok := parseRange(w, r)
+	if !ok {
+		return
+	}
+
+	latencies, err := analytics_service.Latency(rng, r.URL.Query().Get("route"), strings.ToUpper(r.URL.Query().Get("method")))
+	respond(w, latencies, err)
+}
+
+// ~ /administrate/analytics/users ~
+func HandleGetTopUsers(w http.ResponseWriter, r *http.Request) {
+	rng, ok := parseRange(w, r)
+	if !ok {
+		return
+	}
+
+	limit := 10
+	if value := r.URL.Query().Get("limit"); value != "" {
+		parsed, err := strconv.Atoi(value)
+		if err != nil || parsed <= 0 || parsed > 100 {
+			utils.AbortRequest(w, "Invalid limit (1 to 100)", http.StatusBadRequest)
+			return
+		}
+		limit = parsed
+	}
+
+	users, err := analytics_service.TopUsers(rng, limit)
+	respond(w, users, err)
+}
+
+// ~ /administrate/analytics/auth-failures ~
+func HandleGetAuthFailures(w http.ResponseWriter, r *http.Request) {
+	rng, ok := parseRange(w, r)
+	if !ok {
+		return
+	}
+
+	points, err := analytics_service.AuthFailures(rng)
+	respond(w, points, err)
+}
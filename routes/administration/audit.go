@@ -0,0 +1,42 @@
+package administration
+
+import (
+	"errors"
+	audit_service "gox/services/audit"
+	"gox/utils"
+	"net/http"
+	"strings"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// Longueur maximum du motif d'une action
+const maxAuditReasonLength = 500
+
+// ErrNotAudited : l'entrée d'audit n'a pas pu être enregistrée, l'action est annulée
+var ErrNotAudited = errors.New("the action could not be audited and was cancelled")
+
+// Audit enregistre une action d'administration dans le journal d'audit. L'acteur est l'admin authentifié
+// (sauf s'il est déjà renseigné), le motif est lu dans l'en-tête X-Audit-Reason.
+// Audit est appelé dans la transaction de l'action (db) : si l'entrée ne peut pas être enregistrée, il
+// retourne ErrNotAudited et la transaction est annulée, une action non auditée n'est jamais conservée.
+func Audit(db *gorm.DB, r *http.Request, event audit_service.Event) error {
+	if event.ActorID == uuid.Nil {
+		event.ActorID, _ = utils.ExtractUserIDFromJWT(r)
+	}
+	if event.Reason == "" {
+		reason := strings.TrimSpace(r.Header.Get("X-Audit-Reason"))
+		if len(reason) > maxAuditReasonLength {
+			reason = strings.ToValidUTF8(reason[:maxAuditReasonLength], "")
+		}
+		event.Reason = reason
+	}
+	event.ClientIP = utils.ClientIP(r)
+	event.RequestID = utils.RequestID(r)
+
+	if _, err := audit_service.Record(db, event); err != nil {
+		return ErrNotAudited
+	}
+	return nil
+}
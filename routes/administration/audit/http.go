@@ -0,0 +1,150 @@
+package admin_audit
+
+import (
+	"encoding/json"
+	"fmt"
+	"gox/database"
+	"gox/database/models"
+	"gox/routes/administration"
+	audit_service "gox/services/audit"
+	"gox/utils"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// parseFilters lit les filtres communs à la liste et à l'export : action (ou préfixe "subscription."),
+// actor_id, target_type, target_id, since, until (RFC 3339)
+func parseFilters(r *http.Request) (audit_service.Filters, error) {
+	query := r.URL.Query()
+	filters := audit_service.Filters{
+		Action:     query.Get("action"),
+		TargetType: query.Get("target_type"),
+		TargetID:   query.Get("target_id"),
+	}
+	if actorID := query.Get("actor_id"); actorID != "" {
+		actorUUID, err := uuid.Parse(actorID)
+		if err != nil {
+			return filters, fmt.Errorf("Invalid actor ID")
+		}
+		filters.ActorID = &actorUUID
+	}
+	for param, target := range map[string]**time.Time{"since": &filters.Since, "until": &filters.Until} {
+		if value := query.Get(param); value != "" {
+			parsed, err := time.Parse(time.RFC3339, value)
+			if err != nil {
+				return filters, fmt.Errorf("Invalid %s date, expected RFC 3339", param)
+			}
+			*target = &parsed
+		}
+	}
+	return filters, nil
+}
+
+func toItem(entry models.AuditLog) map[string]interface{} {
+	return map[string]interface{}{
+		"sequence":    entry.Sequence,
+		"actor_id":    entry.ActorID,
+		"actor_email": entry.ActorEmail,
+		"action":      entry.Action,
+		"target_type": entry.TargetType,
+		"target_id":   entry.TargetID,
+		"before":      entry.Before,
+		"after":       entry.After,
+		"reason":      entry.Reason,
+		"client_ip":   entry.ClientIP,
+		"request_id":  entry.RequestID,
+		"created_at":  entry.CreatedAt,
+		"prev_hash":   entry.PrevHash,
+		"hash":        entry.Hash,
+	}
+}
+
+// ~ /administrate/audit ~
+func HandleGetAudit(w http.ResponseWriter, r *http.Request) {
+	filters, err := parseFilters(r)
+	if err != nil {
+		utils.AbortRequest(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	// Pagination
+	query := r.URL.Query()
+	page, _ := strconv.Atoi(query.Get("page"))
+	perPage, _ := strconv.Atoi(query.Get("per_page"))
+	if page < 1 {
+		page = 1
+	}
+	if perPage < 1 || perPage > audit_service.MaxPerPage {
+		perPage = audit_service.DefaultPerPage
+	}
+
+	entries, total, err := audit_service.List(filters, page, perPage)
+	if err != nil {
+		utils.AbortRequest(w, "Error fetching audit log", http.StatusInternalServerError)
+		return
+	}
+
+	// Réponse JSON
+	items := make([]map[string]interface{}, len(entries))
+	for i, entry := range entries {
+		items[i] = toItem(entry)
+	}
+	utils.RespondJSON(w, map[string]interface{}{
+		"items":    items,
+		"page":     page,
+		"per_page": perPage,
+		"total":    total,
+	})
+}
+
+// ~ /administrate/audit/verify ~
+func HandleVerifyAudit(w http.ResponseWriter, r *http.Request) {
+	// Relecture de toute la chaîne
+	verification, err := audit_service.Verify()
+	if err != nil {
+		utils.AbortRequest(w, "Error verifying audit log", http.StatusInternalServerError)
+		return
+	}
+
+	if !verification.Valid {
+		utils.ConsoleLogRequest(r, "🚨 Audit chain broken at sequence %d: %s", *verification.BrokenAt, verification.Problem)
+	}
+
+	utils.RespondJSON(w, verification)
+}
+
+// ~ /administrate/audit/export ~
+// NDJSON, une entrée par ligne dans l'ordre de la chaîne, avec les hashes pour une vérification hors ligne
+func HandleExportAudit(w http.ResponseWriter, r *http.Request) {
+	filters, err := parseFilters(r)
+	if err != nil {
+		utils.AbortRequest(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	// L'export est lui-même une action auditée, rien n'est exporté sans son entrée
+	if err := administration.Audit(database.DB, r, audit_service.Event{
+		Action:     "audit.exported",
+		TargetType: audit_service.TargetAudit,
+		After:      filters,
+	}); err != nil {
+		utils.AbortRequest(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/x-ndjson")
+	w.Header().Set("Content-Disposition", fmt.Sprintf(`attachment; filename="audit-%s.ndjson"`, time.Now().UTC().Format("20060102-150405")))
+	w.WriteHeader(http.StatusOK)
+
+	encoder := json.NewEncoder(w)
+	err = audit_service.Export(filters, func(entry models.AuditLog) error {
+		return encoder.Encode(toItem(entry))
+	})
+	if err != nil {
+		// Les en-têtes sont partis : l'export est tronqué, le client le détecte à la vérification
+		utils.ConsoleLogRequest(r, "⚠️ Audit export interrupted: %v", err)
+	}
+}
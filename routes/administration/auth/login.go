@@ -5,6 +5,8 @@ import (
 	"fmt"
 	"gox/database"
 	"gox/database/models"
+	"gox/routes/administration"
+	audit_service "gox/services/audit"
 	"gox/utils"
 	"net/http"
 
@@ -36,13 +38,34 @@ func HandleLogin(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	// Vérifie que le compte est utilisable
+	if !user.IsActive {
+		utils.AbortRequest(w, "Account is disabled", http.StatusForbidden)
+		return
+	}
+	if user.PasswordResetRequired {
+		utils.AbortRequest(w, "Password reset required, check your emails", http.StatusForbidden)
+		return
+	}
+
 	// Générer un token JWT
-	token, err := utils.GenerateJWT(user.ID, true)
+	token, err := utils.GenerateJWT(user.ID, true, user.SessionVersion)
 	if err != nil {
 		utils.AbortRequest(w, fmt.Sprintf("Could not generate token: %s", err), http.StatusInternalServerError)
 		return
 	}
 
+	// Aucun token n'est remis sans son entrée d'audit
+	if err := administration.Audit(database.DB, r, audit_service.Event{
+		ActorID:    user.ID,
+		Action:     "admin.logged_in",
+		TargetType: audit_service.TargetUser,
+		TargetID:   user.ID.String(),
+	}); err != nil {
+		utils.AbortRequest(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
 	// Réponse
 	w.Header().Set("Content-Type", "application/json")
 	json.NewEncoder(w).Encode(map[string]interface{}{
@@ -1,17 +1,117 @@
 package admin_logs
 
 import (
+	"errors"
 	admin_logs_service "gox/services/administration/logs"
+	request_log_service "gox/services/requestlogs"
 	"gox/utils"
 	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/gorilla/mux"
+	"gorm.io/gorm"
 )
 
+// ~ /administrate/logs ~
+// Filtres : user_id, request_id, client_ip, route, domain, method (GET,POST), endpoint, endpoint_prefix, status (404,4xx,500-599),
+// since et until (RFC3339), order (asc|desc), limit, cursor, include_content (true|false)
 func HandleGetLogs(w http.ResponseWriter, r *http.Request) {
-	logs, err := admin_logs_service.GetAll()
+	params := r.URL.Query()
+	query := admin_logs_service.Query{
+		RequestID:      params.Get("request_id"),
+		ClientIP:       params.Get("client_ip"),
+		Route:          params.Get("route"),
+		Domain:         params.Get("domain"),
+		Endpoint:       params.Get("endpoint"),
+		EndpointPrefix: params.Get("endpoint_prefix"),
+		Cursor:         params.Get("cursor"),
+		Ascending:      params.Get("order") == "asc",
+		IncludeContent: params.Get("include_content") == "true",
+	}
+
+	if userID := params.Get("user_id"); userID != "" {
+		userUUID, err := uuid.Parse(userID)
+		if err != nil {
+			utils.AbortRequest(w, "Invalid user_id", http.StatusBadRequest)
+			return
+		}
+		query.UserID = &userUUID
+	}
+	if methods := params.Get("method"); methods != "" {
+		for _, method := range strings.Split(methods, ",") {
+			query.Methods = append(query.Methods, strings.ToUpper(strings.TrimSpace(method)))
+		}
+	}
+	if status := params.Get("status"); status != "" {
+		statuses, err := admin_logs_service.ParseStatuses(status)
+		if err != nil {
+			utils.AbortRequest(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		query.Statuses = statuses
+	}
+	for name, target := range map[string]**time.Time{"since": &query.Since, "until": &query.Until} {
+		if value := params.Get(name); value != "" {
+			parsed, err := time.Parse(time.RFC3339, value)
+			if err != nil {
+				utils.AbortRequest(w, "Invalid "+name+" (expected RFC3339)", http.StatusBadRequest)
+				return
+			}
+			*target = &parsed
+		}
+	}
+	if limit := params.Get("limit"); limit != "" {
+		parsed, err := strconv.Atoi(limit)
+		if err != nil || parsed <= 0 {
+			utils.AbortRequest(w, "Invalid limit", http.StatusBadRequest)
+			return
+		}
+		query.Limit = parsed
+	}
+
+	// Recherche
+	page, err := admin_logs_service.Search(query)
+	if errors.Is(err, admin_logs_service.ErrInvalidCursor) {
+		utils.AbortRequest(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if err != nil {
+		utils.AbortRequest(w, "Error fetching logs", http.StatusInternalServerError)
+		return
+	}
+
+	utils.RespondJSON(w, page)
+}
+
+// ~ /administrate/logs/{id} ~
+func HandleGetLog(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+
+	id, err := strconv.ParseUint(vars["id"], 10, 64)
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		utils.AbortRequest(w, "Invalid log id", http.StatusBadRequest)
 		return
 	}
 
-	utils.RespondJSON(w, logs)
+	// Récupération du log, corps décodé
+	entry, err := admin_logs_service.GetByID(uint(id))
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		utils.AbortRequest(w, "Log not found", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		utils.AbortRequest(w, "Error fetching log", http.StatusInternalServerError)
+		return
+	}
+
+	utils.RespondJSON(w, entry)
+}
+
+// ~ /administrate/logs/metrics ~
+func HandleGetLogMetrics(w http.ResponseWriter, r *http.Request) {
+	// État de la file d'écriture des logs
+	utils.RespondJSON(w, request_log_service.GetMetrics())
 }
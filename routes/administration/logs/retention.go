@@ -0,0 +1,57 @@
+package admin_logs
+
+import (
+	"errors"
+	"gox/database"
+	"gox/routes/administration"
+	audit_service "gox/services/audit"
+	request_log_service "gox/services/requestlogs"
+	"gox/utils"
+	"net/http"
+
+	"gorm.io/gorm"
+)
+
+// ~ /administrate/logs/retention ~
+func HandleGetLogRetention(w http.ResponseWriter, r *http.Request) {
+	// Règles, partitions, dernier passage et dernières archives
+	status, err := request_log_service.GetRetentionStatus()
+	if err != nil {
+		utils.AbortRequest(w, "Error fetching retention status", http.StatusInternalServerError)
+		return
+	}
+
+	utils.RespondJSON(w, status)
+}
+
+func HandlePurgeLogs(w http.ResponseWriter, r *http.Request) {
+	// Lancement d'un passage de la rétention, suivi via GET /administrate/logs/retention.
+	// L'entrée d'audit est enregistrée d'abord : le passage n'est lancé que si elle l'est, et elle est annulée
+	// si un passage est déjà en cours
+	err := database.DB.Transaction(func(tx *gorm.DB) error {
+		if err := administration.Audit(tx, r, audit_service.Event{
+			Action:     "request_logs.purge_started",
+			TargetType: audit_service.TargetRequestLogs,
+		}); err != nil {
+			return err
+		}
+
+		return request_log_service.StartPurge()
+	})
+	if errors.Is(err, administration.ErrNotAudited) {
+		utils.AbortRequest(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if errors.Is(err, request_log_service.ErrPurgeRunning) {
+		utils.AbortRequest(w, err.Error(), http.StatusConflict)
+		return
+	}
+	if err != nil {
+		utils.AbortRequest(w, "Error starting purge", http.StatusInternalServerError)
+		return
+	}
+
+	utils.RespondJSON(w, map[string]interface{}{
+		"started": true,
+	})
+}
@@ -0,0 +1,107 @@
+package admin_logs
+
+import (
+	"encoding/json"
+	"fmt"
+	admin_logs_service "gox/services/administration/logs"
+	request_log_service "gox/services/requestlogs"
+	"gox/utils"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Intervalle des commentaires envoyés pour garder la connexion ouverte (proxies, load balancers)
+const heartbeatInterval = 15 * time.Second
+
+// ~ /administrate/logs/stream ~
+// Server-Sent Events : un événement "log" par requête traitée, sur ce replica ou les autres.
+// Filtres : user_id, endpoint_prefix, status (404,4xx,500-599)
+func HandleStreamLogs(w http.ResponseWriter, r *http.Request) {
+	params := r.URL.Query()
+
+	var userID *uuid.UUID
+	if value := params.Get("user_id"); value != "" {
+		parsed, err := uuid.Parse(value)
+		if err != nil {
+			utils.AbortRequest(w, "Invalid user_id", http.StatusBadRequest)
+			return
+		}
+		userID = &parsed
+	}
+	endpointPrefix := params.Get("endpoint_prefix")
+	var statuses []admin_logs_service.StatusRange
+	if value := params.Get("status"); value != "" {
+		parsed, err := admin_logs_service.ParseStatuses(value)
+		if err != nil {
+			utils.AbortRequest(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		statuses = parsed
+	}
+
+	// Filtrage côté serveur
+	subscription := request_log_service.Subscribe(func(entry request_log_service.StreamEntry) bool {
+		if userID != nil && (entry.UserID == nil || *entry.UserID != *userID) {
+			return false
+		}
+		if endpointPrefix != "" && !strings.HasPrefix(entry.Endpoint, endpointPrefix) {
+			return false
+		}
+		if len(statuses) == 0 {
+			return true
+		}
+		for _, status := range statuses {
+			if entry.Status >= status.Min && entry.Status <= status.Max {
+				return true
+			}
+		}
+		return false
+	})
+	defer request_log_service.Unsubscribe(subscription)
+
+	controller := http.NewResponseController(w)
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("Connection", "keep-alive")
+	w.Header().Set("X-Accel-Buffering", "no")
+	w.WriteHeader(http.StatusOK)
+	fmt.Fprint(w, "retry: 3000\n\n")
+	if err := controller.Flush(); err != nil {
+		return
+	}
+
+	heartbeat := time.NewTicker(heartbeatInterval)
+	defer heartbeat.Stop()
+
+	for {
+		select {
+		case <-r.Context().Done():
+			return
+
+		case entry, ok := <-subscription.C:
+			// Canal fermé : arrêt du serveur
+			if !ok {
+				return
+			}
+			// Logs abandonnés parce que le client ne suivait pas
+			if dropped := subscription.Dropped(); dropped > 0 {
+				fmt.Fprintf(w, "event: dropped\ndata: {\"count\":%d}\n\n", dropped)
+			}
+			data, err := json.Marshal(entry)
+			if err != nil {
+				continue
+			}
+			fmt.Fprintf(w, "event: log\ndata: %s\n\n", data)
+
+		case <-heartbeat.C:
+			fmt.Fprint(w, ": ping\n\n")
+		}
+
+		if err := controller.Flush(); err != nil {
+			return
+		}
+	}
+}
@@ -2,14 +2,20 @@ package admin_subscriptions
 
 import (
 	"encoding/json"
+	"errors"
 	"fmt"
+	"gox/database"
+	"gox/database/models"
+	"gox/routes/administration"
 	admin_subscription_service "gox/services/administration/subscriptions"
+	audit_service "gox/services/audit"
 	subscriptions_service "gox/services/subscriptions"
 	"gox/utils"
 	"net/http"
 
 	"github.com/google/uuid"
 	"github.com/gorilla/mux"
+	"gorm.io/gorm"
 )
 
 // ~ /administrate/subscriptions ~
@@ -36,7 +42,26 @@ func HandleCreateSubscription(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	sub, err := admin_subscription_service.Create(input.Name, input.Description, input.Price, input.Currency, input.ValidForInDays)
+	// Action et entrée d'audit dans la même transaction
+	var sub models.Subscription
+	err := database.DB.Transaction(func(tx *gorm.DB) error {
+		var err error
+		sub, err = admin_subscription_service.Create(tx, input.Name, input.Description, input.Price, input.Currency, input.ValidForInDays)
+		if err != nil {
+			return err
+		}
+
+		return administration.Audit(tx, r, audit_service.Event{
+			Action:     "subscription.created",
+			TargetType: audit_service.TargetSubscription,
+			TargetID:   sub.ID.String(),
+			After:      sub,
+		})
+	})
+	if errors.Is(err, administration.ErrNotAudited) {
+		utils.AbortRequest(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
 	if err != nil {
 		utils.AbortRequest(w, err.Error(), http.StatusBadRequest)
 		return
@@ -99,13 +124,32 @@ func HandleUpdateSubscription(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	before := sub
 	sub.Name = input.Name
 	sub.Description = input.Description
 	sub.Price = input.Price
 	sub.Currency = input.Currency
 	sub.ValidForInDays = input.ValidForInDays
 
-	sub, err = admin_subscription_service.Update(sub)
+	err = database.DB.Transaction(func(tx *gorm.DB) error {
+		var err error
+		sub, err = admin_subscription_service.Update(tx, sub)
+		if err != nil {
+			return err
+		}
+
+		return administration.Audit(tx, r, audit_service.Event{
+			Action:     "subscription.updated",
+			TargetType: audit_service.TargetSubscription,
+			TargetID:   sub.ID.String(),
+			Before:     before,
+			After:      sub,
+		})
+	})
+	if errors.Is(err, administration.ErrNotAudited) {
+		utils.AbortRequest(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
 	if err != nil {
 		utils.AbortRequest(w, err.Error(), http.StatusBadRequest)
 		return
@@ -121,7 +165,29 @@ func HandleDeleteSubscription(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	if err := admin_subscription_service.DeleteByID(id.String()); err != nil {
+	// État avant suppression, pour l'audit
+	var before any
+	if sub, err := subscriptions_service.GetByID(id); err == nil {
+		before = sub
+	}
+
+	err = database.DB.Transaction(func(tx *gorm.DB) error {
+		if err := admin_subscription_service.DeleteByID(tx, id.String()); err != nil {
+			return err
+		}
+
+		return administration.Audit(tx, r, audit_service.Event{
+			Action:     "subscription.deleted",
+			TargetType: audit_service.TargetSubscription,
+			TargetID:   id.String(),
+			Before:     before,
+		})
+	})
+	if errors.Is(err, administration.ErrNotAudited) {
+		utils.AbortRequest(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if err != nil {
 		utils.AbortRequest(w, err.Error(), http.StatusBadRequest)
 		return
 	}
@@ -0,0 +1,395 @@
+package admin_teams
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"gox/database"
+	"gox/database/models"
+	"gox/routes/administration"
+	admin_team_service "gox/services/administration/teams"
+	audit_service "gox/services/audit"
+	team_service "gox/services/teams"
+	team_member_service "gox/services/teams/members"
+	"gox/utils"
+	"net/http"
+	"strconv"
+
+	"github.com/google/uuid"
+	"github.com/gorilla/mux"
+	"gorm.io/gorm"
+)
+
+// ~ /administrate/teams ~
+// Filtres : q (nom ou ID), type (personal|company), suspended, archived (true|false),
+// sort (name|members), order (asc|desc), page, per_page
+func HandleGetTeams(w http.ResponseWriter, r *http.Request) {
+	params := r.URL.Query()
+	query := admin_team_service.Query{
+		Search:     params.Get("q"),
+		Type:       models.TeamType(params.Get("type")),
+		Sort:       params.Get("sort"),
+		Descending: params.Get("order") == "desc",
+	}
+	for name, target := range map[string]**bool{"suspended": &query.Suspended, "archived": &query.Archived} {
+		if value := params.Get(name); value != "" {
+			parsed, err := strconv.ParseBool(value)
+			if err != nil {
+				utils.AbortRequest(w, fmt.Sprintf("Invalid %s (expected true or false)", name), http.StatusBadRequest)
+				return
+			}
+			*target = &parsed
+		}
+	}
+
+	// Pagination
+	query.Page, _ = strconv.Atoi(params.Get("page"))
+	query.PerPage, _ = strconv.Atoi(params.Get("per_page"))
+	if query.Page < 1 {
+		query.Page = 1
+	}
+	if query.PerPage < 1 || query.PerPage > admin_team_service.MaxPerPage {
+		query.PerPage = admin_team_service.DefaultPerPage
+	}
+
+	teams, total, err := admin_team_service.Search(query)
+	if err != nil {
+		utils.AbortRequest(w, "Error fetching teams", http.StatusInternalServerError)
+		return
+	}
+
+	// Réponse JSON
+	utils.RespondJSON(w, map[string]interface{}{
+		"items":    teams,
+		"page":     query.Page,
+		"per_page": query.PerPage,
+		"total":    total,
+	})
+}
+
+// ~ /administrate/teams/{id} ~
+
+func getTeamID(r *http.Request) (uuid.UUID, error) {
+	vars := mux.Vars(r)
+	teamUUID, err := uuid.Parse(vars["id"])
+	if err != nil {
+		return uuid.UUID{}, fmt.Errorf("id invalid")
+	}
+
+	return teamUUID, nil
+}
+
+func HandleGetTeam(w http.ResponseWriter, r *http.Request) {
+	id, err := getTeamID(r)
+	if err != nil {
+		utils.AbortRequest(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	// Team, membres, sous-teams et transfert en attente
+	detail, err := admin_team_service.Get(id)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		utils.AbortRequest(w, "Team not found", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		utils.AbortRequest(w, "Error fetching team", http.StatusInternalServerError)
+		return
+	}
+
+	utils.RespondJSON(w, detail)
+}
+
+// HandleUpdateTeam suspend ou rétablit la Team : is_accessible
+func HandleUpdateTeam(w http.ResponseWriter, r *http.Request) {
+	id, err := getTeamID(r)
+	if err != nil {
+		utils.AbortRequest(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	var body struct {
+		IsAccessible *bool `json:"is_accessible"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&body); err != nil || body.IsAccessible == nil {
+		utils.AbortRequest(w, "body invalid", http.StatusBadRequest)
+		return
+	}
+
+	adminUUID, err := utils.ExtractUserIDFromJWT(r)
+	if err != nil {
+		utils.AbortRequest(w, "Authorization Token is invalid.", http.StatusUnauthorized)
+		return
+	}
+
+	// Action et entrée d'audit dans la même transaction
+	err = database.DB.Transaction(func(tx *gorm.DB) error {
+		before, err := admin_team_service.SetAccessible(tx, id, *body.IsAccessible, adminUUID)
+		if err != nil || before == *body.IsAccessible {
+			return err
+		}
+
+		action := "team.suspended"
+		if *body.IsAccessible {
+			action = "team.unsuspended"
+		}
+		return administration.Audit(tx, r, audit_service.Event{
+			ActorID:    adminUUID,
+			Action:     action,
+			TargetType: audit_service.TargetTeam,
+			TargetID:   id.String(),
+			Before:     map[string]bool{"is_accessible": before},
+			After:      map[string]bool{"is_accessible": *body.IsAccessible},
+		})
+	})
+	if errors.Is(err, administration.ErrNotAudited) {
+		utils.AbortRequest(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		utils.AbortRequest(w, "Team not found", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		utils.AbortRequest(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	utils.RespondJSON(w, map[string]bool{"is_accessible": *body.IsAccessible})
+}
+
+// ~ /administrate/teams/{id}/ownership ~
+// Transfert de propriété immédiat, sans acceptation du membre
+func HandleForceTransferOwnership(w http.ResponseWriter, r *http.Request) {
+	id, err := getTeamID(r)
+	if err != nil {
+		utils.AbortRequest(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	var body struct {
+		MemberID uuid.UUID `json:"member_id"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&body); err != nil || body.MemberID == uuid.Nil {
+		utils.AbortRequest(w, "body invalid", http.StatusBadRequest)
+		return
+	}
+
+	adminUUID, err := utils.ExtractUserIDFromJWT(r)
+	if err != nil {
+		utils.AbortRequest(w, "Authorization Token is invalid.", http.StatusUnauthorized)
+		return
+	}
+
+	err = database.DB.Transaction(func(tx *gorm.DB) error {
+		previousOwners, err := admin_team_service.ForceTransferOwnership(tx, id, body.MemberID, adminUUID)
+		if err != nil {
+			return err
+		}
+
+		return administration.Audit(tx, r, audit_service.Event{
+			ActorID:    adminUUID,
+			Action:     "team.ownership_forced",
+			TargetType: audit_service.TargetTeam,
+			TargetID:   id.String(),
+			Before:     map[string]interface{}{"owners": previousOwners},
+			After:      map[string]interface{}{"owners": []uuid.UUID{body.MemberID}},
+		})
+	})
+	if errors.Is(err, administration.ErrNotAudited) {
+		utils.AbortRequest(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		utils.AbortRequest(w, "Team not found", http.StatusNotFound)
+		return
+	}
+	if errors.Is(err, admin_team_service.ErrAlreadyOwner) {
+		utils.AbortRequest(w, err.Error(), http.StatusConflict)
+		return
+	}
+	if err != nil {
+		utils.AbortRequest(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	utils.RespondJSON(w, "transferred")
+}
+
+// ~ /administrate/teams/{id}/merge ~
+// Fusionne la Team dans target_id, puis l'archive
+func HandleMergeTeam(w http.ResponseWriter, r *http.Request) {
+	id, err := getTeamID(r)
+	if err != nil {
+		utils.AbortRequest(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	var body struct {
+		TargetID uuid.UUID `json:"target_id"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&body); err != nil || body.TargetID == uuid.Nil {
+		utils.AbortRequest(w, "body invalid", http.StatusBadRequest)
+		return
+	}
+
+	adminUUID, err := utils.ExtractUserIDFromJWT(r)
+	if err != nil {
+		utils.AbortRequest(w, "Authorization Token is invalid.", http.StatusUnauthorized)
+		return
+	}
+
+	var merge admin_team_service.MergeResult
+	err = database.DB.Transaction(func(tx *gorm.DB) error {
+		var err error
+		merge, err = admin_team_service.Merge(tx, id, body.TargetID, adminUUID)
+		if err != nil {
+			return err
+		}
+
+		return administration.Audit(tx, r, audit_service.Event{
+			ActorID:    adminUUID,
+			Action:     "team.merged",
+			TargetType: audit_service.TargetTeam,
+			TargetID:   id.String(),
+			After: map[string]interface{}{
+				"target_id": body.TargetID,
+				"result":    merge,
+			},
+		})
+	})
+	if errors.Is(err, administration.ErrNotAudited) {
+		utils.AbortRequest(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		utils.AbortRequest(w, "Team not found", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		utils.AbortRequest(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	utils.RespondJSON(w, merge)
+}
+
+// ~ /administrate/teams/{id}/members/{member_id} ~
+func HandleRemoveTeamMember(w http.ResponseWriter, r *http.Request) {
+	id, err := getTeamID(r)
+	if err != nil {
+		utils.AbortRequest(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	memberUUID, err := uuid.Parse(mux.Vars(r)["member_id"])
+	if err != nil {
+		utils.AbortRequest(w, "member_id invalid", http.StatusBadRequest)
+		return
+	}
+
+	adminUUID, err := utils.ExtractUserIDFromJWT(r)
+	if err != nil {
+		utils.AbortRequest(w, "Authorization Token is invalid.", http.StatusUnauthorized)
+		return
+	}
+
+	// Même règles que pour un admin de la Team : dernier owner et Team personnelle protégés
+	member, err := team_member_service.GetByMemberId(id, memberUUID)
+	if err != nil {
+		utils.AbortRequest(w, "Member not found", http.StatusNotFound)
+		return
+	}
+	err = database.DB.Transaction(func(tx *gorm.DB) error {
+		if err := team_member_service.Remove(tx, id, memberUUID, adminUUID); err != nil {
+			return err
+		}
+
+		return administration.Audit(tx, r, audit_service.Event{
+			ActorID:    adminUUID,
+			Action:     "team.member_removed",
+			TargetType: audit_service.TargetTeam,
+			TargetID:   id.String(),
+			Before: map[string]interface{}{
+				"member_id": memberUUID,
+				"role":      member.Role,
+			},
+		})
+	})
+	if errors.Is(err, administration.ErrNotAudited) {
+		utils.AbortRequest(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if errors.Is(err, team_member_service.ErrLastOwner) || errors.Is(err, team_member_service.ErrPersonalTeamMember) {
+		utils.AbortRequest(w, err.Error(), http.StatusConflict)
+		return
+	}
+	if err != nil {
+		utils.AbortRequest(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	utils.RespondJSON(w, "removed")
+}
+
+// ~ /administrate/teams/archived ~
+
+func HandleGetArchivedTeams(w http.ResponseWriter, r *http.Request) {
+	teams, err := team_service.GetArchived()
+	if err != nil {
+		utils.AbortRequest(w, "Error fetching archived teams", http.StatusInternalServerError)
+		return
+	}
+
+	retention := team_service.RetentionPeriod()
+	data := make([]map[string]interface{}, len(teams))
+	for i, team := range teams {
+		data[i] = map[string]interface{}{
+			"id":          team.ID,
+			"name":        team.Name,
+			"type":        team.Type,
+			"archived_at": team.ArchivedAt,
+			"purge_after": team.ArchivedAt.Add(retention),
+		}
+	}
+	utils.RespondJSON(w, data)
+}
+
+// ~ /administrate/teams/{id}/restore ~
+
+func HandleRestoreTeam(w http.ResponseWriter, r *http.Request) {
+	id, err := getTeamID(r)
+	if err != nil {
+		utils.AbortRequest(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	adminUUID, err := utils.ExtractUserIDFromJWT(r)
+	if err != nil {
+		utils.AbortRequest(w, "Authorization Token is invalid.", http.StatusUnauthorized)
+		return
+	}
+
+	err = database.DB.Transaction(func(tx *gorm.DB) error {
+		if err := team_service.Restore(tx, id, adminUUID); err != nil {
+			return err
+		}
+
+		return administration.Audit(tx, r, audit_service.Event{
+			ActorID:    adminUUID,
+			Action:     "team.restored",
+			TargetType: audit_service.TargetTeam,
+			TargetID:   id.String(),
+		})
+	})
+	if errors.Is(err, administration.ErrNotAudited) {
+		utils.AbortRequest(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if err != nil {
+		utils.AbortRequest(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	utils.RespondJSON(w, "restored")
+}
@@ -0,0 +1,244 @@
+package admin_users
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"gox/database"
+	"gox/database/models"
+	"gox/routes/administration"
+	admin_user_service "gox/services/administration/users"
+	audit_service "gox/services/audit"
+	user_service "gox/services/users"
+	user_password_reset_service "gox/services/users/passwordreset"
+	"gox/utils"
+	"net/http"
+	"strconv"
+
+	"github.com/google/uuid"
+	"github.com/gorilla/mux"
+	"gorm.io/gorm"
+)
+
+// ~ /administrate/users ~
+// Filtres : q (email ou username), team (nom ou ID), is_active, is_accessible, is_app_admin (true|false),
+// sort (created_on|email|username), order (asc|desc), page, per_page
+func HandleGetUsers(w http.ResponseWriter, r *http.Request) {
+	params := r.URL.Query()
+	query := admin_user_service.Query{
+		Search:     params.Get("q"),
+		Team:       params.Get("team"),
+		Sort:       params.Get("sort"),
+		Descending: params.Get("order") == "desc",
+	}
+	for name, target := range map[string]**bool{"is_active": &query.IsActive, "is_accessible": &query.IsAccessible, "is_app_admin": &query.IsAppAdmin} {
+		if value := params.Get(name); value != "" {
+			parsed, err := strconv.ParseBool(value)
+			if err != nil {
+				utils.AbortRequest(w, fmt.Sprintf("Invalid %s (expected true or false)", name), http.StatusBadRequest)
+				return
+			}
+			*target = &parsed
+		}
+	}
+
+	// Pagination
+	query.Page, _ = strconv.Atoi(params.Get("page"))
+	query.PerPage, _ = strconv.Atoi(params.Get("per_page"))
+	if query.Page < 1 {
+		query.Page = 1
+	}
+	if query.PerPage < 1 || query.PerPage > admin_user_service.MaxPerPage {
+		query.PerPage = admin_user_service.DefaultPerPage
+	}
+
+	users, total, err := admin_user_service.Search(query)
+	if err != nil {
+		utils.AbortRequest(w, "Error fetching users", http.StatusInternalServerError)
+		return
+	}
+
+	// Réponse JSON
+	utils.RespondJSON(w, map[string]interface{}{
+		"items":    users,
+		"page":     query.Page,
+		"per_page": query.PerPage,
+		"total":    total,
+	})
+}
+
+// ~ /administrate/users/{id} ~
+
+func getUserID(r *http.Request) (uuid.UUID, error) {
+	vars := mux.Vars(r)
+	userUUID, err := uuid.Parse(vars["id"])
+	if err != nil {
+		return uuid.UUID{}, fmt.Errorf("id invalid")
+	}
+
+	return userUUID, nil
+}
+
+func HandleGetUser(w http.ResponseWriter, r *http.Request) {
+	id, err := getUserID(r)
+	if err != nil {
+		utils.AbortRequest(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	// Utilisateur, profil, Teams, abonnements et crédits
+	detail, err := admin_user_service.Get(id)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		utils.AbortRequest(w, "User not found", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		utils.AbortRequest(w, "Error fetching user", http.StatusInternalServerError)
+		return
+	}
+
+	utils.RespondJSON(w, detail)
+}
+
+// HandleUpdateUser modifie les accès : is_active, is_accessible (compte masqué), is_app_admin
+func HandleUpdateUser(w http.ResponseWriter, r *http.Request) {
+	id, err := getUserID(r)
+	if err != nil {
+		utils.AbortRequest(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	var changes admin_user_service.Changes
+	if err := json.NewDecoder(r.Body).Decode(&changes); err != nil {
+		utils.AbortRequest(w, "body invalid", http.StatusBadRequest)
+		return
+	}
+
+	adminUUID, err := utils.ExtractUserIDFromJWT(r)
+	if err != nil {
+		utils.AbortRequest(w, "Authorization Token is invalid.", http.StatusUnauthorized)
+		return
+	}
+
+	// Action et entrée d'audit dans la même transaction
+	var after admin_user_service.Access
+	err = database.DB.Transaction(func(tx *gorm.DB) error {
+		var before admin_user_service.Access
+		var err error
+		before, after, err = admin_user_service.UpdateAccess(tx, id, adminUUID, changes)
+		if err != nil || before == after {
+			return err
+		}
+
+		return administration.Audit(tx, r, audit_service.Event{
+			ActorID:    adminUUID,
+			Action:     "user.access_updated",
+			TargetType: audit_service.TargetUser,
+			TargetID:   id.String(),
+			Before:     before,
+			After:      after,
+		})
+	})
+	if errors.Is(err, administration.ErrNotAudited) {
+		utils.AbortRequest(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		utils.AbortRequest(w, "User not found", http.StatusNotFound)
+		return
+	}
+	if errors.Is(err, admin_user_service.ErrSelfAction) || errors.Is(err, admin_user_service.ErrLastAdmin) {
+		utils.AbortRequest(w, err.Error(), http.StatusConflict)
+		return
+	}
+	if err != nil {
+		utils.AbortRequest(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	utils.RespondJSON(w, after)
+}
+
+// ~ /administrate/users/{id}/password-reset ~
+func HandleForcePasswordReset(w http.ResponseWriter, r *http.Request) {
+	id, err := getUserID(r)
+	if err != nil {
+		utils.AbortRequest(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	adminUUID, err := utils.ExtractUserIDFromJWT(r)
+	if err != nil {
+		utils.AbortRequest(w, "Authorization Token is invalid.", http.StatusUnauthorized)
+		return
+	}
+
+	// Sessions révoquées et connexion bloquée
+	var reset models.UserPasswordReset
+	var token string
+	err = database.DB.Transaction(func(tx *gorm.DB) error {
+		var err error
+		reset, token, err = user_password_reset_service.Force(tx, id, adminUUID)
+		if err != nil {
+			return err
+		}
+
+		return administration.Audit(tx, r, audit_service.Event{
+			ActorID:    adminUUID,
+			Action:     "user.password_reset_forced",
+			TargetType: audit_service.TargetUser,
+			TargetID:   id.String(),
+		})
+	})
+	if errors.Is(err, administration.ErrNotAudited) {
+		utils.AbortRequest(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		utils.AbortRequest(w, "User not found", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		utils.AbortRequest(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	// Lien envoyé par email, une fois la réinitialisation enregistrée
+	user_password_reset_service.SendForced(reset, token)
+
+	utils.RespondJSON(w, map[string]interface{}{
+		"expires_at": reset.ExpiresAt,
+	})
+}
+
+// ~ /administrate/users/{id}/sessions ~
+func HandleRevokeSessions(w http.ResponseWriter, r *http.Request) {
+	id, err := getUserID(r)
+	if err != nil {
+		utils.AbortRequest(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	// Tous les tokens déjà émis sont refusés
+	err = database.DB.Transaction(func(tx *gorm.DB) error {
+		if err := user_service.RevokeSessions(tx, id); err != nil {
+			return err
+		}
+
+		return administration.Audit(tx, r, audit_service.Event{
+			Action:     "user.sessions_revoked",
+			TargetType: audit_service.TargetUser,
+			TargetID:   id.String(),
+		})
+	})
+	if errors.Is(err, administration.ErrNotAudited) {
+		utils.AbortRequest(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if err != nil {
+		utils.AbortRequest(w, err.Error(), http.StatusNotFound)
+		return
+	}
+
+	utils.RespondJSON(w, "revoked")
+}
@@ -0,0 +1,32 @@
+package auth
+
+import (
+	"encoding/json"
+	user_email_verification_service "gox/services/users/emailverification"
+	"gox/utils"
+	"net/http"
+)
+
+// HandleVerifyEmail confirme l'adresse email à partir du token reçu par email
+func HandleVerifyEmail(w http.ResponseWriter, r *http.Request) {
+	var input struct {
+		Token string `json:"token"`
+	}
+
+	if err := json.NewDecoder(r.Body).Decode(&input); err != nil {
+		utils.AbortRequest(w, "Invalid JSON", http.StatusBadRequest)
+		return
+	}
+
+	// Vérification
+	userID, err := user_email_verification_service.Complete(input.Token)
+	if err != nil {
+		utils.AbortRequest(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	utils.ConsoleLogRequest(r, "📧 Email verified for %s", userID)
+
+	// Réponse JSON
+	utils.RespondJSON(w, "email verified")
+}
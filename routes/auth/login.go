@@ -36,8 +36,18 @@ func HandleLogin(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	// Vérifie que le compte est utilisable
+	if !user.IsActive {
+		utils.AbortRequest(w, "Account is disabled", http.StatusForbidden)
+		return
+	}
+	if user.PasswordResetRequired {
+		utils.AbortRequest(w, "Password reset required, check your emails", http.StatusForbidden)
+		return
+	}
+
 	// Générer un token JWT
-	token, err := utils.GenerateJWT(user.ID, false)
+	token, err := utils.GenerateJWT(user.ID, false, user.SessionVersion)
 	if err != nil {
 		utils.AbortRequest(w, fmt.Sprintf("Could not generate token: %s", err), http.StatusInternalServerError)
 		return
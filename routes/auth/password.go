@@ -0,0 +1,33 @@
+package auth
+
+import (
+	"encoding/json"
+	user_password_reset_service "gox/services/users/passwordreset"
+	"gox/utils"
+	"net/http"
+)
+
+// HandleResetPassword enregistre un nouveau mot de passe à partir du token reçu par email
+func HandleResetPassword(w http.ResponseWriter, r *http.Request) {
+	var input struct {
+		Token    string `json:"token"`
+		Password string `json:"password"`
+	}
+
+	if err := json.NewDecoder(r.Body).Decode(&input); err != nil {
+		utils.AbortRequest(w, "Invalid JSON", http.StatusBadRequest)
+		return
+	}
+
+	// Réinitialisation
+	userID, err := user_password_reset_service.Complete(input.Token, input.Password)
+	if err != nil {
+		utils.AbortRequest(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	utils.ConsoleLogRequest(r, "🔑 Password reset for %s", userID)
+
+	// Réponse JSON
+	utils.RespondJSON(w, "password reset")
+}
@@ -3,6 +3,7 @@ package auth
 import (
 	"encoding/json"
 	"fmt"
+	team_invitation_service "gox/services/teams/invitations"
 	user_service "gox/services/users"
 	"gox/utils"
 	"net/http"
@@ -10,11 +11,12 @@ import (
 
 // handleRegister crée un utilisateur, sa personal team, son team member et retourne un token JWT
 func HandleRegister(w http.ResponseWriter, r *http.Request) {
-	utils.ConsoleLog("📥 Requête POST /auth/register")
+	utils.ConsoleLogRequest(r, "📥 Requête POST /auth/register")
 
 	var input struct {
-		Email    string `json:"email"`
-		Password string `json:"password"`
+		Email           string `json:"email"`
+		Password        string `json:"password"`
+		InvitationToken string `json:"invitation_token"`
 	}
 
 	if err := json.NewDecoder(r.Body).Decode(&input); err != nil {
@@ -33,8 +35,15 @@ func HandleRegister(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	// Générer un token JWT
-	token, err := utils.GenerateJWT(userID, false)
+	// Rejoindre automatiquement la Team, si l'inscription fait suite à une invitation
+	if input.InvitationToken != "" {
+		if _, err := team_invitation_service.Accept(input.InvitationToken, userID); err != nil {
+			utils.ConsoleLogRequest(r, "⚠️ Invitation not accepted for %s: %v", userID, err)
+		}
+	}
+
+	// Générer un token JWT (compte nouveau : première version des sessions)
+	token, err := utils.GenerateJWT(userID, false, 0)
 	if err != nil {
 		utils.AbortRequest(w, fmt.Sprintf("Could not generate token: %s", err), http.StatusInternalServerError)
 		return
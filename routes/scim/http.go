@@ -0,0 +1,257 @@
+package scim
+
+import (
+	"encoding/json"
+	"errors"
+	scim_service "gox/services/scim"
+	"gox/utils"
+	"net/http"
+	"strconv"
+
+	"github.com/gorilla/mux"
+)
+
+const contentType = "application/scim+json"
+
+func respond(w http.ResponseWriter, status int, data any) {
+	w.Header().Set("Content-Type", contentType)
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(data)
+}
+
+func abort(w http.ResponseWriter, r *http.Request, err error) {
+	var scimErr *scim_service.Error
+	if !errors.As(err, &scimErr) {
+		utils.ConsoleLogRequest(r, "❌ SCIM error: %v", err)
+		scimErr = &scim_service.Error{Status: http.StatusInternalServerError, Detail: "An error occured"}
+	}
+	respond(w, scimErr.Status, scimErr)
+}
+
+func invalidBody(w http.ResponseWriter, r *http.Request) {
+	abort(w, r, &scim_service.Error{Status: http.StatusBadRequest, ScimType: "invalidSyntax", Detail: "body invalid"})
+}
+
+// listParams lit filter, startIndex et count
+func listParams(w http.ResponseWriter, r *http.Request) (scim_service.Filter, int, int, bool) {
+	query := r.URL.Query()
+
+	filter, err := scim_service.ParseFilter(query.Get("filter"))
+	if err != nil {
+		abort(w, r, err)
+		return nil, 0, 0, false
+	}
+
+	startIndex, err := strconv.Atoi(query.Get("startIndex"))
+	if err != nil {
+		startIndex = 1
+	}
+	count, err := strconv.Atoi(query.Get("count"))
+	if err != nil {
+		count = scim_service.DefaultCount
+	}
+
+	return filter, startIndex, count, true
+}
+
+// ~ /scim/v2/ServiceProviderConfig ~
+func HandleServiceProviderConfig(w http.ResponseWriter, r *http.Request) {
+	respond(w, http.StatusOK, map[string]any{
+		"schemas":        []string{"urn:ietf:params:scim:schemas:core:2.0:ServiceProviderConfig"},
+		"patch":          map[string]bool{"supported": true},
+		"bulk":           map[string]any{"supported": false, "maxOperations": 0, "maxPayloadSize": 0},
+		"filter":         map[string]any{"supported": true, "maxResults": scim_service.MaxCount},
+		"changePassword": map[string]bool{"supported": false},
+		"sort":           map[string]bool{"supported": false},
+		"etag":           map[string]bool{"supported": false},
+		"authenticationSchemes": []map[string]any{{
+			"type":        "oauthbearertoken",
+			"name":        "Bearer token",
+			"description": "Per-team SCIM token, created from the team settings",
+			"primary":     true,
+		}},
+	})
+}
+
+// ~ /scim/v2/ResourceTypes ~
+func HandleResourceTypes(w http.ResponseWriter, r *http.Request) {
+	resourceTypes := []any{
+		map[string]any{
+			"schemas":  []string{"urn:ietf:params:scim:schemas:core:2.0:ResourceType"},
+			"id":       "User",
+			"name":     "User",
+			"endpoint": "/Users",
+			"schema":   scim_service.SchemaUser,
+		},
+		map[string]any{
+			"schemas":  []string{"urn:ietf:params:scim:schemas:core:2.0:ResourceType"},
+			"id":       "Group",
+			"name":     "Group",
+			"endpoint": "/Groups",
+			"schema":   scim_service.SchemaGroup,
+		},
+	}
+	respond(w, http.StatusOK, scim_service.ListResponse{
+		Schemas:      []string{scim_service.SchemaListResponse},
+		TotalResults: len(resourceTypes),
+		StartIndex:   1,
+		ItemsPerPage: len(resourceTypes),
+		Resources:    resourceTypes,
+	})
+}
+
+// ~ /scim/v2/Users ~
+func HandleListUsers(w http.ResponseWriter, r *http.Request) {
+	filter, startIndex, count, ok := listParams(w, r)
+	if !ok {
+		return
+	}
+
+	list, err := scim_service.ListUsers(teamIDFromRequest(r), filter, startIndex, count)
+	if err != nil {
+		abort(w, r, err)
+		return
+	}
+	respond(w, http.StatusOK, list)
+}
+
+func HandleCreateUser(w http.ResponseWriter, r *http.Request) {
+	var input scim_service.User
+	if err := json.NewDecoder(r.Body).Decode(&input); err != nil {
+		invalidBody(w, r)
+		return
+	}
+
+	user, err := scim_service.CreateUser(teamIDFromRequest(r), input)
+	if err != nil {
+		abort(w, r, err)
+		return
+	}
+	respond(w, http.StatusCreated, user)
+}
+
+// ~ /scim/v2/Users/{id} ~
+func HandleGetUser(w http.ResponseWriter, r *http.Request) {
+	user, err := scim_service.GetUser(teamIDFromRequest(r), mux.Vars(r)["id"])
+	if err != nil {
+		abort(w, r, err)
+		return
+	}
+	respond(w, http.StatusOK, user)
+}
+
+func HandleReplaceUser(w http.ResponseWriter, r *http.Request) {
+	var input scim_service.User
+	if err := json.NewDecoder(r.Body).Decode(&input); err != nil {
+		invalidBody(w, r)
+		return
+	}
+
+	user, err := scim_service.ReplaceUser(teamIDFromRequest(r), mux.Vars(r)["id"], input)
+	if err != nil {
+		abort(w, r, err)
+		return
+	}
+	respond(w, http.StatusOK, user)
+}
+
+func HandlePatchUser(w http.ResponseWriter, r *http.Request) {
+	var patch scim_service.PatchRequest
+	if err := json.NewDecoder(r.Body).Decode(&patch); err != nil {
+		invalidBody(w, r)
+		return
+	}
+
+	user, err := scim_service.PatchUser(teamIDFromRequest(r), mux.Vars(r)["id"], patch)
+	if err != nil {
+		abort(w, r, err)
+		return
+	}
+	respond(w, http.StatusOK, user)
+}
+
+func HandleDeleteUser(w http.ResponseWriter, r *http.Request) {
+	if err := scim_service.DeleteUser(teamIDFromRequest(r), mux.Vars(r)["id"]); err != nil {
+		abort(w, r, err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// ~ /scim/v2/Groups ~
+func HandleListGroups(w http.ResponseWriter, r *http.Request) {
+	filter, startIndex, count, ok := listParams(w, r)
+	if !ok {
+		return
+	}
+
+	list, err := scim_service.ListGroups(teamIDFromRequest(r), filter, startIndex, count)
+	if err != nil {
+		abort(w, r, err)
+		return
+	}
+	respond(w, http.StatusOK, list)
+}
+
+func HandleCreateGroup(w http.ResponseWriter, r *http.Request) {
+	var input scim_service.Group
+	if err := json.NewDecoder(r.Body).Decode(&input); err != nil {
+		invalidBody(w, r)
+		return
+	}
+
+	group, err := scim_service.CreateGroup(teamIDFromRequest(r), input)
+	if err != nil {
+		abort(w, r, err)
+		return
+	}
+	respond(w, http.StatusCreated, group)
+}
+
+// ~ /scim/v2/Groups/{id} ~
+func HandleGetGroup(w http.ResponseWriter, r *http.Request) {
+	group, err := scim_service.GetGroup(teamIDFromRequest(r), mux.Vars(r)["id"])
+	if err != nil {
+		abort(w, r, err)
+		return
+	}
+	respond(w, http.StatusOK, group)
+}
+
+func HandleReplaceGroup(w http.ResponseWriter, r *http.Request) {
+	var input scim_service.Group
+	if err := json.NewDecoder(r.Body).Decode(&input); err != nil {
+		invalidBody(w, r)
+		return
+	}
+
+	group, err := scim_service.ReplaceGroup(teamIDFromRequest(r), mux.Vars(r)["id"], input)
+	if err != nil {
+		abort(w, r, err)
+		return
+	}
+	respond(w, http.StatusOK, group)
+}
+
+func HandlePatchGroup(w http.ResponseWriter, r *http.Request) {
+	var patch scim_service.PatchRequest
+	if err := json.NewDecoder(r.Body).Decode(&patch); err != nil {
+		invalidBody(w, r)
+		return
+	}
+
+	group, err := scim_service.PatchGroup(teamIDFromRequest(r), mux.Vars(r)["id"], patch)
+	if err != nil {
+		abort(w, r, err)
+		return
+	}
+	respond(w, http.StatusOK, group)
+}
+
+func HandleDeleteGroup(w http.ResponseWriter, r *http.Request) {
+	if err := scim_service.DeleteGroup(teamIDFromRequest(r), mux.Vars(r)["id"]); err != nil {
+		abort(w, r, err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
@@ -0,0 +1,132 @@
+package scim
+
+import (
+	"encoding/json"
+	scim_service "gox/services/scim"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+func decode(t *testing.T, rec *httptest.ResponseRecorder) map[string]any {
+	t.Helper()
+	var body map[string]any
+	if err := json.Unmarshal(rec.Body.Bytes(), &body); err != nil {
+		t.Fatalf("invalid JSON body %q: %v", rec.Body.String(), err)
+	}
+	return body
+}
+
+// assertError vérifie une réponse d'erreur SCIM (RFC 7644 §3.12)
+func assertError(t *testing.T, rec *httptest.ResponseRecorder, status int, scimType string) {
+	t.Helper()
+	if rec.Code != status {
+		t.Fatalf("status = %d, want %d (body %s)", rec.Code, status, rec.Body.String())
+	}
+	if got := rec.Header().Get("Content-Type"); got != contentType {
+		t.Errorf("Content-Type = %q, want %q", got, contentType)
+	}
+
+	body := decode(t, rec)
+	if schemas, _ := body["schemas"].([]any); len(schemas) != 1 || schemas[0] != scim_service.SchemaError {
+		t.Errorf("schemas = %v, want [%s]", body["schemas"], scim_service.SchemaError)
+	}
+	if body["status"] != strconv.Itoa(status) {
+		t.Errorf("status field = %#v, want %q", body["status"], strconv.Itoa(status))
+	}
+	if scimType != "" && body["scimType"] != scimType {
+		t.Errorf("scimType = %#v, want %q", body["scimType"], scimType)
+	}
+}
+
+func TestMiddlewareRequiresBearerToken(t *testing.T) {
+	next := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		t.Fatal("handler reached without a token")
+	})
+
+	for _, header := range []string{"", "Basic dXNlcjpwYXNz", "Bearer "} {
+		req := httptest.NewRequest(http.MethodGet, "/scim/v2/Users", nil)
+		if header != "" {
+			req.Header.Set("Authorization", header)
+		}
+		rec := httptest.NewRecorder()
+
+		SCIMRouteMiddleware(next).ServeHTTP(rec, req)
+
+		assertError(t, rec, http.StatusUnauthorized, "")
+		if got := rec.Header().Get("WWW-Authenticate"); got != `Bearer realm="scim"` {
+			t.Errorf("Authorization %q: WWW-Authenticate = %q", header, got)
+		}
+	}
+}
+
+func TestServiceProviderConfig(t *testing.T) {
+	rec := httptest.NewRecorder()
+	HandleServiceProviderConfig(rec, httptest.NewRequest(http.MethodGet, "/scim/v2/ServiceProviderConfig", nil))
+
+	if rec.Code != http.StatusOK || rec.Header().Get("Content-Type") != contentType {
+		t.Fatalf("status = %d, Content-Type = %q", rec.Code, rec.Header().Get("Content-Type"))
+	}
+	body := decode(t, rec)
+	for _, feature := range []string{"patch", "bulk", "filter", "changePassword", "sort", "etag"} {
+		if _, ok := body[feature].(map[string]any)["supported"].(bool); !ok {
+			t.Errorf("%s.supported is missing", feature)
+		}
+	}
+	if filter := body["filter"].(map[string]any); filter["maxResults"] != float64(scim_service.MaxCount) {
+		t.Errorf("filter.maxResults = %v, want %d", filter["maxResults"], scim_service.MaxCount)
+	}
+	if schemes, _ := body["authenticationSchemes"].([]any); len(schemes) == 0 {
+		t.Error("authenticationSchemes is empty")
+	}
+}
+
+func TestResourceTypes(t *testing.T) {
+	rec := httptest.NewRecorder()
+	HandleResourceTypes(rec, httptest.NewRequest(http.MethodGet, "/scim/v2/ResourceTypes", nil))
+
+	body := decode(t, rec)
+	if body["totalResults"] != float64(2) {
+		t.Fatalf("totalResults = %v, want 2", body["totalResults"])
+	}
+	schemas := map[string]string{}
+	for _, resource := range body["Resources"].([]any) {
+		resourceType := resource.(map[string]any)
+		schemas[resourceType["id"].(string)] = resourceType["schema"].(string)
+	}
+	if schemas["User"] != scim_service.SchemaUser || schemas["Group"] != scim_service.SchemaGroup {
+		t.Errorf("resource types = %v", schemas)
+	}
+}
+
+// Un filtre invalide est refusé avant tout accès à la base
+func TestListInvalidFilter(t *testing.T) {
+	handlers := map[string]http.HandlerFunc{"/scim/v2/Users": HandleListUsers, "/scim/v2/Groups": HandleListGroups}
+
+	for path, handler := range handlers {
+		rec := httptest.NewRecorder()
+		handler(rec, httptest.NewRequest(http.MethodGet, path+`?filter=userName+eq`, nil))
+		assertError(t, rec, http.StatusBadRequest, "invalidFilter")
+	}
+}
+
+// Un corps invalide est refusé avant tout accès à la base
+func TestInvalidBody(t *testing.T) {
+	handlers := map[string]http.HandlerFunc{
+		"POST /scim/v2/Users":     HandleCreateUser,
+		"PUT /scim/v2/Users/1":    HandleReplaceUser,
+		"PATCH /scim/v2/Users/1":  HandlePatchUser,
+		"POST /scim/v2/Groups":    HandleCreateGroup,
+		"PUT /scim/v2/Groups/1":   HandleReplaceGroup,
+		"PATCH /scim/v2/Groups/1": HandlePatchGroup,
+	}
+
+	for route, handler := range handlers {
+		method, path, _ := strings.Cut(route, " ")
+		rec := httptest.NewRecorder()
+		handler(rec, httptest.NewRequest(method, path, strings.NewReader(`{"userName":`)))
+		assertError(t, rec, http.StatusBadRequest, "invalidSyntax")
+	}
+}
@@ -0,0 +1,43 @@
+package scim
+
+import (
+	"context"
+	scim_service "gox/services/scim"
+	"net/http"
+	"strings"
+
+	"github.com/google/uuid"
+)
+
+type contextKey string
+
+const teamIDKey contextKey = "scimTeamID"
+
+// ~ /scim/v2/... ~
+func SCIMRouteMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// ~ SCIM clients authenticate with a per-team bearer token
+		header := r.Header.Get("Authorization")
+		token, found := strings.CutPrefix(header, "Bearer ")
+		if !found || token == "" {
+			w.Header().Set("WWW-Authenticate", `Bearer realm="scim"`)
+			abort(w, r, &scim_service.Error{Status: http.StatusUnauthorized, Detail: "Bearer token is missing"})
+			return
+		}
+
+		teamID, err := scim_service.Authenticate(strings.TrimSpace(token))
+		if err != nil {
+			w.Header().Set("WWW-Authenticate", `Bearer realm="scim"`)
+			abort(w, r, &scim_service.Error{Status: http.StatusUnauthorized, Detail: err.Error()})
+			return
+		}
+
+		// ~ OK. Serve, with the team bound to the request.
+		next.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), teamIDKey, teamID)))
+	})
+}
+
+func teamIDFromRequest(r *http.Request) uuid.UUID {
+	teamID, _ := r.Context().Value(teamIDKey).(uuid.UUID)
+	return teamID
+}
@@ -2,19 +2,33 @@ package server
 
 import (
 	"bytes"
+	"context"
+	"errors"
 	"fmt"
-	"gox/database"
 	"gox/database/models"
 	"gox/routes/administration"
+	admin_analytics "gox/routes/administration/analytics"
+	admin_audit "gox/routes/administration/audit"
 	admin_auth "gox/routes/administration/auth"
 	admin_logs "gox/routes/administration/logs"
 	admin_subscriptions "gox/routes/administration/subscriptions"
+	admin_teams "gox/routes/administration/teams"
+	admin_users "gox/routes/administration/users"
 	"gox/routes/auth"
+	"gox/routes/scim"
 	"gox/routes/teams"
 	"gox/routes/users"
+	redaction_service "gox/services/redaction"
+	request_log_service "gox/services/requestlogs"
 	"gox/utils"
 	"io"
+	"mime"
 	"net/http"
+	"os"
+	"os/signal"
+	"strconv"
+	"strings"
+	"syscall"
 	"time"
 
 	"github.com/google/uuid"
@@ -61,6 +75,14 @@ func Start() {
 		auth.HandleRegister(w, r)
 	}, nil)
 
+	createRoute(router, []string{http.MethodPost}, "/auth/password/reset", func(w http.ResponseWriter, r *http.Request) {
+		auth.HandleResetPassword(w, r)
+	}, nil)
+
+	createRoute(router, []string{http.MethodPost}, "/auth/email/verify", func(w http.ResponseWriter, r *http.Request) {
+		auth.HandleVerifyEmail(w, r)
+	}, nil)
+
 	// ~ USERS ~
 
 	createRoute(router, []string{http.MethodGet, http.MethodPost}, "/users", func(w http.ResponseWriter, r *http.Request) {
@@ -102,6 +124,53 @@ func Start() {
 		}
 	}, []func(http.Handler) http.Handler{users.UserRouteMiddleware})
 
+	createRoute(router, []string{http.MethodGet, http.MethodPost, http.MethodPut, http.MethodDelete}, "/users/{id}/profile/avatar", func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodGet {
+			users.HandleGetAvatar(w, r)
+		} else if r.Method == http.MethodPost || r.Method == http.MethodPut {
+			users.HandleUploadAvatar(w, r)
+		} else if r.Method == http.MethodDelete {
+			users.HandleDeleteAvatar(w, r)
+		}
+	}, []func(http.Handler) http.Handler{users.UserRouteMiddleware})
+
+	createRoute(router, []string{http.MethodGet}, "/users/{id}/profile/usernames", func(w http.ResponseWriter, r *http.Request) {
+		users.HandleGetUsernameHistory(w, r)
+	}, []func(http.Handler) http.Handler{users.UserRouteMiddleware})
+
+	createRoute(router, []string{http.MethodGet, http.MethodPost}, "/users/{id}/export", func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodGet {
+			users.HandleGetUserExports(w, r)
+		} else if r.Method == http.MethodPost {
+			users.HandleRequestUserExport(w, r)
+		}
+	}, []func(http.Handler) http.Handler{users.UserRouteMiddleware})
+
+	// Le lien envoyé par email contient son propre token
+	createRoute(router, []string{http.MethodGet}, "/exports/{export_id}/download", func(w http.ResponseWriter, r *http.Request) {
+		users.HandleDownloadUserExport(w, r)
+	}, nil)
+
+	createRoute(router, []string{http.MethodGet, http.MethodPost, http.MethodDelete}, "/users/{id}/deletion", func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodGet {
+			users.HandleGetUserDeletion(w, r)
+		} else if r.Method == http.MethodPost {
+			users.HandleRequestUserDeletion(w, r)
+		} else if r.Method == http.MethodDelete {
+			users.HandleCancelUserDeletion(w, r)
+		}
+	}, []func(http.Handler) http.Handler{users.UserRouteMiddleware})
+
+	// ~ PROFILES ~
+
+	createRoute(router, []string{http.MethodGet}, "/profiles/{username}", func(w http.ResponseWriter, r *http.Request) {
+		users.HandleGetPublicProfile(w, r)
+	}, nil)
+
+	createRoute(router, []string{http.MethodGet, http.MethodHead}, "/avatars/{user_id}/{version}/{file}", func(w http.ResponseWriter, r *http.Request) {
+		users.HandleServeAvatarFile(w, r)
+	}, nil)
+
 	createRoute(router, []string{http.MethodGet, http.MethodPost}, "/users/{id}/subscriptions", func(w http.ResponseWriter, r *http.Request) {
 		if r.Method == http.MethodGet {
 			users.HandleGetUserSubscriptions(w, r)
@@ -148,6 +217,14 @@ func Start() {
 		}
 	}, []func(http.Handler) http.Handler{teams.TeamRouteMiddleware})
 
+	createRoute(router, []string{http.MethodPost}, "/teams/{id}/restore", func(w http.ResponseWriter, r *http.Request) {
+		teams.HandleRestoreTeam(w, r)
+	}, []func(http.Handler) http.Handler{teams.TeamRouteMiddleware})
+
+	createRoute(router, []string{http.MethodPost}, "/teams/{id}/convert", func(w http.ResponseWriter, r *http.Request) {
+		teams.HandleConvertTeam(w, r)
+	}, []func(http.Handler) http.Handler{teams.TeamRouteMiddleware})
+
 	createRoute(router, []string{http.MethodGet, http.MethodPost}, "/teams/{id}/members", func(w http.ResponseWriter, r *http.Request) {
 		if r.Method == http.MethodGet {
 			teams.HandleGetTeamMembers(w, r)
@@ -166,6 +243,198 @@ func Start() {
 		}
 	}, []func(http.Handler) http.Handler{teams.TeamMemberRouteMiddleware})
 
+	createRoute(router, []string{http.MethodGet, http.MethodPost}, "/teams/{id}/roles", func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodGet {
+			teams.HandleGetTeamRoles(w, r)
+		} else if r.Method == http.MethodPost {
+			teams.HandleCreateTeamRole(w, r)
+		}
+	}, []func(http.Handler) http.Handler{teams.TeamRolesRouteMiddleware})
+
+	createRoute(router, []string{http.MethodGet, http.MethodPatch, http.MethodDelete}, "/teams/{id}/roles/{role_id}", func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodGet {
+			teams.HandleGetTeamRole(w, r)
+		} else if r.Method == http.MethodPatch {
+			teams.HandleUpdateTeamRole(w, r)
+		} else if r.Method == http.MethodDelete {
+			teams.HandleDeleteTeamRole(w, r)
+		}
+	}, []func(http.Handler) http.Handler{teams.TeamRolesRouteMiddleware})
+
+	createRoute(router, []string{http.MethodGet, http.MethodPost}, "/teams/{id}/invitations", func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodGet {
+			teams.HandleGetTeamInvitations(w, r)
+		} else if r.Method == http.MethodPost {
+			teams.HandleCreateTeamInvitation(w, r)
+		}
+	}, []func(http.Handler) http.Handler{teams.TeamInvitationsRouteMiddleware})
+
+	createRoute(router, []string{http.MethodDelete}, "/teams/{id}/invitations/{invitation_id}", func(w http.ResponseWriter, r *http.Request) {
+		teams.HandleRevokeTeamInvitation(w, r)
+	}, []func(http.Handler) http.Handler{teams.TeamInvitationsRouteMiddleware})
+
+	createRoute(router, []string{http.MethodPost}, "/teams/{id}/invitations/{invitation_id}/resend", func(w http.ResponseWriter, r *http.Request) {
+		teams.HandleResendTeamInvitation(w, r)
+	}, []func(http.Handler) http.Handler{teams.TeamInvitationsRouteMiddleware})
+
+	createRoute(router, []string{http.MethodGet, http.MethodPost, http.MethodDelete}, "/teams/{id}/transfer-ownership", func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodGet {
+			teams.HandleGetOwnershipTransfer(w, r)
+		} else if r.Method == http.MethodPost {
+			teams.HandleRequestOwnershipTransfer(w, r)
+		} else if r.Method == http.MethodDelete {
+			teams.HandleCancelOwnershipTransfer(w, r)
+		}
+	}, []func(http.Handler) http.Handler{teams.TeamOwnershipRouteMiddleware})
+
+	createRoute(router, []string{http.MethodPost}, "/teams/{id}/transfer-ownership/accept", func(w http.ResponseWriter, r *http.Request) {
+		teams.HandleAcceptOwnershipTransfer(w, r)
+	}, []func(http.Handler) http.Handler{teams.TeamOwnershipRouteMiddleware})
+
+	createRoute(router, []string{http.MethodPost}, "/teams/{id}/transfer-ownership/decline", func(w http.ResponseWriter, r *http.Request) {
+		teams.HandleDeclineOwnershipTransfer(w, r)
+	}, []func(http.Handler) http.Handler{teams.TeamOwnershipRouteMiddleware})
+
+	createRoute(router, []string{http.MethodPost}, "/teams/{id}/members/{member_id}/suspend", func(w http.ResponseWriter, r *http.Request) {
+		teams.HandleSuspendTeamMember(w, r)
+	}, []func(http.Handler) http.Handler{teams.TeamMemberRouteMiddleware})
+
+	createRoute(router, []string{http.MethodPost}, "/teams/{id}/members/{member_id}/reactivate", func(w http.ResponseWriter, r *http.Request) {
+		teams.HandleReactivateTeamMember(w, r)
+	}, []func(http.Handler) http.Handler{teams.TeamMemberRouteMiddleware})
+
+	createRoute(router, []string{http.MethodPost}, "/teams/{id}/leave", func(w http.ResponseWriter, r *http.Request) {
+		teams.HandleLeaveTeam(w, r)
+	}, []func(http.Handler) http.Handler{teams.TeamRouteMiddleware})
+
+	createRoute(router, []string{http.MethodGet, http.MethodPost}, "/teams/{id}/subteams", func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodGet {
+			teams.HandleGetSubTeams(w, r)
+		} else if r.Method == http.MethodPost {
+			teams.HandleCreateSubTeam(w, r)
+		}
+	}, []func(http.Handler) http.Handler{teams.TeamRouteMiddleware})
+
+	createRoute(router, []string{http.MethodPut}, "/teams/{id}/parent", func(w http.ResponseWriter, r *http.Request) {
+		teams.HandleSetParentTeam(w, r)
+	}, []func(http.Handler) http.Handler{teams.TeamRouteMiddleware})
+
+	createRoute(router, []string{http.MethodGet}, "/teams/{id}/hierarchy", func(w http.ResponseWriter, r *http.Request) {
+		teams.HandleGetTeamHierarchy(w, r)
+	}, []func(http.Handler) http.Handler{teams.TeamRouteMiddleware})
+
+	createRoute(router, []string{http.MethodGet, http.MethodPost}, "/teams/{id}/domains", func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodGet {
+			teams.HandleGetTeamDomains(w, r)
+		} else if r.Method == http.MethodPost {
+			teams.HandleClaimTeamDomain(w, r)
+		}
+	}, []func(http.Handler) http.Handler{teams.TeamSettingsRouteMiddleware})
+
+	createRoute(router, []string{http.MethodPatch, http.MethodDelete}, "/teams/{id}/domains/{domain_id}", func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodPatch {
+			teams.HandleUpdateTeamDomain(w, r)
+		} else if r.Method == http.MethodDelete {
+			teams.HandleDeleteTeamDomain(w, r)
+		}
+	}, []func(http.Handler) http.Handler{teams.TeamSettingsRouteMiddleware})
+
+	createRoute(router, []string{http.MethodPost}, "/teams/{id}/domains/{domain_id}/verify", func(w http.ResponseWriter, r *http.Request) {
+		teams.HandleVerifyTeamDomain(w, r)
+	}, []func(http.Handler) http.Handler{teams.TeamSettingsRouteMiddleware})
+
+	createRoute(router, []string{http.MethodGet}, "/teams/{id}/join-requests", func(w http.ResponseWriter, r *http.Request) {
+		teams.HandleGetTeamJoinRequests(w, r)
+	}, []func(http.Handler) http.Handler{teams.TeamSettingsRouteMiddleware})
+
+	createRoute(router, []string{http.MethodPost}, "/teams/{id}/join-requests/{request_id}/approve", func(w http.ResponseWriter, r *http.Request) {
+		teams.HandleApproveTeamJoinRequest(w, r)
+	}, []func(http.Handler) http.Handler{teams.TeamSettingsRouteMiddleware})
+
+	createRoute(router, []string{http.MethodPost}, "/teams/{id}/join-requests/{request_id}/reject", func(w http.ResponseWriter, r *http.Request) {
+		teams.HandleRejectTeamJoinRequest(w, r)
+	}, []func(http.Handler) http.Handler{teams.TeamSettingsRouteMiddleware})
+
+	createRoute(router, []string{http.MethodGet, http.MethodPost}, "/teams/{id}/scim-tokens", func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodGet {
+			teams.HandleGetSCIMTokens(w, r)
+		} else if r.Method == http.MethodPost {
+			teams.HandleCreateSCIMToken(w, r)
+		}
+	}, []func(http.Handler) http.Handler{teams.TeamSettingsRouteMiddleware})
+
+	createRoute(router, []string{http.MethodDelete}, "/teams/{id}/scim-tokens/{token_id}", func(w http.ResponseWriter, r *http.Request) {
+		teams.HandleRevokeSCIMToken(w, r)
+	}, []func(http.Handler) http.Handler{teams.TeamSettingsRouteMiddleware})
+
+	createRoute(router, []string{http.MethodGet}, "/teams/{id}/stats", func(w http.ResponseWriter, r *http.Request) {
+		teams.HandleGetTeamStats(w, r)
+	}, []func(http.Handler) http.Handler{teams.TeamRouteMiddleware})
+
+	createRoute(router, []string{http.MethodGet}, "/teams/{id}/activity", func(w http.ResponseWriter, r *http.Request) {
+		teams.HandleGetTeamActivity(w, r)
+	}, []func(http.Handler) http.Handler{teams.TeamActivityRouteMiddleware})
+
+	// ~ INVITATIONS ~
+
+	createRoute(router, []string{http.MethodPost}, "/invitations/{token}/accept", func(w http.ResponseWriter, r *http.Request) {
+		teams.HandleAcceptInvitation(w, r)
+	}, []func(http.Handler) http.Handler{teams.InvitationRouteMiddleware})
+
+	createRoute(router, []string{http.MethodPost}, "/invitations/{token}/decline", func(w http.ResponseWriter, r *http.Request) {
+		teams.HandleDeclineInvitation(w, r)
+	}, []func(http.Handler) http.Handler{teams.InvitationRouteMiddleware})
+
+	// ~ SCIM ~
+
+	createRoute(router, []string{http.MethodGet}, "/scim/v2/ServiceProviderConfig", func(w http.ResponseWriter, r *http.Request) {
+		scim.HandleServiceProviderConfig(w, r)
+	}, []func(http.Handler) http.Handler{scim.SCIMRouteMiddleware})
+
+	createRoute(router, []string{http.MethodGet}, "/scim/v2/ResourceTypes", func(w http.ResponseWriter, r *http.Request) {
+		scim.HandleResourceTypes(w, r)
+	}, []func(http.Handler) http.Handler{scim.SCIMRouteMiddleware})
+
+	createRoute(router, []string{http.MethodGet, http.MethodPost}, "/scim/v2/Users", func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodGet {
+			scim.HandleListUsers(w, r)
+		} else if r.Method == http.MethodPost {
+			scim.HandleCreateUser(w, r)
+		}
+	}, []func(http.Handler) http.Handler{scim.SCIMRouteMiddleware})
+
+	createRoute(router, []string{http.MethodGet, http.MethodPut, http.MethodPatch, http.MethodDelete}, "/scim/v2/Users/{id}", func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodGet {
+			scim.HandleGetUser(w, r)
+		} else if r.Method == http.MethodPut {
+			scim.HandleReplaceUser(w, r)
+		} else if r.Method == http.MethodPatch {
+			scim.HandlePatchUser(w, r)
+		} else if r.Method == http.MethodDelete {
+			scim.HandleDeleteUser(w, r)
+		}
+	}, []func(http.Handler) http.Handler{scim.SCIMRouteMiddleware})
+
+	createRoute(router, []string{http.MethodGet, http.MethodPost}, "/scim/v2/Groups", func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodGet {
+			scim.HandleListGroups(w, r)
+		} else if r.Method == http.MethodPost {
+			scim.HandleCreateGroup(w, r)
+		}
+	}, []func(http.Handler) http.Handler{scim.SCIMRouteMiddleware})
+
+	createRoute(router, []string{http.MethodGet, http.MethodPut, http.MethodPatch, http.MethodDelete}, "/scim/v2/Groups/{id}", func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodGet {
+			scim.HandleGetGroup(w, r)
+		} else if r.Method == http.MethodPut {
+			scim.HandleReplaceGroup(w, r)
+		} else if r.Method == http.MethodPatch {
+			scim.HandlePatchGroup(w, r)
+		} else if r.Method == http.MethodDelete {
+			scim.HandleDeleteGroup(w, r)
+		}
+	}, []func(http.Handler) http.Handler{scim.SCIMRouteMiddleware})
+
 	// ~ ADMINISTRATION ~
 
 	createRoute(router, []string{http.MethodPost}, "/administrate/login", func(w http.ResponseWriter, r *http.Request) {
@@ -194,22 +463,197 @@ func Start() {
 		admin_logs.HandleGetLogs(w, r)
 	}, []func(http.Handler) http.Handler{administration.AdministrationRouteMiddleware})
 
+	createRoute(router, []string{http.MethodGet}, "/administrate/logs/metrics", func(w http.ResponseWriter, r *http.Request) {
+		admin_logs.HandleGetLogMetrics(w, r)
+	}, []func(http.Handler) http.Handler{administration.AdministrationRouteMiddleware})
+
+	createRoute(router, []string{http.MethodGet, http.MethodPost}, "/administrate/logs/retention", func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodGet {
+			admin_logs.HandleGetLogRetention(w, r)
+		} else if r.Method == http.MethodPost {
+			admin_logs.HandlePurgeLogs(w, r)
+		}
+	}, []func(http.Handler) http.Handler{administration.AdministrationRouteMiddleware})
+
+	createRoute(router, []string{http.MethodGet}, "/administrate/logs/stream", func(w http.ResponseWriter, r *http.Request) {
+		admin_logs.HandleStreamLogs(w, r)
+	}, []func(http.Handler) http.Handler{administration.AdministrationRouteMiddleware})
+
+	createRoute(router, []string{http.MethodGet}, "/administrate/logs/{id}", func(w http.ResponseWriter, r *http.Request) {
+		admin_logs.HandleGetLog(w, r)
+	}, []func(http.Handler) http.Handler{administration.AdministrationRouteMiddleware})
+
+	createRoute(router, []string{http.MethodGet}, "/administrate/analytics", func(w http.ResponseWriter, r *http.Request) {
+		admin_analytics.HandleGetOverview(w, r)
+	}, []func(http.Handler) http.Handler{administration.AdministrationRouteMiddleware})
+
+	createRoute(router, []string{http.MethodGet}, "/administrate/analytics/requests", func(w http.ResponseWriter, r *http.Request) {
+		admin_analytics.HandleGetRequests(w, r)
+	}, []func(http.Handler) http.Handler{administration.AdministrationRouteMiddleware})
+
+	createRoute(router, []string{http.MethodGet}, "/administrate/analytics/errors", func(w http.ResponseWriter, r *http.Request) {
+		admin_analytics.HandleGetErrorRates(w, r)
+	}, []func(http.Handler) http.Handler{administration.AdministrationRouteMiddleware})
+
+	createRoute(router, []string{http.MethodGet}, "/administrate/analytics/latency", func(w http.ResponseWriter, r *http.Request) {
+		admin_analytics.HandleGetLatency(w, r)
+	}, []func(http.Handler) http.Handler{administration.AdministrationRouteMiddleware})
+
+	createRoute(router, []string{http.MethodGet}, "/administrate/analytics/users", func(w http.ResponseWriter, r *http.Request) {
+		admin_analytics.HandleGetTopUsers(w, r)
+	}, []func(http.Handler) http.Handler{administration.AdministrationRouteMiddleware})
+
+	createRoute(router, []string{http.MethodGet}, "/administrate/analytics/auth-failures", func(w http.ResponseWriter, r *http.Request) {
+		admin_analytics.HandleGetAuthFailures(w, r)
+	}, []func(http.Handler) http.Handler{administration.AdministrationRouteMiddleware})
+
+	createRoute(router, []string{http.MethodGet}, "/administrate/audit", func(w http.ResponseWriter, r *http.Request) {
+		admin_audit.HandleGetAudit(w, r)
+	}, []func(http.Handler) http.Handler{administration.AdministrationRouteMiddleware})
+
+	createRoute(router, []string{http.MethodGet}, "/administrate/audit/verify", func(w http.ResponseWriter, r *http.Request) {
+		admin_audit.HandleVerifyAudit(w, r)
+	}, []func(http.Handler) http.Handler{administration.AdministrationRouteMiddleware})
+
+	createRoute(router, []string{http.MethodGet}, "/administrate/audit/export", func(w http.ResponseWriter, r *http.Request) {
+		admin_audit.HandleExportAudit(w, r)
+	}, []func(http.Handler) http.Handler{administration.AdministrationRouteMiddleware})
+
+	createRoute(router, []string{http.MethodGet}, "/administrate/users", func(w http.ResponseWriter, r *http.Request) {
+		admin_users.HandleGetUsers(w, r)
+	}, []func(http.Handler) http.Handler{administration.AdministrationRouteMiddleware})
+
+	createRoute(router, []string{http.MethodGet, http.MethodPatch}, "/administrate/users/{id}", func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodGet {
+			admin_users.HandleGetUser(w, r)
+		} else if r.Method == http.MethodPatch {
+			admin_users.HandleUpdateUser(w, r)
+		}
+	}, []func(http.Handler) http.Handler{administration.AdministrationRouteMiddleware})
+
+	createRoute(router, []string{http.MethodPost}, "/administrate/users/{id}/password-reset", func(w http.ResponseWriter, r *http.Request) {
+		admin_users.HandleForcePasswordReset(w, r)
+	}, []func(http.Handler) http.Handler{administration.AdministrationRouteMiddleware})
+
+	createRoute(router, []string{http.MethodDelete}, "/administrate/users/{id}/sessions", func(w http.ResponseWriter, r *http.Request) {
+		admin_users.HandleRevokeSessions(w, r)
+	}, []func(http.Handler) http.Handler{administration.AdministrationRouteMiddleware})
+
+	createRoute(router, []string{http.MethodGet}, "/administrate/teams/archived", func(w http.ResponseWriter, r *http.Request) {
+		admin_teams.HandleGetArchivedTeams(w, r)
+	}, []func(http.Handler) http.Handler{administration.AdministrationRouteMiddleware})
+
+	createRoute(router, []string{http.MethodPost}, "/administrate/teams/{id}/restore", func(w http.ResponseWriter, r *http.Request) {
+		admin_teams.HandleRestoreTeam(w, r)
+	}, []func(http.Handler) http.Handler{administration.AdministrationRouteMiddleware})
+
+	createRoute(router, []string{http.MethodGet}, "/administrate/teams", func(w http.ResponseWriter, r *http.Request) {
+		admin_teams.HandleGetTeams(w, r)
+	}, []func(http.Handler) http.Handler{administration.AdministrationRouteMiddleware})
+
+	createRoute(router, []string{http.MethodGet, http.MethodPatch}, "/administrate/teams/{id}", func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodGet {
+			admin_teams.HandleGetTeam(w, r)
+		} else if r.Method == http.MethodPatch {
+			admin_teams.HandleUpdateTeam(w, r)
+		}
+	}, []func(http.Handler) http.Handler{administration.AdministrationRouteMiddleware})
+
+	createRoute(router, []string{http.MethodPost}, "/administrate/teams/{id}/ownership", func(w http.ResponseWriter, r *http.Request) {
+		admin_teams.HandleForceTransferOwnership(w, r)
+	}, []func(http.Handler) http.Handler{administration.AdministrationRouteMiddleware})
+
+	createRoute(router, []string{http.MethodPost}, "/administrate/teams/{id}/merge", func(w http.ResponseWriter, r *http.Request) {
+		admin_teams.HandleMergeTeam(w, r)
+	}, []func(http.Handler) http.Handler{administration.AdministrationRouteMiddleware})
+
+	createRoute(router, []string{http.MethodDelete}, "/administrate/teams/{id}/members/{member_id}", func(w http.ResponseWriter, r *http.Request) {
+		admin_teams.HandleRemoveTeamMember(w, r)
+	}, []func(http.Handler) http.Handler{administration.AdministrationRouteMiddleware})
+
 	// ~ all others routes, 404
 	router.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
 		utils.AbortRequest(w, "404 - Route Not Found", http.StatusNotFound)
 	})
 
-	utils.ConsoleLog("🌍 Server started on http://%s", addr)
-	if err := http.ListenAndServe(addr, router); err != nil {
-		utils.ConsoleLog("❌ Server failed to start: %v", err).Fatal()
+	// Les logs sont écrits en tâche de fond, le writer démarre avec le serveur
+	request_log_service.Default()
+
+	// Flux en direct : logs des autres replicas, via LISTEN/NOTIFY
+	listenCtx, stopListening := context.WithCancel(context.Background())
+	defer stopListening()
+	if request_log_service.NotifyEnabled() {
+		go request_log_service.Listen(listenCtx)
+	}
+
+	srv := &http.Server{Addr: addr, Handler: router}
+	// Les flux SSE ne se terminent pas d'eux-mêmes : ils sont fermés à l'arrêt
+	srv.RegisterOnShutdown(request_log_service.CloseSubscriptions)
+	go func() {
+		utils.ConsoleLog("🌍 Server started on http://%s", addr)
+		if err := srv.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			utils.ConsoleLog("❌ Server failed to start: %v", err).Fatal()
+		}
+	}()
+
+	// ~ Graceful shutdown : fin des requêtes en cours, puis écriture des logs restants ~
+	stop := make(chan os.Signal, 1)
+	signal.Notify(stop, os.Interrupt, syscall.SIGTERM)
+	<-stop
+
+	timeout, err := strconv.Atoi(utils.GetEnv("SERVER_SHUTDOWN_TIMEOUT_SECONDS", "15"))
+	if err != nil || timeout <= 0 {
+		timeout = 15
+	}
+	utils.ConsoleLog("🛑 Shutting down server...")
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Duration(timeout)*time.Second)
+	defer cancel()
+	if err := srv.Shutdown(ctx); err != nil {
+		utils.ConsoleLog("⚠️ Server shutdown: %v", err)
 	}
 
+	if err := request_log_service.Stop(time.Duration(timeout) * time.Second); err != nil {
+		utils.ConsoleLog("⚠️ Request logs: %v", err)
+	} else {
+		utils.ConsoleLog("📜 Request logs flushed")
+	}
+}
+
+// captureBody lit le corps à journaliser sans le retirer au handler. Les corps multipart et ceux dépassant
+// MaxCaptureBytes ne sont pas chargés en mémoire : ils sont transmis tels quels et omitted les décrit dans le log.
+func captureBody(r *http.Request) (body []byte, omitted string, err error) {
+	mediaType, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	if strings.HasPrefix(mediaType, "multipart/") {
+		return nil, fmt.Sprintf("[multipart body omitted, %d bytes]", r.ContentLength), nil
+	}
+
+	limit := redaction_service.Current().MaxCaptureBytes
+	if r.ContentLength > int64(limit) {
+		return nil, fmt.Sprintf("[body omitted, %d bytes]", r.ContentLength), nil
+	}
+
+	// Taille inconnue (chunked) : seul le début est lu, le handler reçoit ce début suivi du reste du flux
+	prefix, err := io.ReadAll(io.LimitReader(r.Body, int64(limit)+1))
+	if err != nil {
+		return nil, "", err
+	}
+	r.Body = struct {
+		io.Reader
+		io.Closer
+	}{io.MultiReader(bytes.NewReader(prefix), r.Body), r.Body}
+
+	if len(prefix) > limit {
+		return nil, fmt.Sprintf("[body omitted, more than %d bytes]", limit), nil
+	}
+	return prefix, "", nil
 }
 
-// responseRecorder intercepte le statut HTTP
+// responseRecorder intercepte le statut HTTP et la taille de la réponse
 type responseRecorder struct {
 	http.ResponseWriter
 	statusCode int
+	bytes      int64
 }
 
 // WriteHeader met à jour le statusCode et appelle la méthode WriteHeader de http.ResponseWriter
@@ -218,61 +662,99 @@ func (rec *responseRecorder) WriteHeader(code int) {
 	rec.ResponseWriter.WriteHeader(code)
 }
 
+// Write compte les octets envoyés
+func (rec *responseRecorder) Write(b []byte) (int, error) {
+	n, err := rec.ResponseWriter.Write(b)
+	rec.bytes += int64(n)
+	return n, err
+}
+
+// Unwrap donne accès au ResponseWriter d'origine (http.ResponseController)
+func (rec *responseRecorder) Unwrap() http.ResponseWriter {
+	return rec.ResponseWriter
+}
+
+// isSCIMPath indique si la requête vise l'API SCIM, authentifiée par jeton SCIM et non par JWT
+func isSCIMPath(path string) bool {
+	return path == "/scim/v2" || strings.HasPrefix(path, "/scim/v2/")
+}
+
 func RequestLoggerMiddleware(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		start := time.Now()
 
+		// Identifiant de corrélation, renvoyé au client et repris dans les logs
+		r, requestID := utils.EnsureRequestID(r)
+		w.Header().Set("X-Request-ID", requestID)
+
 		// Récupérer l'ID utilisateur depuis le JWT Token
+		// Les clients SCIM envoient leur propre jeton, vérifié par SCIMRouteMiddleware : la requête reste anonyme
 		tokenString := r.Header.Get("Authorization")
 		var authUserID uuid.UUID = uuid.Nil
 
-		if tokenString != "" {
+		if tokenString != "" && !isSCIMPath(r.URL.Path) {
 			id, err := utils.ExtractUserIDFromJWT(r)
 			if err != nil {
-				utils.ConsoleLog("❌ Erreur lors de la récupération de l'ID utilisateur: %v", err)
+				utils.ConsoleLogRequest(r, "❌ Erreur lors de la récupération de l'ID utilisateur: %v", err)
 				utils.AbortRequest(w, "Unauthorized", http.StatusUnauthorized)
 				return
 			}
 			authUserID = id
 		}
-		body, err := io.ReadAll(r.Body)
+		body, omitted, err := captureBody(r)
 		if err != nil {
-			utils.ConsoleLog("❌ Erreur lors de la lecture du corps de la requête: %v", err)
+			utils.ConsoleLogRequest(r, "❌ Erreur lors de la lecture du corps de la requête: %v", err)
 			utils.AbortRequest(w, "Bad Request", http.StatusBadRequest)
 			return
 		}
 
-		// Encode the body for logging
-		encodedBody := utils.EncodeBase64(body)
-
-		// Restore the body for downstream handlers
-		r.Body = io.NopCloser(bytes.NewBuffer(body))
+		// ~ Redact secrets before anything reaches the database ~
+		path := r.URL.Path
+		if route := mux.CurrentRoute(r); route != nil {
+			if template, err := route.GetPathTemplate(); err == nil {
+				path = template
+			}
+		}
+		content := []byte(omitted)
+		if omitted == "" {
+			content = redaction_service.Body(r.Method, path, r.Header.Get("Content-Type"), body)
+		}
+		encodedBody := utils.EncodeBase64(content)
+		headers := redaction_service.Headers(r.Header)
 
-		// Capture de la réponse HTTP (pour connaître le statut)
+		// Capture de la réponse HTTP (statut et taille)
 		rec := &responseRecorder{ResponseWriter: w, statusCode: http.StatusOK}
 		next.ServeHTTP(rec, r)
+		duration := time.Since(start)
 
 		// Création de l'entrée de log
 		logEntry := models.RequestLog{
-			Domain:    r.Host,
-			Endpoint:  r.URL.Path,
-			Method:    r.Method,
-			Content:   string(encodedBody),
-			Status:    rec.statusCode,
-			Timestamp: start,
+			Domain:        r.Host,
+			Endpoint:      redaction_service.Endpoint(r.URL.Path, mux.Vars(r)),
+			Route:         path,
+			Query:         redaction_service.Query(r.URL.RawQuery),
+			Method:        r.Method,
+			Content:       string(encodedBody),
+			Headers:       headers,
+			Status:        rec.statusCode,
+			Timestamp:     start,
+			RequestID:     requestID,
+			ClientIP:      utils.ClientIP(r),
+			UserAgent:     r.UserAgent(),
+			Duration:      duration,
+			ResponseBytes: rec.bytes,
 		}
 
 		// Ajout du UserID si ce n'est pas un utilisateur anonyme
+		user := "anonymous"
 		if authUserID != uuid.Nil {
 			logEntry.UserID = &authUserID
-			utils.ConsoleLog("📜 Log enregistré -> [%s] %s %s -> %d", authUserID, r.Method, r.URL.Path, rec.statusCode)
-		} else {
-			utils.ConsoleLog("📜 Log enregistré -> [anonymous] %s %s -> %d", r.Method, r.URL.Path, rec.statusCode)
+			user = authUserID.String()
 		}
+		utils.ConsoleLogRequest(r, "📜 Log enregistré -> [%s] %s %s -> %d (%s, %d bytes)", user, r.Method, logEntry.Endpoint, rec.statusCode, duration, rec.bytes)
 
-		// Enregistrement du log en base de données
-		if err := database.DB.Create(&logEntry).Error; err != nil {
-			utils.ConsoleLog("⚠️ Erreur lors de l'enregistrement du log en DB: %v", err)
-		}
+		// Mise en file du log, enregistré en base par lots en tâche de fond, et diffusion aux flux en direct
+		request_log_service.Enqueue(logEntry)
+		request_log_service.Publish(logEntry)
 	})
 }
@@ -0,0 +1,259 @@
+package server
+
+import (
+	"encoding/base64"
+	"gox/database/models"
+	"gox/routes/scim"
+	redaction_service "gox/services/redaction"
+	request_log_service "gox/services/requestlogs"
+	"gox/utils"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/gorilla/mux"
+)
+
+func useRedactionConfig(t *testing.T, maxCaptureBytes int) {
+	t.Helper()
+	previous := redaction_service.Current()
+	c := previous
+	c.MaxCaptureBytes = maxCaptureBytes
+	redaction_service.SetConfig(c)
+	t.Cleanup(func() { redaction_service.SetConfig(previous) })
+}
+
+func TestCaptureBody(t *testing.T) {
+	useRedactionConfig(t, 16)
+
+	tests := []struct {
+		name          string
+		contentType   string
+		body          string
+		contentLength int64
+		wantCaptured  string
+		wantOmitted   string
+	}{
+		{
+			name:         "small body is captured",
+			contentType:  "application/json",
+			body:         `{"a":1}`,
+			wantCaptured: `{"a":1}`,
+		},
+		{
+			name:        "multipart body is not read",
+			contentType: "multipart/form-data; boundary=x",
+			body:        "--x\r\nContent-Disposition: form-data; name=\"file\"\r\n\r\ndata\r\n--x--",
+			wantOmitted: "[multipart body omitted, 63 bytes]",
+		},
+		{
+			name:        "declared size above the limit",
+			contentType: "application/json",
+			body:        `{"data":"0123456789abcdef"}`,
+			wantOmitted: "[body omitted, 27 bytes]",
+		},
+		{
+			name:          "chunked body above the limit",
+			contentType:   "application/json",
+			body:          `{"data":"0123456789abcdef"}`,
+			contentLength: -1,
+			wantOmitted:   "[body omitted, more than 16 bytes]",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest(http.MethodPost, "/upload", strings.NewReader(tt.body))
+			r.Header.Set("Content-Type", tt.contentType)
+			if tt.contentLength != 0 {
+				r.ContentLength = tt.contentLength
+			}
+
+			captured, omitted, err := captureBody(r)
+			if err != nil {
+				t.Fatalf("captureBody() error = %v", err)
+			}
+			if string(captured) != tt.wantCaptured || omitted != tt.wantOmitted {
+				t.Errorf("captureBody() = (%q, %q), want (%q, %q)", captured, omitted, tt.wantCaptured, tt.wantOmitted)
+			}
+
+			// Le handler reçoit toujours le corps complet
+			rest, _ := io.ReadAll(r.Body)
+			if string(rest) != tt.body {
+				t.Errorf("handler body = %q, want %q", rest, tt.body)
+			}
+		})
+	}
+}
+
+// Le log transmis au writer est déjà expurgé : corps, en-têtes, query string et variables de route
+// captureLogs remplace l'écrivain par défaut ; les entrées sont disponibles après Stop
+func captureLogs(t *testing.T) (*request_log_service.Writer, *[]models.RequestLog) {
+	t.Helper()
+	var (
+		mu      sync.Mutex
+		entries []models.RequestLog
+	)
+	writer := request_log_service.NewWriter(request_log_service.Config{
+		QueueSize:     10,
+		BatchSize:     10,
+		FlushInterval: time.Hour,
+		Policy:        request_log_service.PolicyDrop,
+	}, func(batch []models.RequestLog) error {
+		mu.Lock()
+		defer mu.Unlock()
+		entries = append(entries, batch...)
+		return nil
+	})
+	request_log_service.SetDefault(writer)
+	return writer, &entries
+}
+
+func TestRequestLoggerMiddlewareRedacts(t *testing.T) {
+	useRedactionConfig(t, 1<<20)
+	writer, logged := captureLogs(t)
+
+	body := `{"email":"jane@acme.com","password":"hunter2","profile":{"api_key":"k"}}`
+	var received string
+	router := mux.NewRouter()
+	createRoute(router, []string{"POST"}, "/invitations/{token}/accept", func(w http.ResponseWriter, r *http.Request) {
+		raw, _ := io.ReadAll(r.Body)
+		received = string(raw)
+		w.WriteHeader(http.StatusCreated)
+	}, nil)
+
+	userID := uuid.New()
+	jwt, err := utils.GenerateJWT(userID, false, 0)
+	if err != nil {
+		t.Fatalf("GenerateJWT() error = %v", err)
+	}
+
+	r := httptest.NewRequest(http.MethodPost, "/invitations/secret-invite/accept?token=abc&page=2", strings.NewReader(body))
+	r.Header.Set("Content-Type", "application/json")
+	r.Header.Set("Authorization", jwt)
+	r.Header.Set("Cookie", "session=abc")
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, r)
+
+	if err := writer.Stop(time.Second); err != nil {
+		t.Fatalf("Stop() error = %v", err)
+	}
+
+	if received != body {
+		t.Errorf("handler body = %q, want %q", received, body)
+	}
+	if w.Code != http.StatusCreated {
+		t.Fatalf("status = %d, want %d", w.Code, http.StatusCreated)
+	}
+	entries := *logged
+	if len(entries) != 1 {
+		t.Fatalf("enqueued %d entries, want 1", len(entries))
+	}
+	entry := entries[0]
+
+	content, err := base64.StdEncoding.DecodeString(entry.Content)
+	if err != nil {
+		t.Fatalf("content is not base64: %v", err)
+	}
+	if want := `{"email":"jane@acme.com","password":"[REDACTED]","profile":{"api_key":"[REDACTED]"}}`; string(content) != want {
+		t.Errorf("content = %q, want %q", content, want)
+	}
+	if entry.Headers["Authorization"] != redaction_service.Mask || entry.Headers["Cookie"] != redaction_service.Mask {
+		t.Errorf("headers = %v, want Authorization and Cookie redacted", entry.Headers)
+	}
+	if entry.Headers["Content-Type"] != "application/json" {
+		t.Errorf("Content-Type header = %q, want application/json", entry.Headers["Content-Type"])
+	}
+	if want := "page=2&token=%5BREDACTED%5D"; entry.Query != want {
+		t.Errorf("query = %q, want %q", entry.Query, want)
+	}
+	if want := "/invitations/[REDACTED]/accept"; entry.Endpoint != want {
+		t.Errorf("endpoint = %q, want %q", entry.Endpoint, want)
+	}
+	if entry.Route != "/invitations/{token}/accept" || entry.Status != http.StatusCreated {
+		t.Errorf("route, status = %q, %d", entry.Route, entry.Status)
+	}
+	if entry.UserID == nil || *entry.UserID != userID {
+		t.Errorf("user id = %v, want %s", entry.UserID, userID)
+	}
+
+	// Aucune trace des secrets dans le log
+	for _, secret := range []string{"hunter2", "secret-invite", jwt, "session=abc"} {
+		all := string(content) + entry.Query + entry.Endpoint
+		for _, value := range entry.Headers {
+			all += value
+		}
+		if strings.Contains(all, secret) {
+			t.Errorf("log contains %q", secret)
+		}
+	}
+}
+
+// Le jeton SCIM n'est pas un JWT : la requête traverse le logger jusqu'à SCIMRouteMiddleware et reste journalisée
+func TestRequestLoggerMiddlewareSCIM(t *testing.T) {
+	useRedactionConfig(t, 1<<20)
+	writer, logged := captureLogs(t)
+
+	var received string
+	router := mux.NewRouter()
+	createRoute(router, []string{"GET"}, "/scim/v2/Groups", func(w http.ResponseWriter, r *http.Request) {
+		received = r.Header.Get("Authorization")
+	}, nil)
+	createRoute(router, []string{"GET"}, "/scim/v2/Users", func(w http.ResponseWriter, r *http.Request) {
+		t.Error("handler reached without a SCIM token")
+	}, []func(http.Handler) http.Handler{scim.SCIMRouteMiddleware})
+	createRoute(router, []string{"GET"}, "/teams", func(w http.ResponseWriter, r *http.Request) {
+		t.Error("handler reached with an invalid JWT")
+	}, nil)
+
+	serve := func(path, authorization string) *httptest.ResponseRecorder {
+		r := httptest.NewRequest(http.MethodGet, path, nil)
+		r.Header.Set("Authorization", authorization)
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, r)
+		return w
+	}
+
+	// Jeton SCIM transmis tel quel au handler
+	if w := serve("/scim/v2/Groups", "Bearer scim-secret-token"); w.Code != http.StatusOK {
+		t.Errorf("scim request: status = %d, want %d", w.Code, http.StatusOK)
+	}
+	if received != "Bearer scim-secret-token" {
+		t.Errorf("handler Authorization = %q", received)
+	}
+
+	// Refus rendu par SCIMRouteMiddleware, au format SCIM
+	w := serve("/scim/v2/Users", "Basic dXNlcjpwYXNz")
+	if w.Code != http.StatusUnauthorized || w.Header().Get("WWW-Authenticate") != `Bearer realm="scim"` {
+		t.Errorf("scim refusal: status = %d, WWW-Authenticate = %q", w.Code, w.Header().Get("WWW-Authenticate"))
+	}
+
+	// Hors SCIM, un Authorization qui n'est pas un JWT reste refusé
+	if w := serve("/teams", "Bearer scim-secret-token"); w.Code != http.StatusUnauthorized {
+		t.Errorf("non scim request: status = %d, want %d", w.Code, http.StatusUnauthorized)
+	}
+
+	if err := writer.Stop(time.Second); err != nil {
+		t.Fatalf("Stop() error = %v", err)
+	}
+	entries := *logged
+	if len(entries) != 2 {
+		t.Fatalf("enqueued %d entries, want 2", len(entries))
+	}
+	for _, entry := range entries {
+		if entry.UserID != nil {
+			t.Errorf("%s: user id = %v, want anonymous", entry.Route, entry.UserID)
+		}
+		if !strings.HasSuffix(entry.Headers["Authorization"], redaction_service.Mask) {
+			t.Errorf("%s: Authorization header = %q, want redacted", entry.Route, entry.Headers["Authorization"])
+		}
+	}
+	if entries[0].Status != http.StatusOK || entries[1].Status != http.StatusUnauthorized {
+		t.Errorf("statuses = %d, %d", entries[0].Status, entries[1].Status)
+	}
+}
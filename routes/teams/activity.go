@@ -0,0 +1,94 @@
+package teams
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/gorilla/mux"
+
+	team_activity_service "gox/services/teams/activity"
+	"gox/utils"
+)
+
+// ~ /teams/{id}/activity ~
+func HandleGetTeamActivity(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	query := r.URL.Query()
+
+	teamUUID, err := checkForTeamID(vars["id"])
+	if err != nil {
+		utils.AbortRequest(w, fmt.Sprintf("Invalid team ID: %v", err), http.StatusBadRequest)
+		return
+	}
+
+	// Filtres : ?action=member.role_changed (ou préfixe "member."), actor_id, target_type, target_id, since, until (RFC 3339)
+	filters := team_activity_service.Filters{
+		Action:     query.Get("action"),
+		TargetType: query.Get("target_type"),
+		TargetID:   query.Get("target_id"),
+	}
+	if actorID := query.Get("actor_id"); actorID != "" {
+		actorUUID, err := uuid.Parse(actorID)
+		if err != nil {
+			utils.AbortRequest(w, "Invalid actor ID", http.StatusBadRequest)
+			return
+		}
+		filters.ActorID = &actorUUID
+	}
+	for param, target := range map[string]**time.Time{"since": &filters.Since, "until": &filters.Until} {
+		if value := query.Get(param); value != "" {
+			parsed, err := time.Parse(time.RFC3339, value)
+			if err != nil {
+				utils.AbortRequest(w, fmt.Sprintf("Invalid %s date, expected RFC 3339", param), http.StatusBadRequest)
+				return
+			}
+			*target = &parsed
+		}
+	}
+
+	// Pagination
+	page, _ := strconv.Atoi(query.Get("page"))
+	perPage, _ := strconv.Atoi(query.Get("per_page"))
+	if page < 1 {
+		page = 1
+	}
+	if perPage < 1 || perPage > team_activity_service.MaxPerPage {
+		perPage = team_activity_service.DefaultPerPage
+	}
+
+	activities, total, err := team_activity_service.List(teamUUID, filters, page, perPage)
+	if err != nil {
+		utils.AbortRequest(w, "Error fetching team activity", http.StatusInternalServerError)
+		return
+	}
+
+	// Réponse JSON
+	items := make([]map[string]interface{}, len(activities))
+	for i, activity := range activities {
+		var actor map[string]interface{}
+		if activity.Actor != nil {
+			actor = map[string]interface{}{
+				"id":    activity.Actor.ID,
+				"email": activity.Actor.Email,
+			}
+		}
+		items[i] = map[string]interface{}{
+			"id":          activity.ID,
+			"action":      activity.Action,
+			"actor":       actor,
+			"target_type": activity.TargetType,
+			"target_id":   activity.TargetID,
+			"changes":     activity.Changes,
+			"created_on":  activity.CreatedOn,
+		}
+	}
+	utils.RespondJSON(w, map[string]interface{}{
+		"items":    items,
+		"page":     page,
+		"per_page": perPage,
+		"total":    total,
+	})
+}
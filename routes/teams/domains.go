@@ -0,0 +1,256 @@
+package teams
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+
+	"github.com/google/uuid"
+	"github.com/gorilla/mux"
+
+	"gox/database/models"
+	team_service "gox/services/teams"
+	team_domain_service "gox/services/teams/domains"
+	team_join_request_service "gox/services/teams/joinrequests"
+	"gox/utils"
+)
+
+func domainData(domain models.TeamDomain) map[string]interface{} {
+	return map[string]interface{}{
+		"id":           domain.ID,
+		"team_id":      domain.TeamID,
+		"domain":       domain.Domain,
+		"join_policy":  domain.JoinPolicy,
+		"default_role": domain.DefaultRole,
+		"verified_at":  domain.VerifiedAt,
+		"created_on":   domain.CreatedOn,
+		"verification": map[string]string{
+			"type":  "TXT",
+			"name":  team_domain_service.RecordName(domain),
+			"value": team_domain_service.RecordValue(domain),
+		},
+	}
+}
+
+func getDomainUUIDs(w http.ResponseWriter, r *http.Request) (uuid.UUID, uuid.UUID, bool) {
+	vars := mux.Vars(r)
+
+	teamUUID, err := checkForTeamID(vars["id"])
+	if err != nil {
+		utils.AbortRequest(w, fmt.Sprintf("Invalid team ID: %v", err), http.StatusBadRequest)
+		return uuid.Nil, uuid.Nil, false
+	}
+
+	domainUUID, err := uuid.Parse(vars["domain_id"])
+	if err != nil {
+		utils.AbortRequest(w, "Invalid domain ID", http.StatusBadRequest)
+		return uuid.Nil, uuid.Nil, false
+	}
+
+	return teamUUID, domainUUID, true
+}
+
+// ~ /teams/{id}/domains ~
+func HandleGetTeamDomains(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+
+	teamUUID, err := checkForTeamID(vars["id"])
+	if err != nil {
+		utils.AbortRequest(w, fmt.Sprintf("Invalid team ID: %v", err), http.StatusBadRequest)
+		return
+	}
+
+	// Récupération des domaines de la Team
+	domains, err := team_domain_service.GetAll(teamUUID)
+	if err != nil {
+		utils.AbortRequest(w, "Error fetching domains", http.StatusInternalServerError)
+		return
+	}
+
+	// Réponse JSON
+	data := make([]map[string]interface{}, len(domains))
+	for i, domain := range domains {
+		data[i] = domainData(domain)
+	}
+	utils.RespondJSON(w, data)
+}
+
+func HandleClaimTeamDomain(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+
+	teamUUID, err := checkForTeamID(vars["id"])
+	if err != nil {
+		utils.AbortRequest(w, fmt.Sprintf("Invalid team ID: %v", err), http.StatusBadRequest)
+		return
+	}
+
+	var input struct {
+		Domain      string                      `json:"domain"`
+		JoinPolicy  models.TeamDomainJoinPolicy `json:"join_policy"`
+		DefaultRole models.TeamMemberRole       `json:"default_role"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&input); err != nil {
+		utils.AbortRequest(w, "body invalid", http.StatusBadRequest)
+		return
+	}
+
+	// Revendication du domaine, à vérifier ensuite par DNS
+	domain, err := team_domain_service.Claim(teamUUID, input.Domain, input.JoinPolicy, input.DefaultRole)
+	if err != nil {
+		utils.AbortRequest(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	// Réponse JSON
+	utils.RespondJSON(w, domainData(domain))
+}
+
+// ~ /teams/{id}/domains/{domain_id} ~
+func HandleUpdateTeamDomain(w http.ResponseWriter, r *http.Request) {
+	teamUUID, domainUUID, ok := getDomainUUIDs(w, r)
+	if !ok {
+		return
+	}
+
+	var input struct {
+		JoinPolicy  models.TeamDomainJoinPolicy `json:"join_policy"`
+		DefaultRole models.TeamMemberRole       `json:"default_role"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&input); err != nil {
+		utils.AbortRequest(w, "body invalid", http.StatusBadRequest)
+		return
+	}
+
+	if err := team_domain_service.UpdatePolicy(teamUUID, domainUUID, input.JoinPolicy, input.DefaultRole); err != nil {
+		utils.AbortRequest(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	// Réponse JSON
+	utils.RespondJSON(w, map[string]interface{}{
+		"success": true,
+	})
+}
+
+func HandleDeleteTeamDomain(w http.ResponseWriter, r *http.Request) {
+	teamUUID, domainUUID, ok := getDomainUUIDs(w, r)
+	if !ok {
+		return
+	}
+
+	if err := team_domain_service.Delete(teamUUID, domainUUID); err != nil {
+		utils.AbortRequest(w, err.Error(), http.StatusNotFound)
+		return
+	}
+
+	// Réponse JSON
+	utils.RespondJSON(w, map[string]interface{}{
+		"success": true,
+	})
+}
+
+// ~ /teams/{id}/domains/{domain_id}/verify ~
+func HandleVerifyTeamDomain(w http.ResponseWriter, r *http.Request) {
+	teamUUID, domainUUID, ok := getDomainUUIDs(w, r)
+	if !ok {
+		return
+	}
+
+	// Vérification de l'enregistrement DNS TXT
+	domain, err := team_domain_service.Verify(teamUUID, domainUUID)
+	if errors.Is(err, team_domain_service.ErrDomainNotVerified) {
+		utils.AbortRequest(w, err.Error(), http.StatusUnprocessableEntity)
+		return
+	}
+	if err != nil {
+		utils.AbortRequest(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	// Réponse JSON
+	utils.RespondJSON(w, domainData(domain))
+}
+
+// ~ /teams/{id}/join-requests ~
+func HandleGetTeamJoinRequests(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+
+	teamUUID, err := checkForTeamID(vars["id"])
+	if err != nil {
+		utils.AbortRequest(w, fmt.Sprintf("Invalid team ID: %v", err), http.StatusBadRequest)
+		return
+	}
+
+	// Récupération des demandes en attente
+	requests, err := team_join_request_service.GetPending(teamUUID)
+	if err != nil {
+		utils.AbortRequest(w, "Error fetching join requests", http.StatusInternalServerError)
+		return
+	}
+
+	// Réponse JSON
+	data := make([]map[string]interface{}, len(requests))
+	for i, request := range requests {
+		data[i] = map[string]interface{}{
+			"id":         request.ID,
+			"user_id":    request.UserID,
+			"email":      request.User.Email,
+			"domain_id":  request.DomainID,
+			"status":     request.Status,
+			"created_on": request.CreatedOn,
+		}
+	}
+	utils.RespondJSON(w, data)
+}
+
+// ~ /teams/{id}/join-requests/{request_id}/approve ~
+// ~ /teams/{id}/join-requests/{request_id}/reject ~
+func handleRespondJoinRequest(w http.ResponseWriter, r *http.Request, approve bool) {
+	vars := mux.Vars(r)
+
+	teamUUID, err := checkForTeamID(vars["id"])
+	if err != nil {
+		utils.AbortRequest(w, fmt.Sprintf("Invalid team ID: %v", err), http.StatusBadRequest)
+		return
+	}
+
+	requestUUID, err := uuid.Parse(vars["request_id"])
+	if err != nil {
+		utils.AbortRequest(w, "Invalid join request ID", http.StatusBadRequest)
+		return
+	}
+
+	userUUID, err := utils.ExtractUserIDFromJWT(r)
+	if err != nil {
+		utils.AbortRequest(w, "Authorization Token is invalid.", http.StatusUnauthorized)
+		return
+	}
+
+	if approve {
+		err = team_join_request_service.Approve(teamUUID, requestUUID, userUUID)
+	} else {
+		err = team_join_request_service.Reject(teamUUID, requestUUID, userUUID)
+	}
+	if errors.Is(err, team_service.ErrTeamSuspended) {
+		utils.AbortRequest(w, err.Error(), http.StatusForbidden)
+		return
+	}
+	if err != nil {
+		utils.AbortRequest(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	// Réponse JSON
+	utils.RespondJSON(w, map[string]interface{}{
+		"success": true,
+	})
+}
+
+func HandleApproveTeamJoinRequest(w http.ResponseWriter, r *http.Request) {
+	handleRespondJoinRequest(w, r, true)
+}
+
+func HandleRejectTeamJoinRequest(w http.ResponseWriter, r *http.Request) {
+	handleRespondJoinRequest(w, r, false)
+}
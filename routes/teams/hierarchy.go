@@ -0,0 +1,196 @@
+package teams
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+
+	"github.com/google/uuid"
+	"github.com/gorilla/mux"
+
+	"gox/database/models"
+	team_service "gox/services/teams"
+	"gox/utils"
+)
+
+func teamSummary(team models.Team) map[string]interface{} {
+	return map[string]interface{}{
+		"id":        team.ID,
+		"name":      team.Name,
+		"type":      team.Type,
+		"parent_id": team.ParentID,
+	}
+}
+
+// ~ /teams/{id}/subteams ~
+func HandleGetSubTeams(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+
+	teamUUID, err := checkForTeamID(vars["id"])
+	if err != nil {
+		utils.AbortRequest(w, fmt.Sprintf("Invalid team ID: %v", err), http.StatusBadRequest)
+		return
+	}
+
+	// Récupération des sous-teams directes
+	children, err := team_service.GetChildren(teamUUID)
+	if err != nil {
+		utils.AbortRequest(w, "Error fetching sub-teams", http.StatusInternalServerError)
+		return
+	}
+
+	// Réponse JSON
+	data := make([]map[string]interface{}, len(children))
+	for i, child := range children {
+		data[i] = teamSummary(child)
+	}
+	utils.RespondJSON(w, data)
+}
+
+func HandleCreateSubTeam(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+
+	teamUUID, err := checkForTeamID(vars["id"])
+	if err != nil {
+		utils.AbortRequest(w, fmt.Sprintf("Invalid team ID: %v", err), http.StatusBadRequest)
+		return
+	}
+
+	userUUID, err := utils.ExtractUserIDFromJWT(r)
+	if err != nil {
+		utils.AbortRequest(w, "Authorization Token is invalid.", http.StatusUnauthorized)
+		return
+	}
+
+	var input struct {
+		Name string `json:"name"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&input); err != nil {
+		utils.AbortRequest(w, "body invalid", http.StatusBadRequest)
+		return
+	}
+
+	// Création de la sous-team, le créateur en devient owner
+	subTeamID, err := team_service.CreateSubTeam(teamUUID, input.Name, userUUID)
+	if errors.Is(err, team_service.ErrHierarchyTooDeep) {
+		utils.AbortRequest(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if errors.Is(err, team_service.ErrInvalidParent) {
+		utils.AbortRequest(w, "Sub-teams can only be created under an active company team", http.StatusBadRequest)
+		return
+	}
+	if err != nil {
+		utils.AbortRequest(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	// Réponse JSON
+	utils.RespondJSON(w, subTeamID)
+}
+
+// ~ /teams/{id}/parent ~
+func HandleSetParentTeam(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+
+	teamUUID, err := checkForTeamID(vars["id"])
+	if err != nil {
+		utils.AbortRequest(w, fmt.Sprintf("Invalid team ID: %v", err), http.StatusBadRequest)
+		return
+	}
+
+	userUUID, err := utils.ExtractUserIDFromJWT(r)
+	if err != nil {
+		utils.AbortRequest(w, "Authorization Token is invalid.", http.StatusUnauthorized)
+		return
+	}
+
+	// parent_id vide ou absent : la Team est détachée et redevient une organisation
+	var input struct {
+		ParentID string `json:"parent_id"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&input); err != nil {
+		utils.AbortRequest(w, "body invalid", http.StatusBadRequest)
+		return
+	}
+
+	var parentUUID *uuid.UUID
+	if input.ParentID != "" {
+		parsed, err := uuid.Parse(input.ParentID)
+		if err != nil {
+			utils.AbortRequest(w, "Invalid parent ID", http.StatusBadRequest)
+			return
+		}
+		parentUUID = &parsed
+
+		// Il faut aussi pouvoir administrer la nouvelle Team parente
+		if !requireTeamPermission(w, parsed, userUUID, models.TeamPermissionSettingsWrite) {
+			return
+		}
+	}
+
+	err = team_service.SetParent(teamUUID, parentUUID, userUUID)
+	if errors.Is(err, team_service.ErrPersonalTeam) {
+		utils.AbortRequest(w, "Personal teams cannot be part of an organization", http.StatusForbidden)
+		return
+	}
+	if errors.Is(err, team_service.ErrHierarchyTooDeep) {
+		utils.AbortRequest(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if errors.Is(err, team_service.ErrInvalidParent) {
+		utils.AbortRequest(w, "Invalid parent team", http.StatusBadRequest)
+		return
+	}
+	if err != nil {
+		utils.AbortRequest(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	// Réponse JSON
+	utils.RespondJSON(w, map[string]interface{}{
+		"success": true,
+	})
+}
+
+// ~ /teams/{id}/hierarchy ~
+func HandleGetTeamHierarchy(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+
+	teamUUID, err := checkForTeamID(vars["id"])
+	if err != nil {
+		utils.AbortRequest(w, fmt.Sprintf("Invalid team ID: %v", err), http.StatusBadRequest)
+		return
+	}
+
+	// Chemin jusqu'à l'organisation (racine) et sous-teams directes
+	ancestors, err := team_service.GetAncestors(teamUUID)
+	if err != nil {
+		utils.AbortRequest(w, "Error fetching parent teams", http.StatusInternalServerError)
+		return
+	}
+	children, err := team_service.GetChildren(teamUUID)
+	if err != nil {
+		utils.AbortRequest(w, "Error fetching sub-teams", http.StatusInternalServerError)
+		return
+	}
+
+	// Réponse JSON
+	parents := make([]map[string]interface{}, len(ancestors))
+	for i, ancestor := range ancestors {
+		parents[i] = teamSummary(ancestor)
+	}
+	subTeams := make([]map[string]interface{}, len(children))
+	for i, child := range children {
+		subTeams[i] = teamSummary(child)
+	}
+	data := map[string]interface{}{
+		"ancestors": parents,
+		"children":  subTeams,
+	}
+	if len(ancestors) > 0 {
+		data["organization"] = parents[len(parents)-1]
+	}
+	utils.RespondJSON(w, data)
+}
@@ -2,6 +2,7 @@ package teams
 
 import (
 	"encoding/json"
+	"errors"
 	"fmt"
 	"gox/database"
 	"gox/database/models"
@@ -24,8 +25,23 @@ func HandleCreateTeam(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	// Création de la Team
-	team, err := team_service.Create(input.Name, input.Type)
+	// Les Teams personnelles sont créées uniquement avec le compte utilisateur
+	if input.Type == "" {
+		input.Type = models.TeamTypeCompany
+	}
+	if input.Type != models.TeamTypeCompany {
+		utils.AbortRequest(w, "Only company teams can be created", http.StatusBadRequest)
+		return
+	}
+
+	userUUID, err := utils.ExtractUserIDFromJWT(r)
+	if err != nil {
+		utils.AbortRequest(w, "Authorization Token is invalid.", http.StatusUnauthorized)
+		return
+	}
+
+	// Création de la Team, dont le créateur devient owner
+	teamID, err := team_service.CreateWithOwner(input.Name, input.Type, userUUID)
 	if err != nil {
 		utils.AbortRequest(w, err.Error(), http.StatusBadRequest)
 		return
@@ -35,7 +51,7 @@ func HandleCreateTeam(w http.ResponseWriter, r *http.Request) {
 	w.Header().Set("Content-Type", "application/json")
 	json.NewEncoder(w).Encode(map[string]interface{}{
 		"success": true,
-		"team_id": team.ID,
+		"team_id": teamID,
 	})
 }
 
@@ -85,9 +101,11 @@ func HandleGetTeam(w http.ResponseWriter, r *http.Request) {
 
 	// Réponse JSON
 	data := map[string]interface{}{
-		"id":   team.ID,
-		"name": team.Name,
-		"type": team.Type,
+		"id":          team.ID,
+		"name":        team.Name,
+		"type":        team.Type,
+		"archived_at": team.ArchivedAt,
+		"parent_id":   team.ParentID,
 	}
 	utils.RespondJSON(w, data)
 }
@@ -102,6 +120,12 @@ func HandleUpdateTeam(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	userUUID, err := utils.ExtractUserIDFromJWT(r)
+	if err != nil {
+		utils.AbortRequest(w, "Authorization Token is invalid.", http.StatusUnauthorized)
+		return
+	}
+
 	var input struct {
 		Name string `json:"name"`
 	}
@@ -111,7 +135,7 @@ func HandleUpdateTeam(w http.ResponseWriter, r *http.Request) {
 	}
 
 	// Mise à jour de la Team
-	err = team_service.UpdateName(teamUUID, input.Name)
+	err = team_service.UpdateName(teamUUID, input.Name, userUUID)
 	if err != nil {
 		utils.AbortRequest(w, err.Error(), http.StatusBadRequest)
 		return
@@ -134,8 +158,18 @@ func HandleDeleteTeam(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	// Suppression de la Team
-	err = team_service.Delete(teamUUID)
+	userUUID, err := utils.ExtractUserIDFromJWT(r)
+	if err != nil {
+		utils.AbortRequest(w, "Authorization Token is invalid.", http.StatusUnauthorized)
+		return
+	}
+
+	// Archivage de la Team (restaurable pendant la période de rétention)
+	err = team_service.Archive(teamUUID, userUUID)
+	if errors.Is(err, team_service.ErrPersonalTeam) {
+		utils.AbortRequest(w, "A personal team cannot be deleted", http.StatusForbidden)
+		return
+	}
 	if err != nil {
 		utils.AbortRequest(w, err.Error(), http.StatusBadRequest)
 		return
@@ -147,3 +181,72 @@ func HandleDeleteTeam(w http.ResponseWriter, r *http.Request) {
 		"success": true,
 	})
 }
+
+// ~ /teams/{id}/restore ~
+func HandleRestoreTeam(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	teamID := vars["id"]
+
+	teamUUID, err := checkForTeamID(teamID)
+	if err != nil {
+		utils.AbortRequest(w, fmt.Sprintf("Invalid team ID: %v", err), http.StatusBadRequest)
+		return
+	}
+
+	userUUID, err := utils.ExtractUserIDFromJWT(r)
+	if err != nil {
+		utils.AbortRequest(w, "Authorization Token is invalid.", http.StatusUnauthorized)
+		return
+	}
+
+	// Restauration de la Team
+	if err := team_service.Restore(database.DB, teamUUID, userUUID); err != nil {
+		utils.AbortRequest(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	// Réponse JSON
+	utils.RespondJSON(w, map[string]interface{}{
+		"success": true,
+	})
+}
+
+// ~ /teams/{id}/convert ~
+func HandleConvertTeam(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	teamID := vars["id"]
+
+	teamUUID, err := checkForTeamID(teamID)
+	if err != nil {
+		utils.AbortRequest(w, fmt.Sprintf("Invalid team ID: %v", err), http.StatusBadRequest)
+		return
+	}
+
+	userUUID, err := utils.ExtractUserIDFromJWT(r)
+	if err != nil {
+		utils.AbortRequest(w, "Authorization Token is invalid.", http.StatusUnauthorized)
+		return
+	}
+
+	var input struct {
+		Name string `json:"name"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&input); err != nil {
+		utils.AbortRequest(w, "body invalid", http.StatusBadRequest)
+		return
+	}
+
+	// Conversion de la Team personnelle en Team company
+	personalTeamID, err := team_service.ConvertToCompany(teamUUID, userUUID, input.Name)
+	if err != nil {
+		utils.AbortRequest(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	// Réponse JSON
+	utils.RespondJSON(w, map[string]interface{}{
+		"team_id":          teamUUID,
+		"type":             models.TeamTypeCompany,
+		"personal_team_id": personalTeamID,
+	})
+}
@@ -0,0 +1,209 @@
+package teams
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+
+	"github.com/google/uuid"
+	"github.com/gorilla/mux"
+
+	"gox/database/models"
+	team_service "gox/services/teams"
+	team_invitation_service "gox/services/teams/invitations"
+	"gox/utils"
+)
+
+func invitationData(invitation models.TeamInvitation) map[string]interface{} {
+	return map[string]interface{}{
+		"id":            invitation.ID,
+		"team_id":       invitation.TeamID,
+		"email":         invitation.Email,
+		"role":          invitation.Role,
+		"status":        invitation.Status,
+		"invited_by_id": invitation.InvitedByID,
+		"created_on":    invitation.CreatedOn,
+		"expires_at":    invitation.ExpiresAt,
+	}
+}
+
+// ~ /teams/{id}/invitations ~
+func HandleCreateTeamInvitation(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	teamID := vars["id"]
+
+	teamUUID, err := checkForTeamID(teamID)
+	if err != nil {
+		utils.AbortRequest(w, fmt.Sprintf("Invalid team ID: %v", err), http.StatusBadRequest)
+		return
+	}
+
+	userUUID, err := utils.ExtractUserIDFromJWT(r)
+	if err != nil {
+		utils.AbortRequest(w, "Authorization Token is invalid.", http.StatusUnauthorized)
+		return
+	}
+
+	var input struct {
+		Email string                `json:"email"`
+		Role  models.TeamMemberRole `json:"role"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&input); err != nil {
+		utils.AbortRequest(w, "body invalid", http.StatusBadRequest)
+		return
+	}
+
+	// Seuls les owners peuvent inviter un futur owner ou admin
+	if (input.Role == models.TeamMemberRoleOwner || input.Role == models.TeamMemberRoleAdmin) && !isRequesterOwner(r, teamUUID) {
+		utils.AbortRequest(w, "Only owners can invite owners and admins", http.StatusForbidden)
+		return
+	}
+
+	// Création de l'invitation (et envoi de l'email)
+	invitation, err := team_invitation_service.Create(teamUUID, userUUID, input.Email, input.Role)
+	if err != nil {
+		utils.AbortRequest(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	// Réponse JSON
+	utils.RespondJSON(w, invitationData(invitation))
+}
+
+func HandleGetTeamInvitations(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	teamID := vars["id"]
+
+	teamUUID, err := checkForTeamID(teamID)
+	if err != nil {
+		utils.AbortRequest(w, fmt.Sprintf("Invalid team ID: %v", err), http.StatusBadRequest)
+		return
+	}
+
+	// Récupération des invitations en attente
+	invitations, err := team_invitation_service.GetPending(teamUUID)
+	if err != nil {
+		utils.AbortRequest(w, "Error fetching team invitations", http.StatusInternalServerError)
+		return
+	}
+
+	// Réponse JSON
+	data := make([]map[string]interface{}, len(invitations))
+	for i, invitation := range invitations {
+		data[i] = invitationData(invitation)
+	}
+	utils.RespondJSON(w, data)
+}
+
+// ~ /teams/{id}/invitations/{invitation_id} ~
+func getInvitationUUIDs(w http.ResponseWriter, r *http.Request) (uuid.UUID, uuid.UUID, bool) {
+	vars := mux.Vars(r)
+
+	teamUUID, err := checkForTeamID(vars["id"])
+	if err != nil {
+		utils.AbortRequest(w, fmt.Sprintf("Invalid team ID: %v", err), http.StatusBadRequest)
+		return uuid.Nil, uuid.Nil, false
+	}
+
+	invitationUUID, err := uuid.Parse(vars["invitation_id"])
+	if err != nil {
+		utils.AbortRequest(w, "Invalid invitation ID", http.StatusBadRequest)
+		return uuid.Nil, uuid.Nil, false
+	}
+
+	return teamUUID, invitationUUID, true
+}
+
+func HandleRevokeTeamInvitation(w http.ResponseWriter, r *http.Request) {
+	teamUUID, invitationUUID, ok := getInvitationUUIDs(w, r)
+	if !ok {
+		return
+	}
+
+	userUUID, err := utils.ExtractUserIDFromJWT(r)
+	if err != nil {
+		utils.AbortRequest(w, "Authorization Token is invalid.", http.StatusUnauthorized)
+		return
+	}
+
+	// Révocation de l'invitation
+	if err := team_invitation_service.Revoke(teamUUID, invitationUUID, userUUID); err != nil {
+		utils.AbortRequest(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	// Réponse JSON
+	utils.RespondJSON(w, map[string]interface{}{
+		"success": true,
+	})
+}
+
+// ~ /teams/{id}/invitations/{invitation_id}/resend ~
+func HandleResendTeamInvitation(w http.ResponseWriter, r *http.Request) {
+	teamUUID, invitationUUID, ok := getInvitationUUIDs(w, r)
+	if !ok {
+		return
+	}
+
+	userUUID, err := utils.ExtractUserIDFromJWT(r)
+	if err != nil {
+		utils.AbortRequest(w, "Authorization Token is invalid.", http.StatusUnauthorized)
+		return
+	}
+
+	// Nouveau token + nouvel email
+	invitation, err := team_invitation_service.Resend(teamUUID, invitationUUID, userUUID)
+	if err != nil {
+		utils.AbortRequest(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	// Réponse JSON
+	utils.RespondJSON(w, invitationData(invitation))
+}
+
+// ~ /invitations/{token}/accept ~
+func HandleAcceptInvitation(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	token := vars["token"]
+
+	userUUID, err := utils.ExtractUserIDFromJWT(r)
+	if err != nil {
+		utils.AbortRequest(w, "Authorization Token is invalid.", http.StatusUnauthorized)
+		return
+	}
+
+	// Acceptation : création du TeamMember
+	invitation, err := team_invitation_service.Accept(token, userUUID)
+	if errors.Is(err, team_service.ErrTeamSuspended) {
+		utils.AbortRequest(w, err.Error(), http.StatusForbidden)
+		return
+	}
+	if err != nil {
+		utils.AbortRequest(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	// Réponse JSON
+	utils.RespondJSON(w, map[string]interface{}{
+		"team_id": invitation.TeamID,
+		"role":    invitation.Role,
+	})
+}
+
+// ~ /invitations/{token}/decline ~
+func HandleDeclineInvitation(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	token := vars["token"]
+
+	if err := team_invitation_service.Decline(token); err != nil {
+		utils.AbortRequest(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	// Réponse JSON
+	utils.RespondJSON(w, map[string]interface{}{
+		"success": true,
+	})
+}
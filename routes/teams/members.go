@@ -2,18 +2,38 @@ package teams
 
 import (
 	"encoding/json"
+	"errors"
 	"fmt"
 	"net/http"
 
 	"github.com/google/uuid"
 	"github.com/gorilla/mux"
 
+	"gox/database"
 	"gox/database/models"
+	team_invitation_service "gox/services/teams/invitations"
 	team_member_service "gox/services/teams/members"
+	team_role_service "gox/services/teams/roles"
 	user_service "gox/services/users"
 	"gox/utils"
 )
 
+func memberData(member models.TeamMember) map[string]interface{} {
+	return map[string]interface{}{
+		"id":              member.ID,
+		"user_id":         member.MemberID,
+		"role":            member.Role,
+		"custom_role":     member.CustomRole,
+		"permissions":     team_role_service.MemberPermissions(member),
+		"is_active":       member.IsActive,
+		"joined_at":       member.JoinedAt,
+		"role_changed_at": member.RoleChangedAt,
+		"suspended_at":    member.SuspendedAt,
+		"left_at":         member.LeftAt,
+		"team_id":         member.TeamID,
+	}
+}
+
 // ~ /teams/{id}/members ~
 func HandleAddTeamMember(w http.ResponseWriter, r *http.Request) {
 	vars := mux.Vars(r)
@@ -40,25 +60,34 @@ func HandleAddTeamMember(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	inviterUUID, err := utils.ExtractUserIDFromJWT(r)
+	if err != nil {
+		utils.AbortRequest(w, "Authorization Token is invalid.", http.StatusUnauthorized)
+		return
+	}
+
 	// Vérification de l'existence de l'utilisateur
-	_, err = user_service.Get(userUUID)
+	user, err := user_service.Get(userUUID)
 	if err != nil {
 		utils.AbortRequest(w, "User not found", http.StatusNotFound)
 		return
 	}
 
-	// Ajout du membre à la Team
-	err = team_member_service.Add(teamUUID, userUUID, input.Role)
+	// Seuls les owners peuvent inviter un futur owner ou admin
+	if (input.Role == models.TeamMemberRoleOwner || input.Role == models.TeamMemberRoleAdmin) && !isRequesterOwner(r, teamUUID) {
+		utils.AbortRequest(w, "Only owners can invite owners and admins", http.StatusForbidden)
+		return
+	}
+
+	// L'utilisateur doit consentir : on lui envoie une invitation plutôt que de l'ajouter directement
+	invitation, err := team_invitation_service.Create(teamUUID, inviterUUID, user.Email, input.Role)
 	if err != nil {
 		utils.AbortRequest(w, err.Error(), http.StatusBadRequest)
 		return
 	}
 
 	// Réponse JSON
-	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(map[string]bool{
-		"success": true,
-	})
+	utils.RespondJSON(w, invitationData(invitation))
 }
 
 func HandleGetTeamMembers(w http.ResponseWriter, r *http.Request) {
@@ -71,8 +100,14 @@ func HandleGetTeamMembers(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	// Récupération des membres de la Team
-	members, err := team_member_service.GetAll(teamUUID)
+	// Récupération des membres de la Team (?history=true inclut les anciens membres,
+	// ?inherited=true ajoute ceux hérités des Teams parentes)
+	var members []models.TeamMember
+	if r.URL.Query().Get("inherited") == "true" {
+		members, err = team_member_service.GetAllEffective(teamUUID)
+	} else {
+		members, err = team_member_service.GetAll(teamUUID, r.URL.Query().Get("history") == "true")
+	}
 	if err != nil {
 		utils.AbortRequest(w, "Error fetching team members", http.StatusInternalServerError)
 		return
@@ -81,12 +116,7 @@ func HandleGetTeamMembers(w http.ResponseWriter, r *http.Request) {
 	// Réponse JSON
 	data := make([]map[string]interface{}, len(members))
 	for i, member := range members {
-		data[i] = map[string]interface{}{
-			"id":        member.ID,
-			"user_id":   member.MemberID,
-			"role":      member.Role,
-			"is_active": member.IsActive,
-		}
+		data[i] = memberData(member)
 	}
 	utils.RespondJSON(w, data)
 }
@@ -105,6 +135,21 @@ func checkForMemberID(memberID string) (uuid.UUID, error) {
 	return memberUUID, nil
 }
 
+// isRequesterOwner indique si l'utilisateur authentifié est owner de la Team (ou d'une Team parente)
+func isRequesterOwner(r *http.Request, teamUUID uuid.UUID) bool {
+	userUUID, err := utils.ExtractUserIDFromJWT(r)
+	if err != nil {
+		return false
+	}
+
+	member, err := team_member_service.GetEffective(teamUUID, userUUID)
+	if err != nil || !member.IsActive || !member.IsAccessible {
+		return false
+	}
+
+	return member.Role == models.TeamMemberRoleOwner
+}
+
 func HandleGetTeamMember(w http.ResponseWriter, r *http.Request) {
 	vars := mux.Vars(r)
 	teamID := vars["id"]
@@ -124,13 +169,7 @@ func HandleGetTeamMember(w http.ResponseWriter, r *http.Request) {
 	}
 
 	// Réponse JSON
-	data := map[string]interface{}{
-		"id":        member.ID,
-		"user_id":   member.MemberID,
-		"role":      member.Role,
-		"is_active": member.IsActive,
-	}
-	utils.RespondJSON(w, data)
+	utils.RespondJSON(w, memberData(member))
 }
 
 func HandleUpdateTeamMemberRole(w http.ResponseWriter, r *http.Request) {
@@ -150,16 +189,79 @@ func HandleUpdateTeamMemberRole(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	requesterUUID, err := utils.ExtractUserIDFromJWT(r)
+	if err != nil {
+		utils.AbortRequest(w, "Authorization Token is invalid.", http.StatusUnauthorized)
+		return
+	}
+
 	var input struct {
-		Role models.TeamMemberRole `json:"role"`
+		Role         models.TeamMemberRole `json:"role"`
+		CustomRoleID string                `json:"custom_role_id"`
 	}
 	if err := json.NewDecoder(r.Body).Decode(&input); err != nil {
 		utils.AbortRequest(w, "body invalid", http.StatusBadRequest)
 		return
 	}
 
+	// Personne ne peut modifier son propre rôle
+	if memberUUID == requesterUUID {
+		utils.AbortRequest(w, "You cannot change your own role", http.StatusForbidden)
+		return
+	}
+
+	target, err := team_member_service.GetByMemberId(teamUUID, memberUUID)
+	if err != nil {
+		utils.AbortRequest(w, "Team member not found", http.StatusNotFound)
+		return
+	}
+
+	// Seuls les owners peuvent toucher au rôle owner et accorder le rôle admin
+	isOwner := isRequesterOwner(r, teamUUID)
+	if (target.Role == models.TeamMemberRoleOwner || input.Role == models.TeamMemberRoleOwner || input.Role == models.TeamMemberRoleAdmin) && !isOwner {
+		utils.AbortRequest(w, "Only owners can grant or change owner and admin roles", http.StatusForbidden)
+		return
+	}
+
+	// Rôle demandé : rôle personnalisé ou rôle intégré
+	var customRoleUUID uuid.UUID
+	newMember := models.TeamMember{Role: input.Role}
+	if input.CustomRoleID != "" {
+		customRoleUUID, err = uuid.Parse(input.CustomRoleID)
+		if err != nil {
+			utils.AbortRequest(w, "Invalid custom role ID", http.StatusBadRequest)
+			return
+		}
+		customRole, err := team_role_service.Get(teamUUID, customRoleUUID)
+		if err != nil {
+			utils.AbortRequest(w, "role not found", http.StatusBadRequest)
+			return
+		}
+		newMember = models.TeamMember{Role: models.TeamMemberRoleCustom, CustomRole: &customRole}
+	}
+
+	// Hors owners, on ne peut ni accorder plus de permissions que les siennes, ni modifier le rôle
+	// d'un membre qui en a davantage
+	if !isOwner {
+		requester, err := team_member_service.GetEffective(teamUUID, requesterUUID)
+		if err != nil {
+			utils.AbortRequest(w, "Unauthorized", http.StatusForbidden)
+			return
+		}
+		granted := team_role_service.MemberPermissions(requester)
+		if !team_role_service.IsSubset(team_role_service.MemberPermissions(newMember), granted) ||
+			!team_role_service.IsSubset(team_role_service.MemberPermissions(target), granted) {
+			utils.AbortRequest(w, "You cannot grant or change permissions you do not hold", http.StatusForbidden)
+			return
+		}
+	}
+
 	// Mise à jour du rôle du membre
-	err = team_member_service.UpdateRole(teamUUID, memberUUID, input.Role)
+	if input.CustomRoleID != "" {
+		err = team_member_service.AssignCustomRole(teamUUID, memberUUID, customRoleUUID, requesterUUID)
+	} else {
+		err = team_member_service.UpdateRole(teamUUID, memberUUID, input.Role, requesterUUID)
+	}
 	if err != nil {
 		utils.AbortRequest(w, err.Error(), http.StatusBadRequest)
 		return
@@ -189,8 +291,25 @@ func HandleRemoveTeamMember(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	requesterUUID, err := utils.ExtractUserIDFromJWT(r)
+	if err != nil {
+		utils.AbortRequest(w, "Authorization Token is invalid.", http.StatusUnauthorized)
+		return
+	}
+
+	// Seuls les owners peuvent retirer un owner
+	target, err := team_member_service.GetByMemberId(teamUUID, memberUUID)
+	if err != nil {
+		utils.AbortRequest(w, "Team member not found", http.StatusNotFound)
+		return
+	}
+	if target.Role == models.TeamMemberRoleOwner && !isRequesterOwner(r, teamUUID) {
+		utils.AbortRequest(w, "Only owners can remove an owner", http.StatusForbidden)
+		return
+	}
+
 	// Suppression du membre de la Team
-	err = team_member_service.Remove(teamUUID, memberUUID)
+	err = team_member_service.Remove(database.DB, teamUUID, memberUUID, requesterUUID)
 	if err != nil {
 		utils.AbortRequest(w, err.Error(), http.StatusBadRequest)
 		return
@@ -202,3 +321,125 @@ func HandleRemoveTeamMember(w http.ResponseWriter, r *http.Request) {
 		"success": true,
 	})
 }
+
+// ~ /teams/{id}/members/{member_id}/suspend ~
+func HandleSuspendTeamMember(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+
+	teamUUID, err := checkForTeamID(vars["id"])
+	if err != nil {
+		utils.AbortRequest(w, fmt.Sprintf("Invalid team ID: %v", err), http.StatusBadRequest)
+		return
+	}
+
+	memberUUID, err := checkForMemberID(vars["member_id"])
+	if err != nil {
+		utils.AbortRequest(w, fmt.Sprintf("Invalid member ID: %v", err), http.StatusBadRequest)
+		return
+	}
+
+	requesterUUID, err := utils.ExtractUserIDFromJWT(r)
+	if err != nil {
+		utils.AbortRequest(w, "Authorization Token is invalid.", http.StatusUnauthorized)
+		return
+	}
+
+	// Seuls les owners peuvent suspendre un owner
+	target, err := team_member_service.GetByMemberId(teamUUID, memberUUID)
+	if err != nil {
+		utils.AbortRequest(w, "Team member not found", http.StatusNotFound)
+		return
+	}
+	if target.Role == models.TeamMemberRoleOwner && !isRequesterOwner(r, teamUUID) {
+		utils.AbortRequest(w, "Only owners can suspend an owner", http.StatusForbidden)
+		return
+	}
+
+	// Suspension : l'accès est bloqué, l'adhésion est conservée
+	if err := team_member_service.Suspend(teamUUID, memberUUID, requesterUUID); err != nil {
+		utils.AbortRequest(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	// Réponse JSON
+	utils.RespondJSON(w, map[string]interface{}{
+		"success": true,
+	})
+}
+
+// ~ /teams/{id}/members/{member_id}/reactivate ~
+func HandleReactivateTeamMember(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+
+	teamUUID, err := checkForTeamID(vars["id"])
+	if err != nil {
+		utils.AbortRequest(w, fmt.Sprintf("Invalid team ID: %v", err), http.StatusBadRequest)
+		return
+	}
+
+	memberUUID, err := checkForMemberID(vars["member_id"])
+	if err != nil {
+		utils.AbortRequest(w, fmt.Sprintf("Invalid member ID: %v", err), http.StatusBadRequest)
+		return
+	}
+
+	requesterUUID, err := utils.ExtractUserIDFromJWT(r)
+	if err != nil {
+		utils.AbortRequest(w, "Authorization Token is invalid.", http.StatusUnauthorized)
+		return
+	}
+
+	// Seuls les owners peuvent réactiver un owner
+	target, err := team_member_service.GetByMemberId(teamUUID, memberUUID)
+	if err != nil {
+		utils.AbortRequest(w, "Team member not found", http.StatusNotFound)
+		return
+	}
+	if target.Role == models.TeamMemberRoleOwner && !isRequesterOwner(r, teamUUID) {
+		utils.AbortRequest(w, "Only owners can reactivate an owner", http.StatusForbidden)
+		return
+	}
+
+	if err := team_member_service.Reactivate(teamUUID, memberUUID, requesterUUID); err != nil {
+		utils.AbortRequest(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	// Réponse JSON
+	utils.RespondJSON(w, map[string]interface{}{
+		"success": true,
+	})
+}
+
+// ~ /teams/{id}/leave ~
+func HandleLeaveTeam(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+
+	teamUUID, err := checkForTeamID(vars["id"])
+	if err != nil {
+		utils.AbortRequest(w, fmt.Sprintf("Invalid team ID: %v", err), http.StatusBadRequest)
+		return
+	}
+
+	userUUID, err := utils.ExtractUserIDFromJWT(r)
+	if err != nil {
+		utils.AbortRequest(w, "Authorization Token is invalid.", http.StatusUnauthorized)
+		return
+	}
+
+	// Départ volontaire (le dernier owner doit d'abord transférer la propriété)
+	err = team_member_service.Remove(database.DB, teamUUID, userUUID, userUUID)
+	if errors.Is(err, team_member_service.ErrLastOwner) {
+		utils.AbortRequest(w, "You are the last owner: transfer ownership before leaving", http.StatusConflict)
+		return
+	}
+	if err != nil {
+		utils.AbortRequest(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	// Réponse JSON
+	utils.RespondJSON(w, map[string]interface{}{
+		"success": true,
+	})
+}
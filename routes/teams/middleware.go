@@ -1,13 +1,16 @@
 package teams
 
 import (
+	"errors"
 	"fmt"
 	"gox/database/models"
 	auth_utils "gox/services/auth"
 	team_service "gox/services/teams"
 	team_member_service "gox/services/teams/members"
+	team_role_service "gox/services/teams/roles"
 	"gox/utils"
 	"net/http"
+	"strings"
 
 	"github.com/google/uuid"
 	"github.com/gorilla/mux"
@@ -47,6 +50,57 @@ func getTeamUUIDFromRequest(w http.ResponseWriter, r *http.Request) (uuid.UUID,
 	return teamUUID, nil
 }
 
+// ~ Checks that the user holds the permission in the team, aborts the request otherwise
+func requireTeamPermission(w http.ResponseWriter, teamUUID, userUUID uuid.UUID, permission models.TeamPermission) bool {
+	allowed, err := team_role_service.HasPermission(teamUUID, userUUID, permission)
+	if err != nil {
+		utils.AbortRequest(w, "An error occured", http.StatusInternalServerError)
+		return false
+	}
+	if !allowed {
+		utils.AbortRequest(w, fmt.Sprintf("Missing permission: %s", permission), http.StatusForbidden)
+		return false
+	}
+
+	return true
+}
+
+// ~ Suspended teams cannot be modified, aborts the request otherwise
+func requireUnsuspendedTeam(w http.ResponseWriter, team models.Team) bool {
+	if err := team_service.CheckNotSuspended(team); err != nil {
+		if errors.Is(err, team_service.ErrTeamSuspended) {
+			utils.AbortRequest(w, "Team is suspended and read-only", http.StatusForbidden)
+		} else {
+			utils.AbortRequest(w, "An error occured", http.StatusInternalServerError)
+		}
+		return false
+	}
+
+	return true
+}
+
+// ~ Suspended and archived teams are read-only, aborts the request otherwise
+func requireWritableTeam(w http.ResponseWriter, r *http.Request, teamUUID uuid.UUID) bool {
+	if r.Method == http.MethodGet {
+		return true
+	}
+
+	team, err := team_service.Get(teamUUID)
+	if err != nil {
+		utils.AbortRequest(w, "Team not found", http.StatusNotFound)
+		return false
+	}
+	if !requireUnsuspendedTeam(w, team) {
+		return false
+	}
+	if team.IsArchived() {
+		utils.AbortRequest(w, "Team is archived and read-only", http.StatusConflict)
+		return false
+	}
+
+	return true
+}
+
 func TeamRouteMiddleware(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		// ~ Let's check that the user is authenticated
@@ -76,18 +130,46 @@ func TeamRouteMiddleware(next http.Handler) http.Handler {
 			return
 		}
 
-		// ~ Check team member permissions
-		if r.Method == http.MethodPost {
-			// ~ TeamMemberRoleSpectator can't add members
-			member, err := team_member_service.GetByMemberId(teamUUID, userUUID)
+		// ~ Archived teams can only be read or restored, unless suspended
+		if strings.HasSuffix(r.URL.Path, "/restore") {
+			if !requireTeamPermission(w, teamUUID, userUUID, models.TeamPermissionSettingsWrite) {
+				return
+			}
+			team, err := team_service.Get(teamUUID)
 			if err != nil {
-				utils.AbortRequest(w, "An error occured", http.StatusInternalServerError)
+				utils.AbortRequest(w, "Team not found", http.StatusNotFound)
 				return
 			}
-			if member.Role == models.TeamMemberRoleSpectator {
+			if !requireUnsuspendedTeam(w, team) {
+				return
+			}
+			next.ServeHTTP(w, r)
+			return
+		}
+		if !requireWritableTeam(w, r, teamUUID) {
+			return
+		}
+
+		// ~ Check team member permissions
+		if strings.HasSuffix(r.URL.Path, "/convert") {
+			// ~ Only the owner can convert its personal team
+			if !isRequesterOwner(r, teamUUID) {
 				utils.AbortRequest(w, "Unauthorized", http.StatusForbidden)
 				return
 			}
+		} else if (r.Method == http.MethodPost && strings.HasSuffix(r.URL.Path, "/subteams")) || strings.HasSuffix(r.URL.Path, "/parent") {
+			// ~ Managing the hierarchy requires the settings permission (inherited from the organization or not)
+			if !requireTeamPermission(w, teamUUID, userUUID, models.TeamPermissionSettingsWrite) {
+				return
+			}
+		} else if r.Method == http.MethodPost && strings.HasSuffix(r.URL.Path, "/members") {
+			if !requireTeamPermission(w, teamUUID, userUUID, models.TeamPermissionMembersInvite) {
+				return
+			}
+		} else if r.Method == http.MethodPatch || r.Method == http.MethodDelete {
+			if !requireTeamPermission(w, teamUUID, userUUID, models.TeamPermissionSettingsWrite) {
+				return
+			}
 		}
 
 		// ~ OK. Serve.
@@ -116,7 +198,7 @@ func TeamMemberRouteMiddleware(next http.Handler) http.Handler {
 		}
 
 		vars := mux.Vars(r)
-		memberID := vars["memberID"]
+		memberID := vars["member_id"]
 
 		// ~ Check if the user is a member of the team
 		isIn, err := team_service.IsUserInTeam(userUUID, teamUUID)
@@ -129,34 +211,179 @@ func TeamMemberRouteMiddleware(next http.Handler) http.Handler {
 			return
 		}
 
+		if !requireWritableTeam(w, r, teamUUID) {
+			return
+		}
+
 		// ~ Check team member permissions
 		if r.Method == http.MethodDelete {
-			// ~ TeamMemberRoleSpectator can't remove members
-			member, err := team_member_service.GetByMemberId(teamUUID, userUUID)
-			if err != nil {
-				utils.AbortRequest(w, fmt.Sprintf("An error occured: %v", err), http.StatusInternalServerError)
+			if !requireTeamPermission(w, teamUUID, userUUID, models.TeamPermissionMembersRemove) {
 				return
 			}
-			if member.Role == models.TeamMemberRoleSpectator {
-				utils.AbortRequest(w, "Unauthorized", http.StatusForbidden)
+		} else if r.Method == http.MethodPatch || r.Method == http.MethodPost {
+			// ~ PATCH changes the role, POST suspends or reactivates the membership
+			if !requireTeamPermission(w, teamUUID, userUUID, models.TeamPermissionMembersUpdate) {
 				return
 			}
-		} else if r.Method == http.MethodPatch {
-			// ~ TeamMemberRoleSpectator can't update members
-			member, err := team_member_service.GetByMemberId(teamUUID, userUUID)
+		}
+
+		if r.Method != http.MethodGet {
+			// ~ Does member exist?
+			memberUUID, err := uuid.Parse(memberID)
 			if err != nil {
-				utils.AbortRequest(w, "An error occured", http.StatusInternalServerError)
+				utils.AbortRequest(w, "Invalid member ID", http.StatusBadRequest)
 				return
 			}
-			if member.Role == models.TeamMemberRoleSpectator {
-				utils.AbortRequest(w, "Unauthorized", http.StatusForbidden)
+			if _, err = team_member_service.GetByMemberId(teamUUID, memberUUID); err != nil {
+				utils.AbortRequest(w, "Member not found", http.StatusNotFound)
 				return
 			}
+		}
 
-			// ~ Does member exist?
-			_, err = team_member_service.Get(teamUUID, memberID)
-			if err != nil {
-				utils.AbortRequest(w, "Member not found", http.StatusNotFound)
+		// ~ OK. Serve.
+		next.ServeHTTP(w, r)
+	})
+}
+
+// ~ /teams/{id}/invitations ~
+// ~ /teams/{id}/invitations/{invitation_id} ~
+
+func TeamInvitationsRouteMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// ~ Let's check that the user is authenticated
+		if !auth_utils.CheckAuthenticationHeader(w, r) {
+			return
+		}
+
+		userUUID, err := utils.ExtractUserIDFromJWT(r)
+		if err != nil {
+			utils.AbortRequest(w, "Authorization Token is invalid.", http.StatusUnauthorized)
+			return
+		}
+
+		teamUUID, err := getTeamUUIDFromRequest(w, r)
+		if err != nil {
+			return
+		}
+
+		// ~ Managing invitations requires the members:invite permission
+		if !requireTeamPermission(w, teamUUID, userUUID, models.TeamPermissionMembersInvite) {
+			return
+		}
+		if !requireWritableTeam(w, r, teamUUID) {
+			return
+		}
+
+		// ~ OK. Serve.
+		next.ServeHTTP(w, r)
+	})
+}
+
+// ~ /invitations/{token}/accept ~
+// ~ /invitations/{token}/decline ~
+
+func InvitationRouteMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// ~ Declining only needs the token sent by email
+		if strings.HasSuffix(r.URL.Path, "/decline") {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		// ~ Accepting requires to be logged in as the invited user
+		if !auth_utils.CheckAuthenticationHeader(w, r) {
+			return
+		}
+
+		// ~ OK. Serve.
+		next.ServeHTTP(w, r)
+	})
+}
+
+// ~ /teams/{id}/transfer-ownership ~
+// ~ /teams/{id}/transfer-ownership/accept ~
+// ~ /teams/{id}/transfer-ownership/decline ~
+
+func TeamOwnershipRouteMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// ~ Let's check that the user is authenticated
+		if !auth_utils.CheckAuthenticationHeader(w, r) {
+			return
+		}
+
+		userUUID, err := utils.ExtractUserIDFromJWT(r)
+		if err != nil {
+			utils.AbortRequest(w, "Authorization Token is invalid.", http.StatusUnauthorized)
+			return
+		}
+
+		teamUUID, err := getTeamUUIDFromRequest(w, r)
+		if err != nil {
+			return
+		}
+
+		// ~ Check if the user is an active member of the team
+		member, err := team_member_service.GetByMemberId(teamUUID, userUUID)
+		if err != nil || !member.IsActive || !member.IsAccessible {
+			utils.AbortRequest(w, "Unauthorized", http.StatusForbidden)
+			return
+		}
+
+		if !requireWritableTeam(w, r, teamUUID) {
+			return
+		}
+
+		// ~ Only owners can start or cancel a transfer. Accept/decline is checked against the target.
+		isResponse := strings.HasSuffix(r.URL.Path, "/accept") || strings.HasSuffix(r.URL.Path, "/decline")
+		if !isResponse && r.Method != http.MethodGet && member.Role != models.TeamMemberRoleOwner {
+			utils.AbortRequest(w, "Only owners can transfer ownership", http.StatusForbidden)
+			return
+		}
+
+		// ~ OK. Serve.
+		next.ServeHTTP(w, r)
+	})
+}
+
+// ~ /teams/{id}/roles ~
+// ~ /teams/{id}/roles/{role_id} ~
+
+func TeamRolesRouteMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// ~ Let's check that the user is authenticated
+		if !auth_utils.CheckAuthenticationHeader(w, r) {
+			return
+		}
+
+		userUUID, err := utils.ExtractUserIDFromJWT(r)
+		if err != nil {
+			utils.AbortRequest(w, "Authorization Token is invalid.", http.StatusUnauthorized)
+			return
+		}
+
+		teamUUID, err := getTeamUUIDFromRequest(w, r)
+		if err != nil {
+			return
+		}
+
+		// ~ Check if the user is a member of the team
+		isIn, err := team_service.IsUserInTeam(userUUID, teamUUID)
+		if err != nil {
+			utils.AbortRequest(w, "An error occured", http.StatusInternalServerError)
+			return
+		}
+		if !isIn {
+			utils.AbortRequest(w, "Unauthorized", http.StatusForbidden)
+			return
+		}
+
+		if !requireWritableTeam(w, r, teamUUID) {
+			return
+		}
+
+		// ~ Any member can read roles, defining them is a team setting
+		if r.Method != http.MethodGet {
+			if !requireTeamPermission(w, teamUUID, userUUID, models.TeamPermissionSettingsWrite) {
 				return
 			}
 		}
@@ -165,3 +392,81 @@ func TeamMemberRouteMiddleware(next http.Handler) http.Handler {
 		next.ServeHTTP(w, r)
 	})
 }
+
+// ~ /teams/{id}/domains ~
+// ~ /teams/{id}/join-requests ~
+// ~ /teams/{id}/scim-tokens ~
+
+func TeamSettingsRouteMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// ~ Let's check that the user is authenticated
+		if !auth_utils.CheckAuthenticationHeader(w, r) {
+			return
+		}
+
+		userUUID, err := utils.ExtractUserIDFromJWT(r)
+		if err != nil {
+			utils.AbortRequest(w, "Authorization Token is invalid.", http.StatusUnauthorized)
+			return
+		}
+
+		teamUUID, err := getTeamUUIDFromRequest(w, r)
+		if err != nil {
+			return
+		}
+
+		// ~ Domains and SCIM tokens are team settings, join requests are handled like invitations
+		permission := models.TeamPermissionSettingsWrite
+		if strings.Contains(r.URL.Path, "/join-requests") {
+			permission = models.TeamPermissionMembersInvite
+		}
+		if !requireTeamPermission(w, teamUUID, userUUID, permission) {
+			return
+		}
+		if !requireWritableTeam(w, r, teamUUID) {
+			return
+		}
+
+		// ~ OK. Serve.
+		next.ServeHTTP(w, r)
+	})
+}
+
+// ~ /teams/{id}/activity ~
+
+func TeamActivityRouteMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// ~ Let's check that the user is authenticated
+		if !auth_utils.CheckAuthenticationHeader(w, r) {
+			return
+		}
+
+		userUUID, err := utils.ExtractUserIDFromJWT(r)
+		if err != nil {
+			utils.AbortRequest(w, "Authorization Token is invalid.", http.StatusUnauthorized)
+			return
+		}
+
+		teamUUID, err := getTeamUUIDFromRequest(w, r)
+		if err != nil {
+			return
+		}
+
+		// ~ The activity feed is restricted to owners and admins (direct or inherited)
+		member, err := team_member_service.GetEffective(teamUUID, userUUID)
+		if err != nil || !member.IsActive || !member.IsAccessible {
+			utils.AbortRequest(w, "Unauthorized", http.StatusForbidden)
+			return
+		}
+		if member.Role != models.TeamMemberRoleOwner && member.Role != models.TeamMemberRoleAdmin {
+			utils.AbortRequest(w, "Only owners and admins can view the team activity", http.StatusForbidden)
+			return
+		}
+		if !requireWritableTeam(w, r, teamUUID) {
+			return
+		}
+
+		// ~ OK. Serve.
+		next.ServeHTTP(w, r)
+	})
+}
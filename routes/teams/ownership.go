@@ -0,0 +1,161 @@
+package teams
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+
+	"github.com/google/uuid"
+	"github.com/gorilla/mux"
+
+	"gox/database/models"
+	team_ownership_service "gox/services/teams/ownership"
+	"gox/utils"
+)
+
+func ownershipTransferData(transfer models.TeamOwnershipTransfer) map[string]interface{} {
+	return map[string]interface{}{
+		"id":             transfer.ID,
+		"team_id":        transfer.TeamID,
+		"from_member_id": transfer.FromMemberID,
+		"to_member_id":   transfer.ToMemberID,
+		"status":         transfer.Status,
+		"created_on":     transfer.CreatedOn,
+	}
+}
+
+// ~ /teams/{id}/transfer-ownership ~
+func HandleGetOwnershipTransfer(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	teamUUID, err := checkForTeamID(vars["id"])
+	if err != nil {
+		utils.AbortRequest(w, fmt.Sprintf("Invalid team ID: %v", err), http.StatusBadRequest)
+		return
+	}
+
+	// Récupération du transfert en attente
+	transfer, err := team_ownership_service.GetPending(teamUUID)
+	if err != nil {
+		utils.AbortRequest(w, "No pending ownership transfer", http.StatusNotFound)
+		return
+	}
+
+	// Réponse JSON
+	utils.RespondJSON(w, ownershipTransferData(transfer))
+}
+
+func HandleRequestOwnershipTransfer(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	teamUUID, err := checkForTeamID(vars["id"])
+	if err != nil {
+		utils.AbortRequest(w, fmt.Sprintf("Invalid team ID: %v", err), http.StatusBadRequest)
+		return
+	}
+
+	userUUID, err := utils.ExtractUserIDFromJWT(r)
+	if err != nil {
+		utils.AbortRequest(w, "Authorization Token is invalid.", http.StatusUnauthorized)
+		return
+	}
+
+	var input struct {
+		UserID string `json:"user_id"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&input); err != nil {
+		utils.AbortRequest(w, "body invalid", http.StatusBadRequest)
+		return
+	}
+
+	targetUUID, err := uuid.Parse(input.UserID)
+	if err != nil {
+		utils.AbortRequest(w, "Invalid user ID", http.StatusBadRequest)
+		return
+	}
+
+	// Création du transfert, en attente d'acceptation par la cible
+	transfer, err := team_ownership_service.Request(teamUUID, userUUID, targetUUID)
+	if err != nil {
+		utils.AbortRequest(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	// Réponse JSON
+	utils.RespondJSON(w, ownershipTransferData(transfer))
+}
+
+func HandleCancelOwnershipTransfer(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	teamUUID, err := checkForTeamID(vars["id"])
+	if err != nil {
+		utils.AbortRequest(w, fmt.Sprintf("Invalid team ID: %v", err), http.StatusBadRequest)
+		return
+	}
+
+	userUUID, err := utils.ExtractUserIDFromJWT(r)
+	if err != nil {
+		utils.AbortRequest(w, "Authorization Token is invalid.", http.StatusUnauthorized)
+		return
+	}
+
+	if err := team_ownership_service.Cancel(teamUUID, userUUID); err != nil {
+		utils.AbortRequest(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	// Réponse JSON
+	utils.RespondJSON(w, map[string]interface{}{
+		"success": true,
+	})
+}
+
+// ~ /teams/{id}/transfer-ownership/accept ~
+func HandleAcceptOwnershipTransfer(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	teamUUID, err := checkForTeamID(vars["id"])
+	if err != nil {
+		utils.AbortRequest(w, fmt.Sprintf("Invalid team ID: %v", err), http.StatusBadRequest)
+		return
+	}
+
+	userUUID, err := utils.ExtractUserIDFromJWT(r)
+	if err != nil {
+		utils.AbortRequest(w, "Authorization Token is invalid.", http.StatusUnauthorized)
+		return
+	}
+
+	if err := team_ownership_service.Accept(teamUUID, userUUID); err != nil {
+		utils.AbortRequest(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	// Réponse JSON
+	utils.RespondJSON(w, map[string]interface{}{
+		"success": true,
+	})
+}
+
+// ~ /teams/{id}/transfer-ownership/decline ~
+func HandleDeclineOwnershipTransfer(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	teamUUID, err := checkForTeamID(vars["id"])
+	if err != nil {
+		utils.AbortRequest(w, fmt.Sprintf("Invalid team ID: %v", err), http.StatusBadRequest)
+		return
+	}
+
+	userUUID, err := utils.ExtractUserIDFromJWT(r)
+	if err != nil {
+		utils.AbortRequest(w, "Authorization Token is invalid.", http.StatusUnauthorized)
+		return
+	}
+
+	if err := team_ownership_service.Decline(teamUUID, userUUID); err != nil {
+		utils.AbortRequest(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	// Réponse JSON
+	utils.RespondJSON(w, map[string]interface{}{
+		"success": true,
+	})
+}
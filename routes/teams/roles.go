@@ -0,0 +1,204 @@
+package teams
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+
+	"github.com/google/uuid"
+	"github.com/gorilla/mux"
+
+	"gox/database/models"
+	team_member_service "gox/services/teams/members"
+	team_role_service "gox/services/teams/roles"
+	"gox/utils"
+)
+
+func roleData(role models.TeamRole) map[string]interface{} {
+	return map[string]interface{}{
+		"id":          role.ID,
+		"team_id":     role.TeamID,
+		"name":        role.Name,
+		"permissions": role.Permissions,
+	}
+}
+
+// ~ /teams/{id}/roles ~
+func HandleGetTeamRoles(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	teamUUID, err := checkForTeamID(vars["id"])
+	if err != nil {
+		utils.AbortRequest(w, fmt.Sprintf("Invalid team ID: %v", err), http.StatusBadRequest)
+		return
+	}
+
+	// Récupération des rôles personnalisés
+	roles, err := team_role_service.GetAll(teamUUID)
+	if err != nil {
+		utils.AbortRequest(w, "Error fetching team roles", http.StatusInternalServerError)
+		return
+	}
+
+	// Réponse JSON
+	data := make([]map[string]interface{}, len(roles))
+	for i, role := range roles {
+		data[i] = roleData(role)
+	}
+	utils.RespondJSON(w, map[string]interface{}{
+		"roles":       data,
+		"permissions": models.AllTeamPermissions,
+	})
+}
+
+func HandleCreateTeamRole(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	teamUUID, err := checkForTeamID(vars["id"])
+	if err != nil {
+		utils.AbortRequest(w, fmt.Sprintf("Invalid team ID: %v", err), http.StatusBadRequest)
+		return
+	}
+
+	userUUID, err := utils.ExtractUserIDFromJWT(r)
+	if err != nil {
+		utils.AbortRequest(w, "Authorization Token is invalid.", http.StatusUnauthorized)
+		return
+	}
+
+	var input struct {
+		Name        string                  `json:"name"`
+		Permissions []models.TeamPermission `json:"permissions"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&input); err != nil {
+		utils.AbortRequest(w, "body invalid", http.StatusBadRequest)
+		return
+	}
+
+	// Hors owners, on ne peut accorder que des permissions que l'on détient
+	requester, err := team_member_service.GetEffective(teamUUID, userUUID)
+	if err != nil {
+		utils.AbortRequest(w, "Unauthorized", http.StatusForbidden)
+		return
+	}
+	if err := team_role_service.CheckGrant(requester, nil, input.Permissions); err != nil {
+		utils.AbortRequest(w, err.Error(), http.StatusForbidden)
+		return
+	}
+
+	// Création du rôle
+	role, err := team_role_service.Create(teamUUID, input.Name, input.Permissions, userUUID)
+	if err != nil {
+		utils.AbortRequest(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	// Réponse JSON
+	utils.RespondJSON(w, roleData(role))
+}
+
+// ~ /teams/{id}/roles/{role_id} ~
+func getRoleUUIDs(w http.ResponseWriter, r *http.Request) (uuid.UUID, uuid.UUID, bool) {
+	vars := mux.Vars(r)
+
+	teamUUID, err := checkForTeamID(vars["id"])
+	if err != nil {
+		utils.AbortRequest(w, fmt.Sprintf("Invalid team ID: %v", err), http.StatusBadRequest)
+		return uuid.Nil, uuid.Nil, false
+	}
+
+	roleUUID, err := uuid.Parse(vars["role_id"])
+	if err != nil {
+		utils.AbortRequest(w, "Invalid role ID", http.StatusBadRequest)
+		return uuid.Nil, uuid.Nil, false
+	}
+
+	return teamUUID, roleUUID, true
+}
+
+func HandleGetTeamRole(w http.ResponseWriter, r *http.Request) {
+	teamUUID, roleUUID, ok := getRoleUUIDs(w, r)
+	if !ok {
+		return
+	}
+
+	role, err := team_role_service.Get(teamUUID, roleUUID)
+	if err != nil {
+		utils.AbortRequest(w, "Role not found", http.StatusNotFound)
+		return
+	}
+
+	// Réponse JSON
+	utils.RespondJSON(w, roleData(role))
+}
+
+func HandleUpdateTeamRole(w http.ResponseWriter, r *http.Request) {
+	teamUUID, roleUUID, ok := getRoleUUIDs(w, r)
+	if !ok {
+		return
+	}
+
+	userUUID, err := utils.ExtractUserIDFromJWT(r)
+	if err != nil {
+		utils.AbortRequest(w, "Authorization Token is invalid.", http.StatusUnauthorized)
+		return
+	}
+
+	var input struct {
+		Name        string                  `json:"name"`
+		Permissions []models.TeamPermission `json:"permissions"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&input); err != nil {
+		utils.AbortRequest(w, "body invalid", http.StatusBadRequest)
+		return
+	}
+
+	current, err := team_role_service.Get(teamUUID, roleUUID)
+	if err != nil {
+		utils.AbortRequest(w, "Role not found", http.StatusNotFound)
+		return
+	}
+
+	// Hors owners, ni son propre rôle, ni un rôle plus puissant, ni plus de permissions que les siennes
+	requester, err := team_member_service.GetEffective(teamUUID, userUUID)
+	if err != nil {
+		utils.AbortRequest(w, "Unauthorized", http.StatusForbidden)
+		return
+	}
+	if err := team_role_service.CheckGrant(requester, &current, input.Permissions); err != nil {
+		utils.AbortRequest(w, err.Error(), http.StatusForbidden)
+		return
+	}
+
+	// Mise à jour du rôle
+	role, err := team_role_service.Update(teamUUID, roleUUID, input.Name, input.Permissions, userUUID)
+	if err != nil {
+		utils.AbortRequest(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	// Réponse JSON
+	utils.RespondJSON(w, roleData(role))
+}
+
+func HandleDeleteTeamRole(w http.ResponseWriter, r *http.Request) {
+	teamUUID, roleUUID, ok := getRoleUUIDs(w, r)
+	if !ok {
+		return
+	}
+
+	userUUID, err := utils.ExtractUserIDFromJWT(r)
+	if err != nil {
+		utils.AbortRequest(w, "Authorization Token is invalid.", http.StatusUnauthorized)
+		return
+	}
+
+	// Suppression du rôle (ses membres redeviennent spectators)
+	if err := team_role_service.Delete(teamUUID, roleUUID, userUUID); err != nil {
+		utils.AbortRequest(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	// Réponse JSON
+	utils.RespondJSON(w, map[string]interface{}{
+		"success": true,
+	})
+}
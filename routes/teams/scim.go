@@ -0,0 +1,101 @@
+package teams
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+
+	"github.com/google/uuid"
+	"github.com/gorilla/mux"
+
+	scim_service "gox/services/scim"
+	"gox/utils"
+)
+
+// ~ /teams/{id}/scim-tokens ~
+func HandleGetSCIMTokens(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+
+	teamUUID, err := checkForTeamID(vars["id"])
+	if err != nil {
+		utils.AbortRequest(w, fmt.Sprintf("Invalid team ID: %v", err), http.StatusBadRequest)
+		return
+	}
+
+	tokens, err := scim_service.GetTokens(teamUUID)
+	if err != nil {
+		utils.AbortRequest(w, "Error fetching SCIM tokens", http.StatusInternalServerError)
+		return
+	}
+
+	// Réponse JSON (les jetons en clair ne sont jamais renvoyés)
+	data := make([]map[string]interface{}, len(tokens))
+	for i, token := range tokens {
+		data[i] = map[string]interface{}{
+			"id":           token.ID,
+			"name":         token.Name,
+			"created_on":   token.CreatedOn,
+			"last_used_at": token.LastUsedAt,
+		}
+	}
+	utils.RespondJSON(w, data)
+}
+
+func HandleCreateSCIMToken(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+
+	teamUUID, err := checkForTeamID(vars["id"])
+	if err != nil {
+		utils.AbortRequest(w, fmt.Sprintf("Invalid team ID: %v", err), http.StatusBadRequest)
+		return
+	}
+
+	var input struct {
+		Name string `json:"name"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&input); err != nil {
+		utils.AbortRequest(w, "body invalid", http.StatusBadRequest)
+		return
+	}
+
+	scimToken, token, err := scim_service.CreateToken(teamUUID, input.Name)
+	if err != nil {
+		utils.AbortRequest(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	// Réponse JSON : le jeton n'est affiché qu'une seule fois
+	utils.RespondJSON(w, map[string]interface{}{
+		"id":         scimToken.ID,
+		"name":       scimToken.Name,
+		"token":      token,
+		"created_on": scimToken.CreatedOn,
+	})
+}
+
+// ~ /teams/{id}/scim-tokens/{token_id} ~
+func HandleRevokeSCIMToken(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+
+	teamUUID, err := checkForTeamID(vars["id"])
+	if err != nil {
+		utils.AbortRequest(w, fmt.Sprintf("Invalid team ID: %v", err), http.StatusBadRequest)
+		return
+	}
+
+	tokenUUID, err := uuid.Parse(vars["token_id"])
+	if err != nil {
+		utils.AbortRequest(w, "Invalid token ID", http.StatusBadRequest)
+		return
+	}
+
+	if err := scim_service.RevokeToken(teamUUID, tokenUUID); err != nil {
+		utils.AbortRequest(w, err.Error(), http.StatusNotFound)
+		return
+	}
+
+	// Réponse JSON
+	utils.RespondJSON(w, map[string]interface{}{
+		"success": true,
+	})
+}
@@ -0,0 +1,32 @@
+package teams
+
+import (
+	"fmt"
+	"net/http"
+
+	"github.com/gorilla/mux"
+
+	stats_service "gox/services/stats"
+	"gox/utils"
+)
+
+// ~ /teams/{id}/stats ~
+func HandleGetTeamStats(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+
+	teamUUID, err := checkForTeamID(vars["id"])
+	if err != nil {
+		utils.AbortRequest(w, fmt.Sprintf("Invalid team ID: %v", err), http.StatusBadRequest)
+		return
+	}
+
+	// Statistiques précalculées par le job "stats"
+	stats, err := stats_service.GetTeam(teamUUID)
+	if err != nil {
+		utils.AbortRequest(w, "Error fetching team stats", http.StatusInternalServerError)
+		return
+	}
+
+	// Réponse JSON
+	utils.RespondJSON(w, stats_service.TeamView(stats))
+}
@@ -0,0 +1,218 @@
+package users
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"regexp"
+	"strconv"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/gorilla/mux"
+	"gorm.io/gorm"
+
+	storage_service "gox/services/storage"
+	user_service "gox/services/users"
+	user_avatar_service "gox/services/users/avatar"
+	user_profile_service "gox/services/users/profile"
+	"gox/utils"
+)
+
+// ~ /users/{id}/profile/avatar ~
+func HandleUploadAvatar(w http.ResponseWriter, r *http.Request) {
+	userID, err := getUserID(w, r)
+	if err != nil {
+		utils.AbortRequest(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	userUUID, err := uuid.Parse(userID)
+	if err != nil {
+		utils.AbortRequest(w, "Invalid user id", http.StatusBadRequest)
+		return
+	}
+
+	// Limite de taille : le fichier plus une marge pour l'enveloppe multipart
+	maxBytes := user_avatar_service.MaxUploadBytes()
+	r.Body = http.MaxBytesReader(w, r.Body, maxBytes+64<<10)
+	if err := r.ParseMultipartForm(maxBytes); err != nil {
+		var tooLarge *http.MaxBytesError
+		if errors.As(err, &tooLarge) {
+			utils.AbortRequest(w, fmt.Sprintf("Avatar must not exceed %d bytes", maxBytes), http.StatusRequestEntityTooLarge)
+			return
+		}
+		utils.AbortRequest(w, "Expected a multipart/form-data body", http.StatusBadRequest)
+		return
+	}
+	defer r.MultipartForm.RemoveAll()
+
+	file, _, err := r.FormFile("avatar")
+	if err != nil {
+		utils.AbortRequest(w, "Missing avatar file", http.StatusBadRequest)
+		return
+	}
+	defer file.Close()
+
+	data, err := io.ReadAll(io.LimitReader(file, maxBytes+1))
+	if err != nil {
+		utils.AbortRequest(w, "Error reading avatar", http.StatusBadRequest)
+		return
+	}
+	if int64(len(data)) > maxBytes {
+		utils.AbortRequest(w, fmt.Sprintf("Avatar must not exceed %d bytes", maxBytes), http.StatusRequestEntityTooLarge)
+		return
+	}
+
+	// Vérification, redimensionnement et stockage
+	avatarURL, err := user_avatar_service.Upload(userUUID, data)
+	if errors.Is(err, user_avatar_service.ErrUnsupportedImage) {
+		utils.AbortRequest(w, err.Error(), http.StatusUnsupportedMediaType)
+		return
+	}
+	if errors.Is(err, user_avatar_service.ErrImageDimensions) {
+		utils.AbortRequest(w, fmt.Sprintf("Avatar must be between %dx%d and %dx%d pixels", user_avatar_service.MinDimension, user_avatar_service.MinDimension, user_avatar_service.MaxDimension, user_avatar_service.MaxDimension), http.StatusBadRequest)
+		return
+	}
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		utils.AbortRequest(w, "User profile not found", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		utils.AbortRequest(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	// Réponse JSON
+	utils.RespondJSON(w, map[string]interface{}{
+		"avatar_url": user_avatar_service.URL(avatarURL, user_avatar_service.Sizes[0]),
+		"avatars":    user_avatar_service.URLs(avatarURL),
+	})
+}
+
+func HandleGetAvatar(w http.ResponseWriter, r *http.Request) {
+	userID, err := getUserID(w, r)
+	if err != nil {
+		utils.AbortRequest(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	userUUID, err := uuid.Parse(userID)
+	if err != nil {
+		utils.AbortRequest(w, "Invalid user id", http.StatusBadRequest)
+		return
+	}
+
+	user, err := user_service.Get(userUUID)
+	if err != nil {
+		utils.AbortRequest(w, "User not found", http.StatusNotFound)
+		return
+	}
+	profile, err := user_profile_service.Get(userUUID)
+	if err != nil {
+		utils.AbortRequest(w, "User profile not found", http.StatusNotFound)
+		return
+	}
+
+	// Même règle que le profil public : un profil inaccessible ou désactivé n'existe pas pour les autres
+	if (!profile.IsAccessible || !user.IsAccessible) && profileViewer(r, profile) != user_profile_service.ViewerSelf {
+		utils.AbortRequest(w, "User profile not found", http.StatusNotFound)
+		return
+	}
+	if profile.AvatarURL == "" {
+		utils.AbortRequest(w, "User has no avatar", http.StatusNotFound)
+		return
+	}
+
+	// Redirection vers la variante demandée (?size=), l'adresse change à chaque nouvel avatar
+	size := user_avatar_service.Sizes[0]
+	if s, err := strconv.Atoi(r.URL.Query().Get("size")); err == nil && s > 0 {
+		size = s
+	}
+	w.Header().Set("Cache-Control", "private, max-age=300")
+	http.Redirect(w, r, user_avatar_service.URL(profile.AvatarURL, size), http.StatusFound)
+}
+
+func HandleDeleteAvatar(w http.ResponseWriter, r *http.Request) {
+	userID, err := getUserID(w, r)
+	if err != nil {
+		utils.AbortRequest(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	userUUID, err := uuid.Parse(userID)
+	if err != nil {
+		utils.AbortRequest(w, "Invalid user id", http.StatusBadRequest)
+		return
+	}
+
+	// Suppression de l'avatar et de ses variantes
+	err = user_avatar_service.Remove(userUUID)
+	if errors.Is(err, user_avatar_service.ErrNoAvatar) || errors.Is(err, gorm.ErrRecordNotFound) {
+		utils.AbortRequest(w, "User has no avatar", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		utils.AbortRequest(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	// Réponse JSON
+	utils.RespondJSON(w, map[string]interface{}{
+		"success": true,
+	})
+}
+
+// ~ /avatars/{user_id}/{version}/{file} ~
+var (
+	avatarVersionPattern = regexp.MustCompile(`^[0-9a-f]{16}$`)
+	avatarFilePattern    = regexp.MustCompile(`^[0-9]{1,4}\.(jpg|png)$`)
+)
+
+func HandleServeAvatarFile(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+
+	userUUID, err := uuid.Parse(vars["user_id"])
+	if err != nil || !avatarVersionPattern.MatchString(vars["version"]) || !avatarFilePattern.MatchString(vars["file"]) {
+		utils.AbortRequest(w, "Avatar not found", http.StatusNotFound)
+		return
+	}
+
+	storage, err := storage_service.Default()
+	if err != nil {
+		utils.AbortRequest(w, "Storage unavailable", http.StatusInternalServerError)
+		return
+	}
+
+	// Lecture du fichier
+	body, object, err := storage.Get(fmt.Sprintf("avatars/%s/%s/%s", userUUID, vars["version"], vars["file"]))
+	if errors.Is(err, storage_service.ErrNotFound) {
+		utils.AbortRequest(w, "Avatar not found", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		utils.AbortRequest(w, "Error reading avatar", http.StatusInternalServerError)
+		return
+	}
+	defer body.Close()
+
+	data, err := io.ReadAll(body)
+	if err != nil {
+		utils.AbortRequest(w, "Error reading avatar", http.StatusInternalServerError)
+		return
+	}
+
+	// Le contenu d'une URL versionnée ne change jamais : cache d'un an
+	w.Header().Set("Cache-Control", "public, max-age=31536000, immutable")
+	w.Header().Set("Content-Type", object.ContentType)
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	if object.ETag != "" {
+		w.Header().Set("ETag", object.ETag)
+	}
+	if object.LastModified.IsZero() {
+		object.LastModified = time.Now()
+	}
+	http.ServeContent(w, r, vars["file"], object.LastModified, bytes.NewReader(data))
+}
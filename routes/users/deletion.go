@@ -0,0 +1,136 @@
+package users
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+
+	"gox/database/models"
+	team_member_service "gox/services/teams/members"
+	user_deletion_service "gox/services/users/deletion"
+	"gox/utils"
+)
+
+func deletionData(request models.UserDeletionRequest) map[string]interface{} {
+	return map[string]interface{}{
+		"id":               request.ID,
+		"status":           request.Status,
+		"requested_at":     request.RequestedAt,
+		"scheduled_for":    request.ScheduledFor,
+		"team_resolutions": request.TeamResolutions,
+	}
+}
+
+func deletionErrorStatus(err error) int {
+	if errors.Is(err, user_deletion_service.ErrDeletionPending) || errors.Is(err, user_deletion_service.ErrAlreadyAnonymized) || errors.Is(err, team_member_service.ErrLastOwner) {
+		return http.StatusConflict
+	}
+	if errors.Is(err, user_deletion_service.ErrNoPendingDeletion) || errors.Is(err, gorm.ErrRecordNotFound) {
+		return http.StatusNotFound
+	}
+	if errors.Is(err, user_deletion_service.ErrInvalidResolution) {
+		return http.StatusBadRequest
+	}
+	return http.StatusInternalServerError
+}
+
+// ~ /users/{id}/deletion ~
+func HandleGetUserDeletion(w http.ResponseWriter, r *http.Request) {
+	userID, err := getUserID(w, r)
+	if err != nil {
+		utils.AbortRequest(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	userUUID, err := uuid.Parse(userID)
+	if err != nil {
+		utils.AbortRequest(w, "Invalid user id", http.StatusBadRequest)
+		return
+	}
+
+	// Suppression planifiée éventuelle
+	data := map[string]interface{}{"scheduled": false}
+	var resolutions map[string]models.TeamResolution
+	request, err := user_deletion_service.GetPending(userUUID)
+	if err == nil {
+		data = deletionData(request)
+		data["scheduled"] = true
+		resolutions = request.TeamResolutions
+	} else if !errors.Is(err, gorm.ErrRecordNotFound) {
+		utils.AbortRequest(w, "Error fetching account deletion", http.StatusInternalServerError)
+		return
+	}
+
+	// Sort prévu des Teams dont l'utilisateur est le seul owner
+	plan, err := user_deletion_service.Plan(userUUID, resolutions)
+	if err != nil {
+		utils.AbortRequest(w, "Error fetching owned teams", http.StatusInternalServerError)
+		return
+	}
+	data["owned_teams"] = plan
+
+	// Réponse JSON
+	utils.RespondJSON(w, data)
+}
+
+func HandleRequestUserDeletion(w http.ResponseWriter, r *http.Request) {
+	userID, err := getUserID(w, r)
+	if err != nil {
+		utils.AbortRequest(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	userUUID, err := uuid.Parse(userID)
+	if err != nil {
+		utils.AbortRequest(w, "Invalid user id", http.StatusBadRequest)
+		return
+	}
+
+	// Corps facultatif : sort des Teams dont l'utilisateur est le seul owner
+	var input struct {
+		Teams map[string]models.TeamResolution `json:"teams"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&input); err != nil && !errors.Is(err, io.EOF) {
+		utils.AbortRequest(w, "body invalid", http.StatusBadRequest)
+		return
+	}
+
+	// Planification de la suppression
+	request, err := user_deletion_service.Request(userUUID, input.Teams)
+	if err != nil {
+		utils.AbortRequest(w, err.Error(), deletionErrorStatus(err))
+		return
+	}
+
+	// Réponse JSON
+	utils.RespondJSON(w, deletionData(request))
+}
+
+func HandleCancelUserDeletion(w http.ResponseWriter, r *http.Request) {
+	userID, err := getUserID(w, r)
+	if err != nil {
+		utils.AbortRequest(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	userUUID, err := uuid.Parse(userID)
+	if err != nil {
+		utils.AbortRequest(w, "Invalid user id", http.StatusBadRequest)
+		return
+	}
+
+	// Annulation de la suppression planifiée
+	if err := user_deletion_service.Cancel(userUUID); err != nil {
+		utils.AbortRequest(w, err.Error(), deletionErrorStatus(err))
+		return
+	}
+
+	// Réponse JSON
+	utils.RespondJSON(w, map[string]interface{}{
+		"success": true,
+	})
+}
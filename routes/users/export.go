@@ -0,0 +1,126 @@
+package users
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+
+	"github.com/google/uuid"
+	"github.com/gorilla/mux"
+	"gorm.io/gorm"
+
+	"gox/database/models"
+	user_export_service "gox/services/users/export"
+	"gox/utils"
+)
+
+func exportData(export models.UserExport) map[string]interface{} {
+	return map[string]interface{}{
+		"id":           export.ID,
+		"status":       export.Status,
+		"size_bytes":   export.SizeBytes,
+		"error":        export.Error,
+		"requested_at": export.RequestedAt,
+		"completed_at": export.CompletedAt,
+		"expires_at":   export.ExpiresAt,
+	}
+}
+
+// ~ /users/{id}/export ~
+func HandleRequestUserExport(w http.ResponseWriter, r *http.Request) {
+	userID, err := getUserID(w, r)
+	if err != nil {
+		utils.AbortRequest(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	userUUID, err := uuid.Parse(userID)
+	if err != nil {
+		utils.AbortRequest(w, "Invalid user id", http.StatusBadRequest)
+		return
+	}
+
+	// Lancement de l'export, le lien de téléchargement est envoyé par email
+	export, err := user_export_service.Request(userUUID)
+	if errors.Is(err, user_export_service.ErrExportInProgress) {
+		utils.AbortRequest(w, fmt.Sprintf("An export is already in progress (%s)", export.ID), http.StatusConflict)
+		return
+	}
+	if err != nil {
+		utils.AbortRequest(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	// Réponse JSON
+	utils.RespondJSON(w, exportData(export))
+}
+
+func HandleGetUserExports(w http.ResponseWriter, r *http.Request) {
+	userID, err := getUserID(w, r)
+	if err != nil {
+		utils.AbortRequest(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	userUUID, err := uuid.Parse(userID)
+	if err != nil {
+		utils.AbortRequest(w, "Invalid user id", http.StatusBadRequest)
+		return
+	}
+
+	// Récupération des exports
+	exports, err := user_export_service.GetAll(userUUID)
+	if err != nil {
+		utils.AbortRequest(w, "Error fetching exports", http.StatusInternalServerError)
+		return
+	}
+
+	// Réponse JSON
+	data := make([]map[string]interface{}, len(exports))
+	for i, export := range exports {
+		data[i] = exportData(export)
+	}
+	utils.RespondJSON(w, data)
+}
+
+// ~ /exports/{export_id}/download ~
+func HandleDownloadUserExport(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+
+	exportUUID, err := uuid.Parse(vars["export_id"])
+	if err != nil {
+		utils.AbortRequest(w, "Export not found", http.StatusNotFound)
+		return
+	}
+
+	// Le token du lien fait office d'authentification
+	body, export, err := user_export_service.Open(exportUUID, r.URL.Query().Get("token"))
+	if errors.Is(err, gorm.ErrRecordNotFound) || errors.Is(err, user_export_service.ErrInvalidToken) {
+		utils.AbortRequest(w, "Export not found", http.StatusNotFound)
+		return
+	}
+	if errors.Is(err, user_export_service.ErrExportExpired) {
+		utils.AbortRequest(w, err.Error(), http.StatusGone)
+		return
+	}
+	if errors.Is(err, user_export_service.ErrExportNotReady) {
+		utils.AbortRequest(w, err.Error(), http.StatusConflict)
+		return
+	}
+	if err != nil {
+		utils.AbortRequest(w, "Error reading export", http.StatusInternalServerError)
+		return
+	}
+	defer body.Close()
+
+	// Envoi de l'archive, jamais mise en cache
+	w.Header().Set("Content-Type", "application/zip")
+	w.Header().Set("Content-Disposition", fmt.Sprintf(`attachment; filename="gox-export-%s.zip"`, export.CompletedAt.Format("2006-01-02")))
+	w.Header().Set("Content-Length", strconv.FormatInt(export.SizeBytes, 10))
+	w.Header().Set("Cache-Control", "no-store")
+	if _, err := io.Copy(w, body); err != nil {
+		utils.ConsoleLogRequest(r, "Error sending export %s: %v", export.ID, err).Error()
+	}
+}
@@ -5,15 +5,21 @@ import (
 	"errors"
 	"gox/database"
 	"gox/database/models"
+	"gox/routes/administration"
+	audit_service "gox/services/audit"
 	auth_utils "gox/services/auth"
+	stats_service "gox/services/stats"
 	team_service "gox/services/teams"
 	user_service "gox/services/users"
+	user_deletion_service "gox/services/users/deletion"
 	user_profile_service "gox/services/users/profile"
 	"gox/utils"
 	"net/http"
+	"net/url"
 
 	"github.com/google/uuid"
 	"github.com/gorilla/mux"
+	"gorm.io/gorm"
 )
 
 // ~ /users ~
@@ -60,6 +66,7 @@ func HandleGetUsers(w http.ResponseWriter, r *http.Request) {
 			"is_accessible": user.IsAccessible,
 		}
 	}
+	utils.RespondJSON(w, data)
 }
 
 // ~ /users/{id} ~
@@ -166,16 +173,50 @@ func HandleDeleteUser(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	// Supprimer l'utilisateur
-	if err := user_service.Delete(userUUID); err != nil {
-		utils.AbortRequest(w, err.Error(), http.StatusInternalServerError)
+	// Un admin peut anonymiser le compte immédiatement
+	if r.URL.Query().Get("immediate") == "true" && auth_utils.IsAuthenticatedUserAdmin(w, r) {
+		// La suppression est planifiée pour maintenant avec son entrée d'audit, puis exécutée : en cas d'échec,
+		// elle reste due et sera reprise
+		err := database.DB.Transaction(func(tx *gorm.DB) error {
+			if _, err := user_deletion_service.ScheduleNow(tx, userUUID); err != nil {
+				return err
+			}
+
+			return administration.Audit(tx, r, audit_service.Event{
+				Action:     "user.deleted",
+				TargetType: audit_service.TargetUser,
+				TargetID:   userUUID.String(),
+				After:      map[string]bool{"immediate": true},
+			})
+		})
+		if errors.Is(err, administration.ErrNotAudited) {
+			utils.AbortRequest(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		if err != nil {
+			utils.AbortRequest(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		if err := user_deletion_service.DeleteNow(userUUID); err != nil {
+			utils.AbortRequest(w, err.Error(), deletionErrorStatus(err))
+			return
+		}
+
+		utils.RespondJSON(w, map[string]interface{}{
+			"success": true,
+		})
+		return
+	}
+
+	// Sinon la suppression est planifiée, à l'issue du délai de rétractation
+	request, err := user_deletion_service.Request(userUUID, nil)
+	if err != nil {
+		utils.AbortRequest(w, err.Error(), deletionErrorStatus(err))
 		return
 	}
 
 	// Réponse JSON
-	utils.RespondJSON(w, map[string]interface{}{
-		"success": true,
-	})
+	utils.RespondJSON(w, deletionData(request))
 }
 
 // ~ /users/{id}/teams ~
@@ -193,8 +234,13 @@ func HandleGetUserTeams(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	// Récupérer les équipes de l'utilisateur
-	teams, err := team_service.GetTeamsByMemberID(userUUID)
+	// Récupérer les équipes de l'utilisateur (les équipes archivées sont masquées par défaut)
+	var teams []models.Team
+	if r.URL.Query().Get("archived") == "true" {
+		teams, err = team_service.GetArchivedTeamsByMemberID(userUUID)
+	} else {
+		teams, err = team_service.GetTeamsByMemberID(userUUID)
+	}
 	if err != nil {
 		utils.AbortRequest(w, err.Error(), http.StatusInternalServerError)
 		return
@@ -209,9 +255,10 @@ func HandleGetUserTeams(w http.ResponseWriter, r *http.Request) {
 	data := make([]map[string]interface{}, len(teams))
 	for i, team := range teams {
 		data[i] = map[string]interface{}{
-			"id":   team.ID.String(),
-			"name": team.Name,
-			"type": team.Type,
+			"id":          team.ID.String(),
+			"name":        team.Name,
+			"type":        team.Type,
+			"archived_at": team.ArchivedAt,
 		}
 	}
 	utils.RespondJSON(w, data)
@@ -219,6 +266,47 @@ func HandleGetUserTeams(w http.ResponseWriter, r *http.Request) {
 
 // ~ /users/{id}/profile ~
 
+func profileErrorStatus(err error) int {
+	if errors.Is(err, user_profile_service.ErrUsernameTaken) || errors.Is(err, user_profile_service.ErrUsernameCooldown) {
+		return http.StatusConflict
+	}
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return http.StatusNotFound
+	}
+	return http.StatusBadRequest
+}
+
+// profileViewer : le propriétaire du profil et les admins voient tous les champs.
+// Un token révoqué ou d'un compte désactivé est traité comme une visite anonyme.
+func profileViewer(r *http.Request, profile models.UserProfile) user_profile_service.Viewer {
+	claims, ok := auth_utils.GetSessionClaims(r)
+	if !ok {
+		return user_profile_service.ViewerAnonymous
+	}
+	if admin, ok := claims["admin"].(bool); ok && admin {
+		return user_profile_service.ViewerSelf
+	}
+	if userID, ok := claims["user"].(string); ok && userID == profile.CustomerID.String() {
+		return user_profile_service.ViewerSelf
+	}
+	return user_profile_service.ViewerAuthenticated
+}
+
+// withProfileStats ajoute les statistiques au profil si l'utilisateur a choisi de les afficher
+func withProfileStats(r *http.Request, data map[string]interface{}, profile models.UserProfile, viewer user_profile_service.Viewer) map[string]interface{} {
+	if !profile.PublicStatsDisplay && viewer != user_profile_service.ViewerSelf {
+		return data
+	}
+
+	stats, err := stats_service.GetUser(profile.CustomerID)
+	if err != nil {
+		utils.ConsoleLogRequest(r, "Error fetching stats of user %s: %v", profile.CustomerID, err).Error()
+		return data
+	}
+	data["stats"] = stats_service.UserView(stats, profile.Customer)
+	return data
+}
+
 func HandleCreateUserProfile(w http.ResponseWriter, r *http.Request) {
 	userID, err := getUserID(w, r)
 	if err != nil {
@@ -234,9 +322,11 @@ func HandleCreateUserProfile(w http.ResponseWriter, r *http.Request) {
 
 	// Lire le corps de la requête, pour obtenir les données du profil
 	var profileData struct {
-		Username string `json:"username"`
-		// AvatarURL          string `json:"avatar_url"`
-		PublicStatsDisplay bool `json:"public_stats_display"`
+		Username           string                              `json:"username"`
+		DisplayName        string                              `json:"display_name"`
+		Bio                string                              `json:"bio"`
+		Visibility         map[string]models.ProfileVisibility `json:"visibility"`
+		PublicStatsDisplay bool                                `json:"public_stats_display"`
 	}
 	if err := json.NewDecoder(r.Body).Decode(&profileData); err != nil {
 		utils.AbortRequest(w, "body invalid", http.StatusBadRequest)
@@ -244,21 +334,36 @@ func HandleCreateUserProfile(w http.ResponseWriter, r *http.Request) {
 	}
 
 	userProfile := models.UserProfile{
-		Username: profileData.Username,
-		// AvatarURL:          profileData.AvatarURL,
+		Username:           profileData.Username,
+		DisplayName:        profileData.DisplayName,
+		Bio:                profileData.Bio,
+		Visibility:         profileData.Visibility,
 		PublicStatsDisplay: profileData.PublicStatsDisplay,
 	}
 
+	// Un seul profil par utilisateur
+	if _, err := user_profile_service.Get(userUUID); err == nil {
+		utils.AbortRequest(w, "User profile already exists", http.StatusConflict)
+		return
+	}
+
 	// Création du profil de l'utilisateur
 	if err := user_profile_service.Create(userUUID, userProfile); err != nil {
-		utils.AbortRequest(w, err.Error(), http.StatusBadRequest)
+		utils.AbortRequest(w, err.Error(), profileErrorStatus(err))
+		return
+	}
+
+	profile, err := user_profile_service.Get(userUUID)
+	if err != nil {
+		utils.AbortRequest(w, "User profile not found", http.StatusNotFound)
 		return
 	}
 
 	// Réponse JSON
 	utils.RespondJSON(w, map[string]interface{}{
 		"success":    true,
-		"profile_id": userProfile.ID,
+		"profile_id": profile.ID,
+		"username":   profile.Username,
 	})
 }
 
@@ -276,38 +381,29 @@ func HandleGetUserProfile(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	_, err = user_service.Get(userUUID)
+	user, err := user_service.Get(userUUID)
 	if err != nil {
 		utils.AbortRequest(w, "User not found", http.StatusNotFound)
 		return
 	}
 
 	// Récupérer le profil de l'utilisateur
-	var user models.UserProfile
-	if err := database.DB.Where("customer_id = ?", userID).First(&user).Error; err != nil {
+	profile, err := user_profile_service.Get(userUUID)
+	if err != nil {
 		utils.AbortRequest(w, "User profile not found", http.StatusNotFound)
 		return
 	}
+	profile.Customer = user
 
 	// Vérifier si le profil est accessible
-	if !user.IsAccessible {
+	viewer := profileViewer(r, profile)
+	if (!profile.IsAccessible || !user.IsAccessible) && viewer != user_profile_service.ViewerSelf {
 		utils.AbortRequest(w, "User profile is not accessible", http.StatusForbidden)
 		return
 	}
 
-	// Réponse JSON
-	data := struct {
-		CustomerID string `json:"customer_id"`
-		Username   string `json:"username"`
-		// AvatarURL          string `json:"avatar_url"`
-		PublicStatsDisplay bool `json:"public_stats_display"`
-	}{
-		CustomerID: user.CustomerID.String(),
-		Username:   user.Username,
-		// AvatarURL:          user.AvatarURL,
-		PublicStatsDisplay: user.PublicStatsDisplay,
-	}
-	utils.RespondJSON(w, data)
+	// Réponse JSON, limitée aux champs visibles
+	utils.RespondJSON(w, withProfileStats(r, user_profile_service.PublicView(profile, viewer), profile, viewer))
 }
 
 func HandleUpdateUserProfile(w http.ResponseWriter, r *http.Request) {
@@ -317,31 +413,64 @@ func HandleUpdateUserProfile(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	// Récupérer le profil de l'utilisateur
-	var user models.UserProfile
-	if err := database.DB.Where("user_id = ?", userID).First(&user).Error; err != nil {
-		utils.AbortRequest(w, "User profile not found", http.StatusNotFound)
+	userUUID, err := uuid.Parse(userID)
+	if err != nil {
+		utils.AbortRequest(w, "Invalid user id", http.StatusBadRequest)
 		return
 	}
 
+	// Seuls les champs présents dans le corps sont modifiés
 	var input struct {
-		Username string `json:"username"`
-		// AvatarURL          string `json:"avatar_url"`
-		PublicStatsDisplay bool `json:"public_stats_display"`
+		Username           *string                             `json:"username"`
+		DisplayName        *string                             `json:"display_name"`
+		Bio                *string                             `json:"bio"`
+		Visibility         map[string]models.ProfileVisibility `json:"visibility"`
+		PublicStatsDisplay *bool                               `json:"public_stats_display"`
 	}
 	if err := json.NewDecoder(r.Body).Decode(&input); err != nil {
 		utils.AbortRequest(w, "body invalid", http.StatusBadRequest)
 		return
 	}
 
+	// Récupérer le profil de l'utilisateur
+	profile, err := user_profile_service.Get(userUUID)
+	if err != nil {
+		utils.AbortRequest(w, "User profile not found", http.StatusNotFound)
+		return
+	}
+
+	// Changement de username : délai entre deux changements et redirection depuis l'ancien
+	if input.Username != nil {
+		if err := user_profile_service.ChangeUsername(userUUID, *input.Username); err != nil {
+			utils.AbortRequest(w, err.Error(), profileErrorStatus(err))
+			return
+		}
+	}
+
 	// Mettre à jour le profil de l'utilisateur
-	profile := models.UserProfile{
-		Username: input.Username,
-		// AvatarURL:          input.AvatarURL,
-		PublicStatsDisplay: input.PublicStatsDisplay,
+	updates := map[string]interface{}{}
+	if input.DisplayName != nil {
+		updates["display_name"] = *input.DisplayName
 	}
-	if err := user_profile_service.Update(user.ID, profile); err != nil {
-		utils.AbortRequest(w, err.Error(), http.StatusBadRequest)
+	if input.Bio != nil {
+		updates["bio"] = *input.Bio
+	}
+	if input.PublicStatsDisplay != nil {
+		updates["public_stats_display"] = *input.PublicStatsDisplay
+	}
+	if input.Visibility != nil {
+		// Les réglages envoyés complètent les réglages existants
+		visibility := map[string]models.ProfileVisibility{}
+		for field, value := range profile.Visibility {
+			visibility[field] = value
+		}
+		for field, value := range input.Visibility {
+			visibility[field] = value
+		}
+		updates["visibility"] = visibility
+	}
+	if err := user_profile_service.Update(userUUID, updates); err != nil {
+		utils.AbortRequest(w, err.Error(), profileErrorStatus(err))
 		return
 	}
 
@@ -375,3 +504,68 @@ func HandleDeleteUserProfile(w http.ResponseWriter, r *http.Request) {
 		"success": true,
 	})
 }
+
+// ~ /users/{id}/profile/usernames ~
+func HandleGetUsernameHistory(w http.ResponseWriter, r *http.Request) {
+	userID, err := getUserID(w, r)
+	if err != nil {
+		utils.AbortRequest(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	userUUID, err := uuid.Parse(userID)
+	if err != nil {
+		utils.AbortRequest(w, "Invalid user id", http.StatusBadRequest)
+		return
+	}
+
+	// Récupération de l'historique des usernames
+	history, err := user_profile_service.GetUsernameHistory(userUUID)
+	if err != nil {
+		utils.AbortRequest(w, "Error fetching username history", http.StatusInternalServerError)
+		return
+	}
+
+	// Réponse JSON
+	redirectPeriod := user_profile_service.RedirectPeriod()
+	data := make([]map[string]interface{}, len(history))
+	for i, entry := range history {
+		data[i] = map[string]interface{}{
+			"username":       entry.Username,
+			"changed_at":     entry.ChangedAt,
+			"redirect_until": entry.ChangedAt.Add(redirectPeriod),
+		}
+	}
+	utils.RespondJSON(w, data)
+}
+
+// ~ /profiles/{username} ~
+func HandleGetPublicProfile(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+
+	// Récupération du profil (ou redirection depuis un ancien username)
+	profile, redirect, err := user_profile_service.GetByUsername(vars["username"])
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			utils.AbortRequest(w, "User profile not found", http.StatusNotFound)
+			return
+		}
+		utils.AbortRequest(w, "Error fetching user profile", http.StatusInternalServerError)
+		return
+	}
+
+	// Un profil inaccessible ou désactivé n'existe pas pour les autres
+	viewer := profileViewer(r, profile)
+	if (!profile.IsAccessible || !profile.Customer.IsAccessible) && viewer != user_profile_service.ViewerSelf {
+		utils.AbortRequest(w, "User profile not found", http.StatusNotFound)
+		return
+	}
+
+	if redirect {
+		http.Redirect(w, r, "/profiles/"+url.PathEscape(profile.Username), http.StatusMovedPermanently)
+		return
+	}
+
+	// Réponse JSON, limitée aux champs visibles
+	utils.RespondJSON(w, withProfileStats(r, user_profile_service.PublicView(profile, viewer), profile, viewer))
+}
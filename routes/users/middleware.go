@@ -47,8 +47,8 @@ func UserRouteMiddleware(next http.Handler) http.Handler {
 			return
 		}
 
-		// ~ Is it just accessing the user public profile?
-		if strings.HasSuffix(r.URL.Path, "/profile") {
+		// ~ Is it just reading the user public profile? (visible fields are filtered by the handler)
+		if (strings.HasSuffix(r.URL.Path, "/profile") || strings.HasSuffix(r.URL.Path, "/profile/avatar")) && r.Method == http.MethodGet {
 			// ~ OK. Serve.
 			next.ServeHTTP(w, r)
 			return
@@ -72,6 +72,15 @@ func UserRouteMiddleware(next http.Handler) http.Handler {
 		} else if strings.HasSuffix(r.URL.Path, "/subscriptions") {
 			utils.AbortRequest(w, "User is not allowed to access this user's subscriptions", http.StatusForbidden)
 			return
+		} else if strings.HasSuffix(r.URL.Path, "/deletion") || (r.Method == http.MethodDelete && !strings.Contains(r.URL.Path, "/profile")) {
+			utils.AbortRequest(w, "User is not allowed to delete this user", http.StatusForbidden)
+			return
+		} else if strings.HasSuffix(r.URL.Path, "/export") {
+			utils.AbortRequest(w, "User is not allowed to export this user's data", http.StatusForbidden)
+			return
+		} else if strings.Contains(r.URL.Path, "/profile") {
+			utils.AbortRequest(w, "User is not allowed to modify this user's profile", http.StatusForbidden)
+			return
 		}
 
 		urlUserUUID, err := uuid.Parse(urlUserID)
@@ -0,0 +1,268 @@
+package admin_logs_service
+
+import (
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"gox/database"
+	"gox/database/models"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+const (
+	DefaultLimit = 100
+	MaxLimit     = 1000
+)
+
+var ErrInvalidCursor = errors.New("invalid cursor")
+
+// StatusRange : intervalle de statuts HTTP, bornes incluses
+type StatusRange struct {
+	Min int
+	Max int
+}
+
+// Query : tous les filtres se combinent (ET) ; à l'intérieur d'un filtre multiple, les valeurs s'additionnent (OU)
+type Query struct {
+	UserID         *uuid.UUID
+	RequestID      string
+	ClientIP       string
+	Route          string
+	Domain         string
+	Methods        []string
+	Endpoint       string
+	EndpointPrefix string
+	Statuses       []StatusRange
+	Since          *time.Time
+	Until          *time.Time
+	Ascending      bool
+	Limit          int
+	Cursor         string
+	IncludeContent bool
+}
+
+// Entry : log retourné par Query. Le corps n'est décodé que s'il est demandé.
+type Entry struct {
+	ID            uint              `json:"id"`
+	RequestID     string            `json:"request_id"`
+	UserID        *uuid.UUID        `json:"user_id"`
+	Domain        string            `json:"domain"`
+	Endpoint      string            `json:"endpoint"`
+	Route         string            `json:"route"`
+	Query         string            `json:"query,omitempty"`
+	Method        string            `json:"method"`
+	Status        int               `json:"status"`
+	Timestamp     time.Time         `json:"timestamp"`
+	DurationMs    float64           `json:"duration_ms"`
+	ResponseBytes int64             `json:"response_bytes"`
+	ClientIP      string            `json:"client_ip"`
+	UserAgent     string            `json:"user_agent"`
+	Content       *string           `json:"content,omitempty"`
+	Headers       map[string]string `json:"headers,omitempty"`
+}
+
+type Page struct {
+	Logs       []Entry `json:"logs"`
+	NextCursor string  `json:"next_cursor,omitempty"`
+	HasMore    bool    `json:"has_more"`
+}
+
+// ParseStatuses lit une liste séparée par des virgules : "404", "4xx", "500-599"
+func ParseStatuses(value string) ([]StatusRange, error) {
+	var ranges []StatusRange
+	for _, part := range strings.Split(value, ",") {
+		part = strings.ToLower(strings.TrimSpace(part))
+		if part == "" {
+			continue
+		}
+
+		if len(part) == 3 && strings.HasSuffix(part, "xx") {
+			class, err := strconv.Atoi(part[:1])
+			if err != nil || class < 1 || class > 5 {
+				return nil, fmt.Errorf("invalid status class: %s", part)
+			}
+			ranges = append(ranges, StatusRange{Min: class * 100, Max: class*100 + 99})
+			continue
+		}
+
+		if min, max, ok := strings.Cut(part, "-"); ok {
+			lo, err1 := strconv.Atoi(min)
+			hi, err2 := strconv.Atoi(max)
+			if err1 != nil || err2 != nil || lo > hi {
+				return nil, fmt.Errorf("invalid status range: %s", part)
+			}
+			ranges = append(ranges, StatusRange{Min: lo, Max: hi})
+			continue
+		}
+
+		status, err := strconv.Atoi(part)
+		if err != nil {
+			return nil, fmt.Errorf("invalid status: %s", part)
+		}
+		ranges = append(ranges, StatusRange{Min: status, Max: status})
+	}
+	return ranges, nil
+}
+
+// Le curseur est l'ID du dernier log de la page : la pagination par clé reste rapide quelle que soit
+// la profondeur, contrairement à OFFSET. Les IDs croissent avec le temps, trier par ID revient à
+// trier par date.
+func encodeCursor(id uint) string {
+	return base64.RawURLEncoding.EncodeToString([]byte(strconv.FormatUint(uint64(id), 10)))
+}
+
+func decodeCursor(cursor string) (uint, error) {
+	raw, err := base64.RawURLEncoding.DecodeString(cursor)
+	if err != nil {
+		return 0, ErrInvalidCursor
+	}
+	id, err := strconv.ParseUint(string(raw), 10, 64)
+	if err != nil {
+		return 0, ErrInvalidCursor
+	}
+	return uint(id), nil
+}
+
+// escapeLike échappe les jokers de LIKE dans un préfixe saisi par l'utilisateur
+func escapeLike(value string) string {
+	return strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`).Replace(value)
+}
+
+func (q Query) apply(tx *gorm.DB) *gorm.DB {
+	if q.UserID != nil {
+		tx = tx.Where("user_id = ?", *q.UserID)
+	}
+	if q.RequestID != "" {
+		tx = tx.Where("request_id = ?", q.RequestID)
+	}
+	if q.ClientIP != "" {
+		tx = tx.Where("client_ip = ?", q.ClientIP)
+	}
+	if q.Route != "" {
+		tx = tx.Where("route = ?", q.Route)
+	}
+	if q.Domain != "" {
+		tx = tx.Where("domain = ?", q.Domain)
+	}
+	if len(q.Methods) > 0 {
+		tx = tx.Where("method IN ?", q.Methods)
+	}
+	if q.Endpoint != "" {
+		tx = tx.Where("endpoint = ?", q.Endpoint)
+	}
+	if q.EndpointPrefix != "" {
+		tx = tx.Where("endpoint LIKE ?", escapeLike(q.EndpointPrefix)+"%")
+	}
+	if len(q.Statuses) > 0 {
+		conditions := database.DB.Session(&gorm.Session{NewDB: true})
+		for i, r := range q.Statuses {
+			if i == 0 {
+				conditions = conditions.Where("status BETWEEN ? AND ?", r.Min, r.Max)
+			} else {
+				conditions = conditions.Or("status BETWEEN ? AND ?", r.Min, r.Max)
+			}
+		}
+		tx = tx.Where(conditions)
+	}
+	if q.Since != nil {
+		tx = tx.Where("timestamp >= ?", *q.Since)
+	}
+	if q.Until != nil {
+		tx = tx.Where("timestamp < ?", *q.Until)
+	}
+	return tx
+}
+
+// Search retourne une page de logs correspondant aux filtres
+func Search(q Query) (Page, error) {
+	if q.Limit <= 0 {
+		q.Limit = DefaultLimit
+	}
+	if q.Limit > MaxLimit {
+		q.Limit = MaxLimit
+	}
+
+	// Le corps (bytea) n'est lu que s'il est demandé
+	columns := []string{"id", "request_id", "user_id", "domain", "endpoint", "route", "query", "method", "status", "timestamp",
+		"duration", "response_bytes", "client_ip", "user_agent"}
+	if q.IncludeContent {
+		columns = append(columns, "content", "headers")
+	}
+	tx := q.apply(database.DB.Model(&models.RequestLog{}).Select(columns))
+
+	order := "id DESC"
+	if q.Ascending {
+		order = "id ASC"
+	}
+	if q.Cursor != "" {
+		id, err := decodeCursor(q.Cursor)
+		if err != nil {
+			return Page{}, err
+		}
+		if q.Ascending {
+			tx = tx.Where("id > ?", id)
+		} else {
+			tx = tx.Where("id < ?", id)
+		}
+	}
+
+	// Une ligne de plus pour savoir s'il existe une page suivante
+	var logs []models.RequestLog
+	if err := tx.Order(order).Limit(q.Limit + 1).Find(&logs).Error; err != nil {
+		return Page{}, err
+	}
+
+	page := Page{Logs: make([]Entry, 0, len(logs))}
+	if len(logs) > q.Limit {
+		logs = logs[:q.Limit]
+		page.HasMore = true
+		page.NextCursor = encodeCursor(logs[len(logs)-1].ID)
+	}
+	for _, log := range logs {
+		page.Logs = append(page.Logs, toEntry(log, q.IncludeContent))
+	}
+
+	return page, nil
+}
+
+// GetByID retourne un log, avec son corps décodé
+func GetByID(id uint) (Entry, error) {
+	var log models.RequestLog
+	if err := database.DB.Where("id = ?", id).First(&log).Error; err != nil {
+		return Entry{}, err
+	}
+	return toEntry(log, true), nil
+}
+
+func toEntry(log models.RequestLog, withContent bool) Entry {
+	entry := Entry{
+		ID:            log.ID,
+		RequestID:     log.RequestID,
+		UserID:        log.UserID,
+		Domain:        log.Domain,
+		Endpoint:      log.Endpoint,
+		Route:         log.Route,
+		Query:         log.Query,
+		Method:        log.Method,
+		Status:        log.Status,
+		Timestamp:     log.Timestamp,
+		DurationMs:    float64(log.Duration) / float64(time.Millisecond),
+		ResponseBytes: log.ResponseBytes,
+		ClientIP:      log.ClientIP,
+		UserAgent:     log.UserAgent,
+	}
+	if withContent {
+		content := log.Content
+		if decoded, err := base64.StdEncoding.DecodeString(log.Content); err == nil {
+			content = string(decoded)
+		}
+		entry.Content = &content
+		entry.Headers = log.Headers
+	}
+	return entry
+}
@@ -3,6 +3,8 @@ package admin_subscription_service
 import (
 	"gox/database"
 	"gox/database/models"
+
+	"gorm.io/gorm"
 )
 
 func GetAll() ([]models.Subscription, error) {
@@ -11,7 +13,7 @@ func GetAll() ([]models.Subscription, error) {
 	return subs, err
 }
 
-func Create(name, description string, price int, currency string, validForInDays int) (models.Subscription, error) {
+func Create(db *gorm.DB, name, description string, price int, currency string, validForInDays int) (models.Subscription, error) {
 	sub := models.Subscription{
 		Name:           name,
 		Description:    description,
@@ -19,12 +21,12 @@ func Create(name, description string, price int, currency string, validForInDays
 		Currency:       currency,
 		ValidForInDays: validForInDays,
 	}
-	err := database.DB.Create(&sub).Error
+	err := db.Create(&sub).Error
 	return sub, err
 }
 
-func Update(subscription models.Subscription) (models.Subscription, error) {
-	err := database.DB.Save(&subscription).Error
+func Update(db *gorm.DB, subscription models.Subscription) (models.Subscription, error) {
+	err := db.Save(&subscription).Error
 	return subscription, err
 }
 
@@ -32,6 +34,6 @@ func Delete(subscription models.Subscription) error {
 	return database.DB.Delete(&subscription).Error
 }
 
-func DeleteByID(id string) error {
-	return database.DB.Delete(&models.Subscription{}, id).Error
+func DeleteByID(db *gorm.DB, id string) error {
+	return db.Delete(&models.Subscription{}, id).Error
 }
@@ -0,0 +1,572 @@
+package admin_team_service
+
+import (
+	"errors"
+	"fmt"
+	"gox/database"
+	"gox/database/models"
+	team_service "gox/services/teams"
+	team_activity_service "gox/services/teams/activity"
+	team_member_service "gox/services/teams/members"
+	team_ownership_service "gox/services/teams/ownership"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+var (
+	ErrArchived     = errors.New("team is archived")
+	ErrNotMember    = errors.New("user is not an active member of this team")
+	ErrAlreadyOwner = errors.New("user is already the only owner of this team")
+	ErrInvalidMerge = errors.New("teams cannot be merged: the target must be another company team, outside the source hierarchy")
+)
+
+// Pagination : page commence à 1, perPage est borné
+const (
+	DefaultPerPage = 50
+	MaxPerPage     = 200
+)
+
+// Colonnes de tri autorisées
+var sortColumns = map[string]string{
+	"name":    "teams.name",
+	"members": "members",
+}
+
+// Query : Search porte sur le nom (contient) ou l'ID exact de la Team
+type Query struct {
+	Search     string
+	Type       models.TeamType
+	Suspended  *bool
+	Archived   *bool
+	Sort       string
+	Descending bool
+	Page       int
+	PerPage    int
+}
+
+type Owner struct {
+	ID    uuid.UUID `json:"id"`
+	Email string    `json:"email"`
+}
+
+// SubscriptionStatus : abonnement en cours du owner, dont dépendent les avantages de la Team
+type SubscriptionStatus struct {
+	Plan  string    `json:"plan"`
+	EndAt time.Time `json:"end_at"`
+}
+
+type Summary struct {
+	ID           uuid.UUID           `json:"id"`
+	Name         string              `json:"name"`
+	Type         models.TeamType     `json:"type"`
+	ParentID     *uuid.UUID          `json:"parent_id"`
+	IsAccessible bool                `json:"is_accessible"`
+	ArchivedAt   *time.Time          `json:"archived_at"`
+	Members      int64               `json:"members"`
+	Owner        *Owner              `json:"owner"`
+	Subscription *SubscriptionStatus `json:"subscription"`
+}
+
+// Ligne lue par summaries, aplatie
+type summaryRow struct {
+	ID               uuid.UUID
+	Name             string
+	Type             models.TeamType
+	ParentID         *uuid.UUID
+	IsAccessible     bool
+	ArchivedAt       *time.Time
+	Members          int64
+	OwnerID          *uuid.UUID
+	OwnerEmail       *string
+	SubscriptionPlan *string
+	SubscriptionEnd  *time.Time
+}
+
+func (row summaryRow) toSummary() Summary {
+	summary := Summary{
+		ID:           row.ID,
+		Name:         row.Name,
+		Type:         row.Type,
+		ParentID:     row.ParentID,
+		IsAccessible: row.IsAccessible,
+		ArchivedAt:   row.ArchivedAt,
+		Members:      row.Members,
+	}
+	if row.OwnerID != nil {
+		summary.Owner = &Owner{ID: *row.OwnerID}
+		if row.OwnerEmail != nil {
+			summary.Owner.Email = *row.OwnerEmail
+		}
+	}
+	if row.SubscriptionPlan != nil && row.SubscriptionEnd != nil {
+		summary.Subscription = &SubscriptionStatus{Plan: *row.SubscriptionPlan, EndAt: *row.SubscriptionEnd}
+	}
+	return summary
+}
+
+// summaries : Teams avec le nombre de membres actuels, le premier owner et son abonnement en cours
+func summaries() *gorm.DB {
+	return database.DB.Table("teams").
+		Select(`teams.id, teams.name, teams.type, teams.parent_id, teams.is_accessible, teams.archived_at,
+			(SELECT COUNT(*) FROM team_members tm WHERE tm.team_id = teams.id AND tm.`+team_member_service.CurrentCondition+`) AS members,
+			o.member_id AS owner_id, o.email AS owner_email, sub.plan AS subscription_plan, sub.end_at AS subscription_end`).
+		Joins(`LEFT JOIN LATERAL (
+				SELECT tm.member_id, u.email FROM team_members tm JOIN users u ON u.id = tm.member_id
+				WHERE tm.team_id = teams.id AND tm.role = ? AND tm.`+team_member_service.CurrentCondition+`
+				ORDER BY tm.joined_at LIMIT 1
+			) o ON true`, models.TeamMemberRoleOwner).
+		Joins(`LEFT JOIN LATERAL (
+				SELECT s.name AS plan, us.start_at + make_interval(days => s.valid_for_in_days) AS end_at
+				FROM user_subscriptions us JOIN subscriptions s ON s.id = us.subscription_id
+				WHERE us.customer_id = o.member_id AND us.is_accessible
+				AND us.start_at <= now() AND us.start_at + make_interval(days => s.valid_for_in_days) > now()
+				ORDER BY us.start_at DESC LIMIT 1
+			) sub ON true`)
+}
+
+// escapeLike échappe les jokers de LIKE dans un texte saisi par l'admin
+func escapeLike(value string) string {
+	return strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`).Replace(value)
+}
+
+// Search retourne une page de Teams et le nombre total de Teams filtrées
+func Search(q Query) ([]Summary, int64, error) {
+	if q.Page < 1 {
+		q.Page = 1
+	}
+	if q.PerPage < 1 {
+		q.PerPage = DefaultPerPage
+	}
+	if q.PerPage > MaxPerPage {
+		q.PerPage = MaxPerPage
+	}
+
+	filter := func(query *gorm.DB) *gorm.DB {
+		if search := strings.TrimSpace(q.Search); search != "" {
+			if teamID, err := uuid.Parse(search); err == nil {
+				query = query.Where("teams.id = ?", teamID)
+			} else {
+				query = query.Where("teams.name ILIKE ?", "%"+escapeLike(search)+"%")
+			}
+		}
+		if q.Type != "" {
+			query = query.Where("teams.type = ?", q.Type)
+		}
+		if q.Suspended != nil {
+			query = query.Where("teams.is_accessible = ?", !*q.Suspended)
+		}
+		if q.Archived != nil {
+			if *q.Archived {
+				query = query.Where("teams.archived_at IS NOT NULL")
+			} else {
+				query = query.Where("teams.archived_at IS NULL")
+			}
+		}
+		return query
+	}
+
+	var total int64
+	if err := filter(database.DB.Model(&models.Team{})).Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+
+	column, ok := sortColumns[q.Sort]
+	if !ok {
+		column = sortColumns["name"]
+	}
+	direction := " ASC"
+	if q.Descending {
+		direction = " DESC"
+	}
+
+	var rows []summaryRow
+	result := filter(summaries()).
+		Order(column + direction + ", teams.id").
+		Offset((q.Page - 1) * q.PerPage).
+		Limit(q.PerPage).
+		Scan(&rows)
+
+	// Vérification des erreurs GORM
+	if result.Error != nil {
+		return nil, 0, result.Error
+	}
+
+	teams := make([]Summary, len(rows))
+	for i, row := range rows {
+		teams[i] = row.toSummary()
+	}
+	return teams, total, nil
+}
+
+// ~ Détail d'une Team ~
+
+type Member struct {
+	MemberID     uuid.UUID             `json:"member_id"`
+	Email        string                `json:"email"`
+	Role         models.TeamMemberRole `json:"role"`
+	CustomRoleID *uuid.UUID            `json:"custom_role_id"`
+	IsActive     bool                  `json:"is_active"`
+	IsAccessible bool                  `json:"is_accessible"`
+	JoinedAt     time.Time             `json:"joined_at"`
+}
+
+type Detail struct {
+	Summary
+	MemberList       []Member                      `json:"member_list"`
+	Children         []Summary                     `json:"children"`
+	PendingOwnership *models.TeamOwnershipTransfer `json:"pending_ownership_transfer"`
+}
+
+// Get retourne la Team avec ses membres actuels, ses sous-teams et l'éventuel transfert de propriété en attente
+func Get(teamID uuid.UUID) (Detail, error) {
+	var row summaryRow
+	result := summaries().Where("teams.id = ?", teamID).Limit(1).Scan(&row)
+	if result.Error != nil {
+		return Detail{}, result.Error
+	}
+	if result.RowsAffected == 0 {
+		return Detail{}, gorm.ErrRecordNotFound
+	}
+
+	detail := Detail{Summary: row.toSummary(), MemberList: []Member{}, Children: []Summary{}}
+
+	// Membres actuels
+	var members []models.TeamMember
+	if err := database.DB.Preload("Member").Where("team_id = ?", teamID).Where(team_member_service.CurrentCondition).Order("joined_at").Find(&members).Error; err != nil {
+		return Detail{}, err
+	}
+	for _, member := range members {
+		detail.MemberList = append(detail.MemberList, Member{
+			MemberID:     member.MemberID,
+			Email:        member.Member.Email,
+			Role:         member.Role,
+			CustomRoleID: member.CustomRoleID,
+			IsActive:     member.IsActive,
+			IsAccessible: member.IsAccessible,
+			JoinedAt:     member.JoinedAt,
+		})
+	}
+
+	// Sous-teams directes
+	var children []summaryRow
+	if err := summaries().Where("teams.parent_id = ?", teamID).Order("teams.name").Scan(&children).Error; err != nil {
+		return Detail{}, err
+	}
+	for _, child := range children {
+		detail.Children = append(detail.Children, child.toSummary())
+	}
+
+	// Transfert en attente
+	if transfer, err := team_ownership_service.GetPending(teamID); err == nil {
+		detail.PendingOwnership = &transfer
+	}
+
+	return detail, nil
+}
+
+// ~ Actions d'administration ~
+
+// SetAccessible suspend (false) ou rétablit (true) la Team. Une Team suspendue, ainsi que ses sous-teams,
+// reste consultable mais ne peut plus être modifiée par ses membres, ni recevoir de nouveaux membres
+// (invitations, domaines vérifiés, demandes d'adhésion, SCIM).
+func SetAccessible(db *gorm.DB, teamID uuid.UUID, accessible bool, adminID uuid.UUID) (bool, error) {
+	team, err := team_service.Get(teamID)
+	if err != nil {
+		return false, err
+	}
+	if team.IsAccessible == accessible {
+		return team.IsAccessible, nil
+	}
+
+	// Mise à jour du Team
+	result := db.Model(&models.Team{}).Where("id = ?", teamID).Update("is_accessible", accessible)
+
+	// Vérification des erreurs GORM
+	if result.Error != nil {
+		return false, result.Error
+	}
+
+	action := "team.suspended"
+	if accessible {
+		action = "team.unsuspended"
+	}
+	team_activity_service.Record(db, teamID, adminID, action, team_activity_service.TargetTeam, teamID.String(), team_activity_service.Changes{
+		"is_accessible": team_activity_service.Change(team.IsAccessible, accessible),
+	})
+	return team.IsAccessible, nil
+}
+
+// ForceTransferOwnership fait du membre le seul owner de la Team, sans son acceptation : les autres owners
+// sont rétrogradés admin et les transferts en attente annulés. Retourne les anciens owners.
+func ForceTransferOwnership(db *gorm.DB, teamID, toMemberID, adminID uuid.UUID) ([]uuid.UUID, error) {
+	team, err := team_service.Get(teamID)
+	if err != nil {
+		return nil, err
+	}
+	if team.Type == models.TeamTypePersonal {
+		return nil, team_service.ErrPersonalTeam
+	}
+	if team.IsArchived() {
+		return nil, ErrArchived
+	}
+
+	var previousOwners []uuid.UUID
+	err = db.Transaction(func(tx *gorm.DB) error {
+		// La cible doit être un membre actif de la Team
+		var to models.TeamMember
+		if err := tx.Where("team_id = ? AND member_id = ?", teamID, toMemberID).Where(team_member_service.ActiveCondition).First(&to).Error; err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return ErrNotMember
+			}
+			return err
+		}
+
+		if err := tx.Model(&models.TeamMember{}).
+			Where("team_id = ? AND role = ? AND member_id <> ?", teamID, models.TeamMemberRoleOwner, toMemberID).
+			Where(team_member_service.CurrentCondition).
+			Pluck("member_id", &previousOwners).Error; err != nil {
+			return err
+		}
+		if to.Role == models.TeamMemberRoleOwner && len(previousOwners) == 0 {
+			return ErrAlreadyOwner
+		}
+
+		// Les transferts en attente n'ont plus lieu d'être
+		if err := tx.Model(&models.TeamOwnershipTransfer{}).
+			Where("team_id = ? AND status = ?", teamID, models.OwnershipTransferStatusPending).
+			Updates(map[string]interface{}{
+				"status":       models.OwnershipTransferStatusCancelled,
+				"responded_at": time.Now(),
+			}).Error; err != nil {
+			return err
+		}
+
+		// Promotion de la cible, puis rétrogradation des autres owners
+		if err := tx.Model(&models.TeamMember{}).Where("id = ?", to.ID).Updates(map[string]interface{}{
+			"role":            models.TeamMemberRoleOwner,
+			"custom_role_id":  nil,
+			"role_changed_at": time.Now(),
+		}).Error; err != nil {
+			return err
+		}
+
+		if len(previousOwners) > 0 {
+			if err := tx.Model(&models.TeamMember{}).
+				Where("team_id = ? AND role = ? AND member_id IN ?", teamID, models.TeamMemberRoleOwner, previousOwners).
+				Where(team_member_service.CurrentCondition).
+				Updates(map[string]interface{}{
+					"role":            models.TeamMemberRoleAdmin,
+					"role_changed_at": time.Now(),
+				}).Error; err != nil {
+				return err
+			}
+		}
+
+		team_activity_service.Record(tx, teamID, adminID, "ownership.forced", team_activity_service.TargetTeam, teamID.String(), team_activity_service.Changes{
+			"owners": team_activity_service.Change(previousOwners, []uuid.UUID{toMemberID}),
+		})
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return previousOwners, nil
+}
+
+// MergeResult : bilan d'une fusion
+type MergeResult struct {
+	MovedMembers      int `json:"moved_members"`
+	SkippedMembers    int `json:"skipped_members"`
+	MovedChildren     int `json:"moved_children"`
+	RevokedDomains    int `json:"revoked_domains"`
+	RevokedSCIMTokens int `json:"revoked_scim_tokens"`
+	RemovedRoles      int `json:"removed_roles"`
+}
+
+// Merge rattache le contenu de la Team source à la Team cible, puis archive la source :
+// - les membres actuels de la source rejoignent la cible (sauf s'ils y sont déjà), les owners en tant
+// qu'admin et les rôles personnalisés, propres à la source, en tant que spectator ;
+// - les sous-teams de la source sont rattachées à la cible ;
+// - les invitations et transferts en attente de la source sont annulés, comme lors d'un archivage ;
+// - les accès de la source qui feraient encore entrer des membres sont retirés : domaines (et demandes
+// d'adhésion en attente), jetons SCIM et rôles personnalisés.
+// Une cible suspendue ne peut pas recevoir de membres, et les sous-teams rattachées à la cible ne doivent pas
+// dépasser la profondeur maximale de la hiérarchie (team_service.ErrHierarchyTooDeep).
+func Merge(db *gorm.DB, sourceID, targetID, adminID uuid.UUID) (MergeResult, error) {
+	if sourceID == targetID {
+		return MergeResult{}, ErrInvalidMerge
+	}
+
+	source, err := team_service.Get(sourceID)
+	if err != nil {
+		return MergeResult{}, err
+	}
+	target, err := team_service.Get(targetID)
+	if err != nil {
+		return MergeResult{}, err
+	}
+	if source.Type == models.TeamTypePersonal || target.Type == models.TeamTypePersonal {
+		return MergeResult{}, team_service.ErrPersonalTeam
+	}
+	if source.IsArchived() || target.IsArchived() {
+		return MergeResult{}, ErrArchived
+	}
+	if err := team_service.CheckNotSuspended(target); err != nil {
+		return MergeResult{}, err
+	}
+
+	// La cible ne doit pas être une descendante de la source
+	ancestors, err := team_service.GetAncestors(targetID)
+	if err != nil {
+		return MergeResult{}, err
+	}
+	for _, ancestor := range ancestors {
+		if ancestor.ID == sourceID {
+			return MergeResult{}, ErrInvalidMerge
+		}
+	}
+
+	var merge MergeResult
+	err = db.Transaction(func(tx *gorm.DB) error {
+		now := time.Now()
+
+		var members []models.TeamMember
+		if err := tx.Where("team_id = ?", sourceID).Where(team_member_service.CurrentCondition).Find(&members).Error; err != nil {
+			return err
+		}
+
+		var existing []uuid.UUID
+		if err := tx.Model(&models.TeamMember{}).Where("team_id = ?", targetID).Where(team_member_service.CurrentCondition).Pluck("member_id", &existing).Error; err != nil {
+			return err
+		}
+		inTarget := make(map[uuid.UUID]bool, len(existing))
+		for _, memberID := range existing {
+			inTarget[memberID] = true
+		}
+
+		for _, member := range members {
+			// Départ de la source
+			if err := tx.Model(&models.TeamMember{}).Where("id = ?", member.ID).Update("left_at", now).Error; err != nil {
+				return err
+			}
+			if inTarget[member.MemberID] {
+				merge.SkippedMembers++
+				continue
+			}
+
+			role := member.Role
+			switch role {
+			case models.TeamMemberRoleOwner:
+				role = models.TeamMemberRoleAdmin
+			case models.TeamMemberRoleCustom:
+				role = models.TeamMemberRoleSpectator
+			}
+
+			// Arrivée dans la cible, en conservant l'état de l'adhésion
+			moved := models.TeamMember{
+				MemberID:     member.MemberID,
+				TeamID:       targetID,
+				Role:         role,
+				IsActive:     member.IsActive,
+				IsAccessible: member.IsAccessible,
+				SuspendedAt:  member.SuspendedAt,
+				ExternalID:   member.ExternalID,
+			}
+			if err := tx.Select("MemberID", "TeamID", "Role", "IsActive", "IsAccessible", "SuspendedAt", "ExternalID").Create(&moved).Error; err != nil {
+				return err
+			}
+			merge.MovedMembers++
+		}
+
+		// Sous-teams, avec les mêmes règles qu'un rattachement : la hiérarchie de la cible ne doit pas dépasser
+		// la profondeur maximale
+		var childIDs []uuid.UUID
+		if err := tx.Model(&models.Team{}).Where("parent_id = ?", sourceID).Pluck("id", &childIDs).Error; err != nil {
+			return err
+		}
+		for _, childID := range childIDs {
+			if err := team_service.CheckParent(tx, childID, target); err != nil {
+				return err
+			}
+		}
+		children := tx.Model(&models.Team{}).Where("parent_id = ?", sourceID).Update("parent_id", targetID)
+		if children.Error != nil {
+			return children.Error
+		}
+		merge.MovedChildren = int(children.RowsAffected)
+
+		// Archivage de la source
+		if err := tx.Model(&models.Team{}).Where("id = ?", sourceID).Update("archived_at", now).Error; err != nil {
+			return err
+		}
+		if err := tx.Model(&models.TeamInvitation{}).
+			Where("team_id = ? AND status = ?", sourceID, models.TeamInvitationStatusPending).
+			Updates(map[string]interface{}{
+				"status":       models.TeamInvitationStatusRevoked,
+				"responded_at": now,
+			}).Error; err != nil {
+			return err
+		}
+		if err := tx.Model(&models.TeamOwnershipTransfer{}).
+			Where("team_id = ? AND status = ?", sourceID, models.OwnershipTransferStatusPending).
+			Updates(map[string]interface{}{
+				"status":       models.OwnershipTransferStatusCancelled,
+				"responded_at": now,
+			}).Error; err != nil {
+			return err
+		}
+
+		// Domaines : la revendication est libérée, la cible peut revendiquer le domaine à son tour
+		if err := tx.Model(&models.TeamJoinRequest{}).
+			Where("team_id = ? AND status = ?", sourceID, models.TeamJoinRequestStatusPending).
+			Updates(map[string]interface{}{
+				"status":          models.TeamJoinRequestStatusRejected,
+				"responded_at":    now,
+				"responded_by_id": adminID,
+			}).Error; err != nil {
+			return err
+		}
+		domains := tx.Where("team_id = ?", sourceID).Delete(&models.TeamDomain{})
+		if domains.Error != nil {
+			return domains.Error
+		}
+		merge.RevokedDomains = int(domains.RowsAffected)
+
+		tokens := tx.Model(&models.TeamSCIMToken{}).Where("team_id = ? AND revoked_at IS NULL", sourceID).Update("revoked_at", now)
+		if tokens.Error != nil {
+			return tokens.Error
+		}
+		merge.RevokedSCIMTokens = int(tokens.RowsAffected)
+
+		// Les membres ont quitté la source : ses rôles personnalisés ne servent plus
+		if err := tx.Model(&models.TeamMember{}).Where("team_id = ? AND custom_role_id IS NOT NULL", sourceID).Update("custom_role_id", nil).Error; err != nil {
+			return err
+		}
+		roles := tx.Where("team_id = ?", sourceID).Delete(&models.TeamRole{})
+		if roles.Error != nil {
+			return roles.Error
+		}
+		merge.RemovedRoles = int(roles.RowsAffected)
+
+		team_activity_service.Record(tx, sourceID, adminID, "team.merged_into", team_activity_service.TargetTeam, targetID.String(), team_activity_service.Changes{
+			"archived_at": team_activity_service.Change(nil, now),
+			"domains":     team_activity_service.Change(merge.RevokedDomains, 0),
+			"scim_tokens": team_activity_service.Change(merge.RevokedSCIMTokens, 0),
+			"roles":       team_activity_service.Change(merge.RemovedRoles, 0),
+		})
+		team_activity_service.Record(tx, targetID, adminID, "team.merged_from", team_activity_service.TargetTeam, sourceID.String(), team_activity_service.Changes{
+			"members":  team_activity_service.Change(nil, merge.MovedMembers),
+			"children": team_activity_service.Change(nil, merge.MovedChildren),
+		})
+		return nil
+	})
+	if err != nil {
+		return MergeResult{}, fmt.Errorf("error merging teams: %w", err)
+	}
+
+	return merge, nil
+}
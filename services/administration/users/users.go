@@ -0,0 +1,373 @@
+package admin_user_service
+
+import (
+	"errors"
+	"gox/database"
+	"gox/database/models"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+var (
+	ErrSelfAction = errors.New("admins cannot deactivate or demote themselves")
+	ErrLastAdmin  = errors.New("at least one active admin must remain")
+	ErrAnonymized = errors.New("account has been deleted")
+)
+
+// Pagination : page commence à 1, perPage est borné
+const (
+	DefaultPerPage = 50
+	MaxPerPage     = 200
+)
+
+// Nombre d'opérations de crédits retournées avec le détail d'un utilisateur
+const creditHistoryLimit = 20
+
+// Colonnes de tri autorisées
+var sortColumns = map[string]string{
+	"created_on": "users.created_on",
+	"email":      "users.email",
+	"username":   "p.username",
+}
+
+// Query : Search porte sur l'email et le username (contient), Team sur le nom (contient) ou l'ID d'une
+// Team dont l'utilisateur est membre
+type Query struct {
+	Search       string
+	Team         string
+	IsActive     *bool
+	IsAccessible *bool
+	IsAppAdmin   *bool
+	Sort         string
+	Descending   bool
+	Page         int
+	PerPage      int
+}
+
+type Summary struct {
+	ID                    uuid.UUID  `json:"id"`
+	Email                 string     `json:"email"`
+	Username              string     `json:"username"`
+	CreatedOn             time.Time  `json:"created_on"`
+	IsActive              bool       `json:"is_active"`
+	IsAccessible          bool       `json:"is_accessible"`
+	IsAppAdmin            bool       `json:"is_app_admin"`
+	PasswordResetRequired bool       `json:"password_reset_required"`
+	AnonymizedAt          *time.Time `json:"anonymized_at"`
+}
+
+// escapeLike échappe les jokers de LIKE dans un texte saisi par l'admin
+func escapeLike(value string) string {
+	return strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`).Replace(value)
+}
+
+// Search retourne une page d'utilisateurs et le nombre total d'utilisateurs filtrés
+func Search(q Query) ([]Summary, int64, error) {
+	if q.Page < 1 {
+		q.Page = 1
+	}
+	if q.PerPage < 1 {
+		q.PerPage = DefaultPerPage
+	}
+	if q.PerPage > MaxPerPage {
+		q.PerPage = MaxPerPage
+	}
+
+	query := database.DB.Table("users").Joins("LEFT JOIN user_profiles p ON p.customer_id = users.id")
+	if search := strings.TrimSpace(q.Search); search != "" {
+		pattern := "%" + escapeLike(search) + "%"
+		query = query.Where("users.email ILIKE ? OR p.username ILIKE ?", pattern, pattern)
+	}
+	if team := strings.TrimSpace(q.Team); team != "" {
+		if teamID, err := uuid.Parse(team); err == nil {
+			query = query.Where("EXISTS (SELECT 1 FROM team_members tm WHERE tm.member_id = users.id AND tm.left_at IS NULL AND tm.team_id = ?)", teamID)
+		} else {
+			query = query.Where("EXISTS (SELECT 1 FROM team_members tm JOIN teams t ON t.id = tm.team_id WHERE tm.member_id = users.id AND tm.left_at IS NULL AND t.name ILIKE ?)",
+				"%"+escapeLike(team)+"%")
+		}
+	}
+	if q.IsActive != nil {
+		query = query.Where("users.is_active = ?", *q.IsActive)
+	}
+	if q.IsAccessible != nil {
+		query = query.Where("users.is_accessible = ?", *q.IsAccessible)
+	}
+	if q.IsAppAdmin != nil {
+		query = query.Where("users.is_app_admin = ?", *q.IsAppAdmin)
+	}
+
+	var total int64
+	if err := query.Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+
+	column, ok := sortColumns[q.Sort]
+	if !ok {
+		column = sortColumns["created_on"]
+	}
+	direction := " ASC"
+	if q.Descending {
+		direction = " DESC"
+	}
+
+	users := []Summary{}
+	result := query.
+		Select("users.id, users.email, COALESCE(p.username, '') AS username, users.created_on, users.is_active, users.is_accessible, users.is_app_admin, users.password_reset_required, users.anonymized_at").
+		Order(column + direction + ", users.id").
+		Offset((q.Page - 1) * q.PerPage).
+		Limit(q.PerPage).
+		Scan(&users)
+
+	// Vérification des erreurs GORM
+	if result.Error != nil {
+		return nil, 0, result.Error
+	}
+
+	return users, total, nil
+}
+
+// ~ Détail d'un utilisateur ~
+
+type Profile struct {
+	Username     string `json:"username"`
+	DisplayName  string `json:"display_name"`
+	Bio          string `json:"bio"`
+	AvatarURL    string `json:"avatar_url"`
+	IsAccessible bool   `json:"is_accessible"`
+}
+
+type Membership struct {
+	TeamID       uuid.UUID             `json:"team_id"`
+	Name         string                `json:"name"`
+	Type         models.TeamType       `json:"type"`
+	Role         models.TeamMemberRole `json:"role"`
+	IsActive     bool                  `json:"is_active"`
+	IsAccessible bool                  `json:"is_accessible"`
+	JoinedAt     time.Time             `json:"joined_at"`
+	ArchivedAt   *time.Time            `json:"archived_at"`
+}
+
+type Subscription struct {
+	ID         uuid.UUID `json:"id"`
+	Plan       string    `json:"plan"`
+	StartAt    time.Time `json:"start_at"`
+	EndAt      time.Time `json:"end_at"`
+	AutoRenew  bool      `json:"auto_renew"`
+	TotalPrice int       `json:"total_price"`
+	Currency   string    `json:"currency"`
+	IsCurrent  bool      `json:"is_current"`
+}
+
+type CreditOperation struct {
+	Amount    int                        `json:"amount"`
+	Operation models.CreditOperationType `json:"operation"`
+	Reason    string                     `json:"reason"`
+	DateTime  time.Time                  `json:"date_time"`
+}
+
+type Credits struct {
+	Balance int               `json:"balance"`
+	History []CreditOperation `json:"history"` // dernières opérations
+}
+
+type Detail struct {
+	Summary
+	SessionsRevokedAt *time.Time     `json:"sessions_revoked_at"`
+	Profile           *Profile       `json:"profile"`
+	Teams             []Membership   `json:"teams"`
+	Subscriptions     []Subscription `json:"subscriptions"`
+	Credits           Credits        `json:"credits"`
+}
+
+// Get retourne l'utilisateur avec son profil, ses Teams, ses abonnements et ses crédits
+func Get(userID uuid.UUID) (Detail, error) {
+	var user models.User
+	if err := database.DB.Where("id = ?", userID).First(&user).Error; err != nil {
+		return Detail{}, err
+	}
+
+	detail := Detail{
+		Summary: Summary{
+			ID:                    user.ID,
+			Email:                 user.Email,
+			CreatedOn:             user.CreatedOn,
+			IsActive:              user.IsActive,
+			IsAccessible:          user.IsAccessible,
+			IsAppAdmin:            user.IsAppAdmin,
+			PasswordResetRequired: user.PasswordResetRequired,
+			AnonymizedAt:          user.AnonymizedAt,
+		},
+		SessionsRevokedAt: user.SessionsRevokedAt,
+		Teams:             []Membership{},
+		Subscriptions:     []Subscription{},
+		Credits:           Credits{History: []CreditOperation{}},
+	}
+
+	// Profil
+	var profile models.UserProfile
+	result := database.DB.Where("customer_id = ?", userID).Limit(1).Find(&profile)
+	if result.Error != nil {
+		return Detail{}, result.Error
+	}
+	if result.RowsAffected > 0 {
+		detail.Username = profile.Username
+		detail.Profile = &Profile{
+			Username:     profile.Username,
+			DisplayName:  profile.DisplayName,
+			Bio:          profile.Bio,
+			AvatarURL:    profile.AvatarURL,
+			IsAccessible: profile.IsAccessible,
+		}
+	}
+
+	// Teams actuelles
+	var members []models.TeamMember
+	if err := database.DB.Preload("Team").Where("member_id = ? AND left_at IS NULL", userID).Order("joined_at").Find(&members).Error; err != nil {
+		return Detail{}, err
+	}
+	for _, member := range members {
+		detail.Teams = append(detail.Teams, Membership{
+			TeamID:       member.TeamID,
+			Name:         member.Team.Name,
+			Type:         member.Team.Type,
+			Role:         member.Role,
+			IsActive:     member.IsActive,
+			IsAccessible: member.IsAccessible,
+			JoinedAt:     member.JoinedAt,
+			ArchivedAt:   member.Team.ArchivedAt,
+		})
+	}
+
+	// Abonnements, le plus récent en premier
+	var subscriptions []models.UserSubscription
+	if err := database.DB.Preload("Subscription").Where("customer_id = ? AND is_accessible = ?", userID, true).Order("start_at DESC").Find(&subscriptions).Error; err != nil {
+		return Detail{}, err
+	}
+	now := time.Now()
+	for _, subscription := range subscriptions {
+		endAt := subscription.StartAt.AddDate(0, 0, subscription.Subscription.ValidForInDays)
+		detail.Subscriptions = append(detail.Subscriptions, Subscription{
+			ID:         subscription.ID,
+			Plan:       subscription.Subscription.Name,
+			StartAt:    subscription.StartAt,
+			EndAt:      endAt,
+			AutoRenew:  subscription.AutoRenew,
+			TotalPrice: subscription.TotalPrice,
+			Currency:   subscription.Subscription.Currency,
+			IsCurrent:  subscription.StartAt.Before(now) && endAt.After(now),
+		})
+	}
+
+	// Crédits : solde et dernières opérations
+	var balance struct{ Balance int }
+	if err := database.DB.Model(&models.UserCredit{}).Select("COALESCE(SUM(balance), 0) AS balance").
+		Where("customer_id = ? AND is_accessible = ?", userID, true).Scan(&balance).Error; err != nil {
+		return Detail{}, err
+	}
+	detail.Credits.Balance = balance.Balance
+	var history []models.UserCreditHistory
+	if err := database.DB.Where("customer_id = ? AND is_accessible = ?", userID, true).Order("date_time DESC").Limit(creditHistoryLimit).Find(&history).Error; err != nil {
+		return Detail{}, err
+	}
+	for _, entry := range history {
+		detail.Credits.History = append(detail.Credits.History, CreditOperation{
+			Amount:    entry.Amount,
+			Operation: entry.Operation,
+			Reason:    entry.Reason,
+			DateTime:  entry.DateTime,
+		})
+	}
+
+	return detail, nil
+}
+
+// ~ Actions ~
+
+// Changes : accès à modifier, nil pour ne pas modifier
+type Changes struct {
+	IsActive     *bool `json:"is_active"`
+	IsAccessible *bool `json:"is_accessible"`
+	IsAppAdmin   *bool `json:"is_app_admin"`
+}
+
+// Access : état des accès d'un utilisateur, avant et après une modification
+type Access struct {
+	IsActive     bool `json:"is_active"`
+	IsAccessible bool `json:"is_accessible"`
+	IsAppAdmin   bool `json:"is_app_admin"`
+}
+
+func accessOf(user models.User) Access {
+	return Access{IsActive: user.IsActive, IsAccessible: user.IsAccessible, IsAppAdmin: user.IsAppAdmin}
+}
+
+// UpdateAccess active ou désactive le compte, le masque ou le rend visible, le promeut admin ou le rétrograde.
+// Une désactivation ou une rétrogradation révoque les sessions ouvertes (le token porte le droit admin).
+func UpdateAccess(db *gorm.DB, userID, adminID uuid.UUID, changes Changes) (Access, Access, error) {
+	var before, after Access
+
+	err := db.Transaction(func(tx *gorm.DB) error {
+		var user models.User
+		if err := tx.Where("id = ?", userID).First(&user).Error; err != nil {
+			return err
+		}
+		before = accessOf(user)
+
+		updates := map[string]interface{}{}
+		if changes.IsActive != nil && *changes.IsActive != user.IsActive {
+			if *changes.IsActive && user.AnonymizedAt != nil {
+				return ErrAnonymized
+			}
+			updates["is_active"] = *changes.IsActive
+		}
+		if changes.IsAccessible != nil && *changes.IsAccessible != user.IsAccessible {
+			updates["is_accessible"] = *changes.IsAccessible
+		}
+		if changes.IsAppAdmin != nil && *changes.IsAppAdmin != user.IsAppAdmin {
+			updates["is_app_admin"] = *changes.IsAppAdmin
+		}
+		if len(updates) == 0 {
+			after = before
+			return nil
+		}
+
+		// Un admin qui perd ses droits ou son compte
+		losesAdmin := user.IsAppAdmin && user.IsActive && (updates["is_active"] == false || updates["is_app_admin"] == false)
+		if losesAdmin {
+			if userID == adminID {
+				return ErrSelfAction
+			}
+			// Les admins actifs sont verrouillés : deux admins ne peuvent pas se rétrograder mutuellement en même temps
+			var admins []uuid.UUID
+			if err := tx.Model(&models.User{}).Clauses(clause.Locking{Strength: "UPDATE"}).
+				Where("is_app_admin = ? AND is_active = ?", true, true).Pluck("id", &admins).Error; err != nil {
+				return err
+			}
+			if len(admins) <= 1 {
+				return ErrLastAdmin
+			}
+		}
+		if updates["is_active"] == false || updates["is_app_admin"] == false {
+			updates["sessions_revoked_at"] = time.Now()
+			updates["session_version"] = gorm.Expr("session_version + 1")
+		}
+
+		if err := tx.Model(&models.User{}).Where("id = ?", userID).Updates(updates).Error; err != nil {
+			return err
+		}
+		if err := tx.Where("id = ?", userID).First(&user).Error; err != nil {
+			return err
+		}
+		after = accessOf(user)
+		return nil
+	})
+	if err != nil {
+		return Access{}, Access{}, err
+	}
+
+	return before, after, nil
+}
@@ -0,0 +1,304 @@
+package analytics_service
+
+import (
+	"errors"
+	"gox/database"
+	"gox/database/models"
+	"sort"
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+var ErrInvalidRange = errors.New("invalid range: since must be before until, bucket must be hour or day")
+
+// Routes de connexion, pour distinguer les échecs d'authentification
+var LoginRoutes = []string{"/auth/login", "/administrate/login"}
+
+// Range : période [Since, Until) découpée en intervalles d'une heure ou d'un jour
+type Range struct {
+	Since  time.Time
+	Until  time.Time
+	Bucket string
+}
+
+func (r Range) Validate() error {
+	if !r.Since.Before(r.Until) || (r.Bucket != "hour" && r.Bucket != "day") {
+		return ErrInvalidRange
+	}
+	return nil
+}
+
+func (r Range) scope(tx *gorm.DB) *gorm.DB {
+	return tx.Where("hour >= ? AND hour < ?", r.Since, r.Until)
+}
+
+// ~ Requêtes par endpoint ~
+
+type EndpointPoint struct {
+	Bucket   time.Time `json:"bucket"`
+	Route    string    `json:"route"`
+	Method   string    `json:"method"`
+	Requests int64     `json:"requests"`
+	Errors   int64     `json:"errors"`
+}
+
+// Requests retourne le nombre de requêtes par route et par intervalle ; route et method sont optionnels
+func Requests(r Range, route, method string) ([]EndpointPoint, error) {
+	tx := r.scope(database.DB.Model(&models.RequestLogRollup{}))
+	if route != "" {
+		tx = tx.Where("route = ?", route)
+	}
+	if method != "" {
+		tx = tx.Where("method = ?", method)
+	}
+
+	points := []EndpointPoint{}
+	err := tx.Select("date_trunc(?, hour) AS bucket, route, method, SUM(requests) AS requests, SUM(status4xx + status5xx) AS errors", r.Bucket).
+		Group("bucket, route, method").Order("bucket, route, method").
+		Scan(&points).Error
+	return points, err
+}
+
+// ~ Taux d'erreurs ~
+
+type ErrorPoint struct {
+	Bucket       time.Time `json:"bucket"`
+	Requests     int64     `json:"requests"`
+	ClientErrors int64     `json:"client_errors"`
+	ServerErrors int64     `json:"server_errors"`
+	ErrorRate    float64   `json:"error_rate"`
+}
+
+// ErrorRates retourne, par intervalle, la part de réponses 4xx et 5xx ; route est optionnelle
+func ErrorRates(r Range, route string) ([]ErrorPoint, error) {
+	tx := r.scope(database.DB.Model(&models.RequestLogRollup{}))
+	if route != "" {
+		tx = tx.Where("route = ?", route)
+	}
+
+	points := []ErrorPoint{}
+	err := tx.Select("date_trunc(?, hour) AS bucket, SUM(requests) AS requests, SUM(status4xx) AS client_errors, SUM(status5xx) AS server_errors", r.Bucket).
+		Group("bucket").Order("bucket").
+		Scan(&points).Error
+	for i, point := range points {
+		if point.Requests > 0 {
+			points[i].ErrorRate = float64(point.ClientErrors+point.ServerErrors) / float64(point.Requests)
+		}
+	}
+	return points, err
+}
+
+// ~ Latence ~
+
+type RouteLatency struct {
+	Route    string  `json:"route"`
+	Method   string  `json:"method"`
+	Requests int64   `json:"requests"`
+	AvgMs    float64 `json:"avg_ms"`
+	P50Ms    float64 `json:"p50_ms"`
+	P95Ms    float64 `json:"p95_ms"`
+	P99Ms    float64 `json:"p99_ms"`
+}
+
+// Latency retourne la durée moyenne et les percentiles par route, les plus sollicitées en premier.
+// Les percentiles sont estimés à partir des histogrammes horaires (interpolation dans l'intervalle).
+func Latency(r Range, route, method string) ([]RouteLatency, error) {
+	tx := r.scope(database.DB.Model(&models.RequestLogRollup{}))
+	if route != "" {
+		tx = tx.Where("route = ?", route)
+	}
+	if method != "" {
+		tx = tx.Where("method = ?", method)
+	}
+
+	var rollups []models.RequestLogRollup
+	if err := tx.Select("route, method, requests, duration_sum, latency_histogram").Find(&rollups).Error; err != nil {
+		return nil, err
+	}
+
+	type merged struct {
+		requests    int64
+		durationSum int64
+		histogram   []int64
+	}
+	routes := map[[2]string]*merged{}
+	for _, rollup := range rollups {
+		key := [2]string{rollup.Route, rollup.Method}
+		m, ok := routes[key]
+		if !ok {
+			m = &merged{histogram: make([]int64, len(LatencyBuckets)+1)}
+			routes[key] = m
+		}
+		m.requests += rollup.Requests
+		m.durationSum += rollup.DurationSum
+		for i := 0; i < len(rollup.LatencyHistogram) && i < len(m.histogram); i++ {
+			m.histogram[i] += rollup.LatencyHistogram[i]
+		}
+	}
+
+	latencies := make([]RouteLatency, 0, len(routes))
+	for key, m := range routes {
+		latency := RouteLatency{
+			Route:    key[0],
+			Method:   key[1],
+			Requests: m.requests,
+			P50Ms:    percentile(m.histogram, 0.50),
+			P95Ms:    percentile(m.histogram, 0.95),
+			P99Ms:    percentile(m.histogram, 0.99),
+		}
+		if m.requests > 0 {
+			latency.AvgMs = float64(m.durationSum) / float64(m.requests) / float64(time.Millisecond)
+		}
+		latencies = append(latencies, latency)
+	}
+	sort.Slice(latencies, func(i, j int) bool {
+		if latencies[i].Requests != latencies[j].Requests {
+			return latencies[i].Requests > latencies[j].Requests
+		}
+		return latencies[i].Route+latencies[i].Method < latencies[j].Route+latencies[j].Method
+	})
+	return latencies, nil
+}
+
+// percentile estime, en millisecondes, la durée sous laquelle se trouve la fraction p des requêtes
+func percentile(histogram []int64, p float64) float64 {
+	var total int64
+	for _, count := range histogram {
+		total += count
+	}
+	if total == 0 {
+		return 0
+	}
+
+	target := p * float64(total)
+	var cumulative float64
+	for i, count := range histogram {
+		if count == 0 {
+			continue
+		}
+		if cumulative+float64(count) >= target {
+			var lower time.Duration
+			if i > 0 {
+				lower = LatencyBuckets[i-1]
+			}
+			// Dernier intervalle, sans borne supérieure
+			if i >= len(LatencyBuckets) {
+				return float64(lower) / float64(time.Millisecond)
+			}
+			fraction := (target - cumulative) / float64(count)
+			return (float64(lower) + fraction*float64(LatencyBuckets[i]-lower)) / float64(time.Millisecond)
+		}
+		cumulative += float64(count)
+	}
+	return float64(LatencyBuckets[len(LatencyBuckets)-1]) / float64(time.Millisecond)
+}
+
+// ~ Utilisateurs les plus actifs ~
+
+type UserVolume struct {
+	UserID       uuid.UUID `json:"user_id"`
+	Email        string    `json:"email"`
+	Requests     int64     `json:"requests"`
+	Errors       int64     `json:"errors"`
+	AuthFailures int64     `json:"auth_failures"`
+}
+
+// TopUsers retourne les utilisateurs ayant fait le plus de requêtes sur la période
+func TopUsers(r Range, limit int) ([]UserVolume, error) {
+	users := []UserVolume{}
+	err := r.scope(database.DB.Model(&models.RequestLogUserRollup{})).
+		Select("user_id, SUM(requests) AS requests, SUM(errors) AS errors, SUM(auth_failures) AS auth_failures").
+		Group("user_id").Order("requests DESC").Limit(limit).
+		Scan(&users).Error
+	if err != nil || len(users) == 0 {
+		return users, err
+	}
+
+	// Emails des utilisateurs
+	ids := make([]uuid.UUID, len(users))
+	for i, user := range users {
+		ids[i] = user.UserID
+	}
+	var accounts []models.User
+	if err := database.DB.Select("id, email").Where("id IN ?", ids).Find(&accounts).Error; err != nil {
+		return nil, err
+	}
+	emails := make(map[uuid.UUID]string, len(accounts))
+	for _, account := range accounts {
+		emails[account.ID] = account.Email
+	}
+	for i := range users {
+		users[i].Email = emails[users[i].UserID]
+	}
+
+	return users, nil
+}
+
+// ~ Échecs d'authentification ~
+
+type AuthFailurePoint struct {
+	Bucket        time.Time `json:"bucket"`
+	Unauthorized  int64     `json:"unauthorized"`
+	Forbidden     int64     `json:"forbidden"`
+	LoginFailures int64     `json:"login_failures"`
+}
+
+// AuthFailures retourne, par intervalle, les réponses 401 et 403, dont les échecs de connexion
+func AuthFailures(r Range) ([]AuthFailurePoint, error) {
+	points := []AuthFailurePoint{}
+	err := r.scope(database.DB.Model(&models.RequestLogRollup{})).
+		Select("date_trunc(?, hour) AS bucket, SUM(unauthorized) AS unauthorized, SUM(forbidden) AS forbidden, "+
+			"COALESCE(SUM(unauthorized) FILTER (WHERE route IN ?), 0) AS login_failures", r.Bucket, LoginRoutes).
+		Group("bucket").Order("bucket").
+		Scan(&points).Error
+	return points, err
+}
+
+// ~ Vue d'ensemble ~
+
+type Overview struct {
+	Since        time.Time      `json:"since"`
+	Until        time.Time      `json:"until"`
+	Requests     int64          `json:"requests"`
+	ErrorRate    float64        `json:"error_rate"`
+	AuthFailures int64          `json:"auth_failures"`
+	TopRoutes    []RouteLatency `json:"top_routes"`
+	TopUsers     []UserVolume   `json:"top_users"`
+}
+
+// GetOverview résume la période : volume, taux d'erreurs, routes et utilisateurs les plus actifs
+func GetOverview(r Range) (Overview, error) {
+	overview := Overview{Since: r.Since, Until: r.Until}
+
+	var totals struct {
+		Requests     int64
+		Errors       int64
+		AuthFailures int64
+	}
+	err := r.scope(database.DB.Model(&models.RequestLogRollup{})).
+		Select("COALESCE(SUM(requests), 0) AS requests, COALESCE(SUM(status4xx + status5xx), 0) AS errors, " +
+			"COALESCE(SUM(unauthorized + forbidden), 0) AS auth_failures").
+		Scan(&totals).Error
+	if err != nil {
+		return Overview{}, err
+	}
+	overview.Requests = totals.Requests
+	overview.AuthFailures = totals.AuthFailures
+	if totals.Requests > 0 {
+		overview.ErrorRate = float64(totals.Errors) / float64(totals.Requests)
+	}
+
+	routes, err := Latency(r, "", "")
+	if err != nil {
+		return Overview{}, err
+	}
+	overview.TopRoutes = routes[:min(len(routes), 10)]
+
+	if overview.TopUsers, err = TopUsers(r, 10); err != nil {
+		return Overview{}, err
+	}
+
+	return overview, nil
+}
@@ -0,0 +1,259 @@
+package analytics_service
+
+import (
+	"fmt"
+	"gox/database"
+	"gox/database/models"
+	"gox/utils"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// LatencyBuckets : bornes des intervalles de l'histogramme des durées. L'intervalle i couvre
+// [LatencyBuckets[i-1], LatencyBuckets[i]), le premier tout ce qui est en dessous de LatencyBuckets[0]
+// et le dernier tout ce qui dépasse la dernière borne.
+var LatencyBuckets = []time.Duration{
+	time.Millisecond, 2 * time.Millisecond, 5 * time.Millisecond,
+	10 * time.Millisecond, 25 * time.Millisecond, 50 * time.Millisecond,
+	100 * time.Millisecond, 250 * time.Millisecond, 500 * time.Millisecond,
+	time.Second, 2500 * time.Millisecond, 5 * time.Second, 10 * time.Second,
+}
+
+// Clé du verrou consultatif PostgreSQL qui réserve chaque passage à une seule instance
+const rollupLockKey = 0x726f6c6c7570 // "rollup"
+
+// Nombre maximum d'heures agrégées par passage, pour que le premier passage sur un historique
+// important ne bloque pas le job : les suivants reprennent là où il s'est arrêté
+const maxHoursPerRun = 24 * 7
+
+// Interval : intervalle du job d'agrégation, configurable via ANALYTICS_ROLLUP_INTERVAL_MINUTES
+func Interval() time.Duration {
+	minutes, err := strconv.Atoi(utils.GetEnv("ANALYTICS_ROLLUP_INTERVAL_MINUTES", "5"))
+	if err != nil || minutes <= 0 {
+		minutes = 5
+	}
+	return time.Duration(minutes) * time.Minute
+}
+
+// RetentionDays : durée de conservation des agrégats, configurable via ANALYTICS_RETENTION_DAYS
+func RetentionDays() int {
+	days, err := strconv.Atoi(utils.GetEnv("ANALYTICS_RETENTION_DAYS", "400"))
+	if err != nil || days <= 0 {
+		days = 400
+	}
+	return days
+}
+
+// Rollup met à jour les agrégats horaires. L'agrégation reprend à l'heure précédant la dernière heure agrégée
+// (les logs sont écrits en différé, la dernière heure a pu être incomplète) et va jusqu'à l'heure en cours.
+// Chaque heure est recalculée entièrement : l'opération peut être rejouée sans risque. La dernière heure
+// agrégée est enregistrée à chaque heure traitée, même sans aucun log : une interruption des logs plus longue
+// que maxHoursPerRun ne bloque pas l'agrégation.
+// Le job tourne sur chaque instance : le passage est réservé par un verrou consultatif, pris sur une connexion
+// dédiée, et une instance qui ne l'obtient pas passe son tour.
+func Rollup() error {
+	return database.DB.Connection(func(conn *gorm.DB) error {
+		var locked bool
+		if err := conn.Raw("SELECT pg_try_advisory_lock(?)", rollupLockKey).Scan(&locked).Error; err != nil {
+			return err
+		}
+		if !locked {
+			return nil
+		}
+		defer conn.Exec("SELECT pg_advisory_unlock(?)", rollupLockKey)
+
+		return rollup(conn)
+	})
+}
+
+func rollup(db *gorm.DB) error {
+	now := time.Now().UTC().Truncate(time.Hour)
+	cutoff := time.Now().AddDate(0, 0, -RetentionDays())
+
+	start, ok, err := resumeHour(db)
+	if err != nil || !ok {
+		return err
+	}
+	// Les heures au-delà de la rétention seraient purgées aussitôt
+	if floor := cutoff.UTC().Truncate(time.Hour); start.Before(floor) {
+		start = floor
+	}
+
+	for hour, count := start, 0; !hour.After(now) && count < maxHoursPerRun; hour, count = hour.Add(time.Hour), count+1 {
+		if err := rollupHour(db, hour); err != nil {
+			return fmt.Errorf("rollup of %s: %w", hour.Format(time.RFC3339), err)
+		}
+		if err := db.Clauses(clause.OnConflict{UpdateAll: true}).
+			Create(&models.RequestLogRollupWatermark{ID: 1, Hour: hour}).Error; err != nil {
+			return err
+		}
+	}
+
+	// Purge des agrégats trop anciens
+	if err := db.Where("hour < ?", cutoff).Delete(&models.RequestLogRollup{}).Error; err != nil {
+		return err
+	}
+	return db.Where("hour < ?", cutoff).Delete(&models.RequestLogUserRollup{}).Error
+}
+
+// resumeHour retourne la première heure à agréger : l'heure précédant la dernière heure agrégée, à défaut
+// (avant l'enregistrement de l'avancement) le dernier agrégat, sinon le plus ancien log. ok est faux s'il
+// n'y a encore aucun log.
+func resumeHour(db *gorm.DB) (time.Time, bool, error) {
+	var watermark models.RequestLogRollupWatermark
+	result := db.Where("id = ?", 1).Limit(1).Find(&watermark)
+	if result.Error != nil {
+		return time.Time{}, false, result.Error
+	}
+	if result.RowsAffected > 0 {
+		return watermark.Hour.UTC().Add(-time.Hour), true, nil
+	}
+
+	var last struct{ Hour *time.Time }
+	if err := db.Model(&models.RequestLogRollup{}).Select("MAX(hour) AS hour").Scan(&last).Error; err != nil {
+		return time.Time{}, false, err
+	}
+	if last.Hour != nil {
+		return last.Hour.UTC().Add(-time.Hour), true, nil
+	}
+
+	// Premier passage : depuis le plus ancien log
+	var first struct{ Timestamp *time.Time }
+	if err := db.Model(&models.RequestLog{}).Select(`MIN("timestamp") AS timestamp`).Scan(&first).Error; err != nil {
+		return time.Time{}, false, err
+	}
+	if first.Timestamp == nil {
+		return time.Time{}, false, nil
+	}
+	return first.Timestamp.UTC().Truncate(time.Hour), true, nil
+}
+
+// bucketsArray : bornes de l'histogramme en nanosecondes, pour width_bucket
+func bucketsArray() string {
+	bounds := make([]string, len(LatencyBuckets))
+	for i, bound := range LatencyBuckets {
+		bounds[i] = strconv.FormatInt(int64(bound), 10)
+	}
+	return "ARRAY[" + strings.Join(bounds, ",") + "]::bigint[]"
+}
+
+func rollupHour(db *gorm.DB, hour time.Time) error {
+	end := hour.Add(time.Hour)
+
+	// Agrégats par route, méthode et intervalle de durée
+	var rows []struct {
+		Route         string
+		Method        string
+		Bucket        int
+		Requests      int64
+		Status2xx     int64
+		Status3xx     int64
+		Status4xx     int64
+		Status5xx     int64
+		Unauthorized  int64
+		Forbidden     int64
+		DurationSum   int64
+		ResponseBytes int64
+	}
+	err := db.Raw(`SELECT COALESCE(route, '') AS route, method,
+			width_bucket(COALESCE(duration, 0), `+bucketsArray()+`) AS bucket,
+			COUNT(*) AS requests,
+			COUNT(*) FILTER (WHERE status BETWEEN 200 AND 299) AS status2xx,
+			COUNT(*) FILTER (WHERE status BETWEEN 300 AND 399) AS status3xx,
+			COUNT(*) FILTER (WHERE status BETWEEN 400 AND 499) AS status4xx,
+			COUNT(*) FILTER (WHERE status >= 500) AS status5xx,
+			COUNT(*) FILTER (WHERE status = 401) AS unauthorized,
+			COUNT(*) FILTER (WHERE status = 403) AS forbidden,
+			COALESCE(SUM(duration), 0) AS duration_sum,
+			COALESCE(SUM(response_bytes), 0) AS response_bytes
+		FROM request_logs
+		WHERE "timestamp" >= ? AND "timestamp" < ?
+		GROUP BY 1, 2, 3`, hour, end).Scan(&rows).Error
+	if err != nil {
+		return err
+	}
+
+	rollups := map[[2]string]*models.RequestLogRollup{}
+	for _, row := range rows {
+		key := [2]string{row.Route, row.Method}
+		rollup, ok := rollups[key]
+		if !ok {
+			rollup = &models.RequestLogRollup{
+				Hour:             hour,
+				Route:            row.Route,
+				Method:           row.Method,
+				LatencyHistogram: make([]int64, len(LatencyBuckets)+1),
+			}
+			rollups[key] = rollup
+		}
+		rollup.Requests += row.Requests
+		rollup.Status2xx += row.Status2xx
+		rollup.Status3xx += row.Status3xx
+		rollup.Status4xx += row.Status4xx
+		rollup.Status5xx += row.Status5xx
+		rollup.Unauthorized += row.Unauthorized
+		rollup.Forbidden += row.Forbidden
+		rollup.DurationSum += row.DurationSum
+		rollup.ResponseBytes += row.ResponseBytes
+		rollup.LatencyHistogram[row.Bucket] += row.Requests
+	}
+
+	// Agrégats par utilisateur
+	var users []struct {
+		UserID       uuid.UUID
+		Requests     int64
+		Errors       int64
+		AuthFailures int64
+	}
+	err = db.Raw(`SELECT user_id, COUNT(*) AS requests,
+			COUNT(*) FILTER (WHERE status >= 400) AS errors,
+			COUNT(*) FILTER (WHERE status IN (401, 403)) AS auth_failures
+		FROM request_logs
+		WHERE "timestamp" >= ? AND "timestamp" < ? AND user_id IS NOT NULL
+		GROUP BY user_id`, hour, end).Scan(&users).Error
+	if err != nil {
+		return err
+	}
+
+	// Remplacement des agrégats de l'heure
+	return db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("hour = ?", hour).Delete(&models.RequestLogRollup{}).Error; err != nil {
+			return err
+		}
+		if err := tx.Where("hour = ?", hour).Delete(&models.RequestLogUserRollup{}).Error; err != nil {
+			return err
+		}
+
+		if len(rollups) > 0 {
+			values := make([]models.RequestLogRollup, 0, len(rollups))
+			for _, rollup := range rollups {
+				values = append(values, *rollup)
+			}
+			if err := tx.CreateInBatches(values, 500).Error; err != nil {
+				return err
+			}
+		}
+
+		if len(users) > 0 {
+			values := make([]models.RequestLogUserRollup, len(users))
+			for i, user := range users {
+				values[i] = models.RequestLogUserRollup{
+					Hour:         hour,
+					UserID:       user.UserID,
+					Requests:     user.Requests,
+					Errors:       user.Errors,
+					AuthFailures: user.AuthFailures,
+				}
+			}
+			if err := tx.CreateInBatches(values, 500).Error; err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
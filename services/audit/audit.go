@@ -0,0 +1,237 @@
+package audit_service
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"gox/database"
+	"gox/database/models"
+	"gox/utils"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// Hash précédant la première entrée de la chaîne
+var GenesisHash = strings.Repeat("0", 64)
+
+// Clé du verrou consultatif PostgreSQL qui sérialise les ajouts : chaque entrée dépend de la précédente
+const appendLockKey = 0x617564697400 // "audit"
+
+// Types de cibles d'une action
+const (
+	TargetSubscription = "subscription"
+	TargetTeam         = "team"
+	TargetUser         = "user"
+	TargetRequestLogs  = "request_logs"
+	TargetAudit        = "audit"
+)
+
+// Event : action d'administration à enregistrer. Before et After sont l'état de la cible avant et après
+// l'action (nil pour une création ou une suppression), sérialisés en JSON.
+type Event struct {
+	ActorID    uuid.UUID // uuid.Nil pour une action système
+	Action     string
+	TargetType string
+	TargetID   string
+	Before     any
+	After      any
+	Reason     string
+	ClientIP   string
+	RequestID  string
+}
+
+// Migrate interdit toute modification de audit_logs autre qu'un ajout : UPDATE, DELETE et TRUNCATE sont
+// refusés par un trigger
+func Migrate() error {
+	statements := []string{
+		`CREATE OR REPLACE FUNCTION audit_logs_append_only() RETURNS trigger AS $$
+		BEGIN
+			RAISE EXCEPTION 'audit_logs is append-only (% refused)', TG_OP;
+		END;
+		$$ LANGUAGE plpgsql`,
+		`DROP TRIGGER IF EXISTS audit_logs_append_only ON audit_logs`,
+		`CREATE TRIGGER audit_logs_append_only BEFORE UPDATE OR DELETE OR TRUNCATE ON audit_logs
+		FOR EACH STATEMENT EXECUTE FUNCTION audit_logs_append_only()`,
+	}
+	return database.DB.Transaction(func(tx *gorm.DB) error {
+		for _, statement := range statements {
+			if err := tx.Exec(statement).Error; err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// Record ajoute une entrée à la fin de la chaîne. Appelé dans la transaction de l'action auditée, l'action
+// et son entrée sont validées ou annulées ensemble.
+func Record(db *gorm.DB, event Event) (models.AuditLog, error) {
+	entry := models.AuditLog{
+		Action:     event.Action,
+		TargetType: event.TargetType,
+		TargetID:   event.TargetID,
+		Reason:     event.Reason,
+		ClientIP:   event.ClientIP,
+		RequestID:  event.RequestID,
+		// Précision de PostgreSQL : la date relue doit donner le même hash
+		CreatedAt: time.Now().UTC().Truncate(time.Microsecond),
+	}
+
+	// État avant/après sous sa forme relue depuis jsonb
+	var err error
+	if entry.Before, err = canonicalValue(event.Before); err != nil {
+		return models.AuditLog{}, err
+	}
+	if entry.After, err = canonicalValue(event.After); err != nil {
+		return models.AuditLog{}, err
+	}
+
+	// Email de l'acteur, conservé même si le compte est supprimé par la suite
+	if event.ActorID != uuid.Nil {
+		entry.ActorID = &event.ActorID
+		var actor models.User
+		if err := db.Select("email").Where("id = ?", event.ActorID).First(&actor).Error; err == nil {
+			entry.ActorEmail = actor.Email
+		}
+	}
+
+	err = db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Exec("SELECT pg_advisory_xact_lock(?)", appendLockKey).Error; err != nil {
+			return err
+		}
+
+		// Dernière entrée de la chaîne
+		var last models.AuditLog
+		result := tx.Select("sequence", "hash").Order("sequence DESC").Limit(1).Find(&last)
+		if result.Error != nil {
+			return result.Error
+		}
+		entry.Sequence, entry.PrevHash = 1, GenesisHash
+		if result.RowsAffected > 0 {
+			entry.Sequence, entry.PrevHash = last.Sequence+1, last.Hash
+		}
+
+		entry.Hash = ComputeHash(entry)
+		return tx.Create(&entry).Error
+	})
+	if err != nil {
+		utils.ConsoleLog("⚠️ Error recording audit entry %s on %s %s: %v", event.Action, event.TargetType, event.TargetID, err)
+		return models.AuditLog{}, err
+	}
+
+	return entry, nil
+}
+
+// ComputeHash calcule le hash (SHA-256, hexadécimal) d'une entrée : ses champs, dans un ordre fixe,
+// et le hash de l'entrée précédente
+func ComputeHash(entry models.AuditLog) string {
+	var actorID string
+	if entry.ActorID != nil {
+		actorID = entry.ActorID.String()
+	}
+	before, _ := canonicalJSON(entry.Before)
+	after, _ := canonicalJSON(entry.After)
+
+	payload, _ := json.Marshal([]any{
+		entry.Sequence,
+		entry.CreatedAt.UTC().Format(time.RFC3339Nano),
+		actorID,
+		entry.ActorEmail,
+		entry.Action,
+		entry.TargetType,
+		entry.TargetID,
+		json.RawMessage(before),
+		json.RawMessage(after),
+		entry.Reason,
+		entry.ClientIP,
+		entry.RequestID,
+		entry.PrevHash,
+	})
+	sum := sha256.Sum256(payload)
+	return hex.EncodeToString(sum[:])
+}
+
+// canonicalValue convertit une valeur en types JSON génériques (map, slice, float64…), tels que relus
+// depuis la base
+func canonicalValue(value any) (any, error) {
+	if value == nil {
+		return nil, nil
+	}
+	data, err := json.Marshal(value)
+	if err != nil {
+		return nil, err
+	}
+	var canonical any
+	err = json.Unmarshal(data, &canonical)
+	return canonical, err
+}
+
+// canonicalJSON sérialise une valeur de façon stable : jsonb ne conserve ni l'ordre des clés ni les
+// espaces, encoding/json trie les clés des maps
+func canonicalJSON(value any) ([]byte, error) {
+	canonical, err := canonicalValue(value)
+	if err != nil {
+		return nil, err
+	}
+	return json.Marshal(canonical)
+}
+
+// ~ Vérification de la chaîne ~
+
+// Taille des lots lus lors de la vérification
+const verifyBatchSize = 1000
+
+// Verification : résultat de Verify. Head est le hash de la dernière entrée : conservé hors de la base
+// (export, ticket…), il permet de détecter une réécriture complète de la chaîne.
+type Verification struct {
+	Valid      bool      `json:"valid"`
+	Checked    int64     `json:"checked"`
+	Head       string    `json:"head"`
+	BrokenAt   *uint64   `json:"broken_at,omitempty"`
+	Problem    string    `json:"problem,omitempty"`
+	VerifiedAt time.Time `json:"verified_at"`
+}
+
+// Verify relit toute la chaîne : numérotation continue, lien avec l'entrée précédente et hash de chaque entrée
+func Verify() (Verification, error) {
+	verification := Verification{Valid: true, Head: GenesisHash, VerifiedAt: time.Now()}
+
+	var expected uint64 = 1
+	prevHash := GenesisHash
+	for {
+		var entries []models.AuditLog
+		err := database.DB.Where("sequence >= ?", expected).Order("sequence").Limit(verifyBatchSize).Find(&entries).Error
+		if err != nil {
+			return Verification{}, err
+		}
+
+		for _, entry := range entries {
+			problem := ""
+			switch {
+			case entry.Sequence != expected:
+				problem = "missing entries before this sequence"
+			case entry.PrevHash != prevHash:
+				problem = "previous hash does not match the previous entry"
+			case entry.Hash != ComputeHash(entry):
+				problem = "hash does not match the entry content"
+			}
+			if problem != "" {
+				sequence := entry.Sequence
+				verification.Valid, verification.BrokenAt, verification.Problem = false, &sequence, problem
+				return verification, nil
+			}
+
+			verification.Checked++
+			verification.Head = entry.Hash
+			prevHash = entry.Hash
+			expected++
+		}
+
+		if len(entries) < verifyBatchSize {
+			return verification, nil
+		}
+	}
+}
@@ -0,0 +1,112 @@
+package audit_service
+
+import (
+	"gox/database"
+	"gox/database/models"
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+type Filters struct {
+	Action     string
+	ActorID    *uuid.UUID
+	TargetType string
+	TargetID   string
+	Since      *time.Time
+	Until      *time.Time
+}
+
+// Pagination : page commence à 1, perPage est borné
+const (
+	DefaultPerPage = 50
+	MaxPerPage     = 200
+)
+
+// Une action se terminant par "." filtre sur un préfixe (ex : "subscription.")
+func (f Filters) apply(query *gorm.DB) *gorm.DB {
+	if f.Action != "" {
+		if f.Action[len(f.Action)-1] == '.' {
+			query = query.Where("action LIKE ?", f.Action+"%")
+		} else {
+			query = query.Where("action = ?", f.Action)
+		}
+	}
+	if f.ActorID != nil {
+		query = query.Where("actor_id = ?", *f.ActorID)
+	}
+	if f.TargetType != "" {
+		query = query.Where("target_type = ?", f.TargetType)
+	}
+	if f.TargetID != "" {
+		query = query.Where("target_id = ?", f.TargetID)
+	}
+	if f.Since != nil {
+		query = query.Where("created_at >= ?", *f.Since)
+	}
+	if f.Until != nil {
+		query = query.Where("created_at < ?", *f.Until)
+	}
+	return query
+}
+
+// List retourne une page d'entrées (de la plus récente à la plus ancienne) et le nombre total d'entrées filtrées
+func List(filters Filters, page, perPage int) ([]models.AuditLog, int64, error) {
+	if page < 1 {
+		page = 1
+	}
+	if perPage < 1 {
+		perPage = DefaultPerPage
+	}
+	if perPage > MaxPerPage {
+		perPage = MaxPerPage
+	}
+
+	query := filters.apply(database.DB.Model(&models.AuditLog{}))
+
+	var total int64
+	if err := query.Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+
+	var entries []models.AuditLog
+	result := query.Order("sequence DESC").
+		Offset((page - 1) * perPage).
+		Limit(perPage).
+		Find(&entries)
+
+	// Vérification des erreurs GORM
+	if result.Error != nil {
+		return nil, 0, result.Error
+	}
+
+	return entries, total, nil
+}
+
+// Export parcourt les entrées filtrées dans l'ordre de la chaîne, par lots, et les passe à write
+func Export(filters Filters, write func(models.AuditLog) error) error {
+	var after uint64
+	for {
+		var entries []models.AuditLog
+		err := filters.apply(database.DB.Model(&models.AuditLog{})).
+			Where("sequence > ?", after).
+			Order("sequence").
+			Limit(verifyBatchSize).
+			Find(&entries).Error
+		if err != nil {
+			return err
+		}
+
+		for _, entry := range entries {
+			if err := write(entry); err != nil {
+				return err
+			}
+			after = entry.Sequence
+		}
+
+		if len(entries) < verifyBatchSize {
+			return nil
+		}
+	}
+}
@@ -1,8 +1,12 @@
 package auth_utils
 
 import (
+	"gox/database"
+	"gox/database/models"
 	"gox/utils"
 	"net/http"
+
+	"github.com/golang-jwt/jwt/v5"
 )
 
 func CheckAuthenticationHeader(w http.ResponseWriter, r *http.Request) bool {
@@ -27,8 +31,14 @@ func CheckAuthenticationHeader(w http.ResponseWriter, r *http.Request) bool {
 		return false
 	}
 
+	// Vérifier que le compte est actif et que la session n'a pas été révoquée
+	if !isSessionValid(authUserID, claims) {
+		utils.AbortRequest(w, "Session is no longer valid.", http.StatusUnauthorized)
+		return false
+	}
+
 	// Log de l'utilisateur authentifié
-	utils.ConsoleLog("🔑 Utilisateur authentifié: %s -> %s %s", authUserID, r.Method, r.URL.Path)
+	utils.ConsoleLogRequest(r, "🔑 Utilisateur authentifié: %s -> %s %s", authUserID, r.Method, r.URL.Path)
 
 	return true
 }
@@ -72,3 +82,54 @@ func GetAuthenticatedUserID(w http.ResponseWriter, r *http.Request) string {
 
 	return authUserID
 }
+
+// GetSessionClaims retourne les claims du token si la session est valide (compte actif, session non révoquée),
+// sans répondre à la requête : pour les routes publiques dont la réponse dépend de l'utilisateur connecté
+func GetSessionClaims(r *http.Request) (jwt.MapClaims, bool) {
+	claims, err := utils.DecodeJWT(r.Header.Get("Authorization"))
+	if err != nil {
+		return nil, false
+	}
+
+	authUserID, ok := claims["user"].(string)
+	if !ok || authUserID == "" || !isSessionValid(authUserID, claims) {
+		return nil, false
+	}
+
+	return claims, true
+}
+
+// isSessionValid vérifie que le compte est actif et que le token porte la version courante des sessions
+// (voir user_service.RevokeSessions)
+func isSessionValid(userID string, claims jwt.MapClaims) bool {
+	var user models.User
+	if err := database.DB.Select("is_active", "sessions_revoked_at", "session_version").Where("id = ?", userID).First(&user).Error; err != nil {
+		return false
+	}
+	if !user.IsActive {
+		return false
+	}
+
+	return sessionVersionMatches(user, claims)
+}
+
+// sessionVersionMatches compare exactement la version du token à celle de l'utilisateur. Les tokens émis
+// avant l'ajout de la version n'en portent pas : ils restent valides tant qu'aucune révocation n'a
+// incrémenté la version, et s'ils sont postérieurs à la dernière révocation datée.
+func sessionVersionMatches(user models.User, claims jwt.MapClaims) bool {
+	if raw, ok := claims["sv"]; ok {
+		version, ok := raw.(float64)
+		return ok && version == float64(user.SessionVersion)
+	}
+
+	if user.SessionVersion != 0 {
+		return false
+	}
+	if user.SessionsRevokedAt != nil {
+		issuedAt, err := claims.GetIssuedAt()
+		if err != nil || issuedAt == nil || issuedAt.Before(*user.SessionsRevokedAt) {
+			return false
+		}
+	}
+	return true
+}
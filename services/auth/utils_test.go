@@ -0,0 +1,87 @@
+package auth_utils
+
+import (
+	"gox/database/models"
+	"gox/utils"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/google/uuid"
+)
+
+func TestSessionVersionMatches(t *testing.T) {
+	revokedAt := time.Now().Truncate(time.Second)
+
+	tests := []struct {
+		name   string
+		user   models.User
+		claims jwt.MapClaims
+		want   bool
+	}{
+		{
+			name:   "current version",
+			user:   models.User{SessionVersion: 3},
+			claims: jwt.MapClaims{"sv": float64(3), "iat": float64(revokedAt.Unix())},
+			want:   true,
+		},
+		{
+			// Émis dans la même seconde que la révocation : refusé malgré tout
+			name:   "previous version issued in the revocation second",
+			user:   models.User{SessionVersion: 3, SessionsRevokedAt: &revokedAt},
+			claims: jwt.MapClaims{"sv": float64(2), "iat": float64(revokedAt.Unix())},
+			want:   false,
+		},
+		{
+			name:   "version of another type",
+			user:   models.User{SessionVersion: 1},
+			claims: jwt.MapClaims{"sv": "1"},
+			want:   false,
+		},
+		{
+			name:   "token without version, never revoked",
+			user:   models.User{},
+			claims: jwt.MapClaims{"iat": float64(revokedAt.Unix())},
+			want:   true,
+		},
+		{
+			name:   "token without version after a versioned revocation",
+			user:   models.User{SessionVersion: 1},
+			claims: jwt.MapClaims{"iat": float64(revokedAt.Add(time.Hour).Unix())},
+			want:   false,
+		},
+		{
+			name:   "token without version, issued before a dated revocation",
+			user:   models.User{SessionsRevokedAt: &revokedAt},
+			claims: jwt.MapClaims{"iat": float64(revokedAt.Add(-time.Second).Unix())},
+			want:   false,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := sessionVersionMatches(tt.user, tt.claims); got != tt.want {
+				t.Fatalf("sessionVersionMatches() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+// Le token émis par GenerateJWT porte la version demandée, relue telle quelle
+func TestGenerateJWTSessionVersion(t *testing.T) {
+	token, err := utils.GenerateJWT(uuid.New(), false, 7)
+	if err != nil {
+		t.Fatalf("GenerateJWT() error = %v", err)
+	}
+	claims, err := utils.DecodeJWT(token)
+	if err != nil {
+		t.Fatalf("DecodeJWT() error = %v", err)
+	}
+
+	if !sessionVersionMatches(models.User{SessionVersion: 7}, claims) {
+		t.Errorf("token of version 7 refused for version 7")
+	}
+	if sessionVersionMatches(models.User{SessionVersion: 8}, claims) {
+		t.Errorf("token of version 7 accepted for version 8")
+	}
+}
@@ -0,0 +1,38 @@
+package jobs
+
+import (
+	"gox/utils"
+	"time"
+)
+
+// Every exécute fn en tâche de fond, toutes les interval, jusqu'à l'arrêt du processus.
+// Une première exécution a lieu immédiatement.
+func Every(name string, interval time.Duration, fn func() error) {
+	utils.ConsoleLog("⏱️ Scheduling job %s every %s", name, interval)
+
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		for {
+			run(name, fn)
+			<-ticker.C
+		}
+	}()
+}
+
+func run(name string, fn func() error) {
+	// Une tâche qui panique ne doit pas faire tomber le serveur
+	defer func() {
+		if r := recover(); r != nil {
+			utils.ConsoleLog("❌ Job %s panicked: %v", name, r)
+		}
+	}()
+
+	start := time.Now()
+	if err := fn(); err != nil {
+		utils.ConsoleLog("⚠️ Job %s failed: %v", name, err)
+		return
+	}
+	utils.ConsoleLog("⏱️ Job %s done in %s", name, time.Since(start))
+}
@@ -0,0 +1,42 @@
+package mail_service
+
+import (
+	"fmt"
+	"gox/utils"
+	"net/smtp"
+	"strings"
+)
+
+// Send envoie un email en texte brut.
+// Sans SMTP_HOST configuré (dev), l'email est simplement affiché dans la console.
+func Send(to, subject, body string) error {
+	host := utils.GetEnv("SMTP_HOST", "")
+	if host == "" {
+		utils.ConsoleLog("✉️ [mail] -> %s | %s\n%s", to, subject, body)
+		return nil
+	}
+
+	port := utils.GetEnv("SMTP_PORT", "587")
+	from := utils.GetEnv("SMTP_FROM", "no-reply@gox.local")
+
+	var auth smtp.Auth
+	if user := utils.GetEnv("SMTP_USER", ""); user != "" {
+		auth = smtp.PlainAuth("", user, utils.GetEnv("SMTP_PASSWORD", ""), host)
+	}
+
+	msg := strings.Join([]string{
+		fmt.Sprintf("From: %s", from),
+		fmt.Sprintf("To: %s", to),
+		fmt.Sprintf("Subject: %s", subject),
+		"MIME-Version: 1.0",
+		"Content-Type: text/plain; charset=\"utf-8\"",
+		"",
+		body,
+	}, "\r\n")
+
+	if err := smtp.SendMail(fmt.Sprintf("%s:%s", host, port), auth, from, []string{to}, []byte(msg)); err != nil {
+		return fmt.Errorf("error sending mail: %v", err)
+	}
+
+	return nil
+}
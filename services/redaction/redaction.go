@@ -0,0 +1,243 @@
+package redaction_service
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"mime"
+	"net/http"
+	"net/url"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+// Mask remplace toute valeur masquée
+const Mask = "[REDACTED]"
+
+// Body retourne le corps à journaliser : champs sensibles masqués, puis taille plafonnée.
+// En cas de doute (JSON illisible, binaire, multipart), le corps n'est pas conservé.
+func Body(method, path, contentType string, body []byte) []byte {
+	c := Current()
+	return c.Body(method, path, contentType, body)
+}
+
+// Headers retourne les en-têtes à journaliser, valeurs sensibles masquées
+func Headers(header http.Header) map[string]string {
+	c := Current()
+	return c.Headers(header)
+}
+
+// Endpoint masque, dans le chemin journalisé, les variables de route sensibles ("/invitations/{token}/accept")
+func Endpoint(path string, vars map[string]string) string {
+	c := Current()
+	for name, value := range vars {
+		if value != "" && c.SensitiveKeys[strings.ToLower(name)] {
+			path = strings.ReplaceAll(path, value, Mask)
+		}
+	}
+	return path
+}
+
+// Query masque les paramètres sensibles de la query string ("?token=...")
+func Query(rawQuery string) string {
+	if rawQuery == "" {
+		return ""
+	}
+	c := Current()
+	return string(c.redactForm([]byte(rawQuery), nil))
+}
+
+func (c Config) Body(method, path, contentType string, body []byte) []byte {
+	if len(body) == 0 {
+		return body
+	}
+
+	// Champs propres à la route
+	var paths [][]string
+	for _, rule := range c.Rules {
+		if !rule.matches(method, path) {
+			continue
+		}
+		if rule.DropBody {
+			return []byte(fmt.Sprintf("[REDACTED: body omitted, %d bytes]", len(body)))
+		}
+		for _, field := range rule.Fields {
+			paths = append(paths, splitPath(field))
+		}
+	}
+
+	mediaType, _, _ := mime.ParseMediaType(contentType)
+	trimmed := bytes.TrimSpace(body)
+
+	var redacted []byte
+	switch {
+	case strings.HasPrefix(mediaType, "multipart/"):
+		redacted = []byte(fmt.Sprintf("[multipart body omitted, %d bytes]", len(body)))
+	case mediaType == "application/x-www-form-urlencoded":
+		redacted = c.redactForm(body, paths)
+	case mediaType == "application/json" || strings.HasSuffix(mediaType, "+json") ||
+		bytes.HasPrefix(trimmed, []byte("{")) || bytes.HasPrefix(trimmed, []byte("[")):
+		redacted = c.redactJSON(body, paths)
+	case !utf8.Valid(body):
+		redacted = []byte(fmt.Sprintf("[binary body omitted, %d bytes]", len(body)))
+	default:
+		redacted = c.redactText(body)
+	}
+
+	return truncate(redacted, c.MaxBodyBytes)
+}
+
+func (c Config) Headers(header http.Header) map[string]string {
+	result := make(map[string]string, len(header))
+	for name, values := range header {
+		value := strings.Join(values, ", ")
+		if c.SensitiveHeaders[strings.ToLower(name)] {
+			// Le schéma d'authentification reste visible ("Bearer [REDACTED]")
+			if scheme, _, ok := strings.Cut(value, " "); ok && strings.EqualFold(name, "Authorization") {
+				value = scheme + " " + Mask
+			} else {
+				value = Mask
+			}
+		}
+		result[name] = value
+	}
+	return result
+}
+
+// ~ JSON ~
+
+// splitPath découpe "items[*].token" en ["items", "*", "token"]
+func splitPath(path string) []string {
+	path = strings.ReplaceAll(path, "[", ".")
+	path = strings.ReplaceAll(path, "]", "")
+	var segments []string
+	for _, segment := range strings.Split(strings.TrimPrefix(path, "$."), ".") {
+		if segment != "" {
+			segments = append(segments, segment)
+		}
+	}
+	return segments
+}
+
+func (c Config) redactJSON(body []byte, paths [][]string) []byte {
+	decoder := json.NewDecoder(bytes.NewReader(body))
+	decoder.UseNumber()
+
+	var value interface{}
+	if err := decoder.Decode(&value); err != nil || decoder.More() {
+		// Illisible : impossible de garantir qu'aucun secret n'y figure
+		return []byte(fmt.Sprintf("[REDACTED: unparseable JSON body, %d bytes]", len(body)))
+	}
+
+	for _, path := range paths {
+		value = maskPath(value, path)
+	}
+	value = c.maskKeys(value)
+
+	var buf bytes.Buffer
+	encoder := json.NewEncoder(&buf)
+	encoder.SetEscapeHTML(false)
+	if err := encoder.Encode(value); err != nil {
+		return []byte(fmt.Sprintf("[REDACTED: unparseable JSON body, %d bytes]", len(body)))
+	}
+	return bytes.TrimRight(buf.Bytes(), "\n")
+}
+
+// maskPath masque la valeur désignée par le chemin ; "*" couvre toutes les clés ou tous les éléments
+func maskPath(value interface{}, path []string) interface{} {
+	if len(path) == 0 {
+		return Mask
+	}
+	segment, rest := path[0], path[1:]
+
+	switch v := value.(type) {
+	case map[string]interface{}:
+		for key, child := range v {
+			if segment == "*" || key == segment {
+				v[key] = maskPath(child, rest)
+			}
+		}
+	case []interface{}:
+		for i, child := range v {
+			if segment == "*" || segment == strconv.Itoa(i) {
+				v[i] = maskPath(child, rest)
+			}
+		}
+	}
+	return value
+}
+
+// maskKeys masque les clés sensibles à n'importe quelle profondeur
+func (c Config) maskKeys(value interface{}) interface{} {
+	switch v := value.(type) {
+	case map[string]interface{}:
+		for key, child := range v {
+			if c.SensitiveKeys[strings.ToLower(key)] {
+				v[key] = Mask
+			} else {
+				v[key] = c.maskKeys(child)
+			}
+		}
+	case []interface{}:
+		for i, child := range v {
+			v[i] = c.maskKeys(child)
+		}
+	}
+	return value
+}
+
+// ~ Formulaires et texte ~
+
+func (c Config) redactForm(body []byte, paths [][]string) []byte {
+	values, err := url.ParseQuery(string(body))
+	if err != nil {
+		return []byte(fmt.Sprintf("[REDACTED: unparseable form body, %d bytes]", len(body)))
+	}
+
+	fields := map[string]bool{}
+	for _, path := range paths {
+		if len(path) == 1 {
+			fields[path[0]] = true
+		}
+	}
+
+	keys := make([]string, 0, len(values))
+	for key := range values {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		if fields[key] || c.SensitiveKeys[strings.ToLower(key)] {
+			values[key] = []string{Mask}
+		}
+	}
+	return []byte(values.Encode())
+}
+
+var textSecretPattern = regexp.MustCompile(`(?i)([a-z_]+)(\s*[:=]\s*)("[^"]*"|[^\s&,;]+)`)
+
+// redactText masque les paires "clé=valeur" / "clé: valeur" dont la clé est sensible
+func (c Config) redactText(body []byte) []byte {
+	return textSecretPattern.ReplaceAllFunc(body, func(match []byte) []byte {
+		parts := textSecretPattern.FindSubmatch(match)
+		if !c.SensitiveKeys[strings.ToLower(string(parts[1]))] {
+			return match
+		}
+		return append(append(append([]byte{}, parts[1]...), parts[2]...), Mask...)
+	})
+}
+
+// truncate plafonne la taille, sans couper un caractère UTF-8
+func truncate(body []byte, max int) []byte {
+	if max <= 0 || len(body) <= max {
+		return body
+	}
+	cut := max
+	for cut > 0 && !utf8.RuneStart(body[cut]) {
+		cut--
+	}
+	return append(body[:cut:cut], []byte(fmt.Sprintf("…[truncated %d bytes]", len(body)-cut))...)
+}
@@ -0,0 +1,245 @@
+package redaction_service
+
+import (
+	"net/http"
+	"strings"
+	"testing"
+)
+
+func testConfig() Config {
+	c := Current()
+	c.Rules = append(append([]Rule(nil), c.Rules...),
+		Rule{Method: "POST", Path: "/things", Fields: []string{"items[*].code", "meta.note"}},
+		Rule{Path: "/uploads/*", DropBody: true},
+	)
+	c.MaxBodyBytes = 128
+	return c
+}
+
+func TestBody(t *testing.T) {
+	c := testConfig()
+
+	tests := []struct {
+		name        string
+		method      string
+		path        string
+		contentType string
+		body        string
+		want        string
+	}{
+		{
+			name:        "route field",
+			method:      "POST",
+			path:        "/auth/login",
+			contentType: "application/json",
+			body:        `{"email":"jane@acme.com","password":"hunter2"}`,
+			want:        `{"email":"jane@acme.com","password":"[REDACTED]"}`,
+		},
+		{
+			name:        "sensitive keys at any depth, any case",
+			method:      "PUT",
+			path:        "/settings",
+			contentType: "application/json",
+			body:        `{"user":{"name":"jane","profile":{"API_KEY":"k"}},"items":[{"id":1,"Token":"t"}]}`,
+			want:        `{"items":[{"Token":"[REDACTED]","id":1}],"user":{"name":"jane","profile":{"API_KEY":"[REDACTED]"}}}`,
+		},
+		{
+			name:        "rule paths with wildcard and nesting",
+			method:      "POST",
+			path:        "/things",
+			contentType: "application/json",
+			body:        `{"items":[{"code":"a"},{"code":"b"}],"meta":{"note":"n","tag":"t"}}`,
+			want:        `{"items":[{"code":"[REDACTED]"},{"code":"[REDACTED]"}],"meta":{"note":"[REDACTED]","tag":"t"}}`,
+		},
+		{
+			name:        "rule of another method does not apply",
+			method:      "GET",
+			path:        "/things",
+			contentType: "application/json",
+			body:        `{"meta":{"note":"n"}}`,
+			want:        `{"meta":{"note":"n"}}`,
+		},
+		{
+			name:   "json detected without content type",
+			method: "POST",
+			path:   "/other",
+			body:   ` {"secret":"s"}`,
+			want:   `{"secret":"[REDACTED]"}`,
+		},
+		{
+			name:        "unparseable json",
+			method:      "POST",
+			path:        "/auth/login",
+			contentType: "application/json",
+			body:        `{"password":"hunter2"`,
+			want:        "[REDACTED: unparseable JSON body, 21 bytes]",
+		},
+		{
+			name:        "form body",
+			method:      "POST",
+			path:        "/auth/login",
+			contentType: "application/x-www-form-urlencoded",
+			body:        "username=jane&password=hunter2&client_secret=s",
+			want:        "client_secret=%5BREDACTED%5D&password=%5BREDACTED%5D&username=jane",
+		},
+		{
+			name:        "form body with rule field",
+			method:      "POST",
+			path:        "/things",
+			contentType: "application/x-www-form-urlencoded",
+			body:        "code=1&name=x",
+			want:        "code=1&name=x",
+		},
+		{
+			name:        "text body",
+			method:      "POST",
+			path:        "/other",
+			contentType: "text/plain",
+			body:        "token=abc other=1 password: \"p w\"",
+			want:        "token=[REDACTED] other=1 password: [REDACTED]",
+		},
+		{
+			name:        "drop body rule",
+			method:      "POST",
+			path:        "/uploads/avatar",
+			contentType: "application/json",
+			body:        `{"a":1}`,
+			want:        "[REDACTED: body omitted, 7 bytes]",
+		},
+		{
+			name:        "multipart body",
+			method:      "POST",
+			path:        "/other",
+			contentType: "multipart/form-data; boundary=x",
+			body:        "--x--",
+			want:        "[multipart body omitted, 5 bytes]",
+		},
+		{
+			name:        "binary body",
+			method:      "POST",
+			path:        "/other",
+			contentType: "application/octet-stream",
+			body:        "\xff\xfe\x00",
+			want:        "[binary body omitted, 3 bytes]",
+		},
+		{
+			name:        "truncated after redaction",
+			method:      "POST",
+			path:        "/other",
+			contentType: "text/plain",
+			body:        strings.Repeat("a", 134),
+			want:        strings.Repeat("a", 128) + "…[truncated 6 bytes]",
+		},
+		{
+			name:   "empty body",
+			method: "POST",
+			path:   "/auth/login",
+			want:   "",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := string(c.Body(tt.method, tt.path, tt.contentType, []byte(tt.body)))
+			if got != tt.want {
+				t.Fatalf("Body() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestHeaders(t *testing.T) {
+	c := testConfig()
+
+	tests := []struct {
+		name   string
+		header string
+		values []string
+		want   string
+	}{
+		{name: "bearer scheme kept", header: "Authorization", values: []string{"Bearer abc.def"}, want: "Bearer [REDACTED]"},
+		{name: "raw token", header: "Authorization", values: []string{"abc.def"}, want: Mask},
+		{name: "cookie", header: "Cookie", values: []string{"session=abc", "theme=dark"}, want: Mask},
+		{name: "api key", header: "X-Api-Key", values: []string{"k"}, want: Mask},
+		{name: "proxy authorization keeps no scheme", header: "Proxy-Authorization", values: []string{"Basic dXNlcg=="}, want: Mask},
+		{name: "regular header", header: "Content-Type", values: []string{"application/json"}, want: "application/json"},
+		{name: "multiple values joined", header: "Accept", values: []string{"text/html", "application/json"}, want: "text/html, application/json"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			header := http.Header{}
+			for _, value := range tt.values {
+				header.Add(tt.header, value)
+			}
+			got := c.Headers(header)
+			if got[http.CanonicalHeaderKey(tt.header)] != tt.want {
+				t.Fatalf("Headers()[%s] = %q, want %q", tt.header, got[http.CanonicalHeaderKey(tt.header)], tt.want)
+			}
+		})
+	}
+}
+
+func TestQuery(t *testing.T) {
+	tests := map[string]string{
+		"":                           "",
+		"page=2":                     "page=2",
+		"token=abc&page=2":           "page=2&token=%5BREDACTED%5D",
+		"Access_Token=abc&q=x":       "Access_Token=%5BREDACTED%5D&q=x",
+		"invitation_token=a&token=b": "invitation_token=%5BREDACTED%5D&token=%5BREDACTED%5D",
+		"token=%zz":                  "[REDACTED: unparseable form body, 9 bytes]",
+	}
+
+	for rawQuery, want := range tests {
+		if got := Query(rawQuery); got != want {
+			t.Errorf("Query(%q) = %q, want %q", rawQuery, got, want)
+		}
+	}
+}
+
+func TestEndpoint(t *testing.T) {
+	tests := []struct {
+		name string
+		path string
+		vars map[string]string
+		want string
+	}{
+		{
+			name: "token variable",
+			path: "/invitations/abc123/accept",
+			vars: map[string]string{"token": "abc123"},
+			want: "/invitations/[REDACTED]/accept",
+		},
+		{
+			name: "variable name is case insensitive",
+			path: "/auth/verify/xyz",
+			vars: map[string]string{"Token": "xyz"},
+			want: "/auth/verify/[REDACTED]",
+		},
+		{
+			name: "non sensitive variable",
+			path: "/users/42",
+			vars: map[string]string{"id": "42"},
+			want: "/users/42",
+		},
+		{
+			name: "empty value",
+			path: "/invitations//accept",
+			vars: map[string]string{"token": ""},
+			want: "/invitations//accept",
+		},
+		{
+			name: "no route variables",
+			path: "/health",
+			want: "/health",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := Endpoint(tt.path, tt.vars); got != tt.want {
+				t.Fatalf("Endpoint() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
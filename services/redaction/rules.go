@@ -0,0 +1,127 @@
+package redaction_service
+
+import (
+	"encoding/json"
+	"gox/utils"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// Rule : champs à masquer pour une route. Path est le modèle de route de mux ("/users/{id}"),
+// un "*" final couvre tous les sous-chemins. Method vide = toutes les méthodes.
+// Fields contient des chemins JSON : "password", "user.password", "items[*].token", "*.secret".
+// DropBody supprime entièrement le corps.
+type Rule struct {
+	Method   string   `json:"method"`
+	Path     string   `json:"path"`
+	Fields   []string `json:"fields"`
+	DropBody bool     `json:"drop_body"`
+}
+
+func (r Rule) matches(method, path string) bool {
+	if r.Method != "" && !strings.EqualFold(r.Method, method) {
+		return false
+	}
+	if prefix, ok := strings.CutSuffix(r.Path, "*"); ok {
+		return strings.HasPrefix(path, prefix)
+	}
+	return r.Path == path
+}
+
+// Règles par défaut : routes qui reçoivent des identifiants
+var defaultRules = []Rule{
+	{Method: "POST", Path: "/auth/login", Fields: []string{"password"}},
+	{Method: "POST", Path: "/auth/register", Fields: []string{"password", "invitation_token"}},
+	{Method: "POST", Path: "/administrate/login", Fields: []string{"password"}},
+	{Method: "POST", Path: "/auth/password/reset", Fields: []string{"password", "token"}},
+	{Method: "POST", Path: "/auth/email/verify", Fields: []string{"token"}},
+	{Method: "POST", Path: "/users", Fields: []string{"password"}},
+	{Method: "PATCH", Path: "/users/{id}", Fields: []string{"password"}},
+	{Path: "/scim/v2/Users*", Fields: []string{"password"}},
+}
+
+// Clés masquées à n'importe quelle profondeur, quelle que soit la route (comparaison insensible à la casse)
+var defaultSensitiveKeys = []string{
+	"password", "new_password", "old_password", "current_password", "password_confirmation",
+	"token", "access_token", "refresh_token", "id_token", "invitation_token", "verification_token",
+	"secret", "client_secret", "api_key", "apikey", "private_key",
+	"authorization", "credit_card", "card_number", "cvc", "cvv", "iban",
+}
+
+// En-têtes masqués
+var defaultSensitiveHeaders = []string{
+	"Authorization", "Proxy-Authorization", "Cookie", "Set-Cookie", "X-Api-Key", "X-Auth-Token", "X-Csrf-Token",
+}
+
+// Config : règles effectives, défauts complétés par la configuration
+type Config struct {
+	Rules            []Rule
+	SensitiveKeys    map[string]bool
+	SensitiveHeaders map[string]bool
+	MaxBodyBytes     int
+	MaxCaptureBytes  int
+}
+
+var (
+	config     Config
+	configOnce sync.Once
+)
+
+// Current retourne la configuration, lue une fois depuis l'environnement :
+//   - LOG_REDACTION_RULES : règles supplémentaires, tableau JSON de Rule
+//   - LOG_REDACT_KEYS : clés sensibles supplémentaires, séparées par des virgules
+//   - LOG_REDACT_HEADERS : en-têtes sensibles supplémentaires, séparés par des virgules
+//   - LOG_MAX_BODY_BYTES : taille maximum d'un corps journalisé (16 Kio par défaut)
+//   - LOG_MAX_CAPTURE_BYTES : au-delà de cette taille (1 Mio par défaut), le corps n'est pas lu pour être journalisé
+func Current() Config {
+	configOnce.Do(func() {
+		config = Config{
+			Rules:            append([]Rule(nil), defaultRules...),
+			SensitiveKeys:    map[string]bool{},
+			SensitiveHeaders: map[string]bool{},
+			MaxBodyBytes:     16 << 10,
+			MaxCaptureBytes:  1 << 20,
+		}
+
+		if raw := utils.GetEnv("LOG_REDACTION_RULES", ""); raw != "" {
+			var rules []Rule
+			if err := json.Unmarshal([]byte(raw), &rules); err != nil {
+				utils.ConsoleLog("⚠️ Invalid LOG_REDACTION_RULES, ignored: %v", err)
+			} else {
+				config.Rules = append(config.Rules, rules...)
+			}
+		}
+
+		for _, key := range append(defaultSensitiveKeys, splitList(utils.GetEnv("LOG_REDACT_KEYS", ""))...) {
+			config.SensitiveKeys[strings.ToLower(key)] = true
+		}
+		for _, header := range append(defaultSensitiveHeaders, splitList(utils.GetEnv("LOG_REDACT_HEADERS", ""))...) {
+			config.SensitiveHeaders[strings.ToLower(header)] = true
+		}
+
+		if size, err := strconv.Atoi(utils.GetEnv("LOG_MAX_BODY_BYTES", "")); err == nil && size >= 0 {
+			config.MaxBodyBytes = size
+		}
+		if size, err := strconv.Atoi(utils.GetEnv("LOG_MAX_CAPTURE_BYTES", "")); err == nil && size >= 0 {
+			config.MaxCaptureBytes = size
+		}
+	})
+	return config
+}
+
+// SetConfig remplace la configuration (ex : pour vérifier un jeu de règles)
+func SetConfig(c Config) {
+	configOnce.Do(func() {})
+	config = c
+}
+
+func splitList(value string) []string {
+	var items []string
+	for _, item := range strings.Split(value, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
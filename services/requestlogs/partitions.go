@@ -0,0 +1,308 @@
+package request_log_service
+
+import (
+	"fmt"
+	"gox/database"
+	"gox/database/models"
+	"gox/utils"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+const (
+	tableName        = "request_logs"
+	legacyTableName  = "request_logs_unpartitioned"
+	partitionPrefix  = "request_logs_p"
+	defaultPartition = "request_logs_default"
+	partitionLayout  = "20060102"
+)
+
+// Partition : une partition journalière de request_logs (ou la partition par défaut)
+type Partition struct {
+	Name  string     `json:"name"`
+	From  *time.Time `json:"from"` // nil pour la partition par défaut
+	To    *time.Time `json:"to"`
+	Rows  int64      `json:"rows"` // estimation (statistiques PostgreSQL)
+	Bytes int64      `json:"bytes"`
+}
+
+func dayOf(t time.Time) time.Time {
+	t = t.UTC()
+	return time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, time.UTC)
+}
+
+func partitionName(day time.Time) string {
+	return partitionPrefix + day.Format(partitionLayout)
+}
+
+// PremakeDays : nombre de partitions créées à l'avance, configurable via LOG_PARTITION_PREMAKE_DAYS
+func PremakeDays() int {
+	return envInt("LOG_PARTITION_PREMAKE_DAYS", 7)
+}
+
+// Migrate crée request_logs comme table partitionnée par jour, convertit une ancienne table non
+// partitionnée (les logs sont recopiés par CopyLegacyLogs), ajoute les colonnes manquantes et crée les prochaines partitions
+func Migrate() error {
+	db := database.DB
+
+	if !db.Migrator().HasTable(&models.RequestLog{}) {
+		utils.ConsoleLog("📜 Creating partitioned table %s", tableName)
+		if err := db.Transaction(createTable); err != nil {
+			return fmt.Errorf("creating %s: %w", tableName, err)
+		}
+	} else {
+		partitioned, err := isPartitioned(db)
+		if err != nil {
+			return err
+		}
+		if !partitioned {
+			utils.ConsoleLog("📜 Converting %s to a partitioned table, existing logs are copied in the background", tableName)
+			if err := db.Transaction(convertTable); err != nil {
+				return fmt.Errorf("converting %s: %w", tableName, err)
+			}
+		}
+	}
+
+	if err := db.AutoMigrate(&models.RequestLog{}); err != nil {
+		return err
+	}
+	if err := dropSupersededIndexes(db); err != nil {
+		return err
+	}
+
+	return EnsurePartitions()
+}
+
+// Index d'une seule colonne remplacés par les index (colonne, id) : ces derniers servent aussi les
+// recherches par égalité sur la colonne seule
+var supersededIndexes = []string{
+	"idx_request_logs_user_id",
+	"idx_request_logs_domain",
+	"idx_request_logs_endpoint",
+	"idx_request_logs_route",
+	"idx_request_logs_status",
+	"idx_request_logs_request_id",
+	"idx_request_logs_client_ip",
+}
+
+func dropSupersededIndexes(tx *gorm.DB) error {
+	for _, index := range supersededIndexes {
+		if err := tx.Exec("DROP INDEX IF EXISTS ?", clause.Table{Name: index}).Error; err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func isPartitioned(tx *gorm.DB) (bool, error) {
+	var count int64
+	err := tx.Raw("SELECT count(*) FROM pg_partitioned_table WHERE partrelid = to_regclass(?)", tableName).Scan(&count).Error
+	return count > 0, err
+}
+
+// createTable crée la table parente et la partition par défaut, qui reçoit les logs hors des partitions
+// journalières
+func createTable(tx *gorm.DB) error {
+	if err := tx.Set("gorm:table_options", ` PARTITION BY RANGE ("timestamp")`).Migrator().CreateTable(&models.RequestLog{}); err != nil {
+		return err
+	}
+	return tx.Exec("CREATE TABLE IF NOT EXISTS ? PARTITION OF ? DEFAULT", clause.Table{Name: defaultPartition}, clause.Table{Name: tableName}).Error
+}
+
+// createPartition crée la partition d'un jour (UTC). Les bornes sont écrites dans la requête : PostgreSQL
+// n'accepte pas de paramètres dans un CREATE TABLE.
+func createPartition(tx *gorm.DB, day time.Time) error {
+	const bound = "2006-01-02 15:04:05-07"
+	return tx.Exec(fmt.Sprintf("CREATE TABLE IF NOT EXISTS ? PARTITION OF ? FOR VALUES FROM ('%s') TO ('%s')",
+		day.Format(bound), day.AddDate(0, 0, 1).Format(bound)),
+		clause.Table{Name: partitionName(day)}, clause.Table{Name: tableName}).Error
+}
+
+// convertTable remplace une table request_logs non partitionnée : l'ancienne table est renommée (ses index
+// et sa séquence aussi, les noms devant rester libres) et la table partitionnée est créée. Les logs sont
+// ensuite recopiés par lots en tâche de fond (CopyLegacyLogs), le démarrage n'attend pas la copie.
+func convertTable(tx *gorm.DB) error {
+	if err := tx.Exec("ALTER TABLE ? RENAME TO ?", clause.Table{Name: tableName}, clause.Table{Name: legacyTableName}).Error; err != nil {
+		return err
+	}
+
+	// La clé primaire est conservée (renommée) : la copie parcourt l'ancienne table dans l'ordre des IDs
+	var hasPrimaryKey int64
+	if err := tx.Raw("SELECT count(*) FROM pg_constraint WHERE conrelid = to_regclass(?) AND conname = ?",
+		legacyTableName, tableName+"_pkey").Scan(&hasPrimaryKey).Error; err != nil {
+		return err
+	}
+	if hasPrimaryKey > 0 {
+		if err := tx.Exec("ALTER TABLE ? RENAME CONSTRAINT ? TO ?", clause.Table{Name: legacyTableName},
+			clause.Column{Name: tableName + "_pkey"}, clause.Column{Name: legacyTableName + "_pkey"}).Error; err != nil {
+			return err
+		}
+	}
+
+	var indexes []string
+	if err := tx.Raw("SELECT indexname FROM pg_indexes WHERE schemaname = current_schema() AND tablename = ? AND indexname <> ?",
+		legacyTableName, legacyTableName+"_pkey").Scan(&indexes).Error; err != nil {
+		return err
+	}
+	for _, index := range indexes {
+		if err := tx.Exec("DROP INDEX IF EXISTS ?", clause.Table{Name: index}).Error; err != nil {
+			return err
+		}
+	}
+	if err := tx.Exec("ALTER SEQUENCE IF EXISTS ? RENAME TO ?", clause.Table{Name: tableName + "_id_seq"}, clause.Table{Name: legacyTableName + "_id_seq"}).Error; err != nil {
+		return err
+	}
+
+	if err := createTable(tx); err != nil {
+		return err
+	}
+
+	// Une partition par jour présent dans l'ancienne table
+	var bounds struct {
+		First *time.Time
+		Last  *time.Time
+		MaxID *uint
+	}
+	if err := tx.Raw(`SELECT MIN("timestamp") AS first, MAX("timestamp") AS last, MAX(id) AS max_id FROM ?`, clause.Table{Name: legacyTableName}).Scan(&bounds).Error; err != nil {
+		return err
+	}
+	if bounds.First != nil && bounds.Last != nil {
+		for day := dayOf(*bounds.First); !day.After(*bounds.Last); day = day.AddDate(0, 0, 1) {
+			if err := createPartition(tx, day); err != nil {
+				return err
+			}
+		}
+	}
+
+	// Les nouveaux logs prennent des IDs supérieurs à ceux de l'ancienne table, avant même la copie
+	var maxID uint
+	if bounds.MaxID != nil {
+		maxID = *bounds.MaxID
+	}
+	return tx.Exec("SELECT setval(pg_get_serial_sequence(?, 'id'), ?, false)", tableName, maxID+1).Error
+}
+
+// LegacyCopyBatchSize : nombre de logs déplacés par transaction, configurable via LOG_LEGACY_COPY_BATCH_SIZE
+func LegacyCopyBatchSize() int {
+	return envInt("LOG_LEGACY_COPY_BATCH_SIZE", 10000)
+}
+
+// CopyLegacyLogs déplace les logs de l'ancienne table non partitionnée vers request_logs, par lots : chaque
+// lot est supprimé de l'ancienne table et inséré dans la même transaction, la copie reprend donc là où elle
+// s'est arrêtée. L'ancienne table est supprimée une fois vide. Sans ancienne table, rien n'est fait.
+func CopyLegacyLogs() error {
+	db := database.DB
+
+	var exists bool
+	if err := db.Raw("SELECT to_regclass(?) IS NOT NULL", legacyTableName).Scan(&exists).Error; err != nil {
+		return err
+	}
+	if !exists {
+		return nil
+	}
+
+	columns, err := commonColumns(db)
+	if err != nil {
+		return err
+	}
+
+	var moved int64
+	for {
+		// SKIP LOCKED : plusieurs replicas peuvent copier en même temps sans se bloquer
+		result := db.Exec(fmt.Sprintf(`WITH batch AS (
+				DELETE FROM ? WHERE id IN (SELECT id FROM ? ORDER BY id LIMIT ? FOR UPDATE SKIP LOCKED)
+				RETURNING %s
+			)
+			INSERT INTO ? (%s) SELECT %s FROM batch`, columns, columns, columns),
+			clause.Table{Name: legacyTableName}, clause.Table{Name: legacyTableName}, LegacyCopyBatchSize(), clause.Table{Name: tableName})
+		if result.Error != nil {
+			return fmt.Errorf("copying %s: %w (%d logs moved)", legacyTableName, result.Error, moved)
+		}
+		if result.RowsAffected == 0 {
+			break
+		}
+		moved += result.RowsAffected
+	}
+
+	var remaining int64
+	if err := db.Raw("SELECT count(*) FROM (SELECT 1 FROM ? LIMIT 1) AS rest", clause.Table{Name: legacyTableName}).Scan(&remaining).Error; err != nil {
+		return err
+	}
+	if remaining > 0 {
+		// Lots encore verrouillés par un autre replica
+		return nil
+	}
+
+	utils.ConsoleLog("📜 %d logs copied from %s, dropping it", moved, legacyTableName)
+	return db.Exec("DROP TABLE IF EXISTS ?", clause.Table{Name: legacyTableName}).Error
+}
+
+// commonColumns retourne la liste, quotée, des colonnes présentes dans les deux tables
+func commonColumns(tx *gorm.DB) (string, error) {
+	var names []string
+	err := tx.Raw(`SELECT column_name FROM information_schema.columns
+		WHERE table_schema = current_schema() AND table_name = ?
+		AND column_name IN (SELECT column_name FROM information_schema.columns WHERE table_schema = current_schema() AND table_name = ?)
+		ORDER BY ordinal_position`, legacyTableName, tableName).Scan(&names).Error
+	if err != nil {
+		return "", err
+	}
+
+	columns := make([]string, len(names))
+	for i, name := range names {
+		columns[i] = tx.Statement.Quote(clause.Column{Name: name})
+	}
+	return strings.Join(columns, ", "), nil
+}
+
+// EnsurePartitions crée les partitions du jour et des PremakeDays jours suivants.
+// Une partition ne peut pas être créée si la partition par défaut contient déjà des logs de ce jour :
+// d'où la création à l'avance.
+func EnsurePartitions() error {
+	today := dayOf(time.Now())
+	for i := 0; i <= PremakeDays(); i++ {
+		if err := createPartition(database.DB, today.AddDate(0, 0, i)); err != nil {
+			return fmt.Errorf("creating partition %s: %w", partitionName(today.AddDate(0, 0, i)), err)
+		}
+	}
+	return nil
+}
+
+// GetPartitions liste les partitions, de la plus ancienne à la plus récente
+func GetPartitions() ([]Partition, error) {
+	var rows []struct {
+		Name  string
+		Rows  int64
+		Bytes int64
+	}
+	err := database.DB.Raw(`SELECT c.relname AS name, GREATEST(c.reltuples, 0)::bigint AS rows, pg_total_relation_size(c.oid) AS bytes
+		FROM pg_inherits i JOIN pg_class c ON c.oid = i.inhrelid
+		WHERE i.inhparent = to_regclass(?)
+		ORDER BY c.relname`, tableName).Scan(&rows).Error
+	if err != nil {
+		return nil, err
+	}
+
+	partitions := make([]Partition, 0, len(rows))
+	for _, row := range rows {
+		partition := Partition{Name: row.Name, Rows: row.Rows, Bytes: row.Bytes}
+		if day, ok := partitionDay(row.Name); ok {
+			to := day.AddDate(0, 0, 1)
+			partition.From, partition.To = &day, &to
+		}
+		partitions = append(partitions, partition)
+	}
+	return partitions, nil
+}
+
+func partitionDay(name string) (time.Time, bool) {
+	suffix, ok := strings.CutPrefix(name, partitionPrefix)
+	if !ok {
+		return time.Time{}, false
+	}
+	day, err := time.ParseInLocation(partitionLayout, suffix, time.UTC)
+	return day, err == nil
+}
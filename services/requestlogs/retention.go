@@ -0,0 +1,513 @@
+package request_log_service
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"gox/database"
+	"gox/database/models"
+	storage_service "gox/services/storage"
+	"gox/utils"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+const readBatchSize = 1000
+
+var ErrPurgeRunning = errors.New("a request log purge is already running")
+
+// RetentionRule : durée de conservation des logs d'un endpoint et/ou d'une classe de statuts.
+// Endpoint est un chemin ou un modèle de route ("/users/{id}"), un "*" final couvre tous les sous-chemins.
+// Status est une classe ("4xx") ou un statut exact ("404"). Un critère vide correspond à tous les logs.
+// La première règle qui correspond s'applique.
+type RetentionRule struct {
+	Endpoint string `json:"endpoint,omitempty"`
+	Status   string `json:"status,omitempty"`
+	Days     int    `json:"days"`
+}
+
+func (r RetentionRule) matches(log models.RequestLog) bool {
+	if r.Endpoint != "" {
+		if prefix, ok := strings.CutSuffix(r.Endpoint, "*"); ok {
+			if !strings.HasPrefix(log.Endpoint, prefix) && !strings.HasPrefix(log.Route, prefix) {
+				return false
+			}
+		} else if log.Endpoint != r.Endpoint && log.Route != r.Endpoint {
+			return false
+		}
+	}
+	if r.Status != "" {
+		status := strings.ToLower(r.Status)
+		if class, ok := strings.CutSuffix(status, "xx"); ok {
+			if strconv.Itoa(log.Status/100) != class {
+				return false
+			}
+		} else if strconv.Itoa(log.Status) != status {
+			return false
+		}
+	}
+	return true
+}
+
+// RetentionRules : règles de LOG_RETENTION_RULES (tableau JSON de RetentionRule), puis règles par défaut :
+// 5xx gardés 180 jours, 4xx 90 jours, le reste LOG_RETENTION_DAYS jours (30 par défaut)
+func RetentionRules() []RetentionRule {
+	var rules []RetentionRule
+	if raw := utils.GetEnv("LOG_RETENTION_RULES", ""); raw != "" {
+		var configured []RetentionRule
+		if err := json.Unmarshal([]byte(raw), &configured); err != nil {
+			utils.ConsoleLog("⚠️ Invalid LOG_RETENTION_RULES, ignored: %v", err)
+		}
+		for _, rule := range configured {
+			if rule.Days > 0 {
+				rules = append(rules, rule)
+			}
+		}
+	}
+
+	return append(rules,
+		RetentionRule{Status: "5xx", Days: 180},
+		RetentionRule{Status: "4xx", Days: 90},
+		RetentionRule{Days: envInt("LOG_RETENTION_DAYS", 30)},
+	)
+}
+
+func retentionDays(rules []RetentionRule, log models.RequestLog) int {
+	for _, rule := range rules {
+		if rule.matches(log) {
+			return rule.Days
+		}
+	}
+	return rules[len(rules)-1].Days
+}
+
+// retentionBounds retourne la plus courte et la plus longue durée de conservation, en jours
+func retentionBounds(rules []RetentionRule) (int, int) {
+	shortest, longest := rules[0].Days, rules[0].Days
+	for _, rule := range rules {
+		shortest = min(shortest, rule.Days)
+		longest = max(longest, rule.Days)
+	}
+	return shortest, longest
+}
+
+// RetentionInterval : intervalle entre deux passages de la rétention, configurable via LOG_RETENTION_INTERVAL_HOURS
+func RetentionInterval() time.Duration {
+	return time.Duration(envInt("LOG_RETENTION_INTERVAL_HOURS", 24)) * time.Hour
+}
+
+// RetentionReport : bilan d'un passage de la rétention
+type RetentionReport struct {
+	StartedAt         time.Time  `json:"started_at"`
+	FinishedAt        *time.Time `json:"finished_at"`
+	Manual            bool       `json:"manual"`
+	PartitionsDropped []string   `json:"partitions_dropped"`
+	RowsArchived      int64      `json:"rows_archived"`
+	RowsDeleted       int64      `json:"rows_deleted"`
+	Archives          int        `json:"archives"`
+	Errors            []string   `json:"errors,omitempty"`
+}
+
+var (
+	retentionMu sync.Mutex
+	running     bool
+	lastReport  *RetentionReport
+)
+
+func begin() bool {
+	retentionMu.Lock()
+	defer retentionMu.Unlock()
+	if running {
+		return false
+	}
+	running = true
+	return true
+}
+
+func end(report RetentionReport) {
+	retentionMu.Lock()
+	defer retentionMu.Unlock()
+	running = false
+	lastReport = &report
+}
+
+// RunRetention archive puis supprime les logs expirés (tâche de fond)
+func RunRetention() error {
+	if !begin() {
+		return ErrPurgeRunning
+	}
+	report := runRetention(false)
+	if len(report.Errors) > 0 {
+		return errors.New(strings.Join(report.Errors, "; "))
+	}
+	return nil
+}
+
+// StartPurge lance un passage de la rétention en tâche de fond
+func StartPurge() error {
+	if !begin() {
+		return ErrPurgeRunning
+	}
+	go runRetention(true)
+	return nil
+}
+
+func runRetention(manual bool) RetentionReport {
+	report := RetentionReport{StartedAt: time.Now(), Manual: manual, PartitionsDropped: []string{}}
+	defer func() {
+		finished := time.Now()
+		report.FinishedAt = &finished
+		end(report)
+	}()
+	fail := func(err error) {
+		report.Errors = append(report.Errors, err.Error())
+	}
+
+	// Les prochaines partitions sont créées à chaque passage
+	if err := EnsurePartitions(); err != nil {
+		fail(err)
+	}
+
+	storage, err := storage_service.Default()
+	if err != nil {
+		fail(err)
+		return report
+	}
+	partitions, err := GetPartitions()
+	if err != nil {
+		fail(err)
+		return report
+	}
+
+	rules := RetentionRules()
+	minDays, maxDays := retentionBounds(rules)
+	now := time.Now()
+
+	for _, partition := range partitions {
+		switch {
+		// Tous les logs de la partition ont expiré : archivage complet puis suppression de la partition
+		case partition.To != nil && !partition.To.After(now.AddDate(0, 0, -maxDays)):
+			if err := archivePartition(storage, partition, &report); err != nil {
+				fail(fmt.Errorf("%s: %w", partition.Name, err))
+			}
+		// Une partie des logs a pu expirer : archivage et suppression ligne par ligne
+		case partition.From == nil || partition.From.Before(now.AddDate(0, 0, -minDays)):
+			if err := purgeRows(storage, partition, rules, now, &report); err != nil {
+				fail(fmt.Errorf("%s: %w", partition.Name, err))
+			}
+		}
+	}
+
+	utils.ConsoleLog("📜 Request log retention: %d partitions dropped, %d rows archived, %d deleted",
+		len(report.PartitionsDropped), report.RowsArchived, report.RowsDeleted)
+	return report
+}
+
+// eachLog parcourt les logs d'une partition par lots, dans l'ordre des IDs
+func eachLog(partition string, fn func(models.RequestLog) error) error {
+	var lastID uint
+	for {
+		var logs []models.RequestLog
+		if err := database.DB.Table(partition).Where("id > ?", lastID).Order("id").Limit(readBatchSize).Find(&logs).Error; err != nil {
+			return err
+		}
+		for _, log := range logs {
+			if err := fn(log); err != nil {
+				return err
+			}
+		}
+		if len(logs) < readBatchSize {
+			return nil
+		}
+		lastID = logs[len(logs)-1].ID
+	}
+}
+
+func archivePartition(storage storage_service.Storage, partition Partition, report *RetentionReport) error {
+	a := newArchiver(storage, partition.Name, report, nil)
+	if err := eachLog(partition.Name, a.add); err != nil {
+		return err
+	}
+	if err := a.flush(); err != nil {
+		return err
+	}
+
+	// Suppression de la partition, une fois tous ses logs archivés
+	if err := database.DB.Exec("DROP TABLE ?", clause.Table{Name: partition.Name}).Error; err != nil {
+		return err
+	}
+	report.PartitionsDropped = append(report.PartitionsDropped, partition.Name)
+	report.RowsDeleted += a.total
+	return nil
+}
+
+func purgeRows(storage storage_service.Storage, partition Partition, rules []RetentionRule, now time.Time, report *RetentionReport) error {
+	// Les logs sont supprimés dans la transaction qui enregistre leur archive : si la suppression échoue,
+	// l'archive n'est pas comptée et le prochain passage réécrit le même fichier
+	a := newArchiver(storage, partition.Name, report, func(tx *gorm.DB, ids []uint) (int64, error) {
+		var deleted int64
+		for start := 0; start < len(ids); start += readBatchSize {
+			batch := ids[start:min(start+readBatchSize, len(ids))]
+			result := tx.Exec("DELETE FROM ? WHERE id IN ?", clause.Table{Name: partition.Name}, batch)
+			if result.Error != nil {
+				return 0, result.Error
+			}
+			deleted += result.RowsAffected
+		}
+		return deleted, nil
+	})
+
+	err := eachLog(partition.Name, func(log models.RequestLog) error {
+		if log.Timestamp.Before(now.AddDate(0, 0, -retentionDays(rules, log))) {
+			return a.add(log)
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	return a.flush()
+}
+
+// ~ Archives NDJSON compressées ~
+
+// ArchiveChunkRows : nombre maximum de logs par fichier d'archive, configurable via LOG_ARCHIVE_CHUNK_ROWS
+func ArchiveChunkRows() int {
+	return envInt("LOG_ARCHIVE_CHUNK_ROWS", 50000)
+}
+
+// archiveRecord : une ligne NDJSON. Le corps reste tel que stocké (base64, déjà expurgé).
+type archiveRecord struct {
+	ID            uint              `json:"id"`
+	RequestID     string            `json:"request_id,omitempty"`
+	UserID        *uuid.UUID        `json:"user_id"`
+	Domain        string            `json:"domain"`
+	Method        string            `json:"method"`
+	Endpoint      string            `json:"endpoint"`
+	Route         string            `json:"route,omitempty"`
+	Query         string            `json:"query,omitempty"`
+	Status        int               `json:"status"`
+	Timestamp     time.Time         `json:"timestamp"`
+	DurationMs    float64           `json:"duration_ms"`
+	ResponseBytes int64             `json:"response_bytes"`
+	ClientIP      string            `json:"client_ip,omitempty"`
+	UserAgent     string            `json:"user_agent,omitempty"`
+	Headers       map[string]string `json:"headers,omitempty"`
+	Content       string            `json:"content_base64,omitempty"`
+}
+
+type archiver struct {
+	storage   storage_service.Storage
+	partition string
+	onFlush   func(tx *gorm.DB, ids []uint) (int64, error)
+	report    *RetentionReport
+
+	total int64
+	buf   bytes.Buffer
+	gz    *gzip.Writer
+	ids   []uint
+	first time.Time
+	last  time.Time
+}
+
+func newArchiver(storage storage_service.Storage, partition string, report *RetentionReport, onFlush func(*gorm.DB, []uint) (int64, error)) *archiver {
+	return &archiver{
+		storage:   storage,
+		partition: partition,
+		onFlush:   onFlush,
+		report:    report,
+	}
+}
+
+// archiveKey : la clé dépend uniquement des logs archivés (partition, premier et dernier ID). Archiver à
+// nouveau les mêmes logs, après un échec, remplace le fichier au lieu d'en créer un second.
+func archiveKey(partition string, firstID, lastID uint) string {
+	return fmt.Sprintf("request-logs/%s/%012d-%012d.ndjson.gz", partition, firstID, lastID)
+}
+
+func (a *archiver) add(log models.RequestLog) error {
+	if a.gz == nil {
+		a.buf.Reset()
+		a.gz = gzip.NewWriter(&a.buf)
+		a.ids = a.ids[:0]
+		a.first, a.last = log.Timestamp, log.Timestamp
+	}
+
+	line, err := json.Marshal(archiveRecord{
+		ID:            log.ID,
+		RequestID:     log.RequestID,
+		UserID:        log.UserID,
+		Domain:        log.Domain,
+		Method:        log.Method,
+		Endpoint:      log.Endpoint,
+		Route:         log.Route,
+		Query:         log.Query,
+		Status:        log.Status,
+		Timestamp:     log.Timestamp,
+		DurationMs:    float64(log.Duration) / float64(time.Millisecond),
+		ResponseBytes: log.ResponseBytes,
+		ClientIP:      log.ClientIP,
+		UserAgent:     log.UserAgent,
+		Headers:       log.Headers,
+		Content:       log.Content,
+	})
+	if err != nil {
+		return err
+	}
+	if _, err := a.gz.Write(append(line, '\n')); err != nil {
+		return err
+	}
+
+	a.ids = append(a.ids, log.ID)
+	if log.Timestamp.Before(a.first) {
+		a.first = log.Timestamp
+	}
+	if log.Timestamp.After(a.last) {
+		a.last = log.Timestamp
+	}
+
+	if len(a.ids) >= ArchiveChunkRows() {
+		return a.flush()
+	}
+	return nil
+}
+
+// flush enregistre le fichier en cours dans le stockage, puis, dans une même transaction, l'archive et
+// onFlush (qui retourne le nombre de logs supprimés)
+func (a *archiver) flush() error {
+	if a.gz == nil || len(a.ids) == 0 {
+		return nil
+	}
+	if err := a.gz.Close(); err != nil {
+		return err
+	}
+	a.gz = nil
+
+	// Les IDs sont ajoutés dans l'ordre (eachLog)
+	key := archiveKey(a.partition, a.ids[0], a.ids[len(a.ids)-1])
+	if err := a.storage.Put(key, a.buf.Bytes(), "application/gzip"); err != nil {
+		return err
+	}
+
+	archive := models.RequestLogArchive{
+		Partition:  a.partition,
+		StorageKey: key,
+		Rows:       int64(len(a.ids)),
+		SizeBytes:  int64(a.buf.Len()),
+		FirstAt:    a.first,
+		LastAt:     a.last,
+	}
+	var deleted int64
+	err := database.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Clauses(clause.OnConflict{
+			Columns:   []clause.Column{{Name: "storage_key"}},
+			DoUpdates: clause.AssignmentColumns([]string{"rows", "size_bytes", "first_at", "last_at"}),
+		}).Create(&archive).Error; err != nil {
+			return err
+		}
+		if a.onFlush != nil {
+			var err error
+			deleted, err = a.onFlush(tx, a.ids)
+			return err
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	a.report.RowsDeleted += deleted
+	a.total += int64(len(a.ids))
+	a.report.RowsArchived += int64(len(a.ids))
+	a.report.Archives++
+	return nil
+}
+
+// ~ État de la rétention ~
+
+type PartitionRetention struct {
+	Partition
+	DropAt *time.Time `json:"drop_at,omitempty"` // date à partir de laquelle la partition est archivée puis supprimée
+}
+
+type Archive struct {
+	ID         uuid.UUID `json:"id"`
+	Partition  string    `json:"partition"`
+	StorageKey string    `json:"storage_key"`
+	Rows       int64     `json:"rows"`
+	SizeBytes  int64     `json:"size_bytes"`
+	FirstAt    time.Time `json:"first_at"`
+	LastAt     time.Time `json:"last_at"`
+	CreatedAt  time.Time `json:"created_at"`
+}
+
+type RetentionStatus struct {
+	Rules            []RetentionRule      `json:"rules"`
+	MaxRetentionDays int                  `json:"max_retention_days"`
+	Interval         string               `json:"interval"`
+	Partitions       []PartitionRetention `json:"partitions"`
+	Running          bool                 `json:"running"`
+	LastRun          *RetentionReport     `json:"last_run"`
+	Archives         []Archive            `json:"recent_archives"`
+}
+
+// GetRetentionStatus : règles, partitions, dernier passage et dernières archives
+func GetRetentionStatus() (RetentionStatus, error) {
+	rules := RetentionRules()
+	_, maxDays := retentionBounds(rules)
+
+	partitions, err := GetPartitions()
+	if err != nil {
+		return RetentionStatus{}, err
+	}
+
+	var archives []models.RequestLogArchive
+	if err := database.DB.Order("created_at DESC").Limit(20).Find(&archives).Error; err != nil {
+		return RetentionStatus{}, err
+	}
+
+	status := RetentionStatus{
+		Rules:            rules,
+		MaxRetentionDays: maxDays,
+		Interval:         RetentionInterval().String(),
+		Partitions:       make([]PartitionRetention, len(partitions)),
+		Archives:         make([]Archive, len(archives)),
+	}
+	for i, partition := range partitions {
+		status.Partitions[i] = PartitionRetention{Partition: partition}
+		if partition.To != nil {
+			dropAt := partition.To.AddDate(0, 0, maxDays)
+			status.Partitions[i].DropAt = &dropAt
+		}
+	}
+	for i, archive := range archives {
+		status.Archives[i] = Archive{
+			ID:         archive.ID,
+			Partition:  archive.Partition,
+			StorageKey: archive.StorageKey,
+			Rows:       archive.Rows,
+			SizeBytes:  archive.SizeBytes,
+			FirstAt:    archive.FirstAt,
+			LastAt:     archive.LastAt,
+			CreatedAt:  archive.CreatedAt,
+		}
+	}
+
+	retentionMu.Lock()
+	status.Running = running
+	if lastReport != nil {
+		report := *lastReport
+		status.LastRun = &report
+	}
+	retentionMu.Unlock()
+
+	return status, nil
+}
@@ -0,0 +1,272 @@
+package request_log_service
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"gox/database"
+	"gox/database/models"
+	"gox/utils"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5/stdlib"
+)
+
+// Canal PostgreSQL par lequel les replicas se signalent les logs enregistrés
+const notifyChannel = "request_logs"
+
+// Taille maximum d'une notification PostgreSQL (8000 octets), avec une marge
+const maxNotifyPayload = 7500
+
+// Identifiant de ce processus, pour ignorer ses propres notifications
+var instanceID = uuid.New().String()
+
+// StreamEntry : log diffusé en direct (sans corps ni en-têtes). ID vaut 0 pour les logs de ce replica,
+// diffusés avant leur enregistrement : RequestID permet de les retrouver.
+type StreamEntry struct {
+	ID            uint       `json:"id,omitempty"`
+	RequestID     string     `json:"request_id"`
+	UserID        *uuid.UUID `json:"user_id"`
+	Domain        string     `json:"domain"`
+	Method        string     `json:"method"`
+	Endpoint      string     `json:"endpoint"`
+	Route         string     `json:"route"`
+	Status        int        `json:"status"`
+	Timestamp     time.Time  `json:"timestamp"`
+	DurationMs    float64    `json:"duration_ms"`
+	ResponseBytes int64      `json:"response_bytes"`
+	ClientIP      string     `json:"client_ip"`
+}
+
+func toStreamEntry(log models.RequestLog) StreamEntry {
+	return StreamEntry{
+		ID:            log.ID,
+		RequestID:     log.RequestID,
+		UserID:        log.UserID,
+		Domain:        log.Domain,
+		Method:        log.Method,
+		Endpoint:      log.Endpoint,
+		Route:         log.Route,
+		Status:        log.Status,
+		Timestamp:     log.Timestamp,
+		DurationMs:    float64(log.Duration) / float64(time.Millisecond),
+		ResponseBytes: log.ResponseBytes,
+		ClientIP:      log.ClientIP,
+	}
+}
+
+// ~ Pub/sub en mémoire ~
+
+// Subscription : abonnement d'un client au flux. Si le client ne suit pas, les logs en trop sont
+// abandonnés et comptés dans Dropped.
+type Subscription struct {
+	C       chan StreamEntry
+	filter  func(StreamEntry) bool
+	dropped atomic.Uint64
+}
+
+// Dropped retourne et remet à zéro le nombre de logs abandonnés
+func (s *Subscription) Dropped() uint64 {
+	return s.dropped.Swap(0)
+}
+
+var (
+	subscribersMu sync.RWMutex
+	subscribers   = map[*Subscription]struct{}{}
+)
+
+// Subscribe abonne un client aux logs acceptés par filter (tous si nil). Le canal est fermé par
+// Unsubscribe ou à l'arrêt du serveur (CloseSubscriptions).
+func Subscribe(filter func(StreamEntry) bool) *Subscription {
+	subscription := &Subscription{C: make(chan StreamEntry, 256), filter: filter}
+	subscribersMu.Lock()
+	subscribers[subscription] = struct{}{}
+	subscribersMu.Unlock()
+	return subscription
+}
+
+func Unsubscribe(subscription *Subscription) {
+	subscribersMu.Lock()
+	defer subscribersMu.Unlock()
+	if _, ok := subscribers[subscription]; ok {
+		delete(subscribers, subscription)
+		close(subscription.C)
+	}
+}
+
+// CloseSubscriptions ferme tous les flux, pour que l'arrêt du serveur n'attende pas leurs clients
+func CloseSubscriptions() {
+	subscribersMu.Lock()
+	defer subscribersMu.Unlock()
+	for subscription := range subscribers {
+		delete(subscribers, subscription)
+		close(subscription.C)
+	}
+}
+
+func hasSubscribers() bool {
+	subscribersMu.RLock()
+	defer subscribersMu.RUnlock()
+	return len(subscribers) > 0
+}
+
+// Publish diffuse un log aux abonnés de ce replica, sans jamais bloquer l'appelant
+func Publish(log models.RequestLog) {
+	if !hasSubscribers() {
+		return
+	}
+	publish(toStreamEntry(log))
+}
+
+func publish(entry StreamEntry) {
+	subscribersMu.RLock()
+	defer subscribersMu.RUnlock()
+	for subscription := range subscribers {
+		if subscription.filter != nil && !subscription.filter(entry) {
+			continue
+		}
+		select {
+		case subscription.C <- entry:
+		default:
+			subscription.dropped.Add(1)
+		}
+	}
+}
+
+// ~ Diffusion entre replicas : LISTEN/NOTIFY ~
+
+// notification : logs enregistrés par un replica. Seuls les IDs sont envoyés (les notifications sont
+// limitées à 8000 octets), les bornes de dates limitent la lecture aux partitions concernées.
+type notification struct {
+	Origin string    `json:"origin"`
+	IDs    []uint    `json:"ids"`
+	First  time.Time `json:"first"`
+	Last   time.Time `json:"last"`
+}
+
+// NotifyEnabled : diffusion des logs aux autres replicas, désactivable via LOG_STREAM_NOTIFY=false
+func NotifyEnabled() bool {
+	return utils.GetEnv("LOG_STREAM_NOTIFY", "true") == "true"
+}
+
+// notifyBatch signale aux autres replicas un lot de logs qui vient d'être enregistré
+func notifyBatch(batch []models.RequestLog) {
+	if len(batch) == 0 {
+		return
+	}
+
+	n := notification{Origin: instanceID, First: batch[0].Timestamp, Last: batch[0].Timestamp}
+	send := func() {
+		payload, err := json.Marshal(n)
+		if err == nil {
+			err = database.DB.Exec("SELECT pg_notify(?, ?)", notifyChannel, string(payload)).Error
+		}
+		if err != nil {
+			utils.ConsoleLog("⚠️ Request log notification failed: %v", err)
+		}
+	}
+
+	// Chaque ID prend au plus une vingtaine d'octets : un lot est découpé en plusieurs notifications
+	const idsPerNotification = (maxNotifyPayload - 200) / 21
+	for _, log := range batch {
+		n.IDs = append(n.IDs, log.ID)
+		if log.Timestamp.Before(n.First) {
+			n.First = log.Timestamp
+		}
+		if log.Timestamp.After(n.Last) {
+			n.Last = log.Timestamp
+		}
+		if len(n.IDs) >= idsPerNotification {
+			send()
+			n.IDs = nil
+			n.First, n.Last = log.Timestamp, log.Timestamp
+		}
+	}
+	if len(n.IDs) > 0 {
+		send()
+	}
+}
+
+// Listen reçoit, tant que ctx n'est pas annulé, les logs enregistrés par les autres replicas et les
+// diffuse aux abonnés locaux. La connexion est rétablie en cas d'erreur.
+func Listen(ctx context.Context) {
+	for {
+		err := listen(ctx)
+		if ctx.Err() != nil {
+			return
+		}
+		utils.ConsoleLog("⚠️ Request log listener stopped, retrying in 5s: %v", err)
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(5 * time.Second):
+		}
+	}
+}
+
+func listen(ctx context.Context) error {
+	db, err := database.DB.DB()
+	if err != nil {
+		return err
+	}
+	conn, err := db.Conn(ctx)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	return conn.Raw(func(driverConn any) error {
+		stdlibConn, ok := driverConn.(*stdlib.Conn)
+		if !ok {
+			return errors.New("LISTEN requires the pgx driver")
+		}
+		pgConn := stdlibConn.Conn()
+
+		if _, err := pgConn.Exec(ctx, "LISTEN "+notifyChannel); err != nil {
+			return err
+		}
+		// La connexion retourne au pool : elle ne doit plus écouter le canal
+		defer pgConn.Exec(context.Background(), "UNLISTEN "+notifyChannel)
+
+		utils.ConsoleLog("📡 Listening for request logs of other replicas")
+		for {
+			received, err := pgConn.WaitForNotification(ctx)
+			if err != nil {
+				return err
+			}
+			if err := deliver(received.Payload); err != nil {
+				utils.ConsoleLog("⚠️ Request log notification ignored: %v", err)
+			}
+		}
+	})
+}
+
+// deliver lit les logs signalés par un autre replica et les diffuse aux abonnés locaux
+func deliver(payload string) error {
+	var n notification
+	if err := json.Unmarshal([]byte(payload), &n); err != nil {
+		return err
+	}
+	if n.Origin == instanceID || len(n.IDs) == 0 || !hasSubscribers() {
+		return nil
+	}
+
+	var logs []models.RequestLog
+	err := database.DB.
+		Select("id", "request_id", "user_id", "domain", "method", "endpoint", "route", "status", "timestamp", "duration", "response_bytes", "client_ip").
+		Where(`id IN ? AND "timestamp" BETWEEN ? AND ?`, n.IDs, n.First.Add(-time.Millisecond), n.Last.Add(time.Millisecond)).
+		Order("id").
+		Find(&logs).Error
+	if err != nil {
+		return fmt.Errorf("fetching %d logs: %w", len(n.IDs), err)
+	}
+
+	for _, log := range logs {
+		publish(toStreamEntry(log))
+	}
+	return nil
+}
@@ -0,0 +1,278 @@
+package request_log_service
+
+import (
+	"errors"
+	"fmt"
+	"gox/database"
+	"gox/database/models"
+	"gox/utils"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// Policy : comportement quand la file est pleine
+type Policy string
+
+const (
+	PolicyDrop  Policy = "drop"  // le log est abandonné, la requête n'attend jamais
+	PolicyBlock Policy = "block" // la requête attend une place (au plus BlockTimeout), puis le log est abandonné
+)
+
+var ErrStopTimeout = errors.New("request log writer did not flush in time")
+
+// Config : réglages du writer, lus depuis l'environnement par DefaultConfig
+type Config struct {
+	QueueSize     int
+	BatchSize     int
+	FlushInterval time.Duration
+	Policy        Policy
+	BlockTimeout  time.Duration
+}
+
+// Metrics : compteurs exposés aux administrateurs
+type Metrics struct {
+	Policy      Policy     `json:"policy"`
+	Capacity    int        `json:"capacity"`
+	Queued      int        `json:"queued"`
+	Pending     int64      `json:"pending"`
+	Enqueued    uint64     `json:"enqueued"`
+	Written     uint64     `json:"written"`
+	Dropped     uint64     `json:"dropped"`
+	Failed      uint64     `json:"failed"`
+	Batches     uint64     `json:"batches"`
+	LastFlushAt *time.Time `json:"last_flush_at"`
+	LastError   string     `json:"last_error,omitempty"`
+}
+
+// Writer : file bornée en mémoire, vidée en tâche de fond par insertions multi-lignes
+type Writer struct {
+	config Config
+	queue  chan models.RequestLog
+	insert func([]models.RequestLog) error
+
+	closing   chan struct{}
+	done      chan struct{}
+	closeOnce sync.Once
+
+	enqueued  atomic.Uint64
+	written   atomic.Uint64
+	dropped   atomic.Uint64
+	failed    atomic.Uint64
+	batches   atomic.Uint64
+	pending   atomic.Int64
+	lastFlush atomic.Int64
+	lastError atomic.Value
+}
+
+func envInt(key string, fallback int) int {
+	value, err := strconv.Atoi(utils.GetEnv(key, strconv.Itoa(fallback)))
+	if err != nil || value <= 0 {
+		return fallback
+	}
+	return value
+}
+
+// DefaultConfig lit LOG_QUEUE_SIZE, LOG_BATCH_SIZE, LOG_FLUSH_INTERVAL_MS, LOG_QUEUE_POLICY (drop|block)
+// et LOG_QUEUE_BLOCK_TIMEOUT_MS
+func DefaultConfig() Config {
+	policy := Policy(utils.GetEnv("LOG_QUEUE_POLICY", string(PolicyDrop)))
+	if policy != PolicyBlock {
+		policy = PolicyDrop
+	}
+	return Config{
+		QueueSize:     envInt("LOG_QUEUE_SIZE", 10000),
+		BatchSize:     envInt("LOG_BATCH_SIZE", 500),
+		FlushInterval: time.Duration(envInt("LOG_FLUSH_INTERVAL_MS", 1000)) * time.Millisecond,
+		Policy:        policy,
+		BlockTimeout:  time.Duration(envInt("LOG_QUEUE_BLOCK_TIMEOUT_MS", 100)) * time.Millisecond,
+	}
+}
+
+// NewWriter démarre un writer ; insert reçoit chaque lot à enregistrer
+func NewWriter(config Config, insert func([]models.RequestLog) error) *Writer {
+	w := &Writer{
+		config:  config,
+		queue:   make(chan models.RequestLog, config.QueueSize),
+		insert:  insert,
+		closing: make(chan struct{}),
+		done:    make(chan struct{}),
+	}
+	go w.run()
+	return w
+}
+
+// Enqueue ajoute un log à la file. Retourne false si le log a été abandonné.
+func (w *Writer) Enqueue(entry models.RequestLog) bool {
+	select {
+	case <-w.closing:
+		w.dropped.Add(1)
+		return false
+	default:
+	}
+
+	if w.config.Policy == PolicyBlock {
+		timer := time.NewTimer(w.config.BlockTimeout)
+		defer timer.Stop()
+		select {
+		case w.queue <- entry:
+			w.enqueued.Add(1)
+			return true
+		case <-timer.C:
+		case <-w.closing:
+		}
+	} else {
+		select {
+		case w.queue <- entry:
+			w.enqueued.Add(1)
+			return true
+		default:
+		}
+	}
+
+	w.dropped.Add(1)
+	return false
+}
+
+// Stop vide la file et enregistre les logs restants, au plus pendant timeout
+func (w *Writer) Stop(timeout time.Duration) error {
+	w.closeOnce.Do(func() { close(w.closing) })
+	select {
+	case <-w.done:
+		return nil
+	case <-time.After(timeout):
+		return fmt.Errorf("%w: %d entries still queued", ErrStopTimeout, len(w.queue)+int(w.pending.Load()))
+	}
+}
+
+func (w *Writer) Metrics() Metrics {
+	metrics := Metrics{
+		Policy:   w.config.Policy,
+		Capacity: cap(w.queue),
+		Queued:   len(w.queue),
+		Pending:  w.pending.Load(),
+		Enqueued: w.enqueued.Load(),
+		Written:  w.written.Load(),
+		Dropped:  w.dropped.Load(),
+		Failed:   w.failed.Load(),
+		Batches:  w.batches.Load(),
+	}
+	if last := w.lastFlush.Load(); last != 0 {
+		at := time.Unix(0, last)
+		metrics.LastFlushAt = &at
+	}
+	if err, ok := w.lastError.Load().(string); ok {
+		metrics.LastError = err
+	}
+	return metrics
+}
+
+func (w *Writer) run() {
+	defer close(w.done)
+
+	ticker := time.NewTicker(w.config.FlushInterval)
+	defer ticker.Stop()
+
+	batch := make([]models.RequestLog, 0, w.config.BatchSize)
+	add := func(entry models.RequestLog) {
+		batch = append(batch, entry)
+		w.pending.Store(int64(len(batch)))
+		if len(batch) >= w.config.BatchSize {
+			batch = w.flush(batch)
+		}
+	}
+
+	for {
+		select {
+		case entry := <-w.queue:
+			add(entry)
+		case <-ticker.C:
+			batch = w.flush(batch)
+		case <-w.closing:
+			// Arrêt : on vide ce qui reste dans la file
+			for {
+				select {
+				case entry := <-w.queue:
+					add(entry)
+				default:
+					w.flush(batch)
+					return
+				}
+			}
+		}
+	}
+}
+
+// flush enregistre le lot (une nouvelle tentative en cas d'erreur) et retourne le lot vidé
+func (w *Writer) flush(batch []models.RequestLog) []models.RequestLog {
+	if len(batch) == 0 {
+		return batch
+	}
+
+	err := w.insert(batch)
+	if err != nil {
+		time.Sleep(100 * time.Millisecond)
+		err = w.insert(batch)
+	}
+
+	if err != nil {
+		w.failed.Add(uint64(len(batch)))
+		w.lastError.Store(err.Error())
+		utils.ConsoleLog("⚠️ Erreur lors de l'enregistrement de %d logs en DB: %v", len(batch), err)
+	} else {
+		w.written.Add(uint64(len(batch)))
+		w.batches.Add(1)
+	}
+	w.lastFlush.Store(time.Now().UnixNano())
+	w.pending.Store(0)
+
+	return batch[:0]
+}
+
+// ~ Writer par défaut, utilisé par RequestLoggerMiddleware ~
+
+var (
+	defaultWriter *Writer
+	defaultOnce   sync.Once
+)
+
+// insertBatch : une insertion multi-lignes par lot, signalée ensuite aux autres replicas (flux en direct)
+func insertBatch(batch []models.RequestLog) error {
+	if err := database.DB.CreateInBatches(batch, len(batch)).Error; err != nil {
+		return err
+	}
+	if NotifyEnabled() {
+		notifyBatch(batch)
+	}
+	return nil
+}
+
+// Default retourne le writer par défaut, démarré au premier appel
+func Default() *Writer {
+	defaultOnce.Do(func() {
+		config := DefaultConfig()
+		utils.ConsoleLog("📜 Request log writer: queue %d, batch %d, flush every %s, policy %s",
+			config.QueueSize, config.BatchSize, config.FlushInterval, config.Policy)
+		defaultWriter = NewWriter(config, insertBatch)
+	})
+	return defaultWriter
+}
+
+// SetDefault remplace le writer par défaut (ex : pour recevoir les logs sans base de données)
+func SetDefault(w *Writer) {
+	defaultOnce.Do(func() {})
+	defaultWriter = w
+}
+
+func Enqueue(entry models.RequestLog) bool {
+	return Default().Enqueue(entry)
+}
+
+func Stop(timeout time.Duration) error {
+	return Default().Stop(timeout)
+}
+
+func GetMetrics() Metrics {
+	return Default().Metrics()
+}
@@ -0,0 +1,292 @@
+package request_log_service
+
+import (
+	"errors"
+	"gox/database/models"
+	"sync"
+	"testing"
+	"time"
+)
+
+// recorder : insertion factice, qui garde la taille de chaque lot reçu
+type recorder struct {
+	mu      sync.Mutex
+	sizes   []int
+	batches chan int
+}
+
+func newRecorder() *recorder {
+	return &recorder{batches: make(chan int, 100)}
+}
+
+func (r *recorder) insert(batch []models.RequestLog) error {
+	r.mu.Lock()
+	r.sizes = append(r.sizes, len(batch))
+	r.mu.Unlock()
+	r.batches <- len(batch)
+	return nil
+}
+
+func (r *recorder) total() int {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	total := 0
+	for _, size := range r.sizes {
+		total += size
+	}
+	return total
+}
+
+// waitBatch attend le prochain lot enregistré
+func waitBatch(t *testing.T, batches <-chan int) int {
+	t.Helper()
+	select {
+	case size := <-batches:
+		return size
+	case <-time.After(time.Second):
+		t.Fatal("no batch flushed")
+		return 0
+	}
+}
+
+// blockedWriter démarre un writer dont la première insertion reste bloquée jusqu'à release : la file
+// (capacité queueSize) se remplit ensuite sans être vidée
+func blockedWriter(t *testing.T, config Config) (w *Writer, release func()) {
+	t.Helper()
+	started := make(chan struct{}, 1)
+	gate := make(chan struct{})
+	var once sync.Once
+
+	w = NewWriter(config, func(batch []models.RequestLog) error {
+		select {
+		case started <- struct{}{}:
+		default:
+		}
+		<-gate
+		return nil
+	})
+	t.Cleanup(func() {
+		once.Do(func() { close(gate) })
+		w.Stop(time.Second)
+	})
+
+	if !w.Enqueue(models.RequestLog{}) {
+		t.Fatal("first entry dropped")
+	}
+	select {
+	case <-started:
+	case <-time.After(time.Second):
+		t.Fatal("insert not called")
+	}
+	return w, func() { once.Do(func() { close(gate) }) }
+}
+
+func TestDropPolicyCountsDrops(t *testing.T) {
+	w, release := blockedWriter(t, Config{QueueSize: 2, BatchSize: 1, FlushInterval: time.Hour, Policy: PolicyDrop})
+
+	for i := 0; i < 2; i++ {
+		if !w.Enqueue(models.RequestLog{}) {
+			t.Fatalf("entry %d dropped while the queue has room", i)
+		}
+	}
+
+	// File pleine : abandon immédiat
+	start := time.Now()
+	if w.Enqueue(models.RequestLog{}) {
+		t.Fatal("entry accepted in a full queue")
+	}
+	if elapsed := time.Since(start); elapsed > 50*time.Millisecond {
+		t.Errorf("drop policy waited %s", elapsed)
+	}
+
+	release()
+	if err := w.Stop(time.Second); err != nil {
+		t.Fatalf("Stop() error = %v", err)
+	}
+	metrics := w.Metrics()
+	if metrics.Enqueued != 3 || metrics.Written != 3 || metrics.Dropped != 1 {
+		t.Errorf("enqueued, written, dropped = %d, %d, %d, want 3, 3, 1", metrics.Enqueued, metrics.Written, metrics.Dropped)
+	}
+}
+
+func TestBlockPolicyAppliesBackpressure(t *testing.T) {
+	const blockTimeout = 100 * time.Millisecond
+	w, release := blockedWriter(t, Config{QueueSize: 1, BatchSize: 1, FlushInterval: time.Hour, Policy: PolicyBlock, BlockTimeout: blockTimeout})
+
+	if !w.Enqueue(models.RequestLog{}) {
+		t.Fatal("entry dropped while the queue has room")
+	}
+
+	// File pleine : la requête attend BlockTimeout, puis le log est abandonné
+	start := time.Now()
+	if w.Enqueue(models.RequestLog{}) {
+		t.Fatal("entry accepted in a full queue")
+	}
+	if elapsed := time.Since(start); elapsed < blockTimeout {
+		t.Errorf("block policy gave up after %s, want at least %s", elapsed, blockTimeout)
+	}
+
+	// Une place libérée pendant l'attente : le log est accepté
+	accepted := make(chan bool, 1)
+	go func() { accepted <- w.Enqueue(models.RequestLog{}) }()
+	select {
+	case <-accepted:
+		t.Fatal("Enqueue returned while the queue is full")
+	case <-time.After(blockTimeout / 4):
+	}
+	release()
+	select {
+	case ok := <-accepted:
+		if !ok {
+			t.Fatal("entry dropped once room was made")
+		}
+	case <-time.After(time.Second):
+		t.Fatal("Enqueue still blocked once room was made")
+	}
+
+	if err := w.Stop(time.Second); err != nil {
+		t.Fatalf("Stop() error = %v", err)
+	}
+	if metrics := w.Metrics(); metrics.Written != 3 || metrics.Dropped != 1 {
+		t.Errorf("written, dropped = %d, %d, want 3, 1", metrics.Written, metrics.Dropped)
+	}
+}
+
+func TestFlushOnBatchSize(t *testing.T) {
+	rec := newRecorder()
+	w := NewWriter(Config{QueueSize: 100, BatchSize: 3, FlushInterval: time.Hour, Policy: PolicyDrop}, rec.insert)
+
+	for i := 0; i < 7; i++ {
+		w.Enqueue(models.RequestLog{})
+	}
+
+	// Deux lots complets, sans attendre l'intervalle
+	for i := 0; i < 2; i++ {
+		if size := waitBatch(t, rec.batches); size != 3 {
+			t.Errorf("batch %d size = %d, want 3", i, size)
+		}
+	}
+	if pending := w.Metrics().Pending; pending != 1 {
+		t.Errorf("pending = %d, want 1", pending)
+	}
+
+	if err := w.Stop(time.Second); err != nil {
+		t.Fatalf("Stop() error = %v", err)
+	}
+	if size := waitBatch(t, rec.batches); size != 1 {
+		t.Errorf("last batch size = %d, want 1", size)
+	}
+	if metrics := w.Metrics(); metrics.Batches != 3 || metrics.Written != 7 {
+		t.Errorf("batches, written = %d, %d, want 3, 7", metrics.Batches, metrics.Written)
+	}
+}
+
+func TestFlushOnInterval(t *testing.T) {
+	rec := newRecorder()
+	w := NewWriter(Config{QueueSize: 100, BatchSize: 100, FlushInterval: 20 * time.Millisecond, Policy: PolicyDrop}, rec.insert)
+	defer w.Stop(time.Second)
+
+	w.Enqueue(models.RequestLog{})
+	w.Enqueue(models.RequestLog{})
+
+	// Lot incomplet, enregistré à l'échéance de l'intervalle
+	if size := waitBatch(t, rec.batches); size != 2 {
+		t.Errorf("batch size = %d, want 2", size)
+	}
+	if metrics := w.Metrics(); metrics.LastFlushAt == nil || metrics.Pending != 0 {
+		t.Errorf("last flush, pending = %v, %d", metrics.LastFlushAt, metrics.Pending)
+	}
+}
+
+func TestFlushRetriesOnce(t *testing.T) {
+	errInsert := errors.New("connection reset")
+
+	tests := []struct {
+		name        string
+		failures    int
+		wantCalls   int
+		wantWritten uint64
+		wantFailed  uint64
+	}{
+		{name: "second attempt succeeds", failures: 1, wantCalls: 2, wantWritten: 2},
+		{name: "both attempts fail", failures: 5, wantCalls: 2, wantFailed: 2},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			calls := 0
+			w := NewWriter(Config{QueueSize: 10, BatchSize: 10, FlushInterval: time.Hour, Policy: PolicyDrop}, func(batch []models.RequestLog) error {
+				calls++
+				if calls <= tt.failures {
+					return errInsert
+				}
+				return nil
+			})
+
+			w.Enqueue(models.RequestLog{})
+			w.Enqueue(models.RequestLog{})
+			if err := w.Stop(time.Second); err != nil {
+				t.Fatalf("Stop() error = %v", err)
+			}
+
+			metrics := w.Metrics()
+			if calls != tt.wantCalls {
+				t.Errorf("insert calls = %d, want %d", calls, tt.wantCalls)
+			}
+			if metrics.Written != tt.wantWritten || metrics.Failed != tt.wantFailed {
+				t.Errorf("written, failed = %d, %d, want %d, %d", metrics.Written, metrics.Failed, tt.wantWritten, tt.wantFailed)
+			}
+			if tt.wantFailed > 0 && metrics.LastError != errInsert.Error() {
+				t.Errorf("last error = %q, want %q", metrics.LastError, errInsert.Error())
+			}
+		})
+	}
+}
+
+func TestStopDrainsQueue(t *testing.T) {
+	rec := newRecorder()
+	w := NewWriter(Config{QueueSize: 100, BatchSize: 10, FlushInterval: time.Hour, Policy: PolicyDrop}, rec.insert)
+
+	for i := 0; i < 25; i++ {
+		if !w.Enqueue(models.RequestLog{}) {
+			t.Fatalf("entry %d dropped", i)
+		}
+	}
+
+	// Tout est enregistré avant le retour de Stop
+	if err := w.Stop(time.Second); err != nil {
+		t.Fatalf("Stop() error = %v", err)
+	}
+	if total := rec.total(); total != 25 {
+		t.Errorf("written %d entries, want 25", total)
+	}
+	if metrics := w.Metrics(); metrics.Queued != 0 || metrics.Pending != 0 {
+		t.Errorf("queued, pending = %d, %d, want 0, 0", metrics.Queued, metrics.Pending)
+	}
+
+	// Après l'arrêt, les logs sont abandonnés
+	if w.Enqueue(models.RequestLog{}) {
+		t.Error("entry accepted after Stop")
+	}
+	if dropped := w.Metrics().Dropped; dropped != 1 {
+		t.Errorf("dropped = %d, want 1", dropped)
+	}
+}
+
+func TestStopTimeout(t *testing.T) {
+	w, release := blockedWriter(t, Config{QueueSize: 10, BatchSize: 1, FlushInterval: time.Hour, Policy: PolicyDrop})
+	w.Enqueue(models.RequestLog{})
+
+	// L'insertion en cours ne se termine pas à temps
+	if err := w.Stop(50 * time.Millisecond); !errors.Is(err, ErrStopTimeout) {
+		t.Fatalf("Stop() error = %v, want ErrStopTimeout", err)
+	}
+
+	release()
+	if err := w.Stop(time.Second); err != nil {
+		t.Fatalf("second Stop() error = %v", err)
+	}
+	if written := w.Metrics().Written; written != 2 {
+		t.Errorf("written = %d, want 2", written)
+	}
+}
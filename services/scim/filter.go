@@ -0,0 +1,215 @@
+package scim_service
+
+import (
+	"net/http"
+	"strings"
+)
+
+// Sous-ensemble du langage de filtre SCIM (RFC 7644 §3.4.2.2) :
+// comparaisons eq, ne, co, sw, ew, gt, ge, lt, le et pr, combinées par and / or (and prioritaire), sans parenthèses.
+// Les comparaisons ne tiennent pas compte de la casse.
+
+type comparison struct {
+	attr  string
+	op    string
+	value string
+}
+
+// Filter est une disjonction de conjonctions de comparaisons. Un filtre vide accepte tout.
+type Filter [][]comparison
+
+var filterOperators = map[string]bool{
+	"eq": true, "ne": true, "co": true, "sw": true, "ew": true,
+	"gt": true, "ge": true, "lt": true, "le": true, "pr": true,
+}
+
+func tokenize(input string) ([]string, error) {
+	var tokens []string
+	i := 0
+	for i < len(input) {
+		switch {
+		case input[i] == ' ':
+			i++
+		case input[i] == '"':
+			var value strings.Builder
+			i++
+			closed := false
+			for i < len(input) {
+				if input[i] == '\\' && i+1 < len(input) {
+					value.WriteByte(input[i+1])
+					i += 2
+					continue
+				}
+				if input[i] == '"' {
+					closed = true
+					i++
+					break
+				}
+				value.WriteByte(input[i])
+				i++
+			}
+			if !closed {
+				return nil, newError(http.StatusBadRequest, "invalidFilter", "unterminated string in filter")
+			}
+			// Les chaînes sont préfixées pour les distinguer des mots-clés
+			tokens = append(tokens, "\x00"+value.String())
+		default:
+			start := i
+			for i < len(input) && input[i] != ' ' {
+				i++
+			}
+			tokens = append(tokens, input[start:i])
+		}
+	}
+
+	return tokens, nil
+}
+
+// ParseFilter analyse le paramètre filter d'une requête de liste
+func ParseFilter(input string) (Filter, error) {
+	input = strings.TrimSpace(input)
+	if input == "" {
+		return nil, nil
+	}
+	if strings.ContainsAny(input, "()[]") {
+		return nil, newError(http.StatusBadRequest, "invalidFilter", "grouping and complex attribute filters are not supported")
+	}
+
+	tokens, err := tokenize(input)
+	if err != nil {
+		return nil, err
+	}
+
+	filter := Filter{{}}
+	for i := 0; i < len(tokens); {
+		if len(tokens)-i < 2 {
+			return nil, newError(http.StatusBadRequest, "invalidFilter", "incomplete filter expression")
+		}
+
+		attr := strings.ToLower(tokens[i])
+		op := strings.ToLower(tokens[i+1])
+		if !filterOperators[op] || strings.HasPrefix(attr, "\x00") {
+			return nil, newError(http.StatusBadRequest, "invalidFilter", "invalid filter expression near %q", tokens[i])
+		}
+
+		cmp := comparison{attr: attr, op: op}
+		i += 2
+		if op != "pr" {
+			if i >= len(tokens) {
+				return nil, newError(http.StatusBadRequest, "invalidFilter", "missing value for %s", attr)
+			}
+			cmp.value = strings.ToLower(strings.TrimPrefix(tokens[i], "\x00"))
+			i++
+		}
+		filter[len(filter)-1] = append(filter[len(filter)-1], cmp)
+
+		if i < len(tokens) {
+			switch strings.ToLower(tokens[i]) {
+			case "and":
+			case "or":
+				filter = append(filter, []comparison{})
+			default:
+				return nil, newError(http.StatusBadRequest, "invalidFilter", "expected 'and' or 'or' near %q", tokens[i])
+			}
+			i++
+			if i >= len(tokens) {
+				return nil, newError(http.StatusBadRequest, "invalidFilter", "incomplete filter expression")
+			}
+		}
+	}
+
+	return filter, nil
+}
+
+// Column décrit comment lire un attribut en SQL : Expr pour un attribut à valeur unique (NULL ou vide si absent),
+// ou Values, une sous-requête retournant une colonne "value", pour un attribut multivalué. Args sont les
+// paramètres de Expr ou Values.
+type Column struct {
+	Expr   string
+	Values string
+	Args   []any
+}
+
+// Opérateurs SQL des comparaisons ; COLLATE "C" compare les octets, quelle que soit la collation de la base
+var sqlOperators = map[string]string{
+	"eq": `= ?`,
+	"ne": `<> ?`,
+	"co": `LIKE ? ESCAPE '\'`,
+	"sw": `LIKE ? ESCAPE '\'`,
+	"ew": `LIKE ? ESCAPE '\'`,
+	"gt": `> ?`,
+	"ge": `>= ?`,
+	"lt": `< ?`,
+	"le": `<= ?`,
+}
+
+func escapeLike(value string) string {
+	return strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`).Replace(value)
+}
+
+// sqlValue retourne la valeur comparée, sous forme de motif LIKE pour co, sw et ew
+func (c comparison) sqlValue() string {
+	switch c.op {
+	case "co":
+		return "%" + escapeLike(c.value) + "%"
+	case "sw":
+		return escapeLike(c.value) + "%"
+	case "ew":
+		return "%" + escapeLike(c.value)
+	}
+	return c.value
+}
+
+func (c comparison) sql(columns map[string]Column) (string, []any) {
+	column, ok := columns[c.attr]
+	if !ok {
+		// Un attribut inconnu est absent : seul ne est vrai
+		if c.op == "ne" {
+			return "TRUE", nil
+		}
+		return "FALSE", nil
+	}
+
+	args := append([]any{}, column.Args...)
+	if column.Values == "" {
+		switch c.op {
+		case "pr":
+			return "(" + column.Expr + " IS NOT NULL AND " + column.Expr + " <> '')", append(args, column.Args...)
+		case "ne":
+			return "(" + column.Expr + " IS NULL OR LOWER(" + column.Expr + `) COLLATE "C" <> ?)`, append(append(args, column.Args...), c.value)
+		}
+		return "LOWER(" + column.Expr + `) COLLATE "C" ` + sqlOperators[c.op], append(args, c.sqlValue())
+	}
+
+	values := "SELECT 1 FROM (" + column.Values + ") m WHERE "
+	switch c.op {
+	case "pr":
+		return "EXISTS (" + values + "m.value IS NOT NULL AND m.value <> '')", args
+	case "ne":
+		return "(EXISTS (" + values + `LOWER(m.value) COLLATE "C" <> ?) OR NOT EXISTS (SELECT 1 FROM (` + column.Values + ") m))",
+			append(append(args, c.value), column.Args...)
+	}
+	return "EXISTS (" + values + `LOWER(m.value) COLLATE "C" ` + sqlOperators[c.op] + ")", append(args, c.sqlValue())
+}
+
+// SQL traduit le filtre en condition SQL. pr exige une valeur non vide et ne est vrai lorsque l'attribut est absent.
+// Un filtre vide donne "TRUE".
+func (f Filter) SQL(columns map[string]Column) (string, []any) {
+	if len(f) == 0 {
+		return "TRUE", nil
+	}
+
+	var disjunction []string
+	var args []any
+	for _, conjunction := range f {
+		var parts []string
+		for _, cmp := range conjunction {
+			part, partArgs := cmp.sql(columns)
+			parts = append(parts, part)
+			args = append(args, partArgs...)
+		}
+		disjunction = append(disjunction, "("+strings.Join(parts, " AND ")+")")
+	}
+
+	return "(" + strings.Join(disjunction, " OR ") + ")", args
+}
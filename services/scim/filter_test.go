@@ -0,0 +1,222 @@
+package scim_service
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestParseFilter(t *testing.T) {
+	tests := []struct {
+		input string
+		want  Filter
+	}{
+		{input: "", want: nil},
+		{input: `userName eq "Jane@Acme.com"`, want: Filter{{{attr: "username", op: "eq", value: "jane@acme.com"}}}},
+		{input: `externalId pr`, want: Filter{{{attr: "externalid", op: "pr"}}}},
+		{input: `userName sw "j" and active eq "true" or id eq "x"`, want: Filter{
+			{{attr: "username", op: "sw", value: "j"}, {attr: "active", op: "eq", value: "true"}},
+			{{attr: "id", op: "eq", value: "x"}},
+		}},
+		{input: `displayName EQ "Sales \"EU\""`, want: Filter{{{attr: "displayname", op: "eq", value: `sales "eu"`}}}},
+	}
+
+	for _, tt := range tests {
+		got, err := ParseFilter(tt.input)
+		if err != nil {
+			t.Fatalf("ParseFilter(%q) error = %v", tt.input, err)
+		}
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("ParseFilter(%q) = %v, want %v", tt.input, got, tt.want)
+		}
+	}
+}
+
+func TestParseFilterInvalid(t *testing.T) {
+	inputs := []string{
+		`userName`,
+		`userName eq`,
+		`userName like "x"`,
+		`userName eq "x" and`,
+		`userName eq "x" xor id eq "y"`,
+		`userName eq "unterminated`,
+		`"userName" eq "x"`,
+		`(userName eq "x")`,
+		`emails[type eq "work"]`,
+	}
+
+	for _, input := range inputs {
+		_, err := ParseFilter(input)
+		var scimErr *Error
+		if !errors.As(err, &scimErr) || scimErr.Status != http.StatusBadRequest || scimErr.ScimType != "invalidFilter" {
+			t.Errorf("ParseFilter(%q) error = %v, want a 400 invalidFilter", input, err)
+		}
+	}
+}
+
+func TestFilterSQL(t *testing.T) {
+	columns := map[string]Column{
+		"username": {Expr: "users.email"},
+		"members":  {Values: "SELECT member_id AS value FROM team_members WHERE team_id = ?", Args: []any{"team"}},
+	}
+
+	tests := []struct {
+		filter string
+		sql    string
+		args   []any
+	}{
+		{
+			filter: "",
+			sql:    "TRUE",
+		},
+		{
+			filter: `userName eq "Jane@Acme.com"`,
+			sql:    `((LOWER(users.email) COLLATE "C" = ?))`,
+			args:   []any{"jane@acme.com"},
+		},
+		{
+			filter: `userName co "50%_\\"`,
+			sql:    `((LOWER(users.email) COLLATE "C" LIKE ? ESCAPE '\'))`,
+			args:   []any{`%50\%\_\\%`},
+		},
+		{
+			filter: `userName sw "j" and userName ew ".com"`,
+			sql:    `((LOWER(users.email) COLLATE "C" LIKE ? ESCAPE '\' AND LOWER(users.email) COLLATE "C" LIKE ? ESCAPE '\'))`,
+			args:   []any{"j%", "%.com"},
+		},
+		{
+			filter: `userName pr or userName ne "x"`,
+			sql:    `(((users.email IS NOT NULL AND users.email <> '')) OR ((users.email IS NULL OR LOWER(users.email) COLLATE "C" <> ?)))`,
+			args:   []any{"x"},
+		},
+		{
+			filter: `members eq "u1"`,
+			sql:    `((EXISTS (SELECT 1 FROM (SELECT member_id AS value FROM team_members WHERE team_id = ?) m WHERE LOWER(m.value) COLLATE "C" = ?)))`,
+			args:   []any{"team", "u1"},
+		},
+		{
+			filter: `members ne "u1"`,
+			sql: `(((EXISTS (SELECT 1 FROM (SELECT member_id AS value FROM team_members WHERE team_id = ?) m WHERE LOWER(m.value) COLLATE "C" <> ?) ` +
+				`OR NOT EXISTS (SELECT 1 FROM (SELECT member_id AS value FROM team_members WHERE team_id = ?) m))))`,
+			args: []any{"team", "u1", "team"},
+		},
+		{
+			filter: `title eq "x" or title ne "x"`,
+			sql:    `((FALSE) OR (TRUE))`,
+		},
+	}
+
+	for _, tt := range tests {
+		filter, err := ParseFilter(tt.filter)
+		if err != nil {
+			t.Fatalf("ParseFilter(%q) error = %v", tt.filter, err)
+		}
+
+		sql, args := filter.SQL(columns)
+		if sql != tt.sql {
+			t.Errorf("SQL(%q) = %s, want %s", tt.filter, sql, tt.sql)
+		}
+		if len(args) != len(tt.args) || (len(args) > 0 && !reflect.DeepEqual(args, tt.args)) {
+			t.Errorf("SQL(%q) args = %v, want %v", tt.filter, args, tt.args)
+		}
+	}
+}
+
+func TestNormalizePage(t *testing.T) {
+	tests := []struct{ startIndex, count, wantStart, wantCount int }{
+		{1, 10, 1, 10},
+		{0, 10, 1, 10},
+		{-5, -1, 1, 0},
+		{3, 0, 3, 0},
+		{1, MaxCount + 1, 1, MaxCount},
+	}
+
+	for _, tt := range tests {
+		start, count := normalizePage(tt.startIndex, tt.count)
+		if start != tt.wantStart || count != tt.wantCount {
+			t.Errorf("normalizePage(%d, %d) = (%d, %d), want (%d, %d)", tt.startIndex, tt.count, start, count, tt.wantStart, tt.wantCount)
+		}
+	}
+}
+
+func TestListResponse(t *testing.T) {
+	list := listResponse(42, 11, []User{{UserName: "a@acme.com"}, {UserName: "b@acme.com"}})
+
+	raw, err := json.Marshal(list)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var body map[string]any
+	json.Unmarshal(raw, &body)
+
+	if body["totalResults"] != float64(42) || body["startIndex"] != float64(11) || body["itemsPerPage"] != float64(2) {
+		t.Errorf("list response = %s", raw)
+	}
+	if schemas, _ := body["schemas"].([]any); len(schemas) != 1 || schemas[0] != SchemaListResponse {
+		t.Errorf("schemas = %v, want [%s]", body["schemas"], SchemaListResponse)
+	}
+	if resources, _ := body["Resources"].([]any); len(resources) != 2 {
+		t.Errorf("Resources = %v, want 2 items", body["Resources"])
+	}
+
+	// Une page vide reste une liste JSON
+	if raw, _ := json.Marshal(listResponse(0, 1, []Group{})); !json.Valid(raw) || !strings.Contains(string(raw), `"Resources":[]`) {
+		t.Errorf("empty list response = %s", raw)
+	}
+}
+
+func TestErrorJSON(t *testing.T) {
+	raw, err := json.Marshal(newError(http.StatusConflict, "uniqueness", "user %s exists", "jane@acme.com"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var body map[string]any
+	json.Unmarshal(raw, &body)
+	if body["status"] != "409" {
+		t.Errorf("status = %#v, want the string \"409\"", body["status"])
+	}
+	if body["scimType"] != "uniqueness" || body["detail"] != "user jane@acme.com exists" {
+		t.Errorf("error body = %s", raw)
+	}
+	if schemas, _ := body["schemas"].([]any); len(schemas) != 1 || schemas[0] != SchemaError {
+		t.Errorf("schemas = %v, want [%s]", body["schemas"], SchemaError)
+	}
+}
+
+func TestParseBool(t *testing.T) {
+	tests := map[string]bool{`true`: true, `false`: false, `"True"`: true, `"False"`: false}
+	for raw, want := range tests {
+		got, err := parseBool(json.RawMessage(raw))
+		if err != nil || got != want {
+			t.Errorf("parseBool(%s) = %v, %v, want %v", raw, got, err, want)
+		}
+	}
+
+	for _, raw := range []string{`1`, `"yes please"`, `{}`} {
+		if _, err := parseBool(json.RawMessage(raw)); err == nil {
+			t.Errorf("parseBool(%s) succeeded, want an error", raw)
+		}
+	}
+}
+
+func TestUserNameOf(t *testing.T) {
+	tests := []struct {
+		input User
+		want  string
+	}{
+		{User{UserName: " Jane@Acme.com "}, "jane@acme.com"},
+		{User{Emails: []Email{{Value: "work@acme.com"}, {Value: "Primary@Acme.com", Primary: true}}}, "primary@acme.com"},
+		{User{Emails: []Email{{Value: "First@acme.com"}}}, "first@acme.com"},
+		{User{}, ""},
+	}
+
+	for _, tt := range tests {
+		if got := userNameOf(tt.input); got != tt.want {
+			t.Errorf("userNameOf(%+v) = %q, want %q", tt.input, got, tt.want)
+		}
+	}
+}
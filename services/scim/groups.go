@@ -0,0 +1,373 @@
+package scim_service
+
+import (
+	"encoding/json"
+	"errors"
+	"gox/database"
+	"gox/database/models"
+	team_member_service "gox/services/teams/members"
+	team_role_service "gox/services/teams/roles"
+	"net/http"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// Un Group SCIM correspond à un rôle de la Team : les rôles intégrés (id = nom du rôle)
+// et les rôles personnalisés (id = ID du TeamRole). Un membre appartient à un seul groupe ;
+// le retirer d'un groupe le ramène au rôle spectator.
+
+var builtinGroups = []models.TeamMemberRole{
+	models.TeamMemberRoleOwner,
+	models.TeamMemberRoleAdmin,
+	models.TeamMemberRoleSpectator,
+}
+
+var memberFilterPath = regexp.MustCompile(`(?i)^members\[value eq "([^"]+)"\]$`)
+
+// groupsQuery liste les groupes de la Team : les rôles intégrés, dans l'ordre de builtinGroups, puis les rôles personnalisés
+const groupsQuery = `SELECT 'owner' AS id, 'owner' AS display_name, 0 AS position, NULL::timestamptz AS created_on
+	UNION ALL SELECT 'admin', 'admin', 1, NULL
+	UNION ALL SELECT 'spectator', 'spectator', 2, NULL
+	UNION ALL SELECT id::text, name, 3, created_on FROM team_roles WHERE team_id = ?`
+
+// Attributs filtrables d'un Group ; members est multivalué (membres actuels dont le groupe est g.id)
+func groupColumns(teamID uuid.UUID) map[string]Column {
+	members := Column{
+		Values: "SELECT team_members.member_id::text AS value FROM team_members " +
+			"WHERE team_members.team_id = ? AND team_members.left_at IS NULL AND " + groupKey + " = scim_groups.id",
+		Args: []any{teamID},
+	}
+
+	return map[string]Column{
+		"id":            {Expr: "scim_groups.id"},
+		"displayname":   {Expr: "scim_groups.display_name"},
+		"members":       members,
+		"members.value": members,
+	}
+}
+
+type groupRow struct {
+	ID          string
+	DisplayName string
+	CreatedOn   *time.Time
+}
+
+// queryGroups retourne la page de groupes vérifiant la condition, avec leurs membres, et le nombre total de groupes correspondants
+func queryGroups(teamID uuid.UUID, condition string, args []any, startIndex, count int) ([]Group, int64, error) {
+	scope := func(db *gorm.DB) *gorm.DB {
+		return db.Table("(?) AS scim_groups", gorm.Expr(groupsQuery, teamID)).Where(condition, args...)
+	}
+
+	var total int64
+	if err := database.DB.Scopes(scope).Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+
+	groups := []Group{}
+	if count == 0 || int64(startIndex) > total {
+		return groups, total, nil
+	}
+
+	var rows []groupRow
+	if err := database.DB.Scopes(scope).
+		Select("scim_groups.id, scim_groups.display_name, scim_groups.created_on").
+		Order("scim_groups.position, LOWER(scim_groups.display_name), scim_groups.id").
+		Offset(startIndex - 1).Limit(count).
+		Scan(&rows).Error; err != nil {
+		return nil, 0, err
+	}
+
+	ids := make([]string, len(rows))
+	index := map[string]int{}
+	for i, row := range rows {
+		ids[i] = row.ID
+		index[row.ID] = i
+		groups = append(groups, Group{
+			Schemas:     []string{SchemaGroup},
+			ID:          row.ID,
+			DisplayName: row.DisplayName,
+			Members:     []Reference{},
+			Meta:        &Meta{ResourceType: "Group", Created: row.CreatedOn},
+		})
+	}
+
+	// Seuls les membres des groupes de la page sont chargés
+	var members []models.TeamMember
+	if err := database.DB.Preload("Member").
+		Where("team_id = ?", teamID).
+		Where(team_member_service.CurrentCondition).
+		Where(groupKey+" IN ?", ids).
+		Order("joined_at").
+		Find(&members).Error; err != nil {
+		return nil, 0, err
+	}
+	for _, member := range members {
+		key := string(member.Role)
+		if member.Role == models.TeamMemberRoleCustom && member.CustomRoleID != nil {
+			key = member.CustomRoleID.String()
+		}
+		if i, ok := index[key]; ok {
+			groups[i].Members = append(groups[i].Members, Reference{Value: member.MemberID.String(), Display: member.Member.Email})
+		}
+	}
+
+	return groups, total, nil
+}
+
+// ListGroups filtre et pagine en SQL : seuls les groupes de la page et leurs membres sont chargés
+func ListGroups(teamID uuid.UUID, filter Filter, startIndex, count int) (ListResponse, error) {
+	startIndex, count = normalizePage(startIndex, count)
+	condition, args := filter.SQL(groupColumns(teamID))
+
+	groups, total, err := queryGroups(teamID, condition, args, startIndex, count)
+	if err != nil {
+		return ListResponse{}, err
+	}
+
+	return listResponse(total, startIndex, groups), nil
+}
+
+func GetGroup(teamID uuid.UUID, id string) (Group, error) {
+	groups, _, err := queryGroups(teamID, "scim_groups.id = ?", []any{strings.ToLower(id)}, 1, 1)
+	if err != nil {
+		return Group{}, err
+	}
+	if len(groups) == 0 {
+		return Group{}, errNotFound("Group", id)
+	}
+
+	return groups[0], nil
+}
+
+func isBuiltin(id string) bool {
+	return models.TeamMemberRole(id).IsValid()
+}
+
+// assign place le membre dans le groupe, en respectant la règle du dernier owner
+func assign(teamID uuid.UUID, groupID string, value string) error {
+	userID, err := uuid.Parse(value)
+	if err != nil {
+		return newError(http.StatusBadRequest, "invalidValue", "invalid member %q", value)
+	}
+	if _, err := team_member_service.GetByMemberId(teamID, userID); err != nil {
+		return newError(http.StatusBadRequest, "invalidValue", "user %s is not provisioned in this team", value)
+	}
+
+	if isBuiltin(groupID) {
+		err = team_member_service.UpdateRole(teamID, userID, models.TeamMemberRole(groupID), uuid.Nil)
+	} else {
+		err = team_member_service.AssignCustomRole(teamID, userID, uuid.MustParse(groupID), uuid.Nil)
+	}
+	if errors.Is(err, team_member_service.ErrLastOwner) {
+		return newError(http.StatusConflict, "mutability", "%s", err.Error())
+	}
+
+	return err
+}
+
+// unassign retire le membre du groupe (retour au rôle spectator) s'il en fait partie
+func unassign(teamID uuid.UUID, group Group, value string) error {
+	for _, member := range group.Members {
+		if strings.EqualFold(member.Value, value) {
+			if group.ID == string(models.TeamMemberRoleSpectator) {
+				return nil
+			}
+			return assign(teamID, string(models.TeamMemberRoleSpectator), value)
+		}
+	}
+
+	return nil
+}
+
+// setMembers remplace la liste des membres du groupe
+func setMembers(teamID uuid.UUID, group Group, members []Reference) error {
+	wanted := map[string]bool{}
+	for _, member := range members {
+		wanted[strings.ToLower(member.Value)] = true
+		if err := assign(teamID, group.ID, member.Value); err != nil {
+			return err
+		}
+	}
+
+	for _, member := range group.Members {
+		if !wanted[strings.ToLower(member.Value)] {
+			if err := unassign(teamID, group, member.Value); err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+func CreateGroup(teamID uuid.UUID, input Group) (Group, error) {
+	if strings.TrimSpace(input.DisplayName) == "" {
+		return Group{}, newError(http.StatusBadRequest, "invalidValue", "displayName is required")
+	}
+
+	role, err := team_role_service.Create(teamID, input.DisplayName, nil, uuid.Nil)
+	if err != nil {
+		return Group{}, newError(http.StatusConflict, "uniqueness", "%s", err.Error())
+	}
+
+	for _, member := range input.Members {
+		if err := assign(teamID, role.ID.String(), member.Value); err != nil {
+			return Group{}, err
+		}
+	}
+
+	return GetGroup(teamID, role.ID.String())
+}
+
+func rename(teamID uuid.UUID, group Group, displayName string) error {
+	if displayName == "" || displayName == group.DisplayName {
+		return nil
+	}
+	if isBuiltin(group.ID) {
+		return newError(http.StatusBadRequest, "mutability", "built-in groups cannot be renamed")
+	}
+
+	roleID := uuid.MustParse(group.ID)
+	role, err := team_role_service.Get(teamID, roleID)
+	if err != nil {
+		return errNotFound("Group", group.ID)
+	}
+	if _, err := team_role_service.Update(teamID, roleID, displayName, role.Permissions, uuid.Nil); err != nil {
+		return newError(http.StatusBadRequest, "invalidValue", "%s", err.Error())
+	}
+
+	return nil
+}
+
+func ReplaceGroup(teamID uuid.UUID, id string, input Group) (Group, error) {
+	group, err := GetGroup(teamID, id)
+	if err != nil {
+		return Group{}, err
+	}
+
+	if err := rename(teamID, group, input.DisplayName); err != nil {
+		return Group{}, err
+	}
+	if err := setMembers(teamID, group, input.Members); err != nil {
+		return Group{}, err
+	}
+
+	return GetGroup(teamID, group.ID)
+}
+
+func PatchGroup(teamID uuid.UUID, id string, patch PatchRequest) (Group, error) {
+	group, err := GetGroup(teamID, id)
+	if err != nil {
+		return Group{}, err
+	}
+
+	for _, operation := range patch.Operations {
+		op := strings.ToLower(operation.Op)
+		path := operation.Path
+
+		// Sans path, la valeur est un objet d'attributs (displayName, members)
+		if path == "" {
+			var values struct {
+				DisplayName string      `json:"displayName"`
+				Members     []Reference `json:"members"`
+			}
+			if err := json.Unmarshal(operation.Value, &values); err != nil {
+				return Group{}, newError(http.StatusBadRequest, "invalidValue", "value must be an object when path is omitted")
+			}
+			if err := rename(teamID, group, values.DisplayName); err != nil {
+				return Group{}, err
+			}
+			if values.Members != nil {
+				if op == "replace" {
+					err = setMembers(teamID, group, values.Members)
+				} else {
+					for _, member := range values.Members {
+						if err = assign(teamID, group.ID, member.Value); err != nil {
+							break
+						}
+					}
+				}
+				if err != nil {
+					return Group{}, err
+				}
+			}
+		} else if strings.EqualFold(path, "displayName") {
+			var displayName string
+			if err := json.Unmarshal(operation.Value, &displayName); err != nil {
+				return Group{}, newError(http.StatusBadRequest, "invalidValue", "displayName must be a string")
+			}
+			if err := rename(teamID, group, displayName); err != nil {
+				return Group{}, err
+			}
+		} else if match := memberFilterPath.FindStringSubmatch(path); match != nil {
+			if op != "remove" {
+				return Group{}, newError(http.StatusBadRequest, "invalidPath", "filtered member paths only support remove")
+			}
+			if err := unassign(teamID, group, match[1]); err != nil {
+				return Group{}, err
+			}
+		} else if strings.EqualFold(path, "members") {
+			var members []Reference
+			if len(operation.Value) > 0 {
+				if err := json.Unmarshal(operation.Value, &members); err != nil {
+					return Group{}, newError(http.StatusBadRequest, "invalidValue", "members must be a list")
+				}
+			}
+
+			switch op {
+			case "add":
+				for _, member := range members {
+					if err := assign(teamID, group.ID, member.Value); err != nil {
+						return Group{}, err
+					}
+				}
+			case "remove":
+				// Sans valeur, tous les membres sont retirés
+				if len(operation.Value) == 0 {
+					members = group.Members
+				}
+				for _, member := range members {
+					if err := unassign(teamID, group, member.Value); err != nil {
+						return Group{}, err
+					}
+				}
+			case "replace":
+				if err := setMembers(teamID, group, members); err != nil {
+					return Group{}, err
+				}
+			default:
+				return Group{}, newError(http.StatusBadRequest, "invalidSyntax", "unsupported operation %q", operation.Op)
+			}
+		} else {
+			return Group{}, newError(http.StatusBadRequest, "invalidPath", "unsupported path %q", path)
+		}
+
+		// Le groupe est rechargé pour que l'opération suivante parte de l'état à jour
+		if group, err = GetGroup(teamID, group.ID); err != nil {
+			return Group{}, err
+		}
+	}
+
+	return group, nil
+}
+
+// DeleteGroup supprime un rôle personnalisé ; ses membres redeviennent spectators
+func DeleteGroup(teamID uuid.UUID, id string) error {
+	if isBuiltin(id) {
+		return newError(http.StatusBadRequest, "mutability", "built-in groups cannot be deleted")
+	}
+
+	roleID, err := uuid.Parse(id)
+	if err != nil {
+		return errNotFound("Group", id)
+	}
+	if _, err := team_role_service.Get(teamID, roleID); err != nil {
+		return errNotFound("Group", id)
+	}
+
+	return team_role_service.Delete(teamID, roleID, uuid.Nil)
+}
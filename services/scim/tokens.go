@@ -0,0 +1,93 @@
+package scim_service
+
+import (
+	"fmt"
+	"gox/database"
+	"gox/database/models"
+	team_service "gox/services/teams"
+	"gox/utils"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// CreateToken génère un jeton SCIM pour la Team ; le jeton en clair n'est retourné qu'ici
+func CreateToken(teamID uuid.UUID, name string) (models.TeamSCIMToken, string, error) {
+	if name == "" {
+		return models.TeamSCIMToken{}, "", fmt.Errorf("name is required")
+	}
+
+	// Seules les Teams company peuvent être provisionnées
+	var team models.Team
+	if err := database.DB.Where("id = ?", teamID).First(&team).Error; err != nil {
+		return models.TeamSCIMToken{}, "", fmt.Errorf("team not found")
+	}
+	if team.Type != models.TeamTypeCompany {
+		return models.TeamSCIMToken{}, "", fmt.Errorf("only company teams can be provisioned with SCIM")
+	}
+
+	token, err := utils.GenerateToken(32)
+	if err != nil {
+		return models.TeamSCIMToken{}, "", fmt.Errorf("error generating token: %v", err)
+	}
+
+	// Création du jeton
+	scimToken := models.TeamSCIMToken{
+		TeamID:    teamID,
+		Name:      name,
+		TokenHash: utils.HashToken(token),
+	}
+
+	// Insertion en base
+	if err := database.DB.Create(&scimToken).Error; err != nil {
+		return models.TeamSCIMToken{}, "", fmt.Errorf("error creating token: %v", err)
+	}
+
+	return scimToken, token, nil
+}
+
+func GetTokens(teamID uuid.UUID) ([]models.TeamSCIMToken, error) {
+	var tokens []models.TeamSCIMToken
+
+	// Récupération des jetons actifs
+	result := database.DB.Where("team_id = ? AND revoked_at IS NULL", teamID).Order("created_on").Find(&tokens)
+
+	// Vérification des erreurs GORM
+	if result.Error != nil {
+		return nil, result.Error
+	}
+
+	return tokens, nil
+}
+
+func RevokeToken(teamID, tokenID uuid.UUID) error {
+	result := database.DB.Model(&models.TeamSCIMToken{}).
+		Where("team_id = ? AND id = ? AND revoked_at IS NULL", teamID, tokenID).
+		Update("revoked_at", time.Now())
+
+	// Vérification des erreurs GORM
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return fmt.Errorf("token not found")
+	}
+
+	return nil
+}
+
+// Authenticate retourne la Team associée au jeton, si celui-ci est valide et la Team provisionnable
+func Authenticate(token string) (uuid.UUID, error) {
+	var scimToken models.TeamSCIMToken
+	result := database.DB.Preload("Team").Where("token_hash = ? AND revoked_at IS NULL", utils.HashToken(token)).First(&scimToken)
+	if result.Error != nil {
+		return uuid.Nil, fmt.Errorf("invalid token")
+	}
+	if scimToken.Team.IsArchived() || team_service.CheckNotSuspended(scimToken.Team) != nil {
+		return uuid.Nil, fmt.Errorf("team is not available")
+	}
+
+	database.DB.Model(&models.TeamSCIMToken{}).Where("id = ?", scimToken.ID).Update("last_used_at", time.Now())
+
+	return scimToken.TeamID, nil
+}
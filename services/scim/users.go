@@ -0,0 +1,343 @@
+package scim_service
+
+import (
+	"encoding/json"
+	"errors"
+	"gox/database"
+	"gox/database/models"
+	team_invitation_service "gox/services/teams/invitations"
+	team_member_service "gox/services/teams/members"
+	user_service "gox/services/users"
+	"gox/utils"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// Un User SCIM correspond à l'adhésion actuelle d'un models.User à la Team du jeton :
+// id = ID de l'utilisateur, userName = email, active = adhésion non suspendue.
+
+func groupRef(member models.TeamMember) Reference {
+	if member.Role == models.TeamMemberRoleCustom && member.CustomRole != nil {
+		return Reference{Value: member.CustomRole.ID.String(), Display: member.CustomRole.Name}
+	}
+	return Reference{Value: string(member.Role), Display: string(member.Role)}
+}
+
+func userResource(member models.TeamMember) User {
+	active := member.IsActive && member.Member.IsActive
+	modified := member.JoinedAt
+	if member.RoleChangedAt != nil && member.RoleChangedAt.After(modified) {
+		modified = *member.RoleChangedAt
+	}
+	if member.SuspendedAt != nil && member.SuspendedAt.After(modified) {
+		modified = *member.SuspendedAt
+	}
+
+	return User{
+		Schemas:    []string{SchemaUser},
+		ID:         member.MemberID.String(),
+		ExternalID: member.ExternalID,
+		UserName:   member.Member.Email,
+		Emails:     []Email{{Value: member.Member.Email, Type: "work", Primary: true}},
+		Active:     &active,
+		Groups:     []Reference{groupRef(member)},
+		Meta: &Meta{
+			ResourceType: "User",
+			Created:      &member.JoinedAt,
+			LastModified: &modified,
+		},
+	}
+}
+
+func getMember(teamID uuid.UUID, id string) (models.TeamMember, error) {
+	userID, err := uuid.Parse(id)
+	if err != nil {
+		return models.TeamMember{}, errNotFound("User", id)
+	}
+
+	var member models.TeamMember
+	result := database.DB.Preload("Member").Preload("CustomRole").
+		Where("team_id = ? AND member_id = ?", teamID, userID).
+		Where(team_member_service.CurrentCondition).
+		First(&member)
+	if result.Error != nil {
+		return models.TeamMember{}, errNotFound("User", id)
+	}
+
+	return member, nil
+}
+
+// Attributs filtrables d'un User, lus sur team_members joint à users
+var userColumns = map[string]Column{
+	"id":           {Expr: "users.id::text"},
+	"username":     {Expr: "users.email"},
+	"emails":       {Expr: "users.email"},
+	"emails.value": {Expr: "users.email"},
+	"externalid":   {Expr: "team_members.external_id"},
+	"active":       {Expr: "CASE WHEN team_members.is_active AND users.is_active THEN 'true' ELSE 'false' END"},
+	"groups":       {Expr: groupKey},
+	"groups.value": {Expr: groupKey},
+}
+
+// groupKey est l'id du Group SCIM d'une adhésion (voir groupRef)
+const groupKey = "CASE WHEN team_members.role = 'custom' AND team_members.custom_role_id IS NOT NULL " +
+	"THEN team_members.custom_role_id::text ELSE team_members.role END"
+
+// ListUsers filtre et pagine en SQL : seule la page demandée est chargée
+func ListUsers(teamID uuid.UUID, filter Filter, startIndex, count int) (ListResponse, error) {
+	startIndex, count = normalizePage(startIndex, count)
+	condition, args := filter.SQL(userColumns)
+
+	scope := func(db *gorm.DB) *gorm.DB {
+		return db.Model(&models.TeamMember{}).
+			Joins("JOIN users ON users.id = team_members.member_id").
+			Where("team_members.team_id = ? AND team_members.left_at IS NULL", teamID).
+			Where(condition, args...)
+	}
+
+	var total int64
+	if err := database.DB.Scopes(scope).Count(&total).Error; err != nil {
+		return ListResponse{}, err
+	}
+
+	users := []User{}
+	if count > 0 && int64(startIndex) <= total {
+		var members []models.TeamMember
+		if err := database.DB.Scopes(scope).Preload("Member").Preload("CustomRole").
+			Order("team_members.joined_at, team_members.id").
+			Offset(startIndex - 1).Limit(count).
+			Find(&members).Error; err != nil {
+			return ListResponse{}, err
+		}
+		for _, member := range members {
+			users = append(users, userResource(member))
+		}
+	}
+
+	return listResponse(total, startIndex, users), nil
+}
+
+func GetUser(teamID uuid.UUID, id string) (User, error) {
+	member, err := getMember(teamID, id)
+	if err != nil {
+		return User{}, err
+	}
+
+	return userResource(member), nil
+}
+
+// setActive suspend ou réactive l'adhésion : c'est ainsi qu'un déprovisionnement est répercuté
+func setActive(teamID uuid.UUID, member models.TeamMember, active bool) error {
+	if member.IsActive == active {
+		return nil
+	}
+
+	var err error
+	if active {
+		err = team_member_service.Reactivate(teamID, member.MemberID, uuid.Nil)
+	} else {
+		err = team_member_service.Suspend(teamID, member.MemberID, uuid.Nil)
+	}
+	if errors.Is(err, team_member_service.ErrLastOwner) {
+		return newError(http.StatusConflict, "mutability", "%s", err.Error())
+	}
+	if err != nil {
+		return newError(http.StatusBadRequest, "invalidValue", "%s", err.Error())
+	}
+
+	return nil
+}
+
+func setExternalID(teamID uuid.UUID, member models.TeamMember, externalID string) error {
+	if member.ExternalID == externalID {
+		return nil
+	}
+
+	return database.DB.Model(&models.TeamMember{}).
+		Where("team_id = ? AND member_id = ?", teamID, member.MemberID).
+		Where(team_member_service.CurrentCondition).
+		Update("external_id", externalID).Error
+}
+
+func userNameOf(input User) string {
+	if input.UserName != "" {
+		return strings.ToLower(strings.TrimSpace(input.UserName))
+	}
+	for _, email := range input.Emails {
+		if email.Primary {
+			return strings.ToLower(strings.TrimSpace(email.Value))
+		}
+	}
+	if len(input.Emails) > 0 {
+		return strings.ToLower(strings.TrimSpace(input.Emails[0].Value))
+	}
+	return ""
+}
+
+// CreateUser provisionne un utilisateur. Un compte inexistant est créé puis ajouté à la Team en spectator ;
+// un compte existant appartient déjà à quelqu'un : il reçoit une invitation à accepter et n'est jamais rattaché d'office.
+func CreateUser(teamID uuid.UUID, input User) (User, error) {
+	userName := userNameOf(input)
+	if userName == "" || !strings.Contains(userName, "@") {
+		return User{}, newError(http.StatusBadRequest, "invalidValue", "userName must be a valid email address")
+	}
+
+	user, err := user_service.GetByEmail(userName)
+	if err == nil {
+		if _, err := team_member_service.GetByMemberId(teamID, user.ID); err == nil {
+			return User{}, newError(http.StatusConflict, "uniqueness", "user %s is already provisioned", userName)
+		}
+
+		_, err := team_invitation_service.Create(teamID, uuid.Nil, userName, models.TeamMemberRoleSpectator)
+		if err != nil && !errors.Is(err, team_invitation_service.ErrInvitationPending) {
+			return User{}, newError(http.StatusBadRequest, "invalidValue", "%s", err.Error())
+		}
+		return User{}, newError(http.StatusConflict, "uniqueness",
+			"an account already exists for %s: an invitation has been sent and the user will be provisioned once they accept it", userName)
+	}
+
+	// Le mot de passe est aléatoire : l'utilisateur passera par la réinitialisation ou le SSO
+	password, err := utils.GenerateToken(32)
+	if err != nil {
+		return User{}, err
+	}
+	userID, err := user_service.Create(userName, password)
+	if err != nil {
+		return User{}, newError(http.StatusBadRequest, "invalidValue", "%s", err.Error())
+	}
+	if user, err = user_service.Get(userID); err != nil {
+		return User{}, err
+	}
+	if err := team_member_service.Add(teamID, user.ID, models.TeamMemberRoleSpectator, uuid.Nil); err != nil {
+		return User{}, err
+	}
+
+	member, err := getMember(teamID, user.ID.String())
+	if err != nil {
+		return User{}, err
+	}
+	if err := setExternalID(teamID, member, input.ExternalID); err != nil {
+		return User{}, err
+	}
+	if input.Active != nil {
+		if err := setActive(teamID, member, *input.Active); err != nil {
+			return User{}, err
+		}
+	}
+
+	return GetUser(teamID, user.ID.String())
+}
+
+// ReplaceUser applique un PUT : seuls externalId et active sont modifiables, l'email appartient au compte GoX
+func ReplaceUser(teamID uuid.UUID, id string, input User) (User, error) {
+	member, err := getMember(teamID, id)
+	if err != nil {
+		return User{}, err
+	}
+
+	if userName := userNameOf(input); userName != "" && userName != strings.ToLower(member.Member.Email) {
+		return User{}, newError(http.StatusBadRequest, "mutability", "userName cannot be changed through SCIM")
+	}
+
+	if err := setExternalID(teamID, member, input.ExternalID); err != nil {
+		return User{}, err
+	}
+	active := true
+	if input.Active != nil {
+		active = *input.Active
+	}
+	if err := setActive(teamID, member, active); err != nil {
+		return User{}, err
+	}
+
+	return GetUser(teamID, id)
+}
+
+// parseBool accepte les booléens JSON et leur forme texte ("False"), envoyée par certains IdP
+func parseBool(raw json.RawMessage) (bool, error) {
+	var b bool
+	if err := json.Unmarshal(raw, &b); err == nil {
+		return b, nil
+	}
+	var s string
+	if err := json.Unmarshal(raw, &s); err != nil {
+		return false, err
+	}
+	return strconv.ParseBool(strings.ToLower(s))
+}
+
+func PatchUser(teamID uuid.UUID, id string, patch PatchRequest) (User, error) {
+	member, err := getMember(teamID, id)
+	if err != nil {
+		return User{}, err
+	}
+
+	for _, operation := range patch.Operations {
+		op := strings.ToLower(operation.Op)
+		if op != "add" && op != "replace" && op != "remove" {
+			return User{}, newError(http.StatusBadRequest, "invalidSyntax", "unsupported operation %q", operation.Op)
+		}
+
+		// Sans path, la valeur est un objet d'attributs
+		values := map[string]json.RawMessage{}
+		if operation.Path == "" {
+			if err := json.Unmarshal(operation.Value, &values); err != nil {
+				return User{}, newError(http.StatusBadRequest, "invalidValue", "value must be an object when path is omitted")
+			}
+		} else {
+			values[operation.Path] = operation.Value
+		}
+
+		for path, value := range values {
+			switch strings.ToLower(path) {
+			case "active":
+				active := false
+				if op != "remove" {
+					if active, err = parseBool(value); err != nil {
+						return User{}, newError(http.StatusBadRequest, "invalidValue", "active must be a boolean")
+					}
+				}
+				if err := setActive(teamID, member, active); err != nil {
+					return User{}, err
+				}
+				member.IsActive = active
+			case "externalid":
+				externalID := ""
+				if op != "remove" {
+					if err := json.Unmarshal(value, &externalID); err != nil {
+						return User{}, newError(http.StatusBadRequest, "invalidValue", "externalId must be a string")
+					}
+				}
+				if err := setExternalID(teamID, member, externalID); err != nil {
+					return User{}, err
+				}
+				member.ExternalID = externalID
+			case "username", "emails", "displayname", "name", "name.givenname", "name.familyname", "name.formatted":
+				// Attributs gérés par le compte GoX : ignorés
+			default:
+				return User{}, newError(http.StatusBadRequest, "invalidPath", "unsupported path %q", path)
+			}
+		}
+	}
+
+	return GetUser(teamID, id)
+}
+
+// DeleteUser met fin à l'adhésion ; le compte GoX de l'utilisateur est conservé
+func DeleteUser(teamID uuid.UUID, id string) error {
+	member, err := getMember(teamID, id)
+	if err != nil {
+		return err
+	}
+
+	err = team_member_service.Remove(database.DB, teamID, member.MemberID, uuid.Nil)
+	if errors.Is(err, team_member_service.ErrLastOwner) {
+		return newError(http.StatusConflict, "mutability", "%s", err.Error())
+	}
+
+	return err
+}
@@ -0,0 +1,200 @@
+package stats_service
+
+import (
+	"errors"
+	"fmt"
+	"gox/database"
+	"gox/database/models"
+	"gox/utils"
+	"strconv"
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// Fenêtre prise en compte pour les jours d'activité et les séries, configurable via STATS_WINDOW_DAYS
+func Window() time.Duration {
+	days, err := strconv.Atoi(utils.GetEnv("STATS_WINDOW_DAYS", "365"))
+	if err != nil || days <= 0 {
+		days = 365
+	}
+	return time.Duration(days) * 24 * time.Hour
+}
+
+// Intervalle entre deux recalculs, configurable via STATS_INTERVAL_MINUTES
+func Interval() time.Duration {
+	minutes, err := strconv.Atoi(utils.GetEnv("STATS_INTERVAL_MINUTES", "60"))
+	if err != nil || minutes <= 0 {
+		minutes = 60
+	}
+	return time.Duration(minutes) * time.Minute
+}
+
+// userStatsQuery calcule les statistiques des utilisateurs vérifiant le filtre en une seule requête ensembliste.
+// Les séries de jours consécutifs sont des "îlots" : day - rang est constant au sein d'une série.
+const userStatsQuery = `
+	WITH days AS (
+		SELECT user_id AS customer_id, (timestamp AT TIME ZONE 'UTC')::date AS day
+		FROM request_logs WHERE user_id IS NOT NULL AND timestamp > @since
+		UNION
+		SELECT actor_id, (created_on AT TIME ZONE 'UTC')::date
+		FROM team_activities WHERE actor_id IS NOT NULL AND created_on > @since
+	), runs AS (
+		SELECT customer_id, COUNT(*) AS length, MAX(day) AS last_day
+		FROM (SELECT customer_id, day, day - (ROW_NUMBER() OVER (PARTITION BY customer_id ORDER BY day))::int AS island FROM days) d
+		GROUP BY customer_id, island
+	), streaks AS (
+		SELECT customer_id,
+			SUM(length) AS active_days,
+			MAX(length) AS longest_streak,
+			COALESCE(MAX(length) FILTER (WHERE last_day >= @today::date - 1), 0) AS current_streak,
+			MAX(last_day) AS last_active_on
+		FROM runs GROUP BY customer_id
+	), memberships AS (
+		SELECT team_members.member_id,
+			COUNT(*) AS joined,
+			COUNT(*) FILTER (WHERE team_members.role = @owner) AS owned
+		FROM team_members JOIN teams ON teams.id = team_members.team_id
+		WHERE team_members.left_at IS NULL AND teams.type = @company AND teams.archived_at IS NULL
+		GROUP BY team_members.member_id
+	), events AS (
+		SELECT actor_id, COUNT(*) AS events FROM team_activities
+		WHERE actor_id IS NOT NULL AND created_on > @since GROUP BY actor_id
+	)
+	INSERT INTO user_stats (customer_id, teams_joined, teams_owned, activity_events, active_days, current_streak, longest_streak, last_active_on, computed_at)
+	SELECT users.id, COALESCE(memberships.joined, 0), COALESCE(memberships.owned, 0), COALESCE(events.events, 0),
+		COALESCE(streaks.active_days, 0), COALESCE(streaks.current_streak, 0), COALESCE(streaks.longest_streak, 0),
+		streaks.last_active_on, @now
+	FROM users
+	LEFT JOIN memberships ON memberships.member_id = users.id
+	LEFT JOIN events ON events.actor_id = users.id
+	LEFT JOIN streaks ON streaks.customer_id = users.id
+	WHERE %s
+	ON CONFLICT (customer_id) DO UPDATE SET
+		teams_joined = EXCLUDED.teams_joined, teams_owned = EXCLUDED.teams_owned,
+		activity_events = EXCLUDED.activity_events, active_days = EXCLUDED.active_days,
+		current_streak = EXCLUDED.current_streak, longest_streak = EXCLUDED.longest_streak,
+		last_active_on = EXCLUDED.last_active_on, computed_at = EXCLUDED.computed_at`
+
+// teamStatsQuery calcule les statistiques des Teams vérifiant le filtre (activité des 30 derniers jours)
+const teamStatsQuery = `
+	WITH members AS (
+		SELECT team_id, COUNT(*) AS members FROM team_members
+		WHERE left_at IS NULL AND suspended_at IS NULL GROUP BY team_id
+	), sub_teams AS (
+		SELECT parent_id, COUNT(*) AS sub_teams FROM teams
+		WHERE parent_id IS NOT NULL AND archived_at IS NULL GROUP BY parent_id
+	), activity AS (
+		SELECT team_id, COUNT(*) AS events, COUNT(DISTINCT actor_id) AS active_members,
+			COUNT(DISTINCT (created_on AT TIME ZONE 'UTC')::date) AS active_days
+		FROM team_activities WHERE created_on > @since GROUP BY team_id
+	)
+	INSERT INTO team_stats (team_id, members, active_members, sub_teams, activity_events, active_days, computed_at)
+	SELECT teams.id, COALESCE(members.members, 0), COALESCE(activity.active_members, 0), COALESCE(sub_teams.sub_teams, 0),
+		COALESCE(activity.events, 0), COALESCE(activity.active_days, 0), @now
+	FROM teams
+	LEFT JOIN members ON members.team_id = teams.id
+	LEFT JOIN sub_teams ON sub_teams.parent_id = teams.id
+	LEFT JOIN activity ON activity.team_id = teams.id
+	WHERE %s
+	ON CONFLICT (team_id) DO UPDATE SET
+		members = EXCLUDED.members, active_members = EXCLUDED.active_members, sub_teams = EXCLUDED.sub_teams,
+		activity_events = EXCLUDED.activity_events, active_days = EXCLUDED.active_days, computed_at = EXCLUDED.computed_at`
+
+func computeUsers(filter string, args map[string]interface{}) error {
+	now := time.Now()
+	args["now"] = now
+	args["since"] = now.Add(-Window())
+	args["today"] = now.UTC().Format("2006-01-02")
+	args["owner"] = models.TeamMemberRoleOwner
+	args["company"] = models.TeamTypeCompany
+	return database.DB.Exec(fmt.Sprintf(userStatsQuery, filter), args).Error
+}
+
+func computeTeams(filter string, args map[string]interface{}) error {
+	now := time.Now()
+	args["now"] = now
+	args["since"] = now.Add(-30 * 24 * time.Hour)
+	return database.DB.Exec(fmt.Sprintf(teamStatsQuery, filter), args).Error
+}
+
+// ComputeAll recalcule les statistiques de tous les utilisateurs et de toutes les Teams actives,
+// en une requête par table quel que soit le nombre d'utilisateurs
+func ComputeAll() error {
+	if err := computeUsers("TRUE", map[string]interface{}{}); err != nil {
+		return fmt.Errorf("error computing user stats: %v", err)
+	}
+	if err := computeTeams("teams.archived_at IS NULL", map[string]interface{}{}); err != nil {
+		return fmt.Errorf("error computing team stats: %v", err)
+	}
+	return nil
+}
+
+// ComputeUser recalcule et enregistre les statistiques d'un utilisateur
+func ComputeUser(userID uuid.UUID) (models.UserStats, error) {
+	if err := computeUsers("users.id = @user", map[string]interface{}{"user": userID}); err != nil {
+		return models.UserStats{}, err
+	}
+
+	var stats models.UserStats
+	err := database.DB.Where("customer_id = ?", userID).First(&stats).Error
+	return stats, err
+}
+
+// ComputeTeam recalcule et enregistre les statistiques d'une Team
+func ComputeTeam(teamID uuid.UUID) (models.TeamStats, error) {
+	if err := computeTeams("teams.id = @team", map[string]interface{}{"team": teamID}); err != nil {
+		return models.TeamStats{}, err
+	}
+
+	var stats models.TeamStats
+	err := database.DB.Where("team_id = ?", teamID).First(&stats).Error
+	return stats, err
+}
+
+// GetUser retourne les dernières statistiques calculées, ou les calcule si elles n'existent pas encore
+func GetUser(userID uuid.UUID) (models.UserStats, error) {
+	var stats models.UserStats
+	err := database.DB.Where("customer_id = ?", userID).First(&stats).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return ComputeUser(userID)
+	}
+	return stats, err
+}
+
+// GetTeam retourne les dernières statistiques calculées, ou les calcule si elles n'existent pas encore
+func GetTeam(teamID uuid.UUID) (models.TeamStats, error) {
+	var stats models.TeamStats
+	err := database.DB.Where("team_id = ?", teamID).First(&stats).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return ComputeTeam(teamID)
+	}
+	return stats, err
+}
+
+// UserView : statistiques exposées sur un profil, l'ancienneté est calculée à la lecture
+func UserView(stats models.UserStats, user models.User) map[string]interface{} {
+	return map[string]interface{}{
+		"teams_joined":     stats.TeamsJoined,
+		"teams_owned":      stats.TeamsOwned,
+		"account_age_days": int(time.Since(user.CreatedOn).Hours() / 24),
+		"activity_events":  stats.ActivityEvents,
+		"active_days":      stats.ActiveDays,
+		"current_streak":   stats.CurrentStreak,
+		"longest_streak":   stats.LongestStreak,
+		"last_active_on":   stats.LastActiveOn,
+		"computed_at":      stats.ComputedAt,
+	}
+}
+
+func TeamView(stats models.TeamStats) map[string]interface{} {
+	return map[string]interface{}{
+		"members":             stats.Members,
+		"active_members_30d":  stats.ActiveMembers,
+		"sub_teams":           stats.SubTeams,
+		"activity_events_30d": stats.ActivityEvents,
+		"active_days_30d":     stats.ActiveDays,
+		"computed_at":         stats.ComputedAt,
+	}
+}
@@ -0,0 +1,108 @@
+package storage_service
+
+import (
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestLocalRoundTrip(t *testing.T) {
+	root := t.TempDir()
+	local, err := NewLocal(root)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := local.Put("avatars/u1/v1/64.png", []byte("first"), "image/png"); err != nil {
+		t.Fatalf("Put() error = %v", err)
+	}
+	// Un second Put remplace le fichier
+	if err := local.Put("avatars/u1/v1/64.png", []byte("second"), "image/png"); err != nil {
+		t.Fatalf("Put() error = %v", err)
+	}
+
+	body, object, err := local.Get("avatars/u1/v1/64.png")
+	if err != nil {
+		t.Fatalf("Get() error = %v", err)
+	}
+	data, _ := io.ReadAll(body)
+	body.Close()
+	if string(data) != "second" {
+		t.Errorf("Get() body = %q, want %q", data, "second")
+	}
+	if object.Size != 6 || object.ContentType != "image/png" || object.ETag == "" || object.LastModified.IsZero() {
+		t.Errorf("Get() object = %+v", object)
+	}
+
+	// Aucun fichier temporaire ne reste après l'écriture
+	entries, _ := os.ReadDir(filepath.Join(root, "avatars", "u1", "v1"))
+	if len(entries) != 1 {
+		t.Errorf("directory contains %d entries, want 1", len(entries))
+	}
+
+	if err := local.Delete("avatars/u1/v1/64.png"); err != nil {
+		t.Fatalf("Delete() error = %v", err)
+	}
+	if _, _, err := local.Get("avatars/u1/v1/64.png"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("Get() after Delete error = %v, want ErrNotFound", err)
+	}
+	if err := local.Delete("avatars/u1/v1/64.png"); err != nil {
+		t.Errorf("Delete() of a missing file error = %v, want nil", err)
+	}
+}
+
+func TestLocalStaysUnderRoot(t *testing.T) {
+	parent := t.TempDir()
+	local, err := NewLocal(filepath.Join(parent, "uploads"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, key := range []string{"", "/etc/passwd", "../escape.txt", "a/../../escape.txt", "a//b", `a\b`, "./a"} {
+		if err := local.Put(key, []byte("x"), "text/plain"); err == nil {
+			t.Errorf("Put(%q) succeeded, want an error", key)
+		}
+		if _, _, err := local.Get(key); err == nil {
+			t.Errorf("Get(%q) succeeded, want an error", key)
+		}
+		if err := local.Delete(key); err == nil {
+			t.Errorf("Delete(%q) succeeded, want an error", key)
+		}
+	}
+
+	if _, err := os.Stat(filepath.Join(parent, "escape.txt")); !os.IsNotExist(err) {
+		t.Errorf("a file was written outside the root")
+	}
+}
+
+func TestLocalPutStream(t *testing.T) {
+	local, err := NewLocal(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	data := strings.Repeat("zip data ", 1000)
+
+	if err := local.PutStream("exports/u1/e1.zip", strings.NewReader(data), int64(len(data)), "application/zip"); err != nil {
+		t.Fatalf("PutStream() error = %v", err)
+	}
+	body, object, err := local.Get("exports/u1/e1.zip")
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, _ := io.ReadAll(body)
+	body.Close()
+	if string(got) != data || object.Size != int64(len(data)) {
+		t.Errorf("Get() = %d bytes (size %d), want %d", len(got), object.Size, len(data))
+	}
+
+	// Un flux plus court que la taille annoncée n'est pas publié
+	if err := local.PutStream("exports/u1/e2.zip", strings.NewReader("short"), 10, "application/zip"); err == nil {
+		t.Error("PutStream() of a truncated stream succeeded")
+	}
+	if _, _, err := local.Get("exports/u1/e2.zip"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("truncated file was published: %v", err)
+	}
+}
@@ -0,0 +1,82 @@
+package storage_service
+
+import (
+	"errors"
+	"fmt"
+	"gox/utils"
+	"io"
+	"strings"
+	"sync"
+	"time"
+)
+
+// ErrNotFound est retournée quand l'objet demandé n'existe pas
+var ErrNotFound = errors.New("object not found")
+
+// Object décrit un fichier stocké
+type Object struct {
+	Key          string
+	Size         int64
+	ContentType  string
+	LastModified time.Time
+	ETag         string
+}
+
+// Storage abstrait l'endroit où sont rangés les fichiers (disque local, S3, MinIO...)
+type Storage interface {
+	Put(key string, data []byte, contentType string) error
+	// PutStream écrit size octets lus depuis body, sans les charger en mémoire
+	PutStream(key string, body io.Reader, size int64, contentType string) error
+	Get(key string) (io.ReadCloser, Object, error)
+	Delete(key string) error
+}
+
+var (
+	defaultStorage Storage
+	defaultErr     error
+	defaultOnce    sync.Once
+)
+
+// Default retourne le stockage configuré par STORAGE_DRIVER (local par défaut, ou s3)
+func Default() (Storage, error) {
+	defaultOnce.Do(func() {
+		if defaultStorage != nil {
+			return
+		}
+		switch utils.GetEnv("STORAGE_DRIVER", "local") {
+		case "local":
+			defaultStorage, defaultErr = NewLocal(utils.GetEnv("STORAGE_LOCAL_DIR", "./_data/uploads"))
+		case "s3":
+			defaultStorage, defaultErr = NewS3(S3Config{
+				Endpoint:        utils.GetEnv("S3_ENDPOINT", "https://s3.amazonaws.com"),
+				Region:          utils.GetEnv("S3_REGION", "us-east-1"),
+				Bucket:          utils.GetEnv("S3_BUCKET", ""),
+				AccessKeyID:     utils.GetEnv("S3_ACCESS_KEY_ID", ""),
+				SecretAccessKey: utils.GetEnv("S3_SECRET_ACCESS_KEY", ""),
+				PathStyle:       utils.GetEnv("S3_PATH_STYLE", "false") == "true",
+			})
+		default:
+			defaultErr = fmt.Errorf("unknown storage driver: %s", utils.GetEnv("STORAGE_DRIVER", ""))
+		}
+	})
+	return defaultStorage, defaultErr
+}
+
+// SetDefault remplace le stockage par défaut (ex : un faux stockage en mémoire)
+func SetDefault(s Storage) {
+	defaultOnce.Do(func() {})
+	defaultStorage, defaultErr = s, nil
+}
+
+// ValidateKey refuse les clés vides, absolues ou qui remontent l'arborescence
+func ValidateKey(key string) error {
+	if key == "" || strings.HasPrefix(key, "/") || strings.Contains(key, "\\") {
+		return fmt.Errorf("invalid storage key: %q", key)
+	}
+	for _, part := range strings.Split(key, "/") {
+		if part == "" || part == "." || part == ".." {
+			return fmt.Errorf("invalid storage key: %q", key)
+		}
+	}
+	return nil
+}
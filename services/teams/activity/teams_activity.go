@@ -0,0 +1,126 @@
+package team_activity_service
+
+import (
+	"gox/database"
+	"gox/database/models"
+	"gox/utils"
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// Types de cibles d'un événement
+const (
+	TargetTeam         = "team"
+	TargetMember       = "member"
+	TargetRole         = "role"
+	TargetInvitation   = "invitation"
+	TargetOwnership    = "ownership_transfer"
+	TargetDomain       = "domain"
+	TargetJoin         = "join_request"
+	TargetSubscription = "subscription"
+)
+
+// Changes associe un champ modifié à ses valeurs avant/après
+type Changes map[string]models.TeamActivityChange
+
+func Change(before, after any) models.TeamActivityChange {
+	return models.TeamActivityChange{Before: before, After: after}
+}
+
+// Record enregistre un événement dans la transaction db (ou database.DB hors transaction).
+// actorID vaut uuid.Nil pour une action système.
+// Le journal ne bloque jamais l'action journalisée : un échec est loggé puis ignoré. L'insertion passe par un
+// savepoint pour qu'un échec n'interrompe pas la transaction appelante.
+func Record(db *gorm.DB, teamID, actorID uuid.UUID, action, targetType, targetID string, changes Changes) {
+	activity := models.TeamActivity{
+		TeamID:     teamID,
+		Action:     action,
+		TargetType: targetType,
+		TargetID:   targetID,
+		Changes:    changes,
+	}
+	if actorID != uuid.Nil {
+		activity.ActorID = &actorID
+	}
+
+	err := db.Transaction(func(tx *gorm.DB) error {
+		return tx.Create(&activity).Error
+	})
+	if err != nil {
+		utils.ConsoleLog("⚠️ Error recording team activity %s on %s: %v", action, teamID, err)
+	}
+}
+
+type Filters struct {
+	Action     string
+	ActorID    *uuid.UUID
+	TargetType string
+	TargetID   string
+	Since      *time.Time
+	Until      *time.Time
+}
+
+// Pagination : page commence à 1, perPage est borné
+const (
+	DefaultPerPage = 50
+	MaxPerPage     = 200
+)
+
+// List retourne une page d'événements (du plus récent au plus ancien) et le nombre total d'événements filtrés
+func List(teamID uuid.UUID, filters Filters, page, perPage int) ([]models.TeamActivity, int64, error) {
+	if page < 1 {
+		page = 1
+	}
+	if perPage < 1 {
+		perPage = DefaultPerPage
+	}
+	if perPage > MaxPerPage {
+		perPage = MaxPerPage
+	}
+
+	// Requête avec filtres ; une action se terminant par "." filtre sur un préfixe (ex : "member.")
+	query := database.DB.Model(&models.TeamActivity{}).Where("team_id = ?", teamID)
+	if filters.Action != "" {
+		if filters.Action[len(filters.Action)-1] == '.' {
+			query = query.Where("action LIKE ?", filters.Action+"%")
+		} else {
+			query = query.Where("action = ?", filters.Action)
+		}
+	}
+	if filters.ActorID != nil {
+		query = query.Where("actor_id = ?", *filters.ActorID)
+	}
+	if filters.TargetType != "" {
+		query = query.Where("target_type = ?", filters.TargetType)
+	}
+	if filters.TargetID != "" {
+		query = query.Where("target_id = ?", filters.TargetID)
+	}
+	if filters.Since != nil {
+		query = query.Where("created_on >= ?", *filters.Since)
+	}
+	if filters.Until != nil {
+		query = query.Where("created_on < ?", *filters.Until)
+	}
+
+	var total int64
+	if err := query.Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+
+	var activities []models.TeamActivity
+	result := query.Preload("Actor").
+		Order("created_on DESC").
+		Offset((page - 1) * perPage).
+		Limit(perPage).
+		Find(&activities)
+
+	// Vérification des erreurs GORM
+	if result.Error != nil {
+		return nil, 0, result.Error
+	}
+
+	return activities, total, nil
+}
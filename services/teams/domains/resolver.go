@@ -0,0 +1,22 @@
+package team_domain_service
+
+import (
+	"context"
+	"net"
+)
+
+// TXTResolver résout les enregistrements DNS TXT d'un nom de domaine.
+// net.DefaultResolver convient en production ; un faux resolver peut être injecté avec SetResolver.
+type TXTResolver interface {
+	LookupTXT(ctx context.Context, name string) ([]string, error)
+}
+
+var resolver TXTResolver = net.DefaultResolver
+
+// SetResolver remplace le resolver utilisé pour vérifier les domaines
+func SetResolver(r TXTResolver) {
+	if r == nil {
+		r = net.DefaultResolver
+	}
+	resolver = r
+}
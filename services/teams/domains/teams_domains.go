@@ -0,0 +1,284 @@
+package team_domain_service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"gox/database"
+	"gox/database/models"
+	team_service "gox/services/teams"
+	team_join_request_service "gox/services/teams/joinrequests"
+	team_member_service "gox/services/teams/members"
+	"gox/utils"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Préfixe de l'enregistrement TXT à publier : _gox-verification.<domaine> TXT "gox-verification=<token>"
+const (
+	RecordPrefix = "_gox-verification."
+	ValuePrefix  = "gox-verification="
+)
+
+// Les domaines de messageries publiques ne peuvent pas être revendiqués
+var publicDomains = map[string]bool{
+	"gmail.com":      true,
+	"googlemail.com": true,
+	"outlook.com":    true,
+	"hotmail.com":    true,
+	"live.com":       true,
+	"yahoo.com":      true,
+	"icloud.com":     true,
+	"proton.me":      true,
+	"protonmail.com": true,
+	"gmx.com":        true,
+}
+
+var domainPattern = regexp.MustCompile(`^([a-z0-9]([a-z0-9-]{0,61}[a-z0-9])?\.)+[a-z]{2,63}$`)
+
+// ErrDomainNotVerified est retournée lorsque l'enregistrement TXT attendu est introuvable
+var ErrDomainNotVerified = errors.New("verification record not found")
+
+func normalizeDomain(domain string) string {
+	return strings.TrimSuffix(strings.ToLower(strings.TrimSpace(domain)), ".")
+}
+
+// RecordName retourne le nom DNS sur lequel publier l'enregistrement TXT
+func RecordName(domain models.TeamDomain) string {
+	return RecordPrefix + domain.Domain
+}
+
+// RecordValue retourne la valeur TXT attendue
+func RecordValue(domain models.TeamDomain) string {
+	return ValuePrefix + domain.VerificationToken
+}
+
+// Claim revendique un domaine pour une Team company ; il devra ensuite être vérifié par DNS
+func Claim(teamID uuid.UUID, domain string, policy models.TeamDomainJoinPolicy, defaultRole models.TeamMemberRole) (models.TeamDomain, error) {
+	domain = normalizeDomain(domain)
+	if !domainPattern.MatchString(domain) {
+		return models.TeamDomain{}, fmt.Errorf("invalid domain")
+	}
+	if publicDomains[domain] {
+		return models.TeamDomain{}, fmt.Errorf("public email domains cannot be claimed")
+	}
+	if policy == "" {
+		policy = models.TeamDomainJoinPolicyRequest
+	}
+	if !policy.IsValid() {
+		return models.TeamDomain{}, fmt.Errorf("invalid join policy")
+	}
+	if defaultRole == "" {
+		defaultRole = models.TeamMemberRoleSpectator
+	}
+	if !defaultRole.IsValid() || defaultRole == models.TeamMemberRoleOwner {
+		return models.TeamDomain{}, fmt.Errorf("invalid default role")
+	}
+
+	// Seules les Teams company peuvent revendiquer un domaine
+	var team models.Team
+	if err := database.DB.Where("id = ?", teamID).First(&team).Error; err != nil {
+		return models.TeamDomain{}, fmt.Errorf("team not found")
+	}
+	if team.Type != models.TeamTypeCompany {
+		return models.TeamDomain{}, fmt.Errorf("only company teams can claim a domain")
+	}
+
+	var count int64
+	if err := database.DB.Model(&models.TeamDomain{}).Where("domain = ? AND (team_id = ? OR verified_at IS NOT NULL)", domain, teamID).Count(&count).Error; err != nil {
+		return models.TeamDomain{}, err
+	}
+	if count > 0 {
+		return models.TeamDomain{}, fmt.Errorf("domain already claimed")
+	}
+
+	token, err := utils.GenerateToken(16)
+	if err != nil {
+		return models.TeamDomain{}, fmt.Errorf("error generating token: %v", err)
+	}
+
+	// Création du domaine
+	teamDomain := models.TeamDomain{
+		TeamID:            teamID,
+		Domain:            domain,
+		VerificationToken: token,
+		JoinPolicy:        policy,
+		DefaultRole:       defaultRole,
+	}
+
+	// Insertion en base
+	if err := database.DB.Create(&teamDomain).Error; err != nil {
+		return models.TeamDomain{}, fmt.Errorf("error claiming domain: %v", err)
+	}
+
+	return teamDomain, nil
+}
+
+func GetAll(teamID uuid.UUID) ([]models.TeamDomain, error) {
+	var domains []models.TeamDomain
+
+	// Récupération des domaines de la Team
+	result := database.DB.Where("team_id = ?", teamID).Order("domain").Find(&domains)
+
+	// Vérification des erreurs GORM
+	if result.Error != nil {
+		return nil, result.Error
+	}
+
+	return domains, nil
+}
+
+func Get(teamID, domainID uuid.UUID) (models.TeamDomain, error) {
+	var domain models.TeamDomain
+
+	// Récupération du domaine
+	result := database.DB.Where("team_id = ? AND id = ?", teamID, domainID).First(&domain)
+
+	// Vérification des erreurs GORM
+	if result.Error != nil {
+		return models.TeamDomain{}, result.Error
+	}
+
+	return domain, nil
+}
+
+// Verify interroge le DNS et marque le domaine comme vérifié si l'enregistrement TXT attendu est présent
+func Verify(teamID, domainID uuid.UUID) (models.TeamDomain, error) {
+	domain, err := Get(teamID, domainID)
+	if err != nil {
+		return models.TeamDomain{}, fmt.Errorf("domain not found")
+	}
+	if domain.VerifiedAt != nil {
+		return domain, nil
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	if err := checkRecord(ctx, domain); err != nil {
+		return models.TeamDomain{}, err
+	}
+
+	// L'index unique partiel empêche deux Teams de vérifier le même domaine
+	now := time.Now()
+	if err := database.DB.Model(&models.TeamDomain{}).Where("id = ?", domain.ID).Update("verified_at", now).Error; err != nil {
+		return models.TeamDomain{}, fmt.Errorf("domain is already verified by another team")
+	}
+	domain.VerifiedAt = &now
+
+	return domain, nil
+}
+
+// checkRecord vérifie que l'enregistrement TXT attendu est publié pour le domaine
+func checkRecord(ctx context.Context, domain models.TeamDomain) error {
+	records, err := resolver.LookupTXT(ctx, RecordName(domain))
+	if err != nil {
+		return fmt.Errorf("%w: %v", ErrDomainNotVerified, err)
+	}
+
+	for _, record := range records {
+		if strings.TrimSpace(record) == RecordValue(domain) {
+			return nil
+		}
+	}
+
+	return ErrDomainNotVerified
+}
+
+// UpdatePolicy modifie la politique d'adhésion et le rôle attribué aux nouveaux membres
+func UpdatePolicy(teamID, domainID uuid.UUID, policy models.TeamDomainJoinPolicy, defaultRole models.TeamMemberRole) error {
+	if !policy.IsValid() {
+		return fmt.Errorf("invalid join policy")
+	}
+	if !defaultRole.IsValid() || defaultRole == models.TeamMemberRoleOwner {
+		return fmt.Errorf("invalid default role")
+	}
+
+	// Mise à jour du domaine
+	result := database.DB.Model(&models.TeamDomain{}).
+		Where("team_id = ? AND id = ?", teamID, domainID).
+		Updates(map[string]interface{}{
+			"join_policy":  policy,
+			"default_role": defaultRole,
+		})
+
+	// Vérification des erreurs GORM
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return fmt.Errorf("domain not found")
+	}
+
+	return nil
+}
+
+func Delete(teamID, domainID uuid.UUID) error {
+	// Suppression du domaine
+	result := database.DB.Where("team_id = ? AND id = ?", teamID, domainID).Delete(&models.TeamDomain{})
+
+	// Vérification des erreurs GORM
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return fmt.Errorf("domain not found")
+	}
+
+	return nil
+}
+
+// emailDomain retourne le domaine normalisé d'une adresse email ("" si l'adresse n'en a pas)
+func emailDomain(email string) string {
+	at := strings.LastIndex(email, "@")
+	if at < 0 {
+		return ""
+	}
+	return normalizeDomain(email[at+1:])
+}
+
+// OnEmailVerified rattache l'utilisateur aux Teams ayant vérifié le domaine de son email : adhésion directe
+// (politique auto) ou demande d'adhésion en attente (politique request). À n'appeler qu'une fois l'email
+// vérifié : sans cela, n'importe qui pourrait s'inscrire avec une adresse du domaine et entrer dans la Team.
+// Les erreurs sont journalisées sans bloquer la vérification.
+func OnEmailVerified(userID uuid.UUID, email string) {
+	domain := emailDomain(email)
+	if domain == "" {
+		return
+	}
+
+	var domains []models.TeamDomain
+	result := database.DB.Joins("Team").
+		Where("team_domains.domain = ? AND team_domains.verified_at IS NOT NULL", domain).
+		Where(`"Team".archived_at IS NULL AND "Team".type = ?`, models.TeamTypeCompany).
+		Find(&domains)
+	if result.Error != nil {
+		utils.ConsoleLog("⚠️ Error matching domain %s: %v", domain, result.Error)
+		return
+	}
+
+	for _, teamDomain := range domains {
+		// Une Team suspendue ne reçoit pas de nouveaux membres, pas même sur demande
+		if err := team_service.CheckNotSuspended(teamDomain.Team); err != nil {
+			if !errors.Is(err, team_service.ErrTeamSuspended) {
+				utils.ConsoleLog("⚠️ Domain join failed for user %s in team %s: %v", userID, teamDomain.TeamID, err)
+			}
+			continue
+		}
+
+		var err error
+		switch teamDomain.JoinPolicy {
+		case models.TeamDomainJoinPolicyAuto:
+			err = team_member_service.Add(teamDomain.TeamID, userID, teamDomain.DefaultRole, uuid.Nil)
+		default:
+			domainID := teamDomain.ID
+			_, err = team_join_request_service.Create(teamDomain.TeamID, userID, &domainID)
+		}
+		if err != nil {
+			utils.ConsoleLog("⚠️ Domain join failed for user %s in team %s: %v", userID, teamDomain.TeamID, err)
+		}
+	}
+}
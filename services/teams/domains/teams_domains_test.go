@@ -0,0 +1,130 @@
+package team_domain_service
+
+import (
+	"context"
+	"errors"
+	"gox/database/models"
+	"testing"
+
+	"github.com/google/uuid"
+)
+
+// fakeResolver répond à partir d'une table statique, sans requête DNS
+type fakeResolver struct {
+	records map[string][]string
+	err     error
+	lookups []string
+}
+
+func (f *fakeResolver) LookupTXT(ctx context.Context, name string) ([]string, error) {
+	f.lookups = append(f.lookups, name)
+	if f.err != nil {
+		return nil, f.err
+	}
+	return f.records[name], nil
+}
+
+func useResolver(t *testing.T, r TXTResolver) {
+	t.Helper()
+	SetResolver(r)
+	t.Cleanup(func() { SetResolver(nil) })
+}
+
+func TestCheckRecord(t *testing.T) {
+	domain := models.TeamDomain{Domain: "acme.com", VerificationToken: "abc123"}
+
+	tests := []struct {
+		name     string
+		resolver *fakeResolver
+		wantErr  bool
+	}{
+		{
+			name:     "record published",
+			resolver: &fakeResolver{records: map[string][]string{"_gox-verification.acme.com": {"gox-verification=abc123"}}},
+		},
+		{
+			name:     "record among others, with surrounding spaces",
+			resolver: &fakeResolver{records: map[string][]string{"_gox-verification.acme.com": {"v=spf1 -all", "  gox-verification=abc123 "}}},
+		},
+		{
+			name:     "no record",
+			resolver: &fakeResolver{records: map[string][]string{}},
+			wantErr:  true,
+		},
+		{
+			name:     "token of another claim",
+			resolver: &fakeResolver{records: map[string][]string{"_gox-verification.acme.com": {"gox-verification=other"}}},
+			wantErr:  true,
+		},
+		{
+			name:     "record on the bare domain only",
+			resolver: &fakeResolver{records: map[string][]string{"acme.com": {"gox-verification=abc123"}}},
+			wantErr:  true,
+		},
+		{
+			name:     "resolver failure",
+			resolver: &fakeResolver{err: errors.New("no such host")},
+			wantErr:  true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			useResolver(t, tt.resolver)
+
+			err := checkRecord(context.Background(), domain)
+			if tt.wantErr {
+				if !errors.Is(err, ErrDomainNotVerified) {
+					t.Fatalf("checkRecord() error = %v, want ErrDomainNotVerified", err)
+				}
+			} else if err != nil {
+				t.Fatalf("checkRecord() error = %v, want nil", err)
+			}
+
+			if len(tt.resolver.lookups) != 1 || tt.resolver.lookups[0] != "_gox-verification.acme.com" {
+				t.Fatalf("lookups = %v, want [_gox-verification.acme.com]", tt.resolver.lookups)
+			}
+		})
+	}
+}
+
+func TestEmailDomain(t *testing.T) {
+	tests := map[string]string{
+		"jane@acme.com":          "acme.com",
+		"Jane@ACME.com":          "acme.com",
+		"jane@acme.com.":         "acme.com",
+		"odd@name@sub.acme.com":  "sub.acme.com",
+		"no-at-sign.example.com": "",
+	}
+
+	for email, want := range tests {
+		if got := emailDomain(email); got != want {
+			t.Errorf("emailDomain(%q) = %q, want %q", email, got, want)
+		}
+	}
+}
+
+// Les revendications invalides sont refusées avant tout accès à la base
+func TestClaimValidation(t *testing.T) {
+	tests := []struct {
+		name        string
+		domain      string
+		policy      models.TeamDomainJoinPolicy
+		defaultRole models.TeamMemberRole
+	}{
+		{name: "invalid domain", domain: "not a domain"},
+		{name: "missing tld", domain: "localhost"},
+		{name: "public email domain", domain: "Gmail.com"},
+		{name: "unknown policy", domain: "acme.com", policy: "everyone"},
+		{name: "owner as default role", domain: "acme.com", defaultRole: models.TeamMemberRoleOwner},
+		{name: "unknown default role", domain: "acme.com", defaultRole: "superuser"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := Claim(uuid.New(), tt.domain, tt.policy, tt.defaultRole); err == nil {
+				t.Fatalf("Claim(%q) succeeded, want an error", tt.domain)
+			}
+		})
+	}
+}
@@ -0,0 +1,337 @@
+package team_invitation_service
+
+import (
+	"errors"
+	"fmt"
+	"gox/database"
+	"gox/database/models"
+	mail_service "gox/services/mail"
+	team_service "gox/services/teams"
+	team_activity_service "gox/services/teams/activity"
+	team_member_service "gox/services/teams/members"
+	"gox/utils"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+var ErrInvitationPending = errors.New("an invitation is already pending for this email")
+
+// Durée de validité d'une invitation (en heures), configurable via INVITATION_TTL_HOURS
+func invitationTTL() time.Duration {
+	hours, err := strconv.Atoi(utils.GetEnv("INVITATION_TTL_HOURS", "168"))
+	if err != nil || hours <= 0 {
+		hours = 168
+	}
+	return time.Duration(hours) * time.Hour
+}
+
+func normalizeEmail(email string) string {
+	return strings.ToLower(strings.TrimSpace(email))
+}
+
+func sendInvitationMail(team models.Team, invitation models.TeamInvitation, token string) error {
+	appURL := strings.TrimRight(utils.GetEnv("APP_URL", "http://localhost:8080"), "/")
+
+	body := fmt.Sprintf(
+		"You have been invited to join the team \"%s\" as %s.\n\n"+
+			"Accept: %s/invitations/%s/accept\n"+
+			"Decline: %s/invitations/%s/decline\n\n"+
+			"No account yet? Register with this invitation token to join automatically: %s\n\n"+
+			"This invitation expires on %s.",
+		team.Name, invitation.Role,
+		appURL, token,
+		appURL, token,
+		token,
+		invitation.ExpiresAt.Format(time.RFC1123),
+	)
+
+	return mail_service.Send(invitation.Email, fmt.Sprintf("Invitation to join %s", team.Name), body)
+}
+
+func Create(teamID uuid.UUID, invitedByID uuid.UUID, email string, role models.TeamMemberRole) (models.TeamInvitation, error) {
+	// Vérification des champs requis
+	email = normalizeEmail(email)
+	if email == "" || !strings.Contains(email, "@") {
+		return models.TeamInvitation{}, fmt.Errorf("a valid email is required")
+	}
+	if !role.IsValid() {
+		return models.TeamInvitation{}, fmt.Errorf("invalid role")
+	}
+
+	// Vérification de l'existence de la Team
+	var team models.Team
+	if err := database.DB.Where("id = ?", teamID).First(&team).Error; err != nil {
+		return models.TeamInvitation{}, fmt.Errorf("team not found")
+	}
+	if team.Type == models.TeamTypePersonal {
+		return models.TeamInvitation{}, fmt.Errorf("personal teams can only have a single member")
+	}
+
+	// L'utilisateur invité est-il déjà membre ?
+	var count int64
+	if err := database.DB.Model(&models.TeamMember{}).
+		Joins("JOIN users ON users.id = team_members.member_id").
+		Where("team_members.team_id = ? AND LOWER(users.email) = ? AND team_members.left_at IS NULL", teamID, email).
+		Count(&count).Error; err != nil {
+		return models.TeamInvitation{}, fmt.Errorf("error checking membership: %v", err)
+	}
+	if count > 0 {
+		return models.TeamInvitation{}, fmt.Errorf("user is already a member of this team")
+	}
+
+	// Une seule invitation en attente par email et par Team
+	if err := database.DB.Model(&models.TeamInvitation{}).
+		Where("team_id = ? AND email = ? AND status = ? AND expires_at > ?", teamID, email, models.TeamInvitationStatusPending, time.Now()).
+		Count(&count).Error; err != nil {
+		return models.TeamInvitation{}, fmt.Errorf("error checking invitations: %v", err)
+	}
+	if count > 0 {
+		return models.TeamInvitation{}, ErrInvitationPending
+	}
+
+	token, err := utils.GenerateToken(32)
+	if err != nil {
+		return models.TeamInvitation{}, fmt.Errorf("error generating token: %v", err)
+	}
+
+	// Création de l'invitation
+	invitation := models.TeamInvitation{
+		TeamID:    teamID,
+		Email:     email,
+		Role:      role,
+		TokenHash: utils.HashToken(token),
+		Status:    models.TeamInvitationStatusPending,
+		ExpiresAt: time.Now().Add(invitationTTL()),
+	}
+	if invitedByID != uuid.Nil {
+		invitation.InvitedByID = &invitedByID
+	}
+
+	// Insertion en base
+	if err := database.DB.Create(&invitation).Error; err != nil {
+		return models.TeamInvitation{}, fmt.Errorf("error creating invitation: %v", err)
+	}
+
+	team_activity_service.Record(database.DB, teamID, invitedByID, "invitation.created", team_activity_service.TargetInvitation, invitation.ID.String(), team_activity_service.Changes{
+		"email": team_activity_service.Change(nil, email),
+		"role":  team_activity_service.Change(nil, role),
+	})
+
+	// Envoi de l'email
+	if err := sendInvitationMail(team, invitation, token); err != nil {
+		utils.ConsoleLog("⚠️ Invitation %s created but mail not sent: %v", invitation.ID, err)
+	}
+
+	return invitation, nil
+}
+
+func GetPending(teamID uuid.UUID) ([]models.TeamInvitation, error) {
+	var invitations []models.TeamInvitation
+
+	// Récupération des invitations en attente et non expirées
+	result := database.DB.
+		Where("team_id = ? AND status = ? AND expires_at > ?", teamID, models.TeamInvitationStatusPending, time.Now()).
+		Order("created_on DESC").
+		Find(&invitations)
+
+	// Vérification des erreurs GORM
+	if result.Error != nil {
+		return nil, result.Error
+	}
+
+	return invitations, nil
+}
+
+func Get(teamID uuid.UUID, invitationID uuid.UUID) (models.TeamInvitation, error) {
+	var invitation models.TeamInvitation
+
+	// Récupération de l'invitation
+	result := database.DB.Where("team_id = ? AND id = ?", teamID, invitationID).First(&invitation)
+
+	// Vérification des erreurs GORM
+	if result.Error != nil {
+		return models.TeamInvitation{}, result.Error
+	}
+
+	return invitation, nil
+}
+
+// GetByToken retourne l'invitation en attente correspondant au token (non expirée)
+func GetByToken(token string) (models.TeamInvitation, error) {
+	var invitation models.TeamInvitation
+
+	result := database.DB.Preload("Team").Where("token_hash = ?", utils.HashToken(token)).First(&invitation)
+	if result.Error != nil {
+		return models.TeamInvitation{}, fmt.Errorf("invitation not found")
+	}
+
+	if invitation.Status != models.TeamInvitationStatusPending {
+		return models.TeamInvitation{}, fmt.Errorf("invitation is %s", invitation.Status)
+	}
+	if invitation.ExpiresAt.Before(time.Now()) {
+		return models.TeamInvitation{}, fmt.Errorf("invitation has expired")
+	}
+
+	return invitation, nil
+}
+
+// Resend génère un nouveau token (l'ancien lien devient invalide) et repousse l'expiration
+func Resend(teamID uuid.UUID, invitationID uuid.UUID, actorID uuid.UUID) (models.TeamInvitation, error) {
+	invitation, err := Get(teamID, invitationID)
+	if err != nil {
+		return models.TeamInvitation{}, fmt.Errorf("invitation not found")
+	}
+	if invitation.Status != models.TeamInvitationStatusPending {
+		return models.TeamInvitation{}, fmt.Errorf("invitation is %s", invitation.Status)
+	}
+
+	token, err := utils.GenerateToken(32)
+	if err != nil {
+		return models.TeamInvitation{}, fmt.Errorf("error generating token: %v", err)
+	}
+
+	invitation.TokenHash = utils.HashToken(token)
+	invitation.ExpiresAt = time.Now().Add(invitationTTL())
+
+	// Mise à jour de l'invitation
+	result := database.DB.Model(&models.TeamInvitation{}).
+		Where("id = ?", invitation.ID).
+		Updates(map[string]interface{}{
+			"token_hash": invitation.TokenHash,
+			"expires_at": invitation.ExpiresAt,
+		})
+	if result.Error != nil {
+		return models.TeamInvitation{}, result.Error
+	}
+
+	var team models.Team
+	if err := database.DB.Where("id = ?", teamID).First(&team).Error; err != nil {
+		return models.TeamInvitation{}, fmt.Errorf("team not found")
+	}
+	if err := sendInvitationMail(team, invitation, token); err != nil {
+		return models.TeamInvitation{}, err
+	}
+
+	team_activity_service.Record(database.DB, teamID, actorID, "invitation.resent", team_activity_service.TargetInvitation, invitation.ID.String(), nil)
+
+	return invitation, nil
+}
+
+func Revoke(teamID uuid.UUID, invitationID uuid.UUID, actorID uuid.UUID) error {
+	// Seules les invitations en attente peuvent être révoquées
+	result := database.DB.Model(&models.TeamInvitation{}).
+		Where("team_id = ? AND id = ? AND status = ?", teamID, invitationID, models.TeamInvitationStatusPending).
+		Updates(map[string]interface{}{
+			"status":       models.TeamInvitationStatusRevoked,
+			"responded_at": time.Now(),
+		})
+
+	// Vérification des erreurs GORM
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return fmt.Errorf("pending invitation not found")
+	}
+
+	team_activity_service.Record(database.DB, teamID, actorID, "invitation.revoked", team_activity_service.TargetInvitation, invitationID.String(), team_activity_service.Changes{
+		"status": team_activity_service.Change(models.TeamInvitationStatusPending, models.TeamInvitationStatusRevoked),
+	})
+
+	return nil
+}
+
+// Accept transforme l'invitation en TeamMember, pour l'utilisateur dont l'email correspond à l'invitation
+func Accept(token string, userID uuid.UUID) (models.TeamInvitation, error) {
+	invitation, err := GetByToken(token)
+	if err != nil {
+		return models.TeamInvitation{}, err
+	}
+
+	var user models.User
+	if err := database.DB.Where("id = ?", userID).First(&user).Error; err != nil {
+		return models.TeamInvitation{}, fmt.Errorf("user not found")
+	}
+	if normalizeEmail(user.Email) != invitation.Email {
+		return models.TeamInvitation{}, fmt.Errorf("invitation was sent to another email address")
+	}
+	if invitation.Team.Type == models.TeamTypePersonal {
+		return models.TeamInvitation{}, fmt.Errorf("personal teams can only have a single member")
+	}
+	if invitation.Team.IsArchived() {
+		return models.TeamInvitation{}, fmt.Errorf("team is archived")
+	}
+	if err := team_service.CheckNotSuspended(invitation.Team); err != nil {
+		return models.TeamInvitation{}, err
+	}
+
+	err = database.DB.Transaction(func(tx *gorm.DB) error {
+		// Le statut est re-vérifié dans la requête pour éviter une double acceptation
+		result := tx.Model(&models.TeamInvitation{}).
+			Where("id = ? AND status = ?", invitation.ID, models.TeamInvitationStatusPending).
+			Updates(map[string]interface{}{
+				"status":       models.TeamInvitationStatusAccepted,
+				"responded_at": time.Now(),
+			})
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return errors.New("invitation is no longer pending")
+		}
+
+		var count int64
+		if err := tx.Model(&models.TeamMember{}).Where("team_id = ? AND member_id = ?", invitation.TeamID, userID).Where(team_member_service.CurrentCondition).Count(&count).Error; err != nil {
+			return err
+		}
+		if count > 0 {
+			return errors.New("user is already a member of this team")
+		}
+
+		if err := tx.Create(&models.TeamMember{
+			TeamID:   invitation.TeamID,
+			MemberID: userID,
+			Role:     invitation.Role,
+		}).Error; err != nil {
+			return err
+		}
+
+		team_activity_service.Record(tx, invitation.TeamID, userID, "invitation.accepted", team_activity_service.TargetMember, userID.String(), team_activity_service.Changes{
+			"role": team_activity_service.Change(nil, invitation.Role),
+		})
+		return nil
+	})
+	if err != nil {
+		return models.TeamInvitation{}, err
+	}
+
+	invitation.Status = models.TeamInvitationStatusAccepted
+	return invitation, nil
+}
+
+// Decline refuse l'invitation. Le token suffit : pas besoin d'être authentifié
+func Decline(token string) error {
+	invitation, err := GetByToken(token)
+	if err != nil {
+		return err
+	}
+
+	result := database.DB.Model(&models.TeamInvitation{}).
+		Where("id = ? AND status = ?", invitation.ID, models.TeamInvitationStatusPending).
+		Updates(map[string]interface{}{
+			"status":       models.TeamInvitationStatusDeclined,
+			"responded_at": time.Now(),
+		})
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return fmt.Errorf("invitation is no longer pending")
+	}
+
+	return nil
+}
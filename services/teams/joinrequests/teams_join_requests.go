@@ -0,0 +1,151 @@
+package team_join_request_service
+
+import (
+	"errors"
+	"fmt"
+	"gox/database"
+	"gox/database/models"
+	team_service "gox/services/teams"
+	team_activity_service "gox/services/teams/activity"
+	team_member_service "gox/services/teams/members"
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// Create enregistre une demande d'adhésion en attente, sauf si l'utilisateur est déjà membre ou a déjà une demande en cours
+func Create(teamID, userID uuid.UUID, domainID *uuid.UUID) (models.TeamJoinRequest, error) {
+	var count int64
+	if err := database.DB.Model(&models.TeamMember{}).Where("team_id = ? AND member_id = ?", teamID, userID).Where(team_member_service.CurrentCondition).Count(&count).Error; err != nil {
+		return models.TeamJoinRequest{}, err
+	}
+	if count > 0 {
+		return models.TeamJoinRequest{}, fmt.Errorf("user is already a member of this team")
+	}
+
+	if err := database.DB.Model(&models.TeamJoinRequest{}).Where("team_id = ? AND user_id = ? AND status = ?", teamID, userID, models.TeamJoinRequestStatusPending).Count(&count).Error; err != nil {
+		return models.TeamJoinRequest{}, err
+	}
+	if count > 0 {
+		return models.TeamJoinRequest{}, fmt.Errorf("a join request is already pending")
+	}
+
+	// Création de la demande
+	request := models.TeamJoinRequest{
+		TeamID:   teamID,
+		UserID:   userID,
+		DomainID: domainID,
+		Status:   models.TeamJoinRequestStatusPending,
+	}
+
+	// Insertion en base
+	if err := database.DB.Create(&request).Error; err != nil {
+		return models.TeamJoinRequest{}, fmt.Errorf("error creating join request: %v", err)
+	}
+
+	return request, nil
+}
+
+func GetPending(teamID uuid.UUID) ([]models.TeamJoinRequest, error) {
+	var requests []models.TeamJoinRequest
+
+	// Récupération des demandes en attente
+	result := database.DB.Preload("User").
+		Where("team_id = ? AND status = ?", teamID, models.TeamJoinRequestStatusPending).
+		Order("created_on").
+		Find(&requests)
+
+	// Vérification des erreurs GORM
+	if result.Error != nil {
+		return nil, result.Error
+	}
+
+	return requests, nil
+}
+
+func Get(teamID, requestID uuid.UUID) (models.TeamJoinRequest, error) {
+	var request models.TeamJoinRequest
+
+	// Récupération de la demande
+	result := database.DB.Preload("Domain").Where("team_id = ? AND id = ?", teamID, requestID).First(&request)
+
+	// Vérification des erreurs GORM
+	if result.Error != nil {
+		return models.TeamJoinRequest{}, result.Error
+	}
+
+	return request, nil
+}
+
+func respond(tx *gorm.DB, requestID, responderID uuid.UUID, status models.TeamJoinRequestStatus) error {
+	result := tx.Model(&models.TeamJoinRequest{}).
+		Where("id = ? AND status = ?", requestID, models.TeamJoinRequestStatusPending).
+		Updates(map[string]interface{}{
+			"status":          status,
+			"responded_at":    time.Now(),
+			"responded_by_id": responderID,
+		})
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return errors.New("join request is no longer pending")
+	}
+
+	return nil
+}
+
+// Approve ajoute le demandeur à la Team avec le rôle par défaut du domaine (spectator à défaut)
+func Approve(teamID, requestID, responderID uuid.UUID) error {
+	request, err := Get(teamID, requestID)
+	if err != nil {
+		return fmt.Errorf("join request not found")
+	}
+
+	team, err := team_service.Get(teamID)
+	if err != nil {
+		return err
+	}
+	if err := team_service.CheckNotSuspended(team); err != nil {
+		return err
+	}
+
+	role := models.TeamMemberRoleSpectator
+	if request.Domain != nil && request.Domain.DefaultRole.IsValid() && request.Domain.DefaultRole != models.TeamMemberRoleOwner {
+		role = request.Domain.DefaultRole
+	}
+
+	return database.DB.Transaction(func(tx *gorm.DB) error {
+		if err := respond(tx, request.ID, responderID, models.TeamJoinRequestStatusApproved); err != nil {
+			return err
+		}
+
+		if err := tx.Create(&models.TeamMember{
+			TeamID:   teamID,
+			MemberID: request.UserID,
+			Role:     role,
+		}).Error; err != nil {
+			return err
+		}
+
+		team_activity_service.Record(tx, teamID, responderID, "join_request.approved", team_activity_service.TargetMember, request.UserID.String(), team_activity_service.Changes{
+			"role": team_activity_service.Change(nil, role),
+		})
+		return nil
+	})
+}
+
+func Reject(teamID, requestID, responderID uuid.UUID) error {
+	request, err := Get(teamID, requestID)
+	if err != nil {
+		return fmt.Errorf("join request not found")
+	}
+
+	if err := respond(database.DB, request.ID, responderID, models.TeamJoinRequestStatusRejected); err != nil {
+		return err
+	}
+
+	team_activity_service.Record(database.DB, teamID, responderID, "join_request.rejected", team_activity_service.TargetJoin, request.ID.String(), nil)
+	return nil
+}
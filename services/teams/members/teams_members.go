@@ -1,19 +1,43 @@
 package team_member_service
 
 import (
+	"errors"
 	"fmt"
 	"gox/database"
 	"gox/database/models"
+	team_activity_service "gox/services/teams/activity"
 	"strconv"
+	"time"
 
 	"github.com/google/uuid"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
 )
 
-func GetAll(teamID uuid.UUID) ([]models.TeamMember, error) {
+// Les TeamMember ne sont jamais supprimés : un départ est horodaté (left_at) pour garder l'historique.
+// CurrentCondition : membre actuel (éventuellement suspendu).
+// ActiveCondition : membre actuel qui a effectivement accès à la Team.
+const (
+	CurrentCondition = "left_at IS NULL"
+	ActiveCondition  = "left_at IS NULL AND is_active = true AND is_accessible = true"
+)
+
+// ErrLastOwner est retournée lorsqu'une opération retirerait le dernier owner d'une Team
+var ErrLastOwner = errors.New("a team must always keep at least one owner")
+
+// ErrPersonalTeamMember est retournée lorsqu'on tente de modifier les membres d'une Team personnelle
+var ErrPersonalTeamMember = errors.New("personal teams can only have a single member")
+
+// GetAll retourne les membres actuels de la Team, ou tout l'historique si withHistory est vrai
+func GetAll(teamID uuid.UUID, withHistory bool) ([]models.TeamMember, error) {
 	var members []models.TeamMember
 
 	// Requête avec filtre : TeamID = teamID
-	result := database.DB.Where("team_id = ?", teamID).Find(&members)
+	query := database.DB.Preload("CustomRole").Where("team_id = ?", teamID)
+	if !withHistory {
+		query = query.Where(CurrentCondition)
+	}
+	result := query.Order("joined_at").Find(&members)
 
 	// Vérification des erreurs GORM
 	if result.Error != nil {
@@ -31,7 +55,7 @@ func Get(teamID uuid.UUID, teamMemberID string) (models.TeamMember, error) {
 		return models.TeamMember{}, fmt.Errorf("invalid team member ID: %v", err)
 	}
 	// Requête avec filtre : TeamID = teamID ET ID = teamMemberID
-	result := database.DB.Where("team_id = ? AND id = ?", teamID, memberIDInt).First(&member)
+	result := database.DB.Preload("CustomRole").Where("team_id = ? AND id = ?", teamID, memberIDInt).First(&member)
 
 	// Vérification des erreurs GORM
 	if result.Error != nil {
@@ -41,11 +65,12 @@ func Get(teamID uuid.UUID, teamMemberID string) (models.TeamMember, error) {
 	return member, nil
 }
 
+// GetByMemberId retourne l'adhésion actuelle (éventuellement suspendue) de l'utilisateur
 func GetByMemberId(teamID, memberID uuid.UUID) (models.TeamMember, error) {
 	var member models.TeamMember
 
 	// Requête avec filtre : TeamID = teamID ET MemberID = memberID
-	result := database.DB.Where("team_id = ? AND member_id = ?", teamID, memberID).First(&member)
+	result := database.DB.Preload("CustomRole").Where("team_id = ? AND member_id = ?", teamID, memberID).Where(CurrentCondition).First(&member)
 
 	// Vérification des erreurs GORM
 	if result.Error != nil {
@@ -55,12 +80,27 @@ func GetByMemberId(teamID, memberID uuid.UUID) (models.TeamMember, error) {
 	return member, nil
 }
 
-func Add(teamID uuid.UUID, memberID uuid.UUID, role models.TeamMemberRole) error {
+func Add(teamID uuid.UUID, memberID uuid.UUID, role models.TeamMemberRole, actorID uuid.UUID) error {
 	// Vérification des champs requis
 	if teamID == uuid.Nil || memberID == uuid.Nil || role == "" {
 		return nil
 	}
 
+	// Une Team personnelle n'a qu'un seul membre
+	var team models.Team
+	if err := database.DB.Where("id = ?", teamID).First(&team).Error; err != nil {
+		return err
+	}
+	if team.Type == models.TeamTypePersonal {
+		var count int64
+		if err := database.DB.Model(&models.TeamMember{}).Where("team_id = ?", teamID).Where(CurrentCondition).Count(&count).Error; err != nil {
+			return err
+		}
+		if count > 0 {
+			return ErrPersonalTeamMember
+		}
+	}
+
 	// Création du TeamMember
 	member := models.TeamMember{
 		TeamID:   teamID,
@@ -73,31 +113,323 @@ func Add(teamID uuid.UUID, memberID uuid.UUID, role models.TeamMemberRole) error
 		return err
 	}
 
+	team_activity_service.Record(database.DB, teamID, actorID, "member.added", team_activity_service.TargetMember, memberID.String(), team_activity_service.Changes{
+		"role": team_activity_service.Change(nil, role),
+	})
+
 	return nil
 }
 
-func Remove(teamID, memberID uuid.UUID) error {
-	// Suppression du TeamMember
-	result := database.DB.Where("team_id = ? AND member_id = ?", teamID, memberID).Delete(&models.TeamMember{})
+// ensureOwnerRemains vérifie que retirer le rôle owner à memberID laisse au moins un owner actif dans la Team.
+// Les lignes des owners sont verrouillées jusqu'à la fin de la transaction : deux rétrogradations ou départs
+// simultanés sont sérialisés, et le second voit le résultat du premier.
+func ensureOwnerRemains(tx *gorm.DB, teamID, memberID uuid.UUID) error {
+	var locked []uint
+	if err := tx.Model(&models.TeamMember{}).
+		Clauses(clause.Locking{Strength: "UPDATE"}).
+		Where("team_id = ? AND role = ?", teamID, models.TeamMemberRoleOwner).
+		Where(CurrentCondition).
+		Pluck("id", &locked).Error; err != nil {
+		return err
+	}
+
+	var member models.TeamMember
+	if err := tx.Where("team_id = ? AND member_id = ?", teamID, memberID).Where(CurrentCondition).First(&member).Error; err != nil {
+		return err
+	}
+	if member.Role != models.TeamMemberRoleOwner {
+		return nil
+	}
 
-	// Vérification des erreurs GORM
-	if result.Error != nil {
-		return result.Error
+	var owners int64
+	if err := tx.Model(&models.TeamMember{}).
+		Where("team_id = ? AND role = ? AND member_id <> ?", teamID, models.TeamMemberRoleOwner, memberID).
+		Where(ActiveCondition).
+		Count(&owners).Error; err != nil {
+		return err
+	}
+	if owners == 0 {
+		return ErrLastOwner
 	}
 
 	return nil
 }
 
-func UpdateRole(teamID, memberID uuid.UUID, role models.TeamMemberRole) error {
-	// Mise à jour du TeamMember
+// current retourne l'adhésion actuelle dans la transaction, pour calculer le diff d'un événement
+func current(tx *gorm.DB, teamID, memberID uuid.UUID) (models.TeamMember, error) {
+	var member models.TeamMember
+	err := tx.Where("team_id = ? AND member_id = ?", teamID, memberID).Where(CurrentCondition).First(&member).Error
+	return member, err
+}
+
+func CountOwners(teamID uuid.UUID) (int64, error) {
+	var count int64
+	result := database.DB.Model(&models.TeamMember{}).Where("team_id = ? AND role = ?", teamID, models.TeamMemberRoleOwner).Where(ActiveCondition).Count(&count)
+	if result.Error != nil {
+		return 0, result.Error
+	}
+
+	return count, nil
+}
+
+// Remove met fin à l'adhésion (départ volontaire ou retrait par un admin), sans effacer l'historique
+func Remove(db *gorm.DB, teamID, memberID, actorID uuid.UUID) error {
+	return db.Transaction(func(tx *gorm.DB) error {
+		// Le seul membre d'une Team personnelle ne peut pas la quitter
+		var team models.Team
+		if err := tx.Where("id = ?", teamID).First(&team).Error; err != nil {
+			return err
+		}
+		if team.Type == models.TeamTypePersonal {
+			return ErrPersonalTeamMember
+		}
+
+		// Le dernier owner ne peut pas être retiré
+		if err := ensureOwnerRemains(tx, teamID, memberID); err != nil {
+			return err
+		}
+
+		// Départ du TeamMember
+		result := tx.Model(&models.TeamMember{}).
+			Where("team_id = ? AND member_id = ?", teamID, memberID).
+			Where(CurrentCondition).
+			Update("left_at", time.Now())
+
+		// Vérification des erreurs GORM
+		if result.Error != nil {
+			return result.Error
+		}
+
+		action := "member.removed"
+		if actorID == memberID {
+			action = "member.left"
+		}
+		team_activity_service.Record(tx, teamID, actorID, action, team_activity_service.TargetMember, memberID.String(), nil)
+		return nil
+	})
+}
+
+func UpdateRole(teamID, memberID uuid.UUID, role models.TeamMemberRole, actorID uuid.UUID) error {
+	if !role.IsValid() {
+		return fmt.Errorf("invalid role")
+	}
+
+	return database.DB.Transaction(func(tx *gorm.DB) error {
+		before, err := current(tx, teamID, memberID)
+		if err != nil {
+			return err
+		}
+
+		// Le dernier owner ne peut pas être rétrogradé
+		if role != models.TeamMemberRoleOwner {
+			if err := ensureOwnerRemains(tx, teamID, memberID); err != nil {
+				return err
+			}
+		}
+
+		// Mise à jour du TeamMember (un rôle intégré remplace un éventuel rôle personnalisé)
+		result := tx.Model(&models.TeamMember{}).
+			Where("team_id = ? AND member_id = ?", teamID, memberID).
+			Where(CurrentCondition).
+			Updates(map[string]interface{}{
+				"role":            role,
+				"custom_role_id":  nil,
+				"role_changed_at": time.Now(),
+			})
+
+		// Vérification des erreurs GORM
+		if result.Error != nil {
+			return result.Error
+		}
+
+		team_activity_service.Record(tx, teamID, actorID, "member.role_changed", team_activity_service.TargetMember, memberID.String(), team_activity_service.Changes{
+			"role":           team_activity_service.Change(before.Role, role),
+			"custom_role_id": team_activity_service.Change(before.CustomRoleID, nil),
+		})
+		return nil
+	})
+}
+
+func AssignCustomRole(teamID, memberID, roleID, actorID uuid.UUID) error {
+	return database.DB.Transaction(func(tx *gorm.DB) error {
+		// Le rôle doit appartenir à la Team
+		var count int64
+		if err := tx.Model(&models.TeamRole{}).Where("team_id = ? AND id = ?", teamID, roleID).Count(&count).Error; err != nil {
+			return err
+		}
+		if count == 0 {
+			return fmt.Errorf("role not found")
+		}
+
+		before, err := current(tx, teamID, memberID)
+		if err != nil {
+			return err
+		}
+
+		// Le dernier owner ne peut pas être rétrogradé
+		if err := ensureOwnerRemains(tx, teamID, memberID); err != nil {
+			return err
+		}
+
+		// Mise à jour du TeamMember
+		result := tx.Model(&models.TeamMember{}).
+			Where("team_id = ? AND member_id = ?", teamID, memberID).
+			Where(CurrentCondition).
+			Updates(map[string]interface{}{
+				"role":            models.TeamMemberRoleCustom,
+				"custom_role_id":  roleID,
+				"role_changed_at": time.Now(),
+			})
+
+		// Vérification des erreurs GORM
+		if result.Error != nil {
+			return result.Error
+		}
+
+		team_activity_service.Record(tx, teamID, actorID, "member.role_changed", team_activity_service.TargetMember, memberID.String(), team_activity_service.Changes{
+			"role":           team_activity_service.Change(before.Role, models.TeamMemberRoleCustom),
+			"custom_role_id": team_activity_service.Change(before.CustomRoleID, roleID),
+		})
+		return nil
+	})
+}
+
+// Suspend bloque l'accès du membre à la Team, sans rien supprimer
+func Suspend(teamID, memberID, actorID uuid.UUID) error {
+	return database.DB.Transaction(func(tx *gorm.DB) error {
+		var team models.Team
+		if err := tx.Where("id = ?", teamID).First(&team).Error; err != nil {
+			return err
+		}
+		if team.Type == models.TeamTypePersonal {
+			return ErrPersonalTeamMember
+		}
+
+		// Un owner suspendu ne compte plus : il doit en rester un autre
+		if err := ensureOwnerRemains(tx, teamID, memberID); err != nil {
+			return err
+		}
+
+		result := tx.Model(&models.TeamMember{}).
+			Where("team_id = ? AND member_id = ? AND is_active = ?", teamID, memberID, true).
+			Where(CurrentCondition).
+			Updates(map[string]interface{}{
+				"is_active":    false,
+				"suspended_at": time.Now(),
+			})
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return fmt.Errorf("member is already suspended")
+		}
+
+		team_activity_service.Record(tx, teamID, actorID, "member.suspended", team_activity_service.TargetMember, memberID.String(), team_activity_service.Changes{
+			"is_active": team_activity_service.Change(true, false),
+		})
+		return nil
+	})
+}
+
+func Reactivate(teamID, memberID, actorID uuid.UUID) error {
 	result := database.DB.Model(&models.TeamMember{}).
-		Where("team_id = ? AND member_id = ?", teamID, memberID).
-		Update("role", role)
+		Where("team_id = ? AND member_id = ? AND is_active = ?", teamID, memberID, false).
+		Where(CurrentCondition).
+		Updates(map[string]interface{}{
+			"is_active":    true,
+			"suspended_at": nil,
+		})
 
 	// Vérification des erreurs GORM
 	if result.Error != nil {
 		return result.Error
 	}
+	if result.RowsAffected == 0 {
+		return fmt.Errorf("member is not suspended")
+	}
+
+	team_activity_service.Record(database.DB, teamID, actorID, "member.reactivated", team_activity_service.TargetMember, memberID.String(), team_activity_service.Changes{
+		"is_active": team_activity_service.Change(false, true),
+	})
 
 	return nil
 }
+
+// ~ Hiérarchie ~
+// Une Team peut avoir une Team parente : l'adhésion et le rôle d'un membre descendent dans les sous-teams,
+// sauf si une adhésion plus proche (directe) les remplace. Tout est résolu en une seule requête récursive.
+
+const MaxHierarchyDepth = 16
+
+// ancestorsCTE liste, pour chaque Team de départ (root_id), la Team elle-même et tous ses parents avec leur distance
+const ancestorsCTE = `WITH RECURSIVE ancestors AS (
+	SELECT id AS root_id, id, parent_id, 0 AS depth FROM teams WHERE id IN ?
+	UNION ALL
+	SELECT a.root_id, t.id, t.parent_id, a.depth + 1 FROM teams t JOIN ancestors a ON t.id = a.parent_id WHERE a.depth < ?
+) `
+
+// GetEffective retourne l'adhésion qui s'applique à l'utilisateur dans la Team : la plus proche dans la hiérarchie
+func GetEffective(teamID, memberID uuid.UUID) (models.TeamMember, error) {
+	var member models.TeamMember
+
+	result := database.DB.Raw(ancestorsCTE+`
+		SELECT tm.* FROM team_members tm
+		JOIN ancestors a ON a.id = tm.team_id
+		WHERE tm.member_id = ? AND tm.`+CurrentCondition+`
+		ORDER BY a.depth
+		LIMIT 1`, []uuid.UUID{teamID}, MaxHierarchyDepth, memberID).Scan(&member)
+	if result.Error != nil {
+		return models.TeamMember{}, result.Error
+	}
+	if member.ID == 0 {
+		return models.TeamMember{}, gorm.ErrRecordNotFound
+	}
+
+	if member.CustomRoleID != nil {
+		var role models.TeamRole
+		if err := database.DB.Where("id = ?", *member.CustomRoleID).First(&role).Error; err == nil {
+			member.CustomRole = &role
+		}
+	}
+
+	return member, nil
+}
+
+// CountEffectiveTeams compte, parmi teamIDs, les Teams auxquelles l'utilisateur a effectivement accès
+func CountEffectiveTeams(memberID uuid.UUID, teamIDs []uuid.UUID) (int64, error) {
+	var count int64
+	if len(teamIDs) == 0 {
+		return 0, nil
+	}
+
+	result := database.DB.Raw(ancestorsCTE+`
+		SELECT COUNT(*) FROM (
+			SELECT DISTINCT ON (a.root_id) tm.is_active, tm.is_accessible
+			FROM team_members tm
+			JOIN ancestors a ON a.id = tm.team_id
+			WHERE tm.member_id = ? AND tm.`+CurrentCondition+`
+			ORDER BY a.root_id, a.depth
+		) nearest
+		WHERE nearest.is_active AND nearest.is_accessible`, teamIDs, MaxHierarchyDepth, memberID).Scan(&count)
+	if result.Error != nil {
+		return 0, result.Error
+	}
+
+	return count, nil
+}
+
+// GetAllEffective retourne les membres directs de la Team ainsi que ceux hérités des Teams parentes
+func GetAllEffective(teamID uuid.UUID) ([]models.TeamMember, error) {
+	var members []models.TeamMember
+
+	result := database.DB.Raw(ancestorsCTE+`
+		SELECT DISTINCT ON (tm.member_id) tm.* FROM team_members tm
+		JOIN ancestors a ON a.id = tm.team_id
+		WHERE tm.`+CurrentCondition+`
+		ORDER BY tm.member_id, a.depth`, []uuid.UUID{teamID}, MaxHierarchyDepth).Scan(&members)
+	if result.Error != nil {
+		return nil, result.Error
+	}
+
+	return members, nil
+}
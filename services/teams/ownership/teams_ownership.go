@@ -0,0 +1,177 @@
+package team_ownership_service
+
+import (
+	"errors"
+	"fmt"
+	"gox/database"
+	"gox/database/models"
+	team_activity_service "gox/services/teams/activity"
+	team_member_service "gox/services/teams/members"
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+func GetPending(teamID uuid.UUID) (models.TeamOwnershipTransfer, error) {
+	var transfer models.TeamOwnershipTransfer
+
+	// Récupération du transfert en attente
+	result := database.DB.Where("team_id = ? AND status = ?", teamID, models.OwnershipTransferStatusPending).First(&transfer)
+
+	// Vérification des erreurs GORM
+	if result.Error != nil {
+		return models.TeamOwnershipTransfer{}, result.Error
+	}
+
+	return transfer, nil
+}
+
+// Request initie un transfert de propriété, qui devra être accepté par le membre ciblé
+func Request(teamID, fromMemberID, toMemberID uuid.UUID) (models.TeamOwnershipTransfer, error) {
+	if fromMemberID == toMemberID {
+		return models.TeamOwnershipTransfer{}, fmt.Errorf("cannot transfer ownership to yourself")
+	}
+
+	// L'initiateur doit être owner
+	var from models.TeamMember
+	if err := database.DB.Where("team_id = ? AND member_id = ?", teamID, fromMemberID).Where(team_member_service.ActiveCondition).First(&from).Error; err != nil {
+		return models.TeamOwnershipTransfer{}, fmt.Errorf("member not found")
+	}
+	if from.Role != models.TeamMemberRoleOwner {
+		return models.TeamOwnershipTransfer{}, fmt.Errorf("only owners can transfer ownership")
+	}
+
+	// La cible doit être membre de la Team
+	var to models.TeamMember
+	if err := database.DB.Where("team_id = ? AND member_id = ?", teamID, toMemberID).Where(team_member_service.ActiveCondition).First(&to).Error; err != nil {
+		return models.TeamOwnershipTransfer{}, fmt.Errorf("target is not a member of this team")
+	}
+	if to.Role == models.TeamMemberRoleOwner {
+		return models.TeamOwnershipTransfer{}, fmt.Errorf("target is already an owner")
+	}
+
+	// Un seul transfert en attente par Team
+	var count int64
+	if err := database.DB.Model(&models.TeamOwnershipTransfer{}).
+		Where("team_id = ? AND status = ?", teamID, models.OwnershipTransferStatusPending).
+		Count(&count).Error; err != nil {
+		return models.TeamOwnershipTransfer{}, err
+	}
+	if count > 0 {
+		return models.TeamOwnershipTransfer{}, fmt.Errorf("an ownership transfer is already pending")
+	}
+
+	// Création du transfert
+	transfer := models.TeamOwnershipTransfer{
+		TeamID:       teamID,
+		FromMemberID: fromMemberID,
+		ToMemberID:   toMemberID,
+		Status:       models.OwnershipTransferStatusPending,
+	}
+	if err := database.DB.Create(&transfer).Error; err != nil {
+		return models.TeamOwnershipTransfer{}, fmt.Errorf("error creating ownership transfer: %v", err)
+	}
+
+	team_activity_service.Record(database.DB, teamID, fromMemberID, "ownership.requested", team_activity_service.TargetOwnership, transfer.ID.String(), team_activity_service.Changes{
+		"to_member_id": team_activity_service.Change(nil, toMemberID),
+	})
+
+	return transfer, nil
+}
+
+func setStatus(tx *gorm.DB, transferID uuid.UUID, status models.OwnershipTransferStatus) error {
+	result := tx.Model(&models.TeamOwnershipTransfer{}).
+		Where("id = ? AND status = ?", transferID, models.OwnershipTransferStatusPending).
+		Updates(map[string]interface{}{
+			"status":       status,
+			"responded_at": time.Now(),
+		})
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return errors.New("ownership transfer is no longer pending")
+	}
+
+	return nil
+}
+
+// Accept promeut la cible owner, puis rétrograde l'initiateur en admin
+func Accept(teamID, memberID uuid.UUID) error {
+	transfer, err := GetPending(teamID)
+	if err != nil {
+		return fmt.Errorf("no pending ownership transfer")
+	}
+	if transfer.ToMemberID != memberID {
+		return fmt.Errorf("this ownership transfer is not addressed to you")
+	}
+
+	return database.DB.Transaction(func(tx *gorm.DB) error {
+		if err := setStatus(tx, transfer.ID, models.OwnershipTransferStatusAccepted); err != nil {
+			return err
+		}
+
+		result := tx.Model(&models.TeamMember{}).
+			Where("team_id = ? AND member_id = ?", teamID, transfer.ToMemberID).
+			Where(team_member_service.ActiveCondition).
+			Updates(map[string]interface{}{
+				"role":            models.TeamMemberRoleOwner,
+				"custom_role_id":  nil,
+				"role_changed_at": time.Now(),
+			})
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return errors.New("target is no longer a member of this team")
+		}
+
+		// La Team a désormais un nouvel owner : l'ancien peut être rétrogradé sans risque
+		if err := tx.Model(&models.TeamMember{}).
+			Where("team_id = ? AND member_id = ? AND role = ?", teamID, transfer.FromMemberID, models.TeamMemberRoleOwner).
+			Where(team_member_service.CurrentCondition).
+			Updates(map[string]interface{}{
+				"role":            models.TeamMemberRoleAdmin,
+				"role_changed_at": time.Now(),
+			}).Error; err != nil {
+			return err
+		}
+
+		team_activity_service.Record(tx, teamID, memberID, "ownership.accepted", team_activity_service.TargetOwnership, transfer.ID.String(), team_activity_service.Changes{
+			"owner": team_activity_service.Change(transfer.FromMemberID, transfer.ToMemberID),
+		})
+		return nil
+	})
+}
+
+func Decline(teamID, memberID uuid.UUID) error {
+	transfer, err := GetPending(teamID)
+	if err != nil {
+		return fmt.Errorf("no pending ownership transfer")
+	}
+	if transfer.ToMemberID != memberID {
+		return fmt.Errorf("this ownership transfer is not addressed to you")
+	}
+
+	if err := setStatus(database.DB, transfer.ID, models.OwnershipTransferStatusDeclined); err != nil {
+		return err
+	}
+
+	team_activity_service.Record(database.DB, teamID, memberID, "ownership.declined", team_activity_service.TargetOwnership, transfer.ID.String(), nil)
+	return nil
+}
+
+func Cancel(teamID, actorID uuid.UUID) error {
+	transfer, err := GetPending(teamID)
+	if err != nil {
+		return fmt.Errorf("no pending ownership transfer")
+	}
+
+	if err := setStatus(database.DB, transfer.ID, models.OwnershipTransferStatusCancelled); err != nil {
+		return err
+	}
+
+	team_activity_service.Record(database.DB, teamID, actorID, "ownership.cancelled", team_activity_service.TargetOwnership, transfer.ID.String(), nil)
+	return nil
+}
@@ -0,0 +1,250 @@
+package team_role_service
+
+import (
+	"errors"
+	"fmt"
+	"gox/database"
+	"gox/database/models"
+	team_activity_service "gox/services/teams/activity"
+	team_member_service "gox/services/teams/members"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+var (
+	ErrPermissionEscalation = errors.New("you cannot grant or change permissions you do not hold")
+	ErrOwnRole              = errors.New("you cannot change the role you hold")
+)
+
+// Permissions des rôles intégrés. Les rôles personnalisés définissent les leurs (TeamRole)
+var builtinPermissions = map[models.TeamMemberRole][]models.TeamPermission{
+	models.TeamMemberRoleOwner:     models.AllTeamPermissions,
+	models.TeamMemberRoleAdmin:     models.AllTeamPermissions,
+	models.TeamMemberRoleSpectator: {},
+}
+
+func validatePermissions(permissions []models.TeamPermission) ([]models.TeamPermission, error) {
+	seen := map[models.TeamPermission]bool{}
+	cleaned := []models.TeamPermission{}
+	for _, perm := range permissions {
+		if !perm.IsValid() {
+			return nil, fmt.Errorf("unknown permission: %s", perm)
+		}
+		if seen[perm] {
+			continue
+		}
+		seen[perm] = true
+		cleaned = append(cleaned, perm)
+	}
+
+	return cleaned, nil
+}
+
+func Create(teamID uuid.UUID, name string, permissions []models.TeamPermission, actorID uuid.UUID) (models.TeamRole, error) {
+	// Vérification des champs requis
+	name = strings.TrimSpace(name)
+	if name == "" {
+		return models.TeamRole{}, fmt.Errorf("name is required")
+	}
+	if models.TeamMemberRole(strings.ToLower(name)).IsValid() {
+		return models.TeamRole{}, fmt.Errorf("name is reserved for a built-in role")
+	}
+
+	permissions, err := validatePermissions(permissions)
+	if err != nil {
+		return models.TeamRole{}, err
+	}
+
+	// Un nom de rôle est unique au sein d'une Team
+	var count int64
+	if err := database.DB.Model(&models.TeamRole{}).Where("team_id = ? AND LOWER(name) = ?", teamID, strings.ToLower(name)).Count(&count).Error; err != nil {
+		return models.TeamRole{}, err
+	}
+	if count > 0 {
+		return models.TeamRole{}, fmt.Errorf("a role with this name already exists")
+	}
+
+	// Création du rôle
+	role := models.TeamRole{
+		TeamID:      teamID,
+		Name:        name,
+		Permissions: permissions,
+	}
+	if err := database.DB.Create(&role).Error; err != nil {
+		return models.TeamRole{}, fmt.Errorf("error creating role: %v", err)
+	}
+
+	team_activity_service.Record(database.DB, teamID, actorID, "role.created", team_activity_service.TargetRole, role.ID.String(), team_activity_service.Changes{
+		"name":        team_activity_service.Change(nil, role.Name),
+		"permissions": team_activity_service.Change(nil, role.Permissions),
+	})
+
+	return role, nil
+}
+
+func GetAll(teamID uuid.UUID) ([]models.TeamRole, error) {
+	var roles []models.TeamRole
+
+	// Récupération des rôles personnalisés de la Team
+	result := database.DB.Where("team_id = ?", teamID).Order("name").Find(&roles)
+
+	// Vérification des erreurs GORM
+	if result.Error != nil {
+		return nil, result.Error
+	}
+
+	return roles, nil
+}
+
+func Get(teamID, roleID uuid.UUID) (models.TeamRole, error) {
+	var role models.TeamRole
+
+	// Récupération du rôle
+	result := database.DB.Where("team_id = ? AND id = ?", teamID, roleID).First(&role)
+
+	// Vérification des erreurs GORM
+	if result.Error != nil {
+		return models.TeamRole{}, result.Error
+	}
+
+	return role, nil
+}
+
+func Update(teamID, roleID uuid.UUID, name string, permissions []models.TeamPermission, actorID uuid.UUID) (models.TeamRole, error) {
+	role, err := Get(teamID, roleID)
+	if err != nil {
+		return models.TeamRole{}, fmt.Errorf("role not found")
+	}
+	before := role
+
+	if name = strings.TrimSpace(name); name != "" {
+		if models.TeamMemberRole(strings.ToLower(name)).IsValid() {
+			return models.TeamRole{}, fmt.Errorf("name is reserved for a built-in role")
+		}
+		role.Name = name
+	}
+	if permissions != nil {
+		role.Permissions, err = validatePermissions(permissions)
+		if err != nil {
+			return models.TeamRole{}, err
+		}
+	}
+
+	// Mise à jour du rôle
+	if err := database.DB.Save(&role).Error; err != nil {
+		return models.TeamRole{}, err
+	}
+
+	changes := team_activity_service.Changes{}
+	if before.Name != role.Name {
+		changes["name"] = team_activity_service.Change(before.Name, role.Name)
+	}
+	if permissions != nil {
+		changes["permissions"] = team_activity_service.Change(before.Permissions, role.Permissions)
+	}
+	team_activity_service.Record(database.DB, teamID, actorID, "role.updated", team_activity_service.TargetRole, role.ID.String(), changes)
+
+	return role, nil
+}
+
+// Delete supprime un rôle personnalisé. Ses membres redeviennent spectators.
+func Delete(teamID, roleID, actorID uuid.UUID) error {
+	return database.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(&models.TeamMember{}).
+			Where("team_id = ? AND custom_role_id = ?", teamID, roleID).
+			Updates(map[string]interface{}{
+				"role":            models.TeamMemberRoleSpectator,
+				"custom_role_id":  nil,
+				"role_changed_at": time.Now(),
+			}).Error; err != nil {
+			return err
+		}
+
+		result := tx.Where("team_id = ? AND id = ?", teamID, roleID).Delete(&models.TeamRole{})
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return fmt.Errorf("role not found")
+		}
+
+		team_activity_service.Record(tx, teamID, actorID, "role.deleted", team_activity_service.TargetRole, roleID.String(), nil)
+		return nil
+	})
+}
+
+// MemberPermissions retourne les permissions effectives d'un membre
+func MemberPermissions(member models.TeamMember) []models.TeamPermission {
+	if member.Role == models.TeamMemberRoleCustom {
+		if member.CustomRole == nil {
+			return []models.TeamPermission{}
+		}
+		return member.CustomRole.Permissions
+	}
+
+	return builtinPermissions[member.Role]
+}
+
+// IsSubset indique si toutes les permissions de permissions figurent dans granted
+func IsSubset(permissions, granted []models.TeamPermission) bool {
+	held := make(map[models.TeamPermission]bool, len(granted))
+	for _, perm := range granted {
+		held[perm] = true
+	}
+	for _, perm := range permissions {
+		if !held[perm] {
+			return false
+		}
+	}
+
+	return true
+}
+
+// CheckGrant vérifie que requester peut donner permissions au rôle role (nil à la création) : hors owners,
+// uniquement des permissions qu'il détient, sur un rôle qui n'en a pas davantage et qui n'est pas le sien
+func CheckGrant(requester models.TeamMember, role *models.TeamRole, permissions []models.TeamPermission) error {
+	if requester.Role == models.TeamMemberRoleOwner {
+		return nil
+	}
+
+	granted := MemberPermissions(requester)
+	if role != nil {
+		if requester.CustomRoleID != nil && *requester.CustomRoleID == role.ID {
+			return ErrOwnRole
+		}
+		if !IsSubset(role.Permissions, granted) {
+			return ErrPermissionEscalation
+		}
+	}
+	if !IsSubset(permissions, granted) {
+		return ErrPermissionEscalation
+	}
+
+	return nil
+}
+
+// HasPermission indique si l'utilisateur possède la permission dans la Team
+func HasPermission(teamID, userID uuid.UUID, permission models.TeamPermission) (bool, error) {
+	// Le rôle hérité d'une Team parente s'applique, sauf adhésion plus proche
+	member, err := team_member_service.GetEffective(teamID, userID)
+	if err != nil {
+		if err == gorm.ErrRecordNotFound {
+			return false, nil
+		}
+		return false, err
+	}
+	if !member.IsActive || !member.IsAccessible {
+		return false, nil
+	}
+
+	for _, perm := range MemberPermissions(member) {
+		if perm == permission {
+			return true, nil
+		}
+	}
+
+	return false, nil
+}
@@ -0,0 +1,96 @@
+package team_role_service
+
+import (
+	"gox/database/models"
+	"testing"
+
+	"github.com/google/uuid"
+)
+
+func TestCheckGrant(t *testing.T) {
+	heldRole := models.TeamRole{
+		ID:          uuid.New(),
+		Permissions: []models.TeamPermission{models.TeamPermissionSettingsWrite, models.TeamPermissionMembersInvite},
+	}
+	settingsManager := models.TeamMember{Role: models.TeamMemberRoleCustom, CustomRoleID: &heldRole.ID, CustomRole: &heldRole}
+	otherRole := models.TeamRole{ID: uuid.New(), Permissions: []models.TeamPermission{models.TeamPermissionMembersInvite}}
+	strongerRole := models.TeamRole{ID: uuid.New(), Permissions: []models.TeamPermission{models.TeamPermissionBillingRead}}
+
+	tests := []struct {
+		name        string
+		requester   models.TeamMember
+		role        *models.TeamRole
+		permissions []models.TeamPermission
+		want        error
+	}{
+		{
+			name:        "create with held permissions",
+			requester:   settingsManager,
+			permissions: []models.TeamPermission{models.TeamPermissionMembersInvite},
+		},
+		{
+			name:        "create with a permission not held",
+			requester:   settingsManager,
+			permissions: []models.TeamPermission{models.TeamPermissionMembersRemove},
+			want:        ErrPermissionEscalation,
+		},
+		{
+			name:        "update another role within held permissions",
+			requester:   settingsManager,
+			role:        &otherRole,
+			permissions: []models.TeamPermission{models.TeamPermissionMembersInvite, models.TeamPermissionSettingsWrite},
+		},
+		{
+			name:        "update another role beyond held permissions",
+			requester:   settingsManager,
+			role:        &otherRole,
+			permissions: []models.TeamPermission{models.TeamPermissionBillingRead},
+			want:        ErrPermissionEscalation,
+		},
+		{
+			name:      "rename a role stronger than the requester",
+			requester: settingsManager,
+			role:      &strongerRole,
+			want:      ErrPermissionEscalation,
+		},
+		{
+			name:        "grant every permission to the held role",
+			requester:   settingsManager,
+			role:        &heldRole,
+			permissions: models.AllTeamPermissions,
+			want:        ErrOwnRole,
+		},
+		{
+			name:      "rename the held role",
+			requester: settingsManager,
+			role:      &heldRole,
+			want:      ErrOwnRole,
+		},
+		{
+			name:        "admin grants every permission",
+			requester:   models.TeamMember{Role: models.TeamMemberRoleAdmin},
+			role:        &otherRole,
+			permissions: models.AllTeamPermissions,
+		},
+		{
+			name:        "owner is not limited",
+			requester:   models.TeamMember{Role: models.TeamMemberRoleOwner},
+			role:        &strongerRole,
+			permissions: models.AllTeamPermissions,
+		},
+		{
+			name:        "custom role that was not loaded holds nothing",
+			requester:   models.TeamMember{Role: models.TeamMemberRoleCustom},
+			permissions: []models.TeamPermission{models.TeamPermissionMembersInvite},
+			want:        ErrPermissionEscalation,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := CheckGrant(tt.requester, tt.role, tt.permissions); got != tt.want {
+				t.Fatalf("CheckGrant() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -1,30 +1,41 @@
 package team_service
 
 import (
+	"errors"
 	"fmt"
 	"gox/database"
 	"gox/database/models"
+	team_activity_service "gox/services/teams/activity"
+	team_member_service "gox/services/teams/members"
 	"gox/utils"
+	"strconv"
+	"time"
 
 	"github.com/google/uuid"
 	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
 )
 
+// ErrPersonalTeam est retournée pour les opérations interdites sur une Team personnelle
+var ErrPersonalTeam = errors.New("operation not allowed on a personal team")
+
+// IsUserInTeam tient compte de la hiérarchie : un membre d'une Team parente a accès à ses sous-teams
 func IsUserInTeam(userID uuid.UUID, teamID uuid.UUID) (bool, error) {
-	var count int64
-	result := database.DB.Model(&models.TeamMember{}).Where("member_id = ? AND team_id = ?", userID, teamID).Count(&count)
-	if result.Error != nil {
-		return false, result.Error
+	member, err := team_member_service.GetEffective(teamID, userID)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return false, nil
+		}
+		return false, err
 	}
 
-	return count > 0, nil
+	return member.IsActive && member.IsAccessible, nil
 }
 
 func IsUserInTeams(userID uuid.UUID, teamIDs []uuid.UUID) bool {
-	var count int64
-	result := database.DB.Model(&models.TeamMember{}).Where("member_id = ? AND team_id IN ?", userID, teamIDs).Count(&count)
-	if result.Error != nil {
-		utils.ConsoleLog("An error occured in IsUserInTeams: %v", result.Error).Error()
+	count, err := team_member_service.CountEffectiveTeams(userID, teamIDs)
+	if err != nil {
+		utils.ConsoleLog("An error occured in IsUserInTeams: %v", err).Error()
 		return false
 	}
 
@@ -55,6 +66,53 @@ func Create(name string, teamType models.TeamType) (uuid.UUID, error) {
 	return team.ID, nil
 }
 
+// CreateWithOwner crée la Team et y ajoute son owner dans la même transaction : une Team ne reste jamais sans owner
+func CreateWithOwner(name string, teamType models.TeamType, ownerID uuid.UUID) (uuid.UUID, error) {
+	// Vérification des champs requis
+	if name == "" {
+		return uuid.UUID{}, fmt.Errorf("name is required")
+	}
+	if teamType == "" {
+		return uuid.UUID{}, fmt.Errorf("type is required")
+	}
+	if ownerID == uuid.Nil {
+		return uuid.UUID{}, fmt.Errorf("owner is required")
+	}
+
+	team := models.Team{
+		Name: name,
+		Type: teamType,
+	}
+	err := database.DB.Transaction(func(tx *gorm.DB) error {
+		return createWithOwner(tx, &team, ownerID)
+	})
+	if err != nil {
+		return uuid.UUID{}, err
+	}
+
+	return team.ID, nil
+}
+
+// createWithOwner insère la Team et son owner dans la transaction tx
+func createWithOwner(tx *gorm.DB, team *models.Team, ownerID uuid.UUID) error {
+	if err := tx.Create(team).Error; err != nil {
+		return fmt.Errorf("error creating Team: %v", err)
+	}
+
+	if err := tx.Create(&models.TeamMember{
+		TeamID:   team.ID,
+		MemberID: ownerID,
+		Role:     models.TeamMemberRoleOwner,
+	}).Error; err != nil {
+		return fmt.Errorf("error creating team owner: %v", err)
+	}
+
+	team_activity_service.Record(tx, team.ID, ownerID, "member.added", team_activity_service.TargetMember, ownerID.String(), team_activity_service.Changes{
+		"role": team_activity_service.Change(nil, models.TeamMemberRoleOwner),
+	})
+	return nil
+}
+
 func GetAll(db *gorm.DB) ([]models.Team, error) {
 	var teams []models.Team
 
@@ -85,7 +143,7 @@ func Get(teamID uuid.UUID) (models.Team, error) {
 
 func GetPersonalTeamByMemberID(memberID uuid.UUID) (models.Team, error) {
 	var team models.Team
-	result := database.DB.Where("type = ? AND id = (SELECT team_id FROM team_members WHERE member_id = ?)", models.TeamTypePersonal, memberID).First(&team)
+	result := database.DB.Where("type = ? AND id IN (SELECT team_id FROM team_members WHERE member_id = ? AND "+team_member_service.CurrentCondition+")", models.TeamTypePersonal, memberID).First(&team)
 	if result.Error != nil {
 		return models.Team{}, result.Error
 	}
@@ -93,9 +151,20 @@ func GetPersonalTeamByMemberID(memberID uuid.UUID) (models.Team, error) {
 	return team, nil
 }
 
+// GetTeamsByMemberID retourne les Teams du membre, y compris les sous-teams dont il hérite l'accès.
+// Une adhésion directe suspendue dans une sous-team en bloque l'accès (et celui de ses descendantes).
 func GetTeamsByMemberID(memberID uuid.UUID) ([]models.Team, error) {
 	var teams []models.Team
-	result := database.DB.Where("id IN (SELECT team_id FROM team_members WHERE member_id = ?)", memberID).Find(&teams)
+	result := database.DB.Raw(`WITH RECURSIVE tree AS (
+		SELECT id, 0 AS depth FROM teams
+		WHERE id IN (SELECT team_id FROM team_members WHERE member_id = ? AND `+team_member_service.ActiveCondition+`)
+		UNION
+		SELECT t.id, tree.depth + 1 FROM teams t JOIN tree ON t.parent_id = tree.id
+		WHERE tree.depth < ?
+		AND t.id NOT IN (SELECT team_id FROM team_members WHERE member_id = ? AND `+team_member_service.CurrentCondition+` AND NOT (is_active AND is_accessible))
+	)
+	SELECT * FROM teams WHERE archived_at IS NULL AND id IN (SELECT id FROM tree)`,
+		memberID, team_member_service.MaxHierarchyDepth, memberID).Scan(&teams)
 	if result.Error != nil {
 		return nil, result.Error
 	}
@@ -103,7 +172,12 @@ func GetTeamsByMemberID(memberID uuid.UUID) ([]models.Team, error) {
 	return teams, nil
 }
 
-func UpdateName(teamID uuid.UUID, name string) error {
+func UpdateName(teamID uuid.UUID, name string, actorID uuid.UUID) error {
+	team, err := Get(teamID)
+	if err != nil {
+		return err
+	}
+
 	// Mise à jour du Team
 	result := database.DB.Model(&models.Team{}).
 		Where("id = ?", teamID).
@@ -115,17 +189,413 @@ func UpdateName(teamID uuid.UUID, name string) error {
 	if result.Error != nil {
 		return result.Error
 	}
+
+	team_activity_service.Record(database.DB, teamID, actorID, "team.renamed", team_activity_service.TargetTeam, teamID.String(), team_activity_service.Changes{
+		"name": team_activity_service.Change(team.Name, name),
+	})
 	return nil
 }
 
-func Delete(teamID uuid.UUID) error {
-	// Suppression du Team
-	result := database.DB.Where("id = ?", teamID).Delete(&models.Team{})
+// Durée pendant laquelle une Team archivée peut être restaurée, configurable via TEAM_RETENTION_DAYS
+func RetentionPeriod() time.Duration {
+	days, err := strconv.Atoi(utils.GetEnv("TEAM_RETENTION_DAYS", "30"))
+	if err != nil || days <= 0 {
+		days = 30
+	}
+	return time.Duration(days) * 24 * time.Hour
+}
+
+// Archive remplace la suppression : la Team devient lecture seule et disparaît des listes,
+// jusqu'à sa restauration ou sa purge définitive une fois la période de rétention écoulée.
+func Archive(teamID, actorID uuid.UUID) error {
+	team, err := Get(teamID)
+	if err != nil {
+		return err
+	}
+
+	// Une Team personnelle vit aussi longtemps que son utilisateur
+	if team.Type == models.TeamTypePersonal {
+		return ErrPersonalTeam
+	}
+	if team.IsArchived() {
+		return fmt.Errorf("team is already archived")
+	}
+
+	return database.DB.Transaction(func(tx *gorm.DB) error {
+		// Archivage de la Team
+		if err := tx.Model(&models.Team{}).Where("id = ?", teamID).Update("archived_at", time.Now()).Error; err != nil {
+			return err
+		}
+
+		// Les invitations et transferts en attente n'ont plus lieu d'être
+		if err := tx.Model(&models.TeamInvitation{}).
+			Where("team_id = ? AND status = ?", teamID, models.TeamInvitationStatusPending).
+			Updates(map[string]interface{}{
+				"status":       models.TeamInvitationStatusRevoked,
+				"responded_at": time.Now(),
+			}).Error; err != nil {
+			return err
+		}
+
+		if err := tx.Model(&models.TeamOwnershipTransfer{}).
+			Where("team_id = ? AND status = ?", teamID, models.OwnershipTransferStatusPending).
+			Updates(map[string]interface{}{
+				"status":       models.OwnershipTransferStatusCancelled,
+				"responded_at": time.Now(),
+			}).Error; err != nil {
+			return err
+		}
+
+		team_activity_service.Record(tx, teamID, actorID, "team.archived", team_activity_service.TargetTeam, teamID.String(), nil)
+		return nil
+	})
+}
+
+// ErrRetentionExpired est retournée pour la restauration d'une Team archivée depuis plus longtemps que la période de rétention
+var ErrRetentionExpired = errors.New("retention period is over, team can no longer be restored")
+
+// Restore rend la Team à nouveau active, si elle est encore dans la période de rétention
+func Restore(db *gorm.DB, teamID, actorID uuid.UUID) error {
+	team, err := Get(teamID)
+	if err != nil {
+		return err
+	}
+	if !team.IsArchived() {
+		return fmt.Errorf("team is not archived")
+	}
+
+	// Désarchivage, dans la même requête que la vérification de la rétention : une Team qui vient d'en sortir
+	// (ou d'être purgée par PurgeArchived) n'est pas restaurée
+	result := db.Model(&models.Team{}).
+		Where("id = ? AND archived_at IS NOT NULL AND archived_at >= ?", teamID, time.Now().Add(-RetentionPeriod())).
+		Update("archived_at", nil)
 
 	// Vérification des erreurs GORM
 	if result.Error != nil {
 		return result.Error
 	}
+	if result.RowsAffected == 0 {
+		return ErrRetentionExpired
+	}
 
+	team_activity_service.Record(db, teamID, actorID, "team.restored", team_activity_service.TargetTeam, teamID.String(), team_activity_service.Changes{
+		"archived_at": team_activity_service.Change(team.ArchivedAt, nil),
+	})
 	return nil
 }
+
+func GetArchived() ([]models.Team, error) {
+	var teams []models.Team
+	result := database.DB.Where("archived_at IS NOT NULL").Order("archived_at DESC").Find(&teams)
+	if result.Error != nil {
+		return nil, result.Error
+	}
+
+	return teams, nil
+}
+
+func GetArchivedTeamsByMemberID(memberID uuid.UUID) ([]models.Team, error) {
+	var teams []models.Team
+	result := database.DB.Where("archived_at IS NOT NULL AND id IN (SELECT team_id FROM team_members WHERE member_id = ? AND "+team_member_service.ActiveCondition+")", memberID).Find(&teams)
+	if result.Error != nil {
+		return nil, result.Error
+	}
+
+	return teams, nil
+}
+
+// PurgeArchived supprime définitivement les Teams archivées au-delà de la période de rétention.
+// Membres, rôles, invitations et transferts suivent via les contraintes ON DELETE CASCADE.
+func PurgeArchived() error {
+	result := database.DB.
+		Where("archived_at IS NOT NULL AND archived_at < ?", time.Now().Add(-RetentionPeriod())).
+		Delete(&models.Team{})
+	if result.Error != nil {
+		return result.Error
+	}
+
+	if result.RowsAffected > 0 {
+		utils.ConsoleLog("🗑️ %d archived team(s) purged", result.RowsAffected)
+	}
+
+	return nil
+}
+
+// ConvertToCompany transforme la Team personnelle en Team company, en conservant son ID et son owner.
+// L'utilisateur reçoit une nouvelle Team personnelle vierge.
+func ConvertToCompany(teamID uuid.UUID, ownerID uuid.UUID, name string) (uuid.UUID, error) {
+	if name == "" {
+		return uuid.Nil, fmt.Errorf("name is required")
+	}
+
+	var personalTeamID uuid.UUID
+	err := database.DB.Transaction(func(tx *gorm.DB) error {
+		var team models.Team
+		if err := tx.Where("id = ?", teamID).First(&team).Error; err != nil {
+			return err
+		}
+		if team.Type != models.TeamTypePersonal {
+			return fmt.Errorf("team is already a company team")
+		}
+
+		var count int64
+		if err := tx.Model(&models.TeamMember{}).
+			Where("team_id = ? AND member_id = ? AND role = ?", teamID, ownerID, models.TeamMemberRoleOwner).
+			Where(team_member_service.ActiveCondition).
+			Count(&count).Error; err != nil {
+			return err
+		}
+		if count == 0 {
+			return fmt.Errorf("only the owner can convert a personal team")
+		}
+
+		// Conversion
+		if err := tx.Model(&models.Team{}).Where("id = ?", teamID).Updates(map[string]interface{}{
+			"type": models.TeamTypeCompany,
+			"name": name,
+		}).Error; err != nil {
+			return err
+		}
+		team_activity_service.Record(tx, teamID, ownerID, "team.converted", team_activity_service.TargetTeam, teamID.String(), team_activity_service.Changes{
+			"type": team_activity_service.Change(team.Type, models.TeamTypeCompany),
+			"name": team_activity_service.Change(team.Name, name),
+		})
+
+		// Nouvelle Team personnelle pour l'utilisateur
+		personal := models.Team{
+			Name: "Personal",
+			Type: models.TeamTypePersonal,
+		}
+		if err := tx.Create(&personal).Error; err != nil {
+			return err
+		}
+		personalTeamID = personal.ID
+
+		return tx.Create(&models.TeamMember{
+			TeamID:   personal.ID,
+			MemberID: ownerID,
+			Role:     models.TeamMemberRoleOwner,
+		}).Error
+	})
+	if err != nil {
+		return uuid.Nil, err
+	}
+
+	return personalTeamID, nil
+}
+
+// DeletePersonalTeams supprime les Teams personnelles d'un utilisateur (suppression de compte)
+func DeletePersonalTeams(memberID uuid.UUID) error {
+	result := database.DB.
+		Where("type = ? AND id IN (SELECT team_id FROM team_members WHERE member_id = ?)", models.TeamTypePersonal, memberID).
+		Delete(&models.Team{})
+
+	// Vérification des erreurs GORM
+	if result.Error != nil {
+		return result.Error
+	}
+
+	return nil
+}
+
+// ~ Hiérarchie ~
+
+// ErrInvalidParent est retournée lorsqu'un rattachement créerait un cycle ou implique une Team personnelle
+var ErrInvalidParent = errors.New("invalid parent team")
+
+// ErrHierarchyTooDeep est retournée lorsqu'un rattachement dépasserait la profondeur maximale de la hiérarchie
+var ErrHierarchyTooDeep = fmt.Errorf("%w: the hierarchy cannot be deeper than %d levels", ErrInvalidParent, team_member_service.MaxHierarchyDepth)
+
+// GetChildren retourne les sous-teams directes (non archivées) de la Team
+func GetChildren(teamID uuid.UUID) ([]models.Team, error) {
+	var teams []models.Team
+	result := database.DB.Where("parent_id = ? AND archived_at IS NULL", teamID).Order("name").Find(&teams)
+	if result.Error != nil {
+		return nil, result.Error
+	}
+
+	return teams, nil
+}
+
+// GetAncestors retourne les Teams parentes, de la plus proche à la racine (l'organisation)
+func GetAncestors(teamID uuid.UUID) ([]models.Team, error) {
+	return ancestorsOf(database.DB, teamID)
+}
+
+func ancestorsOf(db *gorm.DB, teamID uuid.UUID) ([]models.Team, error) {
+	var teams []models.Team
+	result := db.Raw(`WITH RECURSIVE ancestors AS (
+		SELECT parent_id, 1 AS depth FROM teams WHERE id = ?
+		UNION ALL
+		SELECT t.parent_id, a.depth + 1 FROM teams t JOIN ancestors a ON t.id = a.parent_id WHERE a.depth < ?
+	)
+	SELECT teams.* FROM teams JOIN ancestors a ON teams.id = a.parent_id ORDER BY a.depth`,
+		teamID, team_member_service.MaxHierarchyDepth).Scan(&teams)
+	if result.Error != nil {
+		return nil, result.Error
+	}
+
+	return teams, nil
+}
+
+// SubtreeHeight retourne la profondeur de la plus longue branche de sous-teams sous la Team (0 sans sous-team)
+func SubtreeHeight(teamID uuid.UUID) (int, error) {
+	return subtreeHeight(database.DB, teamID)
+}
+
+func subtreeHeight(db *gorm.DB, teamID uuid.UUID) (int, error) {
+	var height int
+	result := db.Raw(`WITH RECURSIVE descendants AS (
+		SELECT id, 0 AS depth FROM teams WHERE id = ?
+		UNION ALL
+		SELECT t.id, d.depth + 1 FROM teams t JOIN descendants d ON t.parent_id = d.id WHERE d.depth < ?
+	)
+	SELECT COALESCE(MAX(depth), 0) FROM descendants`,
+		teamID, team_member_service.MaxHierarchyDepth).Scan(&height)
+	if result.Error != nil {
+		return 0, result.Error
+	}
+
+	return height, nil
+}
+
+// ErrTeamSuspended est retournée lorsqu'une Team suspendue par un admin (ou l'une de ses parentes) devrait être modifiée
+var ErrTeamSuspended = errors.New("team is suspended")
+
+// IsSuspended indique si la Team, ou l'une de ses Teams parentes, a été suspendue par un admin (IsAccessible)
+func IsSuspended(team models.Team) (bool, error) {
+	if !team.IsAccessible {
+		return true, nil
+	}
+
+	ancestors, err := GetAncestors(team.ID)
+	if err != nil {
+		return false, err
+	}
+	for _, ancestor := range ancestors {
+		if !ancestor.IsAccessible {
+			return true, nil
+		}
+	}
+
+	return false, nil
+}
+
+// CheckNotSuspended retourne ErrTeamSuspended si la Team, ou l'une de ses parentes, est suspendue
+func CheckNotSuspended(team models.Team) error {
+	suspended, err := IsSuspended(team)
+	if err != nil {
+		return err
+	}
+	if suspended {
+		return ErrTeamSuspended
+	}
+	return nil
+}
+
+// CheckParent vérifie, dans db, que la Team peut être rattachée à parent : une Team company non archivée, qui
+// n'est ni la Team elle-même ni l'une de ses descendantes, et sous laquelle la branche déplacée (la Team et ses
+// sous-teams) ne dépasse pas la profondeur maximale (ErrHierarchyTooDeep)
+func CheckParent(db *gorm.DB, teamID uuid.UUID, parent models.Team) error {
+	if parent.Type == models.TeamTypePersonal || parent.IsArchived() || parent.ID == teamID {
+		return ErrInvalidParent
+	}
+
+	ancestors, err := ancestorsOf(db, parent.ID)
+	if err != nil {
+		return err
+	}
+	for _, ancestor := range ancestors {
+		if ancestor.ID == teamID {
+			return ErrInvalidParent
+		}
+	}
+
+	height, err := subtreeHeight(db, teamID)
+	if err != nil {
+		return err
+	}
+	if len(ancestors)+1+height >= team_member_service.MaxHierarchyDepth {
+		return ErrHierarchyTooDeep
+	}
+
+	return nil
+}
+
+// SetParent rattache la Team à une Team parente, ou la détache si parentID est nil
+func SetParent(teamID uuid.UUID, parentID *uuid.UUID, actorID uuid.UUID) error {
+	team, err := Get(teamID)
+	if err != nil {
+		return err
+	}
+	if team.Type == models.TeamTypePersonal {
+		return ErrPersonalTeam
+	}
+
+	if parentID != nil {
+		parent, err := Get(*parentID)
+		if err != nil {
+			return err
+		}
+		if err := CheckParent(database.DB, teamID, parent); err != nil {
+			return err
+		}
+	}
+
+	// Mise à jour du Team
+	result := database.DB.Model(&models.Team{}).Where("id = ?", teamID).Update("parent_id", parentID)
+
+	// Vérification des erreurs GORM
+	if result.Error != nil {
+		return result.Error
+	}
+
+	team_activity_service.Record(database.DB, teamID, actorID, "team.parent_changed", team_activity_service.TargetTeam, teamID.String(), team_activity_service.Changes{
+		"parent_id": team_activity_service.Change(team.ParentID, parentID),
+	})
+	return nil
+}
+
+// CreateSubTeam crée une sous-team de type company, dont le créateur devient owner. La Team, son owner et son
+// rattachement sont créés dans une même transaction : aucune Team orpheline ne subsiste en cas d'échec.
+func CreateSubTeam(parentID uuid.UUID, name string, ownerID uuid.UUID) (uuid.UUID, error) {
+	if name == "" {
+		return uuid.UUID{}, fmt.Errorf("name is required")
+	}
+	if ownerID == uuid.Nil {
+		return uuid.UUID{}, fmt.Errorf("owner is required")
+	}
+
+	team := models.Team{
+		Name:     name,
+		Type:     models.TeamTypeCompany,
+		ParentID: &parentID,
+	}
+	err := database.DB.Transaction(func(tx *gorm.DB) error {
+		// La parente est verrouillée : elle ne peut pas être archivée ou déplacée pendant la création
+		var parent models.Team
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).Where("id = ?", parentID).First(&parent).Error; err != nil {
+			return err
+		}
+
+		if err := createWithOwner(tx, &team, ownerID); err != nil {
+			return err
+		}
+		if err := CheckParent(tx, team.ID, parent); err != nil {
+			return err
+		}
+
+		team_activity_service.Record(tx, team.ID, ownerID, "team.parent_changed", team_activity_service.TargetTeam, team.ID.String(), team_activity_service.Changes{
+			"parent_id": team_activity_service.Change(nil, parentID),
+		})
+		team_activity_service.Record(tx, parentID, ownerID, "team.subteam_created", team_activity_service.TargetTeam, team.ID.String(), team_activity_service.Changes{
+			"name": team_activity_service.Change(nil, name),
+		})
+		return nil
+	})
+	if err != nil {
+		return uuid.UUID{}, err
+	}
+
+	return team.ID, nil
+}
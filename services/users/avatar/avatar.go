@@ -0,0 +1,165 @@
+package user_avatar_service
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"gox/database"
+	"gox/database/models"
+	storage_service "gox/services/storage"
+	"gox/utils"
+	"path"
+	"strconv"
+	"strings"
+
+	"github.com/google/uuid"
+)
+
+// Tailles des variantes carrées générées, de la plus grande à la plus petite
+var Sizes = []int{512, 256, 128, 64}
+
+// ErrNoAvatar est retournée quand le profil n'a pas d'avatar
+var ErrNoAvatar = errors.New("user has no avatar")
+
+// Taille maximum d'un fichier envoyé, configurable via AVATAR_MAX_BYTES
+func MaxUploadBytes() int64 {
+	size, err := strconv.ParseInt(utils.GetEnv("AVATAR_MAX_BYTES", "5242880"), 10, 64)
+	if err != nil || size <= 0 {
+		size = 5 << 20
+	}
+	return size
+}
+
+// Les fichiers sont rangés sous avatars/{user}/{version}/{taille}.{ext}. La version dépend du
+// contenu : une URL ne change jamais de contenu, ce qui permet un cache long côté client.
+func key(userID uuid.UUID, version string, size int, ext string) string {
+	return fmt.Sprintf("avatars/%s/%s/%d.%s", userID, version, size, ext)
+}
+
+// Upload vérifie et redimensionne l'image, stocke ses variantes et remplace l'avatar du profil
+func Upload(userID uuid.UUID, data []byte) (string, error) {
+	if int64(len(data)) > MaxUploadBytes() {
+		return "", fmt.Errorf("avatar exceeds %d bytes", MaxUploadBytes())
+	}
+
+	// Type réel du fichier, puis décodage
+	contentType, err := SniffContentType(data)
+	if err != nil {
+		return "", err
+	}
+	img, err := decode(data, contentType)
+	if err != nil {
+		return "", err
+	}
+
+	var profile models.UserProfile
+	if err := database.DB.Where("customer_id = ?", userID).First(&profile).Error; err != nil {
+		return "", err
+	}
+
+	storage, err := storage_service.Default()
+	if err != nil {
+		return "", err
+	}
+
+	// Variantes carrées
+	square := cropSquare(img)
+	opaque := isOpaque(square)
+	ext, outputType := "png", "image/png"
+	if opaque {
+		ext, outputType = "jpg", "image/jpeg"
+	}
+	sum := sha256.Sum256(data)
+	version := hex.EncodeToString(sum[:8])
+
+	for _, size := range Sizes {
+		encoded, err := encode(resize(square, size), opaque)
+		if err != nil {
+			return "", err
+		}
+		if err := storage.Put(key(userID, version, size, ext), encoded, outputType); err != nil {
+			return "", fmt.Errorf("error storing avatar: %v", err)
+		}
+	}
+
+	// Mise à jour du profil, puis suppression de l'ancien avatar
+	avatarURL := "/" + key(userID, version, Sizes[0], ext)
+	if err := database.DB.Model(&profile).Update("avatar_url", avatarURL).Error; err != nil {
+		return "", err
+	}
+	if profile.AvatarURL != "" && profile.AvatarURL != avatarURL {
+		deleteFiles(profile.AvatarURL)
+	}
+
+	return avatarURL, nil
+}
+
+// Remove retire l'avatar du profil et supprime ses fichiers
+func Remove(userID uuid.UUID) error {
+	var profile models.UserProfile
+	if err := database.DB.Where("customer_id = ?", userID).First(&profile).Error; err != nil {
+		return err
+	}
+	if profile.AvatarURL == "" {
+		return ErrNoAvatar
+	}
+
+	if err := database.DB.Model(&profile).Update("avatar_url", nil).Error; err != nil {
+		return err
+	}
+	deleteFiles(profile.AvatarURL)
+	return nil
+}
+
+// deleteFiles supprime toutes les variantes d'un avatar ; une erreur laisse au pire un fichier orphelin
+func deleteFiles(avatarURL string) {
+	storage, err := storage_service.Default()
+	if err != nil {
+		return
+	}
+	for _, variant := range variants(avatarURL) {
+		if err := storage.Delete(strings.TrimPrefix(variant, "/")); err != nil {
+			utils.ConsoleLog("Error deleting avatar file %s: %v", variant, err).Error()
+		}
+	}
+}
+
+func variants(avatarURL string) map[int]string {
+	if avatarURL == "" {
+		return nil
+	}
+	dir, ext := path.Dir(avatarURL), path.Ext(avatarURL)
+	result := make(map[int]string, len(Sizes))
+	for _, size := range Sizes {
+		result[size] = fmt.Sprintf("%s/%d%s", dir, size, ext)
+	}
+	return result
+}
+
+// URLs retourne l'URL publique de chaque variante, indexée par taille
+func URLs(avatarURL string) map[string]string {
+	if avatarURL == "" {
+		return nil
+	}
+	appURL := strings.TrimRight(utils.GetEnv("APP_URL", "http://localhost:8080"), "/")
+	result := make(map[string]string, len(Sizes))
+	for size, variant := range variants(avatarURL) {
+		result[strconv.Itoa(size)] = appURL + variant
+	}
+	return result
+}
+
+// URL retourne la plus petite variante au moins aussi grande que la taille demandée
+func URL(avatarURL string, size int) string {
+	if avatarURL == "" {
+		return ""
+	}
+	chosen := Sizes[0]
+	for _, s := range Sizes {
+		if s >= size {
+			chosen = s
+		}
+	}
+	return URLs(avatarURL)[strconv.Itoa(chosen)]
+}
@@ -0,0 +1,164 @@
+package user_avatar_service
+
+import (
+	"bytes"
+	"errors"
+	"image"
+	"image/draw"
+	"image/gif"
+	"image/jpeg"
+	"image/png"
+	"net/http"
+)
+
+var (
+	ErrUnsupportedImage = errors.New("avatar must be a JPEG, PNG or GIF image")
+	ErrImageDimensions  = errors.New("avatar dimensions are out of bounds")
+)
+
+const (
+	MinDimension = 32
+	MaxDimension = 4096
+)
+
+// Formats acceptés, détectés à partir du contenu et non de l'en-tête envoyé par le client
+var decoders = map[string]func([]byte) (image.Image, error){
+	"image/jpeg": func(data []byte) (image.Image, error) { return jpeg.Decode(bytes.NewReader(data)) },
+	"image/png":  func(data []byte) (image.Image, error) { return png.Decode(bytes.NewReader(data)) },
+	"image/gif":  func(data []byte) (image.Image, error) { return gif.Decode(bytes.NewReader(data)) },
+}
+
+var configDecoders = map[string]func([]byte) (image.Config, error){
+	"image/jpeg": func(data []byte) (image.Config, error) { return jpeg.DecodeConfig(bytes.NewReader(data)) },
+	"image/png":  func(data []byte) (image.Config, error) { return png.DecodeConfig(bytes.NewReader(data)) },
+	"image/gif":  func(data []byte) (image.Config, error) { return gif.DecodeConfig(bytes.NewReader(data)) },
+}
+
+// SniffContentType retourne le type réel du fichier, ou ErrUnsupportedImage
+func SniffContentType(data []byte) (string, error) {
+	contentType := http.DetectContentType(data)
+	if _, ok := decoders[contentType]; !ok {
+		return "", ErrUnsupportedImage
+	}
+	return contentType, nil
+}
+
+// decode lit d'abord les dimensions, pour refuser une image géante avant d'allouer ses pixels
+func decode(data []byte, contentType string) (image.Image, error) {
+	config, err := configDecoders[contentType](data)
+	if err != nil {
+		return nil, ErrUnsupportedImage
+	}
+	if config.Width < MinDimension || config.Height < MinDimension || config.Width > MaxDimension || config.Height > MaxDimension {
+		return nil, ErrImageDimensions
+	}
+
+	img, err := decoders[contentType](data)
+	if err != nil {
+		return nil, ErrUnsupportedImage
+	}
+	return img, nil
+}
+
+// cropSquare garde le carré central de l'image, en pixels RGBA prémultipliés
+func cropSquare(img image.Image) *image.RGBA {
+	bounds := img.Bounds()
+	side := bounds.Dx()
+	if bounds.Dy() < side {
+		side = bounds.Dy()
+	}
+	origin := image.Pt(bounds.Min.X+(bounds.Dx()-side)/2, bounds.Min.Y+(bounds.Dy()-side)/2)
+
+	square := image.NewRGBA(image.Rect(0, 0, side, side))
+	draw.Draw(square, square.Bounds(), img, origin, draw.Src)
+	return square
+}
+
+type contribution struct {
+	index  int
+	weight float64
+}
+
+// weights calcule, pour chaque pixel de destination, la part de chaque pixel source qu'il recouvre
+func weights(srcSize, dstSize int) [][]contribution {
+	scale := float64(srcSize) / float64(dstSize)
+	result := make([][]contribution, dstSize)
+	for d := 0; d < dstSize; d++ {
+		start, end := float64(d)*scale, float64(d+1)*scale
+		for s := int(start); s < srcSize && float64(s) < end; s++ {
+			lo, hi := start, end
+			if float64(s) > lo {
+				lo = float64(s)
+			}
+			if float64(s+1) < hi {
+				hi = float64(s + 1)
+			}
+			if hi > lo {
+				result[d] = append(result[d], contribution{index: s, weight: (hi - lo) / scale})
+			}
+		}
+	}
+	return result
+}
+
+// resize redimensionne un carré par moyenne des surfaces (filtre "box"), adapté à la réduction
+func resize(src *image.RGBA, size int) *image.RGBA {
+	srcSize := src.Bounds().Dx()
+	dst := image.NewRGBA(image.Rect(0, 0, size, size))
+	table := weights(srcSize, size)
+
+	for dy := 0; dy < size; dy++ {
+		for dx := 0; dx < size; dx++ {
+			var r, g, b, a float64
+			for _, cy := range table[dy] {
+				row := cy.index * src.Stride
+				for _, cx := range table[dx] {
+					w := cx.weight * cy.weight
+					p := src.Pix[row+cx.index*4 : row+cx.index*4+4]
+					r += float64(p[0]) * w
+					g += float64(p[1]) * w
+					b += float64(p[2]) * w
+					a += float64(p[3]) * w
+				}
+			}
+			o := dy*dst.Stride + dx*4
+			dst.Pix[o] = clamp(r)
+			dst.Pix[o+1] = clamp(g)
+			dst.Pix[o+2] = clamp(b)
+			dst.Pix[o+3] = clamp(a)
+		}
+	}
+	return dst
+}
+
+func clamp(v float64) uint8 {
+	v += 0.5
+	if v < 0 {
+		return 0
+	}
+	if v > 255 {
+		return 255
+	}
+	return uint8(v)
+}
+
+// isOpaque : les images sans transparence sont ré-encodées en JPEG, les autres en PNG
+func isOpaque(img *image.RGBA) bool {
+	for i := 3; i < len(img.Pix); i += 4 {
+		if img.Pix[i] != 0xff {
+			return false
+		}
+	}
+	return true
+}
+
+func encode(img *image.RGBA, opaque bool) ([]byte, error) {
+	var buf bytes.Buffer
+	var err error
+	if opaque {
+		err = jpeg.Encode(&buf, img, &jpeg.Options{Quality: 85})
+	} else {
+		err = png.Encode(&buf, img)
+	}
+	return buf.Bytes(), err
+}
@@ -0,0 +1,456 @@
+package user_deletion_service
+
+import (
+	"errors"
+	"fmt"
+	"gox/database"
+	"gox/database/models"
+	mail_service "gox/services/mail"
+	storage_service "gox/services/storage"
+	team_service "gox/services/teams"
+	team_activity_service "gox/services/teams/activity"
+	team_member_service "gox/services/teams/members"
+	user_avatar_service "gox/services/users/avatar"
+	"gox/utils"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+var (
+	ErrDeletionPending   = errors.New("account deletion is already scheduled")
+	ErrNoPendingDeletion = errors.New("no account deletion is scheduled")
+	ErrAlreadyAnonymized = errors.New("account has already been deleted")
+	ErrInvalidResolution = errors.New("invalid team resolution")
+)
+
+// Délai de rétractation avant l'anonymisation, configurable via ACCOUNT_DELETION_GRACE_DAYS
+func GracePeriod() time.Duration {
+	days, err := strconv.Atoi(utils.GetEnv("ACCOUNT_DELETION_GRACE_DAYS", "30"))
+	if err != nil || days < 0 {
+		days = 30
+	}
+	return time.Duration(days) * 24 * time.Hour
+}
+
+// OwnedTeams retourne les Teams company actives dont l'utilisateur est le seul owner :
+// elles doivent changer de propriétaire ou être archivées avant l'anonymisation.
+func OwnedTeams(userID uuid.UUID) ([]models.Team, error) {
+	var teams []models.Team
+	err := database.DB.
+		Where("type = ? AND archived_at IS NULL", models.TeamTypeCompany).
+		Where("id IN (SELECT team_id FROM team_members WHERE member_id = ? AND role = ? AND left_at IS NULL)", userID, models.TeamMemberRoleOwner).
+		Where(`NOT EXISTS (
+			SELECT 1 FROM team_members other
+			WHERE other.team_id = teams.id AND other.member_id <> ? AND other.role = ?
+			AND other.left_at IS NULL AND other.is_active = true AND other.is_accessible = true
+		)`, userID, models.TeamMemberRoleOwner).
+		Order("name").
+		Find(&teams).Error
+	return teams, err
+}
+
+// successor choisit le nouvel owner d'une Team : un admin de préférence, puis le membre le plus ancien
+func successor(teamID, userID uuid.UUID) (*models.TeamMember, error) {
+	var member models.TeamMember
+	err := database.DB.
+		Where("team_id = ? AND member_id <> ?", teamID, userID).
+		Where(team_member_service.ActiveCondition).
+		Order(gorm.Expr("CASE WHEN role = ? THEN 0 ELSE 1 END, joined_at", models.TeamMemberRoleAdmin)).
+		First(&member).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &member, nil
+}
+
+// Plan décrit le sort prévu de chaque Team possédée : choix de l'utilisateur ou, à défaut,
+// transfert forcé au meilleur candidat, ou archivage s'il n'y a personne d'autre.
+func Plan(userID uuid.UUID, resolutions map[string]models.TeamResolution) (map[string]models.TeamResolution, error) {
+	teams, err := OwnedTeams(userID)
+	if err != nil {
+		return nil, err
+	}
+
+	plan := make(map[string]models.TeamResolution, len(teams))
+	for _, team := range teams {
+		if resolution, ok := resolutions[team.ID.String()]; ok {
+			plan[team.ID.String()] = resolution
+			continue
+		}
+
+		candidate, err := successor(team.ID, userID)
+		if err != nil {
+			return nil, err
+		}
+		if candidate == nil {
+			plan[team.ID.String()] = models.TeamResolution{Action: models.TeamResolutionArchive}
+		} else {
+			plan[team.ID.String()] = models.TeamResolution{Action: models.TeamResolutionTransfer, ToMemberID: &candidate.MemberID}
+		}
+	}
+	return plan, nil
+}
+
+// validate vérifie les choix de l'utilisateur pour ses Teams
+func validate(userID uuid.UUID, resolutions map[string]models.TeamResolution) error {
+	if len(resolutions) == 0 {
+		return nil
+	}
+
+	teams, err := OwnedTeams(userID)
+	if err != nil {
+		return err
+	}
+	owned := make(map[string]bool, len(teams))
+	for _, team := range teams {
+		owned[team.ID.String()] = true
+	}
+
+	for teamID, resolution := range resolutions {
+		if !owned[teamID] {
+			return fmt.Errorf("%w: %s is not a team you are the only owner of", ErrInvalidResolution, teamID)
+		}
+		switch resolution.Action {
+		case models.TeamResolutionArchive:
+		case models.TeamResolutionTransfer:
+			if resolution.ToMemberID == nil || *resolution.ToMemberID == userID {
+				return fmt.Errorf("%w: a new owner is required for team %s", ErrInvalidResolution, teamID)
+			}
+			var count int64
+			if err := database.DB.Model(&models.TeamMember{}).
+				Where("team_id = ? AND member_id = ?", teamID, *resolution.ToMemberID).
+				Where(team_member_service.ActiveCondition).
+				Count(&count).Error; err != nil {
+				return err
+			}
+			if count == 0 {
+				return fmt.Errorf("%w: the new owner of team %s must be an active member", ErrInvalidResolution, teamID)
+			}
+		default:
+			return fmt.Errorf("%w: unknown action %q for team %s", ErrInvalidResolution, resolution.Action, teamID)
+		}
+	}
+	return nil
+}
+
+// Request planifie la suppression du compte à l'issue du délai de rétractation
+func Request(userID uuid.UUID, resolutions map[string]models.TeamResolution) (models.UserDeletionRequest, error) {
+	var user models.User
+	if err := database.DB.Where("id = ?", userID).First(&user).Error; err != nil {
+		return models.UserDeletionRequest{}, err
+	}
+	if user.AnonymizedAt != nil {
+		return models.UserDeletionRequest{}, ErrAlreadyAnonymized
+	}
+	if _, err := GetPending(userID); err == nil {
+		return models.UserDeletionRequest{}, ErrDeletionPending
+	}
+	if err := validate(userID, resolutions); err != nil {
+		return models.UserDeletionRequest{}, err
+	}
+
+	request := models.UserDeletionRequest{
+		CustomerID:      userID,
+		Status:          models.UserDeletionStatusPending,
+		TeamResolutions: resolutions,
+		ScheduledFor:    time.Now().Add(GracePeriod()),
+	}
+	if err := database.DB.Create(&request).Error; err != nil {
+		return models.UserDeletionRequest{}, err
+	}
+
+	// Confirmation par email, avec la marche à suivre pour annuler
+	appURL := strings.TrimRight(utils.GetEnv("APP_URL", "http://localhost:8080"), "/")
+	if err := mail_service.Send(user.Email, "Your account deletion is scheduled", fmt.Sprintf(
+		"Your account will be deleted on %s.\n\n"+
+			"Until then, you can cancel the deletion by signing in and sending DELETE %s/users/me/deletion.\n"+
+			"After that date your personal data will be anonymized. Invoices and credit history are kept for accounting purposes.",
+		request.ScheduledFor.Format(time.RFC1123), appURL,
+	)); err != nil {
+		utils.ConsoleLog("Error sending deletion mail to user %s: %v", userID, err).Error()
+	}
+
+	return request, nil
+}
+
+func GetPending(userID uuid.UUID) (models.UserDeletionRequest, error) {
+	var request models.UserDeletionRequest
+	err := database.DB.Where("customer_id = ? AND status = ?", userID, models.UserDeletionStatusPending).First(&request).Error
+	return request, err
+}
+
+// Cancel annule la suppression planifiée, tant que le délai n'est pas écoulé
+func Cancel(userID uuid.UUID) error {
+	result := database.DB.Model(&models.UserDeletionRequest{}).
+		Where("customer_id = ? AND status = ?", userID, models.UserDeletionStatusPending).
+		Updates(map[string]interface{}{
+			"status":       models.UserDeletionStatusCancelled,
+			"cancelled_at": time.Now(),
+		})
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return ErrNoPendingDeletion
+	}
+	return nil
+}
+
+// ProcessDue anonymise les comptes dont le délai de rétractation est écoulé
+func ProcessDue() error {
+	var requests []models.UserDeletionRequest
+	if err := database.DB.
+		Where("status = ? AND scheduled_for <= ?", models.UserDeletionStatusPending, time.Now()).
+		Find(&requests).Error; err != nil {
+		return err
+	}
+
+	for _, request := range requests {
+		if err := execute(request); err != nil {
+			utils.ConsoleLog("Error deleting account %s: %v", request.CustomerID, err).Error()
+		}
+	}
+	return nil
+}
+
+// ScheduleNow avance la suppression du compte à maintenant, sans délai de rétractation (administration) :
+// à défaut de DeleteNow, ProcessDue la reprendra
+func ScheduleNow(db *gorm.DB, userID uuid.UUID) (models.UserDeletionRequest, error) {
+	var request models.UserDeletionRequest
+	err := db.Where("customer_id = ? AND status = ?", userID, models.UserDeletionStatusPending).First(&request).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		request = models.UserDeletionRequest{
+			CustomerID:   userID,
+			Status:       models.UserDeletionStatusPending,
+			ScheduledFor: time.Now(),
+		}
+		return request, db.Create(&request).Error
+	}
+	if err != nil {
+		return models.UserDeletionRequest{}, err
+	}
+
+	if request.ScheduledFor.After(time.Now()) {
+		request.ScheduledFor = time.Now()
+		if err := db.Model(&request).Update("scheduled_for", request.ScheduledFor).Error; err != nil {
+			return models.UserDeletionRequest{}, err
+		}
+	}
+	return request, nil
+}
+
+// DeleteNow anonymise immédiatement le compte, sans délai de rétractation (administration)
+func DeleteNow(userID uuid.UUID) error {
+	request, err := ScheduleNow(database.DB, userID)
+	if err != nil {
+		return err
+	}
+
+	return execute(request)
+}
+
+func execute(request models.UserDeletionRequest) error {
+	userID := request.CustomerID
+
+	var user models.User
+	if err := database.DB.Where("id = ?", userID).First(&user).Error; err != nil {
+		return err
+	}
+
+	// 1) Teams dont l'utilisateur est le seul owner
+	plan, err := Plan(userID, request.TeamResolutions)
+	if err != nil {
+		return err
+	}
+	for teamID, resolution := range plan {
+		_, chosen := request.TeamResolutions[teamID]
+		if err := resolve(uuid.MustParse(teamID), userID, resolution, chosen); err != nil {
+			return fmt.Errorf("error resolving team %s: %v", teamID, err)
+		}
+	}
+
+	// 2) Départ de toutes les Teams company, suppression de la Team personnelle
+	var memberships []models.TeamMember
+	if err := database.DB.
+		Where("member_id = ? AND team_id IN (SELECT id FROM teams WHERE type = ?)", userID, models.TeamTypeCompany).
+		Where(team_member_service.CurrentCondition).
+		Find(&memberships).Error; err != nil {
+		return err
+	}
+	// Une Team où l'utilisateur serait encore le dernier owner interrompt la suppression :
+	// la demande reste en attente plutôt que de laisser une Team sans owner
+	for _, membership := range memberships {
+		if err := team_member_service.Remove(database.DB, membership.TeamID, userID, userID); err != nil {
+			return fmt.Errorf("error leaving team %s: %w", membership.TeamID, err)
+		}
+	}
+	if err := team_service.DeletePersonalTeams(userID); err != nil {
+		return fmt.Errorf("error deleting personal teams: %v", err)
+	}
+
+	// 3) Fichiers : avatar et exports
+	if err := user_avatar_service.Remove(userID); err != nil && !errors.Is(err, user_avatar_service.ErrNoAvatar) && !errors.Is(err, gorm.ErrRecordNotFound) {
+		return fmt.Errorf("error deleting avatar: %v", err)
+	}
+	deleteExports(userID)
+
+	// 4) Anonymisation des données personnelles
+	if err := anonymize(user); err != nil {
+		return err
+	}
+
+	now := time.Now()
+	if err := database.DB.Model(&request).Updates(map[string]interface{}{
+		"status":       models.UserDeletionStatusCompleted,
+		"completed_at": now,
+	}).Error; err != nil {
+		return err
+	}
+
+	if err := mail_service.Send(user.Email, "Your account has been deleted", "Your account has been deleted and your personal data anonymized."); err != nil {
+		utils.ConsoleLog("Error sending deletion mail to user %s: %v", userID, err).Error()
+	}
+	utils.ConsoleLog("🗑️ Account %s anonymized", userID)
+	return nil
+}
+
+// resolve applique le sort prévu à une Team ; un transfert automatique est attribué au système
+func resolve(teamID, userID uuid.UUID, resolution models.TeamResolution, chosen bool) error {
+	actorID := uuid.Nil
+	if chosen {
+		actorID = userID
+	}
+
+	if resolution.Action == models.TeamResolutionTransfer && resolution.ToMemberID != nil {
+		err := team_member_service.UpdateRole(teamID, *resolution.ToMemberID, models.TeamMemberRoleOwner, actorID)
+		if err == nil {
+			return nil
+		}
+		// Le successeur choisi n'est plus membre : repli sur le choix automatique
+		if !errors.Is(err, gorm.ErrRecordNotFound) {
+			return err
+		}
+		candidate, err := successor(teamID, userID)
+		if err != nil {
+			return err
+		}
+		if candidate != nil {
+			return team_member_service.UpdateRole(teamID, candidate.MemberID, models.TeamMemberRoleOwner, uuid.Nil)
+		}
+	}
+
+	team, err := team_service.Get(teamID)
+	if err != nil {
+		return err
+	}
+	if team.IsArchived() {
+		return nil
+	}
+	return team_service.Archive(teamID, actorID)
+}
+
+func deleteExports(userID uuid.UUID) {
+	var exports []models.UserExport
+	if err := database.DB.Where("customer_id = ? AND storage_key IS NOT NULL", userID).Find(&exports).Error; err != nil {
+		utils.ConsoleLog("Error listing exports of user %s: %v", userID, err).Error()
+		return
+	}
+	storage, err := storage_service.Default()
+	if err != nil {
+		return
+	}
+	for _, export := range exports {
+		if err := storage.Delete(export.StorageKey); err != nil {
+			utils.ConsoleLog("Error deleting export %s: %v", export.ID, err).Error()
+		}
+	}
+}
+
+// anonymize efface les données personnelles en place. Le compte (et son ID) est conservé pour que
+// les factures, l'historique des crédits et les logs restent cohérents.
+func anonymize(user models.User) error {
+	userID := user.ID
+	alias := "deleted-" + strings.ReplaceAll(userID.String(), "-", "")[:12]
+
+	return database.DB.Transaction(func(tx *gorm.DB) error {
+		now := time.Now()
+
+		// Compte : email anonyme et mot de passe inutilisable
+		if err := tx.Model(&models.User{}).Where("id = ?", userID).Updates(map[string]interface{}{
+			"email":         fmt.Sprintf("%s@deleted.invalid", userID),
+			"password":      "",
+			"is_app_admin":  false,
+			"is_active":     false,
+			"is_accessible": false,
+			"anonymized_at": now,
+		}).Error; err != nil {
+			return err
+		}
+
+		// Profil
+		if err := tx.Model(&models.UserProfile{}).Where("customer_id = ?", userID).Updates(map[string]interface{}{
+			"username":      alias,
+			"display_name":  nil,
+			"bio":           nil,
+			"avatar_url":    nil,
+			"visibility":    nil,
+			"is_accessible": false,
+		}).Error; err != nil {
+			return err
+		}
+		if err := tx.Where("customer_id = ?", userID).Delete(&models.UsernameHistory{}).Error; err != nil {
+			return err
+		}
+
+		// Corps des requêtes journalisées (données saisies...), adresse IP et navigateur
+		if err := tx.Model(&models.RequestLog{}).Where("user_id = ?", userID).Updates(map[string]interface{}{
+			"content":    "",
+			"query":      "",
+			"headers":    nil,
+			"client_ip":  "",
+			"user_agent": "",
+		}).Error; err != nil {
+			return err
+		}
+
+		// Invitations et demandes d'adhésion
+		if err := tx.Where("LOWER(email) = LOWER(?) AND status = ?", user.Email, models.TeamInvitationStatusPending).Delete(&models.TeamInvitation{}).Error; err != nil {
+			return err
+		}
+		if err := tx.Model(&models.TeamInvitation{}).Where("LOWER(email) = LOWER(?)", user.Email).Update("email", fmt.Sprintf("%s@deleted.invalid", userID)).Error; err != nil {
+			return err
+		}
+		if err := tx.Where("user_id = ? AND status = ?", userID, models.TeamJoinRequestStatusPending).Delete(&models.TeamJoinRequest{}).Error; err != nil {
+			return err
+		}
+
+		// Journal d'activité des Teams : anciennes valeurs (email, pseudo...) des entrées qui visent
+		// l'utilisateur ou mentionnent son adresse, puis attribution des actions qu'il a effectuées
+		if err := tx.Model(&models.TeamActivity{}).
+			Where("(target_type = ? AND target_id = ?) OR position(LOWER(?) IN LOWER(changes::text)) > 0", team_activity_service.TargetMember, userID.String(), user.Email).
+			Update("changes", gorm.Expr("NULL")).Error; err != nil {
+			return err
+		}
+		if err := tx.Model(&models.TeamActivity{}).Where("actor_id = ?", userID).Update("actor_id", nil).Error; err != nil {
+			return err
+		}
+
+		// Identifiants fournis par les annuaires (SCIM)
+		if err := tx.Model(&models.TeamMember{}).Where("member_id = ?", userID).Update("external_id", "").Error; err != nil {
+			return err
+		}
+
+		// Données dérivées
+		if err := tx.Where("customer_id = ?", userID).Delete(&models.UserStats{}).Error; err != nil {
+			return err
+		}
+		return tx.Where("customer_id = ?", userID).Delete(&models.UserExport{}).Error
+	})
+}
@@ -0,0 +1,119 @@
+package user_email_verification_service
+
+import (
+	"errors"
+	"fmt"
+	"gox/database"
+	"gox/database/models"
+	mail_service "gox/services/mail"
+	team_domain_service "gox/services/teams/domains"
+	"gox/utils"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+var ErrInvalidToken = errors.New("invalid or expired verification token")
+
+// Durée de validité d'un lien de vérification (en heures), configurable via EMAIL_VERIFICATION_TTL_HOURS
+func TTL() time.Duration {
+	hours, err := strconv.Atoi(utils.GetEnv("EMAIL_VERIFICATION_TTL_HOURS", "48"))
+	if err != nil || hours <= 0 {
+		hours = 48
+	}
+	return time.Duration(hours) * time.Hour
+}
+
+// Send envoie un lien de vérification à l'adresse de l'utilisateur. Les liens précédents sont invalidés.
+func Send(userID uuid.UUID, email string) error {
+	token, err := utils.GenerateToken(32)
+	if err != nil {
+		return fmt.Errorf("error generating token: %v", err)
+	}
+
+	verification := models.UserEmailVerification{
+		CustomerID: userID,
+		Email:      email,
+		TokenHash:  utils.HashToken(token),
+		ExpiresAt:  time.Now().Add(TTL()),
+	}
+
+	err = database.DB.Transaction(func(tx *gorm.DB) error {
+		now := time.Now()
+		if err := tx.Model(&models.UserEmailVerification{}).
+			Where("customer_id = ? AND used_at IS NULL AND expires_at > ?", userID, now).
+			Update("expires_at", now).Error; err != nil {
+			return err
+		}
+		return tx.Create(&verification).Error
+	})
+	if err != nil {
+		return err
+	}
+
+	appURL := strings.TrimRight(utils.GetEnv("APP_URL", "http://localhost:8080"), "/")
+	if err := mail_service.Send(email, "Verify your email address", fmt.Sprintf(
+		"Please confirm that this address belongs to you.\n\n"+
+			"Verify your email: %s/email/verify?token=%s\n\n"+
+			"This link expires on %s.",
+		appURL, token,
+		verification.ExpiresAt.Format(time.RFC1123),
+	)); err != nil {
+		utils.ConsoleLog("Error sending email verification mail for %s: %v", userID, err).Error()
+	}
+
+	return nil
+}
+
+// Complete marque l'email comme vérifié si le token est valide et que l'adresse n'a pas changé entre-temps,
+// puis rattache l'utilisateur aux Teams ayant vérifié le domaine de cet email
+func Complete(token string) (uuid.UUID, error) {
+	var verification models.UserEmailVerification
+	result := database.DB.Where("token_hash = ?", utils.HashToken(token)).First(&verification)
+	if errors.Is(result.Error, gorm.ErrRecordNotFound) {
+		return uuid.Nil, ErrInvalidToken
+	}
+	if result.Error != nil {
+		return uuid.Nil, result.Error
+	}
+	if verification.UsedAt != nil || time.Now().After(verification.ExpiresAt) {
+		return uuid.Nil, ErrInvalidToken
+	}
+
+	err := database.DB.Transaction(func(tx *gorm.DB) error {
+		now := time.Now()
+		// Le token ne sert qu'une fois, même en cas de requêtes simultanées
+		claimed := tx.Model(&models.UserEmailVerification{}).
+			Where("id = ? AND used_at IS NULL", verification.ID).
+			Update("used_at", now)
+		if claimed.Error != nil {
+			return claimed.Error
+		}
+		if claimed.RowsAffected == 0 {
+			return ErrInvalidToken
+		}
+
+		verified := tx.Model(&models.User{}).
+			Where("id = ? AND email = ?", verification.CustomerID, verification.Email).
+			Update("email_verified_at", now)
+		if verified.Error != nil {
+			return verified.Error
+		}
+		if verified.RowsAffected == 0 {
+			return ErrInvalidToken
+		}
+
+		return nil
+	})
+	if err != nil {
+		return uuid.Nil, err
+	}
+
+	// Adhésion aux Teams ayant vérifié le domaine de l'email (directe ou sur demande)
+	team_domain_service.OnEmailVerified(verification.CustomerID, verification.Email)
+
+	return verification.CustomerID, nil
+}
@@ -0,0 +1,356 @@
+package user_export_service
+
+import (
+	"archive/zip"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"gox/database"
+	"gox/database/models"
+	"io"
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// Nombre de logs de requêtes lus à la fois
+const logBatchSize = 1000
+
+// ManifestFile décrit un fichier de l'archive
+type ManifestFile struct {
+	Name        string `json:"name"`
+	Description string `json:"description"`
+	Records     int    `json:"records"`
+	Bytes       int64  `json:"bytes"`
+	SHA256      string `json:"sha256"`
+}
+
+type Manifest struct {
+	ExportID    uuid.UUID      `json:"export_id"`
+	UserID      uuid.UUID      `json:"user_id"`
+	GeneratedAt time.Time      `json:"generated_at"`
+	Format      string         `json:"format"`
+	Files       []ManifestFile `json:"files"`
+}
+
+// archive écrit les fichiers dans le ZIP et tient le manifeste à jour
+type archive struct {
+	zip      *zip.Writer
+	manifest Manifest
+}
+
+// countingWriter calcule taille et empreinte de ce qui est écrit
+type countingWriter struct {
+	w     io.Writer
+	hash  io.Writer
+	bytes int64
+}
+
+func (c *countingWriter) Write(p []byte) (int, error) {
+	n, err := c.w.Write(p)
+	c.hash.Write(p[:n])
+	c.bytes += int64(n)
+	return n, err
+}
+
+// writeFile ouvre un fichier de l'archive ; fill écrit le contenu et retourne le nombre d'enregistrements
+func (a *archive) writeFile(name, description string, fill func(w io.Writer) (int, error)) error {
+	entry, err := a.zip.CreateHeader(&zip.FileHeader{Name: name, Method: zip.Deflate, Modified: time.Now()})
+	if err != nil {
+		return err
+	}
+
+	hash := sha256.New()
+	counter := &countingWriter{w: entry, hash: hash}
+	records, err := fill(counter)
+	if err != nil {
+		return err
+	}
+
+	a.manifest.Files = append(a.manifest.Files, ManifestFile{
+		Name:        name,
+		Description: description,
+		Records:     records,
+		Bytes:       counter.bytes,
+		SHA256:      hex.EncodeToString(hash.Sum(nil)),
+	})
+	return nil
+}
+
+// writeJSON écrit une valeur en JSON indenté ; records vaut la longueur des listes, 1 sinon
+func (a *archive) writeJSON(name, description string, value interface{}, records int) error {
+	return a.writeFile(name, description, func(w io.Writer) (int, error) {
+		encoder := json.NewEncoder(w)
+		encoder.SetIndent("", "  ")
+		return records, encoder.Encode(value)
+	})
+}
+
+// build écrit dans w un ZIP de fichiers JSON rassemblant toutes les données rattachées à l'utilisateur
+func build(w io.Writer, exportID, userID uuid.UUID) error {
+	a := &archive{
+		zip: zip.NewWriter(w),
+		manifest: Manifest{
+			ExportID:    exportID,
+			UserID:      userID,
+			GeneratedAt: time.Now().UTC(),
+			Format:      "gox-export/1",
+		},
+	}
+
+	// Compte (sans le hash du mot de passe)
+	var user models.User
+	if err := database.DB.Where("id = ?", userID).First(&user).Error; err != nil {
+		return err
+	}
+	if err := a.writeJSON("account.json", "Account", map[string]interface{}{
+		"id":            user.ID,
+		"email":         user.Email,
+		"created_on":    user.CreatedOn,
+		"is_app_admin":  user.IsAppAdmin,
+		"is_active":     user.IsActive,
+		"is_accessible": user.IsAccessible,
+	}, 1); err != nil {
+		return err
+	}
+
+	// Profil et anciens usernames
+	var profiles []models.UserProfile
+	if err := database.DB.Where("customer_id = ?", userID).Find(&profiles).Error; err != nil {
+		return err
+	}
+	profileData := make([]map[string]interface{}, len(profiles))
+	for i, profile := range profiles {
+		profileData[i] = map[string]interface{}{
+			"username":             profile.Username,
+			"display_name":         profile.DisplayName,
+			"bio":                  profile.Bio,
+			"avatar_url":           profile.AvatarURL,
+			"visibility":           profile.Visibility,
+			"username_changed_at":  profile.UsernameChangedAt,
+			"public_stats_display": profile.PublicStatsDisplay,
+		}
+	}
+	if err := a.writeJSON("profile.json", "Public profile", profileData, len(profileData)); err != nil {
+		return err
+	}
+
+	var usernames []models.UsernameHistory
+	if err := database.DB.Where("customer_id = ?", userID).Order("changed_at").Find(&usernames).Error; err != nil {
+		return err
+	}
+	usernameData := make([]map[string]interface{}, len(usernames))
+	for i, entry := range usernames {
+		usernameData[i] = map[string]interface{}{"username": entry.Username, "changed_at": entry.ChangedAt}
+	}
+	if err := a.writeJSON("username_history.json", "Previous usernames", usernameData, len(usernameData)); err != nil {
+		return err
+	}
+
+	// Appartenances aux Teams, y compris passées
+	var memberships []models.TeamMember
+	if err := database.DB.Preload("Team").Preload("CustomRole").Where("member_id = ?", userID).Order("joined_at").Find(&memberships).Error; err != nil {
+		return err
+	}
+	membershipData := make([]map[string]interface{}, len(memberships))
+	for i, membership := range memberships {
+		data := map[string]interface{}{
+			"team_id":         membership.TeamID,
+			"team_name":       membership.Team.Name,
+			"team_type":       membership.Team.Type,
+			"role":            membership.Role,
+			"joined_at":       membership.JoinedAt,
+			"role_changed_at": membership.RoleChangedAt,
+			"suspended_at":    membership.SuspendedAt,
+			"left_at":         membership.LeftAt,
+			"external_id":     membership.ExternalID,
+		}
+		if membership.CustomRole != nil {
+			data["custom_role"] = membership.CustomRole.Name
+		}
+		membershipData[i] = data
+	}
+	if err := a.writeJSON("team_memberships.json", "Team memberships, current and past", membershipData, len(membershipData)); err != nil {
+		return err
+	}
+
+	var joinRequests []models.TeamJoinRequest
+	if err := database.DB.Where("user_id = ?", userID).Find(&joinRequests).Error; err != nil {
+		return err
+	}
+	joinRequestData := make([]map[string]interface{}, len(joinRequests))
+	for i, request := range joinRequests {
+		joinRequestData[i] = map[string]interface{}{
+			"team_id":      request.TeamID,
+			"status":       request.Status,
+			"created_on":   request.CreatedOn,
+			"responded_at": request.RespondedAt,
+		}
+	}
+	if err := a.writeJSON("team_join_requests.json", "Requests to join teams", joinRequestData, len(joinRequestData)); err != nil {
+		return err
+	}
+
+	var invitations []models.TeamInvitation
+	if err := database.DB.Where("LOWER(email) = LOWER(?)", user.Email).Find(&invitations).Error; err != nil {
+		return err
+	}
+	invitationData := make([]map[string]interface{}, len(invitations))
+	for i, invitation := range invitations {
+		invitationData[i] = map[string]interface{}{
+			"team_id":    invitation.TeamID,
+			"role":       invitation.Role,
+			"status":     invitation.Status,
+			"created_on": invitation.CreatedOn,
+			"expires_at": invitation.ExpiresAt,
+		}
+	}
+	if err := a.writeJSON("team_invitations.json", "Invitations received", invitationData, len(invitationData)); err != nil {
+		return err
+	}
+
+	var activities []models.TeamActivity
+	if err := database.DB.Where("actor_id = ?", userID).Order("created_on").Find(&activities).Error; err != nil {
+		return err
+	}
+	activityData := make([]map[string]interface{}, len(activities))
+	for i, activity := range activities {
+		activityData[i] = map[string]interface{}{
+			"team_id":     activity.TeamID,
+			"action":      activity.Action,
+			"target_type": activity.TargetType,
+			"target_id":   activity.TargetID,
+			"changes":     activity.Changes,
+			"created_on":  activity.CreatedOn,
+		}
+	}
+	if err := a.writeJSON("team_activity.json", "Actions performed in teams", activityData, len(activityData)); err != nil {
+		return err
+	}
+
+	// Abonnements et avantages associés
+	var subscriptions []models.UserSubscription
+	if err := database.DB.Preload("Subscription").Preload("SubscriptionPerks").Where("customer_id = ?", userID).Order("start_at").Find(&subscriptions).Error; err != nil {
+		return err
+	}
+	subscriptionData := make([]map[string]interface{}, len(subscriptions))
+	for i, subscription := range subscriptions {
+		perks := subscription.SubscriptionPerks
+		subscriptionData[i] = map[string]interface{}{
+			"id":          subscription.ID,
+			"plan":        subscription.Subscription.Name,
+			"description": subscription.Subscription.Description,
+			"currency":    subscription.Subscription.Currency,
+			"start_at":    subscription.StartAt,
+			"auto_renew":  subscription.AutoRenew,
+			"total_price": subscription.TotalPrice,
+			"perks": map[string]interface{}{
+				"collaborative_team_count":     perks.CollaborativeTeamCount,
+				"included_team_count":          perks.IncludedTeamCount,
+				"price_per_additional_team":    perks.PricePerAdditionalTeam,
+				"max_products_per_team":        perks.MaxProductsPerTeam,
+				"included_product_count":       perks.IncludedProductCount,
+				"price_per_additional_product": perks.PricePerAdditionalProduct,
+			},
+		}
+	}
+	if err := a.writeJSON("subscriptions.json", "Subscriptions and their perks", subscriptionData, len(subscriptionData)); err != nil {
+		return err
+	}
+
+	// Crédits : solde et historique des opérations
+	var credits []models.UserCredit
+	if err := database.DB.Where("customer_id = ?", userID).Find(&credits).Error; err != nil {
+		return err
+	}
+	var creditHistory []models.UserCreditHistory
+	if err := database.DB.Where("customer_id = ?", userID).Order("date_time").Find(&creditHistory).Error; err != nil {
+		return err
+	}
+	balances := make([]map[string]interface{}, len(credits))
+	for i, credit := range credits {
+		balances[i] = map[string]interface{}{"id": credit.ID, "balance": credit.Balance}
+	}
+	operations := make([]map[string]interface{}, len(creditHistory))
+	for i, entry := range creditHistory {
+		operations[i] = map[string]interface{}{
+			"amount":    entry.Amount,
+			"operation": entry.Operation,
+			"reason":    entry.Reason,
+			"date_time": entry.DateTime,
+		}
+	}
+	if err := a.writeJSON("credits.json", "Credit balance and history", map[string]interface{}{
+		"balances": balances,
+		"history":  operations,
+	}, len(balances)+len(operations)); err != nil {
+		return err
+	}
+
+	// Logs des requêtes, lus par lots pour ne pas tout charger en mémoire
+	if err := a.writeFile("request_logs.json", "Requests made to the API", func(w io.Writer) (int, error) {
+		return writeRequestLogs(w, userID)
+	}); err != nil {
+		return err
+	}
+
+	// Manifeste en dernier, il décrit tous les autres fichiers
+	if err := a.writeJSON("manifest.json", "Archive contents", a.manifest, len(a.manifest.Files)); err != nil {
+		return err
+	}
+	if err := a.zip.Close(); err != nil {
+		return err
+	}
+
+	return nil
+}
+
+func writeRequestLogs(w io.Writer, userID uuid.UUID) (int, error) {
+	if _, err := io.WriteString(w, "[\n"); err != nil {
+		return 0, err
+	}
+
+	count := 0
+	var logs []models.RequestLog
+	result := database.DB.Where("user_id = ?", userID).Order("id").FindInBatches(&logs, logBatchSize, func(tx *gorm.DB, batch int) error {
+		for _, log := range logs {
+			// Le corps est stocké en base64 : il est décodé pour être lisible
+			body := log.Content
+			if decoded, err := base64.StdEncoding.DecodeString(log.Content); err == nil {
+				body = string(decoded)
+			}
+			entry, err := json.Marshal(map[string]interface{}{
+				"timestamp":  log.Timestamp,
+				"method":     log.Method,
+				"domain":     log.Domain,
+				"endpoint":   log.Endpoint,
+				"query":      log.Query,
+				"status":     log.Status,
+				"client_ip":  log.ClientIP,
+				"user_agent": log.UserAgent,
+				"body":       body,
+			})
+			if err != nil {
+				return err
+			}
+			if count > 0 {
+				if _, err := io.WriteString(w, ",\n"); err != nil {
+					return err
+				}
+			}
+			if _, err := w.Write(entry); err != nil {
+				return err
+			}
+			count++
+		}
+		return nil
+	})
+	if result.Error != nil {
+		return count, result.Error
+	}
+
+	_, err := io.WriteString(w, "\n]\n")
+	return count, err
+}
@@ -0,0 +1,281 @@
+package user_export_service
+
+import (
+	"errors"
+	"fmt"
+	"gox/database"
+	"gox/database/models"
+	mail_service "gox/services/mail"
+	storage_service "gox/services/storage"
+	"gox/utils"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+var (
+	ErrExportInProgress = errors.New("an export is already in progress")
+	ErrExportNotReady   = errors.New("export is not ready")
+	ErrExportExpired    = errors.New("export download link has expired")
+	ErrInvalidToken     = errors.New("invalid download token")
+)
+
+// Durée de validité du lien de téléchargement, configurable via EXPORT_DOWNLOAD_TTL_HOURS
+func DownloadTTL() time.Duration {
+	hours, err := strconv.Atoi(utils.GetEnv("EXPORT_DOWNLOAD_TTL_HOURS", "48"))
+	if err != nil || hours <= 0 {
+		hours = 48
+	}
+	return time.Duration(hours) * time.Hour
+}
+
+// Au-delà, un export toujours en cours est considéré comme abandonné (redémarrage du serveur...)
+const staleAfter = time.Hour
+
+// Migrate crée l'index garantissant un seul export en cours par utilisateur. Les doublons existants,
+// sauf le plus récent, sont d'abord marqués en échec.
+func Migrate() error {
+	inProgress := []models.UserExportStatus{models.UserExportStatusPending, models.UserExportStatusProcessing}
+	return database.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Exec(`UPDATE user_exports SET status = ?, error = ?
+			WHERE status IN ? AND id NOT IN (
+				SELECT DISTINCT ON (customer_id) id FROM user_exports WHERE status IN ? ORDER BY customer_id, requested_at DESC
+			)`, models.UserExportStatusFailed, "superseded by a newer export", inProgress, inProgress).Error; err != nil {
+			return err
+		}
+		return tx.Exec(`CREATE UNIQUE INDEX IF NOT EXISTS idx_user_exports_in_progress ON user_exports (customer_id)
+			WHERE status IN ('pending', 'processing')`).Error
+	})
+}
+
+// Request crée un export et lance sa génération en tâche de fond.
+// Un seul export peut être en cours par utilisateur : l'export en cours est alors retourné.
+// Les demandes simultanées sont sérialisées par un verrou sur l'utilisateur ; l'index unique reste le dernier rempart.
+func Request(userID uuid.UUID) (models.UserExport, error) {
+	inProgress := []models.UserExportStatus{models.UserExportStatusPending, models.UserExportStatusProcessing}
+
+	var export models.UserExport
+	err := database.DB.Transaction(func(tx *gorm.DB) error {
+		var user models.User
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).Select("id").Where("id = ?", userID).First(&user).Error; err != nil {
+			return err
+		}
+
+		var current models.UserExport
+		err := tx.Where("customer_id = ? AND status IN ?", userID, inProgress).First(&current).Error
+		if err == nil {
+			// Un export abandonné (redémarrage du serveur...) ne bloque pas une nouvelle demande
+			if current.RequestedAt.After(time.Now().Add(-staleAfter)) {
+				export = current
+				return ErrExportInProgress
+			}
+			if err := tx.Model(&current).Updates(map[string]interface{}{
+				"status": models.UserExportStatusFailed,
+				"error":  "export timed out",
+			}).Error; err != nil {
+				return err
+			}
+		} else if !errors.Is(err, gorm.ErrRecordNotFound) {
+			return err
+		}
+
+		export = models.UserExport{
+			CustomerID: userID,
+			Status:     models.UserExportStatusPending,
+		}
+		return tx.Create(&export).Error
+	})
+	if errors.Is(err, ErrExportInProgress) {
+		return export, err
+	}
+	if err != nil {
+		return models.UserExport{}, err
+	}
+
+	go process(export.ID)
+
+	return export, nil
+}
+
+func GetAll(userID uuid.UUID) ([]models.UserExport, error) {
+	var exports []models.UserExport
+	err := database.DB.Where("customer_id = ?", userID).Order("requested_at DESC").Find(&exports).Error
+	return exports, err
+}
+
+func Get(userID, exportID uuid.UUID) (models.UserExport, error) {
+	var export models.UserExport
+	err := database.DB.Where("id = ? AND customer_id = ?", exportID, userID).First(&export).Error
+	return export, err
+}
+
+// process génère l'archive, la stocke, puis prévient l'utilisateur avec un lien de téléchargement
+func process(exportID uuid.UUID) {
+	defer func() {
+		if r := recover(); r != nil {
+			fail(exportID, fmt.Errorf("panic: %v", r))
+		}
+	}()
+
+	var export models.UserExport
+	if err := database.DB.Where("id = ?", exportID).First(&export).Error; err != nil {
+		utils.ConsoleLog("Error loading export %s: %v", exportID, err).Error()
+		return
+	}
+	if err := database.DB.Model(&export).Update("status", models.UserExportStatusProcessing).Error; err != nil {
+		fail(exportID, err)
+		return
+	}
+
+	// Génération de l'archive dans un fichier temporaire : elle n'est jamais chargée en mémoire
+	file, err := os.CreateTemp("", "gox-export-*.zip")
+	if err != nil {
+		fail(exportID, err)
+		return
+	}
+	defer os.Remove(file.Name())
+	defer file.Close()
+
+	if err := build(file, export.ID, export.CustomerID); err != nil {
+		fail(exportID, err)
+		return
+	}
+	size, err := file.Seek(0, io.SeekCurrent)
+	if err == nil {
+		_, err = file.Seek(0, io.SeekStart)
+	}
+	if err != nil {
+		fail(exportID, err)
+		return
+	}
+
+	// Stockage, en flux depuis le fichier temporaire
+	storage, err := storage_service.Default()
+	if err != nil {
+		fail(exportID, err)
+		return
+	}
+	key := fmt.Sprintf("exports/%s/%s.zip", export.CustomerID, export.ID)
+	if err := storage.PutStream(key, file, size, "application/zip"); err != nil {
+		fail(exportID, err)
+		return
+	}
+
+	// Lien de téléchargement : seul le hash du token est conservé
+	token, err := utils.GenerateToken(32)
+	if err != nil {
+		fail(exportID, err)
+		return
+	}
+	now := time.Now()
+	expiresAt := now.Add(DownloadTTL())
+	if err := database.DB.Model(&export).Updates(map[string]interface{}{
+		"status":       models.UserExportStatusReady,
+		"storage_key":  key,
+		"size_bytes":   size,
+		"token_hash":   utils.HashToken(token),
+		"completed_at": now,
+		"expires_at":   expiresAt,
+	}).Error; err != nil {
+		fail(exportID, err)
+		return
+	}
+
+	// Notification
+	var user models.User
+	if err := database.DB.Where("id = ?", export.CustomerID).First(&user).Error; err != nil {
+		utils.ConsoleLog("Error loading user of export %s: %v", exportID, err).Error()
+		return
+	}
+	if err := mail_service.Send(user.Email, "Your data export is ready", fmt.Sprintf(
+		"The export of your data is ready.\n\n"+
+			"Download: %s\n\n"+
+			"This link expires on %s.",
+		DownloadURL(export.ID, token),
+		expiresAt.Format(time.RFC1123),
+	)); err != nil {
+		utils.ConsoleLog("Error sending export mail for %s: %v", exportID, err).Error()
+	}
+}
+
+func fail(exportID uuid.UUID, cause error) {
+	utils.ConsoleLog("Export %s failed: %v", exportID, cause).Error()
+	database.DB.Model(&models.UserExport{}).Where("id = ?", exportID).Updates(map[string]interface{}{
+		"status": models.UserExportStatusFailed,
+		"error":  cause.Error(),
+	})
+}
+
+func DownloadURL(exportID uuid.UUID, token string) string {
+	appURL := strings.TrimRight(utils.GetEnv("APP_URL", "http://localhost:8080"), "/")
+	return fmt.Sprintf("%s/exports/%s/download?token=%s", appURL, exportID, token)
+}
+
+// Open vérifie le token du lien et ouvre l'archive
+func Open(exportID uuid.UUID, token string) (io.ReadCloser, models.UserExport, error) {
+	var export models.UserExport
+	if err := database.DB.Where("id = ?", exportID).First(&export).Error; err != nil {
+		return nil, models.UserExport{}, err
+	}
+	if token == "" || export.TokenHash == "" || utils.HashToken(token) != export.TokenHash {
+		return nil, export, ErrInvalidToken
+	}
+	if export.Status == models.UserExportStatusExpired || (export.ExpiresAt != nil && time.Now().After(*export.ExpiresAt)) {
+		return nil, export, ErrExportExpired
+	}
+	if export.Status != models.UserExportStatusReady {
+		return nil, export, ErrExportNotReady
+	}
+
+	storage, err := storage_service.Default()
+	if err != nil {
+		return nil, export, err
+	}
+	body, _, err := storage.Get(export.StorageKey)
+	if err != nil {
+		return nil, export, err
+	}
+	return body, export, nil
+}
+
+// PurgeExpired supprime les archives dont le lien a expiré et clôt les exports abandonnés
+func PurgeExpired() error {
+	var expired []models.UserExport
+	if err := database.DB.
+		Where("status = ? AND expires_at < ?", models.UserExportStatusReady, time.Now()).
+		Find(&expired).Error; err != nil {
+		return err
+	}
+
+	if len(expired) > 0 {
+		storage, err := storage_service.Default()
+		if err != nil {
+			return err
+		}
+		for _, export := range expired {
+			if err := storage.Delete(export.StorageKey); err != nil {
+				utils.ConsoleLog("Error deleting export %s: %v", export.ID, err).Error()
+				continue
+			}
+			database.DB.Model(&export).Updates(map[string]interface{}{
+				"status":      models.UserExportStatusExpired,
+				"storage_key": nil,
+				"token_hash":  nil,
+			})
+		}
+		utils.ConsoleLog("🗑️ %d expired export(s) purged", len(expired))
+	}
+
+	return database.DB.Model(&models.UserExport{}).
+		Where("status IN ? AND requested_at < ?", []models.UserExportStatus{models.UserExportStatusPending, models.UserExportStatusProcessing}, time.Now().Add(-staleAfter)).
+		Updates(map[string]interface{}{
+			"status": models.UserExportStatusFailed,
+			"error":  "export timed out",
+		}).Error
+}
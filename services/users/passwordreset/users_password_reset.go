@@ -0,0 +1,150 @@
+package user_password_reset_service
+
+import (
+	"errors"
+	"fmt"
+	"gox/database"
+	"gox/database/models"
+	mail_service "gox/services/mail"
+	"gox/utils"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"golang.org/x/crypto/bcrypt"
+	"gorm.io/gorm"
+)
+
+var ErrInvalidToken = errors.New("invalid or expired reset token")
+
+// Durée de validité d'un lien de réinitialisation (en heures), configurable via PASSWORD_RESET_TTL_HOURS
+func TTL() time.Duration {
+	hours, err := strconv.Atoi(utils.GetEnv("PASSWORD_RESET_TTL_HOURS", "24"))
+	if err != nil || hours <= 0 {
+		hours = 24
+	}
+	return time.Duration(hours) * time.Hour
+}
+
+// Force impose une réinitialisation du mot de passe : les sessions sont révoquées et la connexion est refusée
+// jusqu'à la réinitialisation. Les liens précédents sont invalidés. Retourne le nouveau lien (son token en
+// clair), à envoyer avec SendForced une fois la transaction validée.
+func Force(db *gorm.DB, userID, requestedByID uuid.UUID) (models.UserPasswordReset, string, error) {
+	var user models.User
+	if err := db.Where("id = ?", userID).First(&user).Error; err != nil {
+		return models.UserPasswordReset{}, "", err
+	}
+	if user.AnonymizedAt != nil {
+		return models.UserPasswordReset{}, "", fmt.Errorf("account has been deleted")
+	}
+
+	token, err := utils.GenerateToken(32)
+	if err != nil {
+		return models.UserPasswordReset{}, "", fmt.Errorf("error generating token: %v", err)
+	}
+
+	reset := models.UserPasswordReset{
+		CustomerID: userID,
+		TokenHash:  utils.HashToken(token),
+		ExpiresAt:  time.Now().Add(TTL()),
+	}
+	if requestedByID != uuid.Nil {
+		reset.RequestedByID = &requestedByID
+	}
+
+	err = db.Transaction(func(tx *gorm.DB) error {
+		now := time.Now()
+		if err := tx.Model(&models.UserPasswordReset{}).
+			Where("customer_id = ? AND used_at IS NULL AND expires_at > ?", userID, now).
+			Update("expires_at", now).Error; err != nil {
+			return err
+		}
+		if err := tx.Create(&reset).Error; err != nil {
+			return err
+		}
+		return tx.Model(&models.User{}).Where("id = ?", userID).Updates(map[string]interface{}{
+			"password_reset_required": true,
+			"sessions_revoked_at":     now,
+			"session_version":         gorm.Expr("session_version + 1"),
+		}).Error
+	})
+	if err != nil {
+		return models.UserPasswordReset{}, "", err
+	}
+
+	return reset, token, nil
+}
+
+// SendForced envoie par email le lien de réinitialisation créé par Force
+func SendForced(reset models.UserPasswordReset, token string) {
+	userID := reset.CustomerID
+	var user models.User
+	if err := database.DB.Select("email").Where("id = ?", userID).First(&user).Error; err != nil {
+		utils.ConsoleLog("Error sending password reset mail for %s: %v", userID, err).Error()
+		return
+	}
+
+	appURL := strings.TrimRight(utils.GetEnv("APP_URL", "http://localhost:8080"), "/")
+	if err := mail_service.Send(user.Email, "Reset your password", fmt.Sprintf(
+		"An administrator requires you to choose a new password. You have been signed out everywhere.\n\n"+
+			"Reset your password: %s/password/reset?token=%s\n\n"+
+			"This link expires on %s.",
+		appURL, token,
+		reset.ExpiresAt.Format(time.RFC1123),
+	)); err != nil {
+		utils.ConsoleLog("Error sending password reset mail for %s: %v", userID, err).Error()
+	}
+}
+
+// Complete enregistre le nouveau mot de passe si le token est valide, puis lève l'obligation de
+// réinitialisation. Les sessions ouvertes entre-temps sont révoquées.
+func Complete(token, password string) (uuid.UUID, error) {
+	if password == "" {
+		return uuid.Nil, fmt.Errorf("password is required")
+	}
+
+	var reset models.UserPasswordReset
+	result := database.DB.Where("token_hash = ?", utils.HashToken(token)).First(&reset)
+	if errors.Is(result.Error, gorm.ErrRecordNotFound) {
+		return uuid.Nil, ErrInvalidToken
+	}
+	if result.Error != nil {
+		return uuid.Nil, result.Error
+	}
+	if reset.UsedAt != nil || time.Now().After(reset.ExpiresAt) {
+		return uuid.Nil, ErrInvalidToken
+	}
+
+	// Hash du mot de passe
+	hashedPassword, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
+	if err != nil {
+		return uuid.Nil, fmt.Errorf("error hashing password: %v", err)
+	}
+
+	err = database.DB.Transaction(func(tx *gorm.DB) error {
+		now := time.Now()
+		// Le token ne sert qu'une fois, même en cas de requêtes simultanées
+		claimed := tx.Model(&models.UserPasswordReset{}).
+			Where("id = ? AND used_at IS NULL", reset.ID).
+			Update("used_at", now)
+		if claimed.Error != nil {
+			return claimed.Error
+		}
+		if claimed.RowsAffected == 0 {
+			return ErrInvalidToken
+		}
+
+		return tx.Model(&models.User{}).Where("id = ?", reset.CustomerID).Updates(map[string]interface{}{
+			"password":                string(hashedPassword),
+			"password_reset_required": false,
+			"sessions_revoked_at":     now,
+			"session_version":         gorm.Expr("session_version + 1"),
+		}).Error
+	})
+	if err != nil {
+		return uuid.Nil, err
+	}
+
+	return reset.CustomerID, nil
+}
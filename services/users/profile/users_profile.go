@@ -1,18 +1,149 @@
 package user_profile_service
 
 import (
+	"encoding/json"
+	"errors"
+	"fmt"
 	"gox/database"
 	"gox/database/models"
+	user_avatar_service "gox/services/users/avatar"
+	"gox/utils"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
 
 	"github.com/google/uuid"
+	"gorm.io/gorm"
 )
 
+var (
+	ErrInvalidUsername  = errors.New("username must be 3 to 30 characters long, using only lowercase letters, digits, '_' and '-', and start and end with a letter or digit")
+	ErrReservedUsername = errors.New("username is reserved")
+	ErrUsernameTaken    = errors.New("username already used")
+	ErrUsernameCooldown = errors.New("username was changed too recently")
+)
+
+var usernamePattern = regexp.MustCompile(`^[a-z0-9](?:[a-z0-9_-]{1,28}[a-z0-9])$`)
+
+// Noms réservés : routes, rôles et termes pouvant prêter à confusion
+var reservedUsernames = map[string]bool{
+	"admin": true, "administrator": true, "root": true, "system": true, "support": true,
+	"help": true, "api": true, "auth": true, "login": true, "logout": true, "register": true,
+	"signup": true, "me": true, "self": true, "user": true, "users": true, "profile": true,
+	"profiles": true, "team": true, "teams": true, "settings": true, "billing": true,
+	"security": true, "staff": true, "moderator": true, "owner": true, "null": true,
+	"undefined": true, "anonymous": true, "gox": true, "scim": true, "invitations": true,
+	"no-reply": true, "noreply": true, "postmaster": true, "webmaster": true,
+}
+
+// Niveau de la personne qui consulte un profil public
+type Viewer int
+
+const (
+	ViewerAnonymous     Viewer = iota // requête sans authentification
+	ViewerAuthenticated               // utilisateur connecté
+	ViewerSelf                        // le propriétaire du profil (ou un admin)
+)
+
+// NormalizeUsername : les usernames sont insensibles à la casse, ils sont stockés en minuscules
+func NormalizeUsername(username string) string {
+	return strings.ToLower(strings.TrimSpace(username))
+}
+
+func ValidateUsername(username string) error {
+	username = NormalizeUsername(username)
+	if !usernamePattern.MatchString(username) {
+		return ErrInvalidUsername
+	}
+	if reservedUsernames[username] {
+		return ErrReservedUsername
+	}
+	return nil
+}
+
+// Délai minimum entre deux changements de username, configurable via USERNAME_CHANGE_COOLDOWN_DAYS
+func ChangeCooldown() time.Duration {
+	days, err := strconv.Atoi(utils.GetEnv("USERNAME_CHANGE_COOLDOWN_DAYS", "30"))
+	if err != nil || days < 0 {
+		days = 30
+	}
+	return time.Duration(days) * 24 * time.Hour
+}
+
+// Durée pendant laquelle un ancien username redirige vers le nouveau et reste réservé à son ancien
+// propriétaire, configurable via USERNAME_REDIRECT_DAYS
+func RedirectPeriod() time.Duration {
+	days, err := strconv.Atoi(utils.GetEnv("USERNAME_REDIRECT_DAYS", "90"))
+	if err != nil || days < 0 {
+		days = 90
+	}
+	return time.Duration(days) * 24 * time.Hour
+}
+
+// IsAvailable vérifie qu'un username n'est ni utilisé, ni retenu par la redirection d'un autre utilisateur
+func IsAvailable(tx *gorm.DB, username string, userID uuid.UUID) (bool, error) {
+	username = NormalizeUsername(username)
+
+	var count int64
+	if err := tx.Model(&models.UserProfile{}).
+		Where("LOWER(username) = ? AND customer_id <> ?", username, userID).
+		Count(&count).Error; err != nil {
+		return false, err
+	}
+	if count > 0 {
+		return false, nil
+	}
+
+	if err := tx.Model(&models.UsernameHistory{}).
+		Where("LOWER(username) = ? AND customer_id <> ? AND changed_at > ?", username, userID, time.Now().Add(-RedirectPeriod())).
+		Count(&count).Error; err != nil {
+		return false, err
+	}
+
+	return count == 0, nil
+}
+
+// DefaultUsername génère un username libre à partir de l'ID de l'utilisateur
+func DefaultUsername(userID uuid.UUID) (string, error) {
+	hex := strings.ReplaceAll(userID.String(), "-", "")
+	for length := 8; length <= len(hex); length += 4 {
+		candidate := "user-" + hex[:length]
+		available, err := IsAvailable(database.DB, candidate, userID)
+		if err != nil {
+			return "", err
+		}
+		if available {
+			return candidate, nil
+		}
+	}
+	return "", ErrUsernameTaken
+}
+
 func Create(userID uuid.UUID, profile models.UserProfile) error {
-	// Création de l'utilisateur
+	// Validation du username
+	username := NormalizeUsername(profile.Username)
+	if err := ValidateUsername(username); err != nil {
+		return err
+	}
+	available, err := IsAvailable(database.DB, username, userID)
+	if err != nil {
+		return fmt.Errorf("error checking username: %v", err)
+	}
+	if !available {
+		return ErrUsernameTaken
+	}
+	if err := validateVisibility(profile.Visibility); err != nil {
+		return err
+	}
+
+	// Création du profil
 	result := database.DB.Create(&models.UserProfile{
-		CustomerID: userID,
-		Username:   profile.Username,
-		// AvatarURL:          profile.AvatarURL,
+		CustomerID:         userID,
+		Username:           username,
+		DisplayName:        profile.DisplayName,
+		Bio:                profile.Bio,
+		Visibility:         profile.Visibility,
 		PublicStatsDisplay: profile.PublicStatsDisplay,
 	})
 
@@ -25,35 +156,148 @@ func Create(userID uuid.UUID, profile models.UserProfile) error {
 }
 
 func Get(userID uuid.UUID) (models.UserProfile, error) {
-	// Récupération de l'utilisateur
-	var user models.UserProfile
-	result := database.DB.Where("user_id = ?", userID).First(&user)
+	// Récupération du profil
+	var profile models.UserProfile
+	result := database.DB.Where("customer_id = ?", userID).First(&profile)
 
 	// Vérification des erreurs GORM
 	if result.Error != nil {
 		return models.UserProfile{}, result.Error
 	}
 
-	return user, nil
+	return profile, nil
+}
+
+// GetByUsername retrouve un profil par son username actuel. Si le username est un ancien handle encore
+// dans sa période de redirection, le profil est retourné avec redirect à true.
+func GetByUsername(username string) (models.UserProfile, bool, error) {
+	username = NormalizeUsername(username)
+
+	// Récupération du profil
+	var profile models.UserProfile
+	err := database.DB.Preload("Customer").Where("LOWER(username) = ?", username).First(&profile).Error
+	if err == nil {
+		return profile, false, nil
+	}
+	if !errors.Is(err, gorm.ErrRecordNotFound) {
+		return models.UserProfile{}, false, err
+	}
+
+	// Ancien handle : redirection vers le profil actuel
+	var history models.UsernameHistory
+	err = database.DB.
+		Where("LOWER(username) = ? AND changed_at > ?", username, time.Now().Add(-RedirectPeriod())).
+		Order("changed_at DESC").
+		First(&history).Error
+	if err != nil {
+		return models.UserProfile{}, false, err
+	}
+
+	if err := database.DB.Preload("Customer").Where("customer_id = ?", history.CustomerID).First(&profile).Error; err != nil {
+		return models.UserProfile{}, false, err
+	}
+
+	return profile, true, nil
 }
 
-func Update(userID uuid.UUID, profile models.UserProfile) error {
-	// Mise à jour de l'utilisateur
+// Update met à jour les champs du profil, hors username (voir ChangeUsername)
+func Update(userID uuid.UUID, updates map[string]interface{}) error {
+	delete(updates, "username")
+	if visibility, ok := updates["visibility"].(map[string]models.ProfileVisibility); ok {
+		if err := validateVisibility(visibility); err != nil {
+			return err
+		}
+		// Le serializer GORM ne s'applique pas aux mises à jour par map
+		encoded, err := json.Marshal(visibility)
+		if err != nil {
+			return err
+		}
+		updates["visibility"] = string(encoded)
+	}
+	if len(updates) == 0 {
+		return nil
+	}
+
+	// Mise à jour du profil
 	result := database.DB.Model(&models.UserProfile{}).
-		Where("user_id = ?", userID).
-		Updates(profile)
+		Where("customer_id = ?", userID).
+		Updates(updates)
 
 	// Vérification des erreurs GORM
 	if result.Error != nil {
 		return result.Error
 	}
+	if result.RowsAffected == 0 {
+		return gorm.ErrRecordNotFound
+	}
 
 	return nil
 }
 
+// ChangeUsername applique le délai entre deux changements et garde l'ancien username dans l'historique
+func ChangeUsername(userID uuid.UUID, username string) error {
+	username = NormalizeUsername(username)
+	if err := ValidateUsername(username); err != nil {
+		return err
+	}
+
+	return database.DB.Transaction(func(tx *gorm.DB) error {
+		var profile models.UserProfile
+		if err := tx.Where("customer_id = ?", userID).First(&profile).Error; err != nil {
+			return err
+		}
+		if profile.Username == username {
+			return nil
+		}
+
+		// Délai entre deux changements
+		if profile.UsernameChangedAt != nil && time.Since(*profile.UsernameChangedAt) < ChangeCooldown() {
+			return ErrUsernameCooldown
+		}
+
+		available, err := IsAvailable(tx, username, userID)
+		if err != nil {
+			return fmt.Errorf("error checking username: %v", err)
+		}
+		if !available {
+			return ErrUsernameTaken
+		}
+
+		// Historique de l'ancien username, pour les redirections
+		if err := tx.Create(&models.UsernameHistory{
+			CustomerID: userID,
+			Username:   profile.Username,
+		}).Error; err != nil {
+			return err
+		}
+
+		// Reprendre un de ses anciens usernames le retire de l'historique
+		if err := tx.Where("customer_id = ? AND LOWER(username) = ?", userID, username).Delete(&models.UsernameHistory{}).Error; err != nil {
+			return err
+		}
+
+		now := time.Now()
+		return tx.Model(&profile).Updates(map[string]interface{}{
+			"username":            username,
+			"username_changed_at": now,
+		}).Error
+	})
+}
+
+func GetUsernameHistory(userID uuid.UUID) ([]models.UsernameHistory, error) {
+	var history []models.UsernameHistory
+	err := database.DB.Where("customer_id = ?", userID).Order("changed_at DESC").Find(&history).Error
+	return history, err
+}
+
 func Delete(userID uuid.UUID) error {
-	// Suppression de l'utilisateur
-	result := database.DB.Where("user_id = ?", userID).Delete(&models.UserProfile{})
+	// Suppression de l'avatar et de ses fichiers
+	if err := user_avatar_service.Remove(userID); err != nil && !errors.Is(err, user_avatar_service.ErrNoAvatar) && !errors.Is(err, gorm.ErrRecordNotFound) {
+		return err
+	}
+
+	// Suppression du profil
+	result := database.DB.Where("customer_id = ?", userID).Delete(&models.UserProfile{})
 
 	// Vérification des erreurs GORM
 	if result.Error != nil {
@@ -62,3 +306,71 @@ func Delete(userID uuid.UUID) error {
 
 	return nil
 }
+
+// PublicView ne garde que les champs visibles par la personne qui consulte le profil.
+// Le profil doit avoir été chargé avec son Customer.
+func PublicView(profile models.UserProfile, viewer Viewer) map[string]interface{} {
+	data := map[string]interface{}{
+		"username":   profile.Username,
+		"avatar_url": user_avatar_service.URL(profile.AvatarURL, user_avatar_service.Sizes[0]),
+		"avatars":    user_avatar_service.URLs(profile.AvatarURL),
+	}
+
+	fields := map[string]interface{}{
+		"display_name": profile.DisplayName,
+		"bio":          profile.Bio,
+		"email":        profile.Customer.Email,
+		"member_since": profile.Customer.CreatedOn,
+	}
+	for _, field := range models.ProfileFields {
+		if canView(profile.FieldVisibility(field), viewer) {
+			data[field] = fields[field]
+		}
+	}
+
+	if viewer == ViewerSelf {
+		data["customer_id"] = profile.CustomerID
+		data["visibility"] = visibilitySettings(profile)
+		data["public_stats_display"] = profile.PublicStatsDisplay
+	}
+
+	return data
+}
+
+func canView(visibility models.ProfileVisibility, viewer Viewer) bool {
+	switch visibility {
+	case models.ProfileVisibilityPublic:
+		return true
+	case models.ProfileVisibilityAuthenticated:
+		return viewer >= ViewerAuthenticated
+	default:
+		return viewer == ViewerSelf
+	}
+}
+
+func visibilitySettings(profile models.UserProfile) map[string]models.ProfileVisibility {
+	settings := make(map[string]models.ProfileVisibility, len(models.ProfileFields))
+	for _, field := range models.ProfileFields {
+		settings[field] = profile.FieldVisibility(field)
+	}
+	return settings
+}
+
+func validateVisibility(visibility map[string]models.ProfileVisibility) error {
+	for field, value := range visibility {
+		known := false
+		for _, f := range models.ProfileFields {
+			if f == field {
+				known = true
+				break
+			}
+		}
+		if !known {
+			return fmt.Errorf("unknown profile field: %s", field)
+		}
+		if !value.IsValid() {
+			return fmt.Errorf("invalid visibility for %s: %s", field, value)
+		}
+	}
+	return nil
+}
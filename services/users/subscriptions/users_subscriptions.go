@@ -4,11 +4,22 @@ import (
 	"errors"
 	"gox/database"
 	"gox/database/models"
+	team_service "gox/services/teams"
+	team_activity_service "gox/services/teams/activity"
 	"time"
 
 	"github.com/google/uuid"
 )
 
+// ~ Subscriptions belong to the user: their events are recorded in the user's personal team feed
+func recordActivity(userID, actorID uuid.UUID, action string, subscriptionID uuid.UUID, changes team_activity_service.Changes) {
+	team, err := team_service.GetPersonalTeamByMemberID(userID)
+	if err != nil {
+		return
+	}
+	team_activity_service.Record(database.DB, team.ID, actorID, action, team_activity_service.TargetSubscription, subscriptionID.String(), changes)
+}
+
 func GetAll(userID uuid.UUID) ([]models.UserSubscription, error) {
 	var subscriptions []models.UserSubscription
 	if err := database.DB.Preload("Subscription").Preload("SubscriptionPerks").Where("customer_id = ? AND is_accessible = ?", userID, true).Find(&subscriptions).Error; err != nil {
@@ -93,6 +104,11 @@ func Create(userID uuid.UUID, subscriptionID uuid.UUID, autoRenew bool) (*models
 		return nil, err
 	}
 
+	recordActivity(userID, userID, "subscription.created", userSubscription.ID, team_activity_service.Changes{
+		"subscription_id": team_activity_service.Change(nil, subscriptionID),
+		"auto_renew":      team_activity_service.Change(nil, autoRenew),
+	})
+
 	return &userSubscription, nil
 }
 
@@ -110,10 +126,15 @@ func Update(userID uuid.UUID, subscriptionID uuid.UUID, autoRenew bool) error {
 	}
 
 	// ~ Update the subscription
+	previous := subscription.AutoRenew
 	if err := database.DB.Model(&subscription).Update("auto_renew", autoRenew).Error; err != nil {
 		return err
 	}
 
+	recordActivity(userID, userID, "subscription.updated", subscription.ID, team_activity_service.Changes{
+		"auto_renew": team_activity_service.Change(previous, autoRenew),
+	})
+
 	return nil
 }
 
@@ -159,6 +180,10 @@ func Cancel(userID uuid.UUID, subscriptionID uuid.UUID) error {
 		return err
 	}
 
+	recordActivity(userID, uuid.Nil, "subscription.cancelled", subscription.ID, team_activity_service.Changes{
+		"is_accessible": team_activity_service.Change(true, false),
+	})
+
 	return nil
 }
 
@@ -5,11 +5,14 @@ import (
 	"gox/database"
 	"gox/database/models"
 	team_service "gox/services/teams"
-	team_member_service "gox/services/teams/members"
-	"strings"
+	user_email_verification_service "gox/services/users/emailverification"
+	user_profile_service "gox/services/users/profile"
+	"gox/utils"
+	"time"
 
 	"github.com/google/uuid"
 	"golang.org/x/crypto/bcrypt"
+	"gorm.io/gorm"
 )
 
 func Create(email, password string) (uuid.UUID, error) {
@@ -43,25 +46,27 @@ func Create(email, password string) (uuid.UUID, error) {
 		return uuid.UUID{}, fmt.Errorf("error creating user: %v", err)
 	}
 
-	// Création d'un profil par défaut pour l'utilisateur
+	// Création d'un profil par défaut pour l'utilisateur, avec un username libre
+	username, err := user_profile_service.DefaultUsername(user.ID)
+	if err != nil {
+		return uuid.UUID{}, fmt.Errorf("error generating username: %v", err)
+	}
 	profile := models.UserProfile{
 		CustomerID: user.ID,
-		Username:   strings.Split(user.ID.String(), "-")[0],
+		Username:   username,
 	}
 	if err := database.DB.Create(&profile).Error; err != nil {
 		return uuid.UUID{}, fmt.Errorf("error creating user profile: %v", err)
 	}
 
-	// Création d'une équipe par défaut pour l'utilisateur
-	teamID, err := team_service.Create("Personal", models.TeamTypePersonal)
-	if err != nil {
+	// Création d'une équipe par défaut pour l'utilisateur, qui en est owner
+	if _, err := team_service.CreateWithOwner("Personal", models.TeamTypePersonal, user.ID); err != nil {
 		return uuid.UUID{}, fmt.Errorf("error creating default team: %v", err)
 	}
 
-	// Création d'un team member pour l'utilisateur dans l'équipe par défaut
-	err = team_member_service.Add(teamID, user.ID, models.TeamMemberRoleOwner)
-	if err != nil {
-		return uuid.UUID{}, fmt.Errorf("error creating default team member: %v", err)
+	// Vérification de l'email : l'adhésion aux Teams ayant vérifié son domaine n'a lieu qu'ensuite
+	if err := user_email_verification_service.Send(user.ID, user.Email); err != nil {
+		utils.ConsoleLog("⚠️ Error sending email verification for %s: %v", user.ID, err)
 	}
 
 	// retourner l'utilisateur créé
@@ -109,16 +114,23 @@ func UpdateEmail(userID uuid.UUID, email string) error {
 		return fmt.Errorf("email already used")
 	}
 
-	// Mise à jour de l'utilisateur
+	// Mise à jour de l'utilisateur : la nouvelle adresse doit à son tour être vérifiée
 	result := database.DB.Model(&models.User{}).
 		Where("id = ?", userID).
-		Update("email", email)
+		Updates(map[string]interface{}{
+			"email":             email,
+			"email_verified_at": nil,
+		})
 
 	// Vérification des erreurs GORM
 	if result.Error != nil {
 		return result.Error
 	}
 
+	if err := user_email_verification_service.Send(userID, email); err != nil {
+		utils.ConsoleLog("⚠️ Error sending email verification for %s: %v", userID, err)
+	}
+
 	return nil
 }
 
@@ -147,14 +159,24 @@ func UpdatePassword(userID uuid.UUID, password string) error {
 	return nil
 }
 
-func Delete(userID uuid.UUID) error {
-	// Suppression de l'utilisateur
-	result := database.DB.Where("id = ?", userID).Delete(&models.User{})
+// RevokeSessions invalide tous les tokens déjà émis pour l'utilisateur : la version des sessions est
+// incrémentée, un token émis ensuite porte la nouvelle version
+func RevokeSessions(db *gorm.DB, userID uuid.UUID) error {
+	// Mise à jour de l'utilisateur
+	result := db.Model(&models.User{}).
+		Where("id = ?", userID).
+		Updates(map[string]interface{}{
+			"sessions_revoked_at": time.Now(),
+			"session_version":     gorm.Expr("session_version + 1"),
+		})
 
 	// Vérification des erreurs GORM
 	if result.Error != nil {
 		return result.Error
 	}
+	if result.RowsAffected == 0 {
+		return fmt.Errorf("user not found")
+	}
 
 	return nil
 }
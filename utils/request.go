@@ -0,0 +1,113 @@
+package utils
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"net/http"
+	"regexp"
+	"strings"
+	"sync"
+
+	"github.com/google/uuid"
+)
+
+type requestIDKey struct{}
+
+// Un X-Request-ID fourni par le client (ou un proxy) n'est repris que s'il a une forme raisonnable
+var requestIDPattern = regexp.MustCompile(`^[A-Za-z0-9._:-]{1,128}$`)
+
+// EnsureRequestID reprend l'en-tête X-Request-ID s'il est valide, en génère un sinon,
+// et l'attache au contexte de la requête
+func EnsureRequestID(r *http.Request) (*http.Request, string) {
+	id := r.Header.Get("X-Request-ID")
+	if !requestIDPattern.MatchString(id) {
+		id = uuid.New().String()
+	}
+	return r.WithContext(context.WithValue(r.Context(), requestIDKey{}, id)), id
+}
+
+// RequestID retourne l'identifiant de corrélation de la requête ("" hors requête)
+func RequestID(r *http.Request) string {
+	id, _ := r.Context().Value(requestIDKey{}).(string)
+	return id
+}
+
+// ConsoleLogRequest préfixe le message par l'identifiant de la requête
+func ConsoleLogRequest(r *http.Request, msg string, args ...any) Logger {
+	if id := RequestID(r); id != "" {
+		return ConsoleLog("[%s] %s", id, fmt.Sprintf(msg, args...))
+	}
+	return ConsoleLog(msg, args...)
+}
+
+var (
+	trustedProxies     []*net.IPNet
+	trustedProxiesOnce sync.Once
+)
+
+// TRUSTED_PROXIES : adresses ou plages CIDR des reverse proxies, séparées par des virgules
+func loadTrustedProxies() {
+	for _, value := range strings.Split(GetEnv("TRUSTED_PROXIES", ""), ",") {
+		value = strings.TrimSpace(value)
+		if value == "" {
+			continue
+		}
+		if !strings.Contains(value, "/") {
+			if ip := net.ParseIP(value); ip != nil && ip.To4() != nil {
+				value += "/32"
+			} else {
+				value += "/128"
+			}
+		}
+		_, network, err := net.ParseCIDR(value)
+		if err != nil {
+			ConsoleLog("⚠️ Invalid TRUSTED_PROXIES entry %q ignored", value)
+			continue
+		}
+		trustedProxies = append(trustedProxies, network)
+	}
+}
+
+func isTrustedProxy(ip net.IP) bool {
+	trustedProxiesOnce.Do(loadTrustedProxies)
+	for _, network := range trustedProxies {
+		if network.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
+
+// ClientIP retourne l'adresse du client. X-Forwarded-For et X-Real-IP ne sont pris en compte que si la
+// connexion vient d'un proxy de confiance : la liste est lue de droite à gauche, en ignorant les proxies
+// de confiance, car seules les entrées ajoutées par ceux-ci sont fiables.
+func ClientIP(r *http.Request) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		host = r.RemoteAddr
+	}
+	remote := net.ParseIP(host)
+	if remote == nil || !isTrustedProxy(remote) {
+		return host
+	}
+
+	if forwarded := r.Header.Values("X-Forwarded-For"); len(forwarded) > 0 {
+		hops := strings.Split(strings.Join(forwarded, ","), ",")
+		for i := len(hops) - 1; i >= 0; i-- {
+			ip := net.ParseIP(strings.TrimSpace(hops[i]))
+			if ip == nil {
+				break
+			}
+			if !isTrustedProxy(ip) || i == 0 {
+				return ip.String()
+			}
+		}
+	}
+
+	if ip := net.ParseIP(strings.TrimSpace(r.Header.Get("X-Real-IP"))); ip != nil {
+		return ip.String()
+	}
+
+	return host
+}
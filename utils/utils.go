@@ -1,7 +1,10 @@
 package utils
 
 import (
+	"crypto/rand"
+	"crypto/sha256"
 	"encoding/base64"
+	"encoding/hex"
 	"encoding/json"
 	"fmt"
 	"net/http"
@@ -45,10 +48,12 @@ func (l Logger) Error() {
 
 const defaultJWTSecret = "unsecure-hard-coded-secret"
 
-func GenerateJWT(userID uuid.UUID, isAdmin bool) (string, error) {
+// GenerateJWT émet un token pour la version courante des sessions de l'utilisateur (User.SessionVersion)
+func GenerateJWT(userID uuid.UUID, isAdmin bool, sessionVersion int) (string, error) {
 	claims := jwt.MapClaims{
 		"user":  userID.String(),
 		"admin": isAdmin,
+		"sv":    sessionVersion,
 		"iat":   time.Now().Unix(),
 		"exp":   time.Now().Add(time.Hour * 24).Unix(), // Expire en 24h
 	}
@@ -70,6 +75,21 @@ func EncodeBase64(data []byte) string {
 	return str
 }
 
+// GenerateToken retourne un token aléatoire (hex) de n octets
+func GenerateToken(n int) (string, error) {
+	buf := make([]byte, n)
+	if _, err := rand.Read(buf); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(buf), nil
+}
+
+// HashToken retourne l'empreinte SHA-256 d'un token, pour ne jamais le stocker en clair
+func HashToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
 func ExtractUserIDFromJWT(r *http.Request) (uuid.UUID, error) {
 	tokenString := r.Header.Get("Authorization")
 